@@ -1,13 +1,17 @@
 package app
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strings"
 
+	"github.com/docker/go-units"
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/kapella-hub/NexusClaw/internal/nodes"
@@ -20,7 +24,8 @@ import (
 )
 
 // New creates the HTTP handler with all routes and middleware wired up.
-func New(cfg *config.Config, logger *slog.Logger, pool *pgxpool.Pool) http.Handler {
+// Background workers run until ctx is done.
+func New(ctx context.Context, cfg *config.Config, logger *slog.Logger, pool *pgxpool.Pool) http.Handler {
 	r := chi.NewRouter()
 
 	// Global middleware
@@ -60,29 +65,142 @@ func New(cfg *config.Config, logger *slog.Logger, pool *pgxpool.Pool) http.Handl
 	passSvc := pass.NewService(passRepo, userRepo, passRelay, tokenSecret, cfg.Auth.TokenExpiry, vaultKey)
 	passHandler := &pass.Handler{Service: passSvc, AuthMW: authMW}
 
+	// -- Sentry module --
+	sentryRepo := sentry.NewPgRepository(pool)
+	sentrySvc := sentry.NewService(sentryRepo)
+	sentryEvents := sentry.NewBroker()
+	sentryNotifiers := sentry.Notifiers{sentryEvents}
+	if cfg.Sentry.WebhookURL != "" {
+		sentryNotifiers = append(sentryNotifiers, sentry.NewWebhookNotifier(cfg.Sentry.WebhookURL))
+	}
+	approvers := userIDs(cfg.Sentry.Approvers, "sentry approver", logger)
+	sentryAudit := sentry.NewAuditLogger(sentryRepo)
+
 	// -- Nodes module --
 	nodesRepo := nodes.NewPgRepository(pool)
+	sentryApprovals := sentry.NewApprovalQueue(sentryRepo, sentryNotifiers, cfg.Sentry.ApprovalTimeout, approvers, &nodes.ServerOwners{Repo: nodesRepo})
+	var nodesEgress *nodes.EgressProxy
+	if cfg.Egress.Enabled {
+		if cfg.Docker.Network == "" {
+			logger.Warn("egress proxy requires a docker network, egress disabled")
+		} else if proxy, err := nodes.NewEgressProxy(nodesRepo, sentryAudit, tokenSecret, cfg.Egress.ProxyURL); err != nil {
+			logger.Warn("egress proxy disabled", "error", err)
+		} else {
+			nodesEgress = proxy
+		}
+	}
 	var containerMgr nodes.ContainerManager
-	if cm, err := nodes.NewContainerManager(); err != nil {
+	if cm, err := nodes.NewContainerManager(nodes.ContainerOptions{
+		Network:         cfg.Docker.Network,
+		Host:            cfg.Docker.Host,
+		InstanceID:      cfg.Docker.InstanceID,
+		SeccompDir:      cfg.Docker.SeccompDir,
+		SecretsDir:      cfg.Docker.SecretsDir,
+		AllowUnconfined: cfg.Docker.AllowUnconfinedSeccomp,
+		AllowRoot:       cfg.Docker.AllowRoot,
+		Internal:        nodesEgress != nil,
+	}); err != nil {
 		logger.Warn("docker container manager unavailable, server lifecycle disabled", "error", err)
 	} else {
 		containerMgr = cm
 	}
-	nodesSvc := nodes.NewService(nodesRepo, containerMgr)
+	var nodesPorts *nodes.PortAllocator
+	if containerMgr != nil && cfg.Docker.Network == "" {
+		ports, err := nodes.NewPortAllocator(nodesRepo, cfg.Docker.PortMin, cfg.Docker.PortMax)
+		if err != nil {
+			logger.Warn("host port allocation disabled", "error", err)
+		} else {
+			nodesPorts = ports
+		}
+	}
+	nodesLimits := limitTiers(cfg.Limits, logger)
+	nodesSvc := nodes.NewService(nodesRepo, containerMgr, nodesPorts, nodesEgress, nodesLimits, passSvc, nodes.NewOAuthTokens(nodesRepo, vaultKey))
 	nodesRegistry := nodes.NewRegistry(nodesRepo)
 	nodesLimiter := nodes.NewRateLimiter(5, 10)
-	nodesHandler := &nodes.Handler{Service: nodesSvc, Registry: nodesRegistry, AuthMW: authMW, RateLimiter: nodesLimiter}
 
-	// -- Sentry module --
-	sentryRepo := sentry.NewPgRepository(pool)
-	sentrySvc := sentry.NewService(sentryRepo)
-	sentryHandler := &sentry.Handler{Service: sentrySvc, AuthMW: authMW}
+	// -- Server lifecycle --
+	// Bring server records in line with Docker before releasing the ports of
+	// servers left without a container and supervising the rest.
+	if containerMgr != nil {
+		if _, err := nodes.Reconcile(ctx, nodesRepo, containerMgr, sentryAudit); err != nil {
+			logger.Warn("reconciling servers with containers", "error", err)
+		}
+	}
+	if nodesPorts != nil {
+		if err := nodesPorts.Reconcile(ctx); err != nil {
+			logger.Warn("reconciling host port allocations", "error", err)
+		}
+	}
+	if containerMgr != nil && cfg.Supervisor.Enabled {
+		supervisor := nodes.NewSupervisor(nodesRepo, containerMgr, nodesSvc, nodes.SupervisorOptions{
+			HealthInterval: cfg.Supervisor.HealthInterval,
+			HealthTimeout:  cfg.Supervisor.HealthTimeout,
+			MaxFailures:    cfg.Supervisor.MaxFailures,
+			BackoffMin:     cfg.Supervisor.BackoffMin,
+			BackoffMax:     cfg.Supervisor.BackoffMax,
+		})
+		go supervisor.Run(ctx)
+	}
+	nodesSessions := nodes.NewSessionRegistry()
+	var nodesScaler *nodes.Scaler
+	if containerMgr != nil {
+		nodesScaler = nodes.NewScaler(nodesRepo, nodesSvc, nodesSessions, nodes.ScalerOptions{
+			Interval:     cfg.Scale.Interval,
+			ReadyTimeout: cfg.Scale.ReadyTimeout,
+		})
+		go nodesScaler.Run(ctx)
+	}
+	var sentryQuarantine *sentry.QuarantinePolicy
+	alertNotifiers := sentry.Notifiers{sentryNotifiers}
+	if cfg.Sentry.Quarantine.Enabled {
+		sentryQuarantine = sentry.NewQuarantinePolicy(sentry.QuarantineConfig{
+			Threshold:   cfg.Sentry.Quarantine.Threshold,
+			Window:      cfg.Sentry.Quarantine.Window,
+			MinSeverity: cfg.Sentry.Quarantine.MinSeverity,
+		}, &nodes.Quarantiner{
+			Service:       nodesSvc,
+			Repo:          nodesRepo,
+			Sessions:      nodesSessions,
+			StopContainer: cfg.Sentry.Quarantine.StopContainer,
+		}, sentryAudit, sentryNotifiers, approvers)
+		if err := sentryQuarantine.Load(ctx); err != nil {
+			logger.Warn("loading quarantined servers", "error", err)
+		}
+		alertNotifiers = append(alertNotifiers, sentryQuarantine)
+	}
+	sentryAlerter := sentry.NewAlerter(sentryRepo, alertNotifiers)
+	if nodesEgress != nil {
+		// The alerter needs the nodes service for quarantines, which in turn
+		// needs the proxy, so it is attached before the proxy starts serving.
+		nodesEgress.Alerter = sentryAlerter
+		go func() {
+			if err := nodes.ServeEgress(ctx, cfg.Egress.Listen, nodesEgress); err != nil {
+				logger.Error("egress proxy stopped", "error", err)
+			}
+		}()
+	}
+	var sentryAnomalies sentry.AnomalyDetector
+	if cfg.Sentry.Anomaly.Enabled {
+		sentryAnomalies = sentry.NewAnomalyDetector(sentry.AnomalyConfig{
+			ZThreshold: cfg.Sentry.Anomaly.ZThreshold,
+			MinSamples: cfg.Sentry.Anomaly.MinSamples,
+			Alpha:      cfg.Sentry.Anomaly.Alpha,
+		}, sentryAlerter)
+	}
+	sentryEnforcer := sentry.NewEnforcer(sentry.NewRuleEngine(sentryRepo), sentryApprovals, sentryAudit, sentryAlerter, sentryAnomalies)
+	sentryHandler := &sentry.Handler{Service: sentrySvc, Approvals: sentryApprovals, Quarantine: sentryQuarantine, Events: sentryEvents, AuthMW: authMW}
+
+	nodesHandler := &nodes.Handler{Service: nodesSvc, Registry: nodesRegistry, AuthMW: authMW, RateLimiter: nodesLimiter, Enforcer: sentryEnforcer, Audit: sentryAudit, Sessions: nodesSessions, Schemas: nodes.NewSchemaCache(), HTTPSessions: nodes.NewHTTPSessions(), Pool: nodes.NewBackendPool(nodesSvc.OpenBackend), Scaler: nodesScaler, Admins: userIDs(cfg.Auth.Admins, "admin", logger)}
 
 	// -- OAuth handler (optional, from config) --
 	var oauthHandler *nodes.OAuthHandler
 	if len(cfg.OAuth) > 0 {
 		providers := make(map[string]*oauth2.Config, len(cfg.OAuth))
+		revocationURLs := make(map[string]string)
 		for name, p := range cfg.OAuth {
+			if p.RevocationURL != "" {
+				revocationURLs[name] = p.RevocationURL
+			}
 			providers[name] = &oauth2.Config{
 				ClientID:     p.ClientID,
 				ClientSecret: p.ClientSecret,
@@ -95,11 +213,17 @@ func New(cfg *config.Config, logger *slog.Logger, pool *pgxpool.Pool) http.Handl
 			}
 		}
 		oauthHandler = &nodes.OAuthHandler{
-			Providers: providers,
-			Repo:      nodesRepo,
-			VaultKey:  vaultKey,
-			AuthMW:    authMW,
+			Providers:      providers,
+			RevocationURLs: revocationURLs,
+			Repo:           nodesRepo,
+			VaultKey:       vaultKey,
+			AuthMW:         authMW,
 		}
+		oauthRefresher := nodes.NewOAuthRefresher(nodesRepo, providers, vaultKey, nodesSvc, sentryAlerter, nodes.OAuthRefreshOptions{
+			Interval: cfg.OAuthRefresh.Interval,
+			Window:   cfg.OAuthRefresh.Window,
+		})
+		go oauthRefresher.Run(ctx)
 	}
 
 	r.Route("/api/v1", func(r chi.Router) {
@@ -113,3 +237,59 @@ func New(cfg *config.Config, logger *slog.Logger, pool *pgxpool.Pool) http.Handl
 
 	return r
 }
+
+// userIDs parses configured user IDs, skipping invalid ones.
+func userIDs(list []string, kind string, logger *slog.Logger) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, s := range list {
+		id, err := uuid.Parse(strings.TrimSpace(s))
+		if err != nil {
+			logger.Warn("ignoring invalid "+kind+" id", "id", s, "error", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// limitTiers converts the configured tier maximums, skipping invalid tiers and
+// user assignments. It returns nil if no tiers are configured.
+func limitTiers(cfg config.LimitsConfig, logger *slog.Logger) *nodes.LimitTiers {
+	if len(cfg.Tiers) == 0 {
+		return nil
+	}
+	tiers := &nodes.LimitTiers{
+		Tiers:       make(map[string]nodes.ResourceLimits, len(cfg.Tiers)),
+		Users:       make(map[uuid.UUID]string, len(cfg.Users)),
+		DefaultTier: cfg.DefaultTier,
+	}
+	for name, t := range cfg.Tiers {
+		var memory, disk int64
+		var err error
+		if t.Memory != "" {
+			memory, err = units.RAMInBytes(t.Memory)
+		}
+		if err == nil && t.Disk != "" {
+			disk, err = units.RAMInBytes(t.Disk)
+		}
+		if err != nil {
+			logger.Warn("ignoring limits tier with invalid size", "tier", name, "error", err)
+			continue
+		}
+		tiers.Tiers[name] = nodes.ResourceLimits{
+			Memory: nodes.ByteSize(memory),
+			CPUs:   t.CPUs,
+			Pids:   t.Pids,
+			Disk:   nodes.ByteSize(disk),
+		}
+	}
+	for user, tier := range cfg.Users {
+		id, err := uuid.Parse(user)
+		if err != nil {
+			logger.Warn("ignoring limits tier of invalid user id", "user", user, "error", err)
+			continue
+		}
+		tiers.Users[id] = tier
+	}
+	return tiers
+}
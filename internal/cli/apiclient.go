@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -61,6 +62,33 @@ func (c *apiClient) do(method, path string, body any) ([]byte, int, error) {
 	return data, resp.StatusCode, nil
 }
 
+// stream performs a GET whose response body is read as it arrives, so the
+// request has no timeout and ends when ctx is done. On a non-2xx status the
+// body is read into data for checkError and nil is returned for body.
+func (c *apiClient) stream(ctx context.Context, path string) (body io.ReadCloser, data []byte, status int, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("creating request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := (&http.Client{Transport: c.http.Transport}).Do(req)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("executing request: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, nil, resp.StatusCode, fmt.Errorf("reading response: %w", err)
+		}
+		return nil, data, resp.StatusCode, nil
+	}
+	return resp.Body, nil, resp.StatusCode, nil
+}
+
 func (c *apiClient) get(path string) ([]byte, int, error) {
 	return c.do("GET", path, nil)
 }
@@ -73,6 +101,10 @@ func (c *apiClient) put(path string, body any) ([]byte, int, error) {
 	return c.do("PUT", path, body)
 }
 
+func (c *apiClient) patch(path string, body any) ([]byte, int, error) {
+	return c.do("PATCH", path, body)
+}
+
 func (c *apiClient) delete(path string) ([]byte, int, error) {
 	return c.do("DELETE", path, nil)
 }
@@ -85,10 +117,17 @@ func checkError(data []byte, status int) bool {
 		return false
 	}
 	var errResp struct {
-		Error string `json:"error"`
+		Error  string `json:"error"`
+		Fields []struct {
+			Field   string `json:"field"`
+			Message string `json:"message"`
+		} `json:"fields"`
 	}
 	if json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
 		fmt.Printf("Error: %s\n", errResp.Error)
+		for _, f := range errResp.Fields {
+			fmt.Printf("  %s: %s\n", f.Field, f.Message)
+		}
 	} else {
 		fmt.Printf("Error: unexpected status %d\n", status)
 	}
@@ -3,9 +3,17 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"text/tabwriter"
+	"time"
 
+	"github.com/docker/go-units"
 	"github.com/spf13/cobra"
 )
 
@@ -52,12 +60,50 @@ var nodeRegisterCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name, _ := cmd.Flags().GetString("name")
 		image, _ := cmd.Flags().GetString("image")
+		transport, _ := cmd.Flags().GetString("transport")
+		pooled, _ := cmd.Flags().GetBool("pooled")
+		restart, _ := cmd.Flags().GetString("restart")
+		memory, _ := cmd.Flags().GetString("memory")
+		cpus, _ := cmd.Flags().GetFloat64("cpus")
+		pids, _ := cmd.Flags().GetInt64("pids")
+		disk, _ := cmd.Flags().GetString("disk")
 
-		client := newAPIClient()
-		data, status, err := client.post("/api/v1/nodes", map[string]string{
+		body := map[string]any{
 			"name":  name,
 			"image": image,
-		})
+		}
+		config := map[string]any{}
+		if transport != "" {
+			config["transport"] = transport
+		}
+		if pooled {
+			config["pooled"] = true
+		}
+		if restart != "" {
+			config["restart"] = restart
+		}
+		limits := map[string]any{}
+		if memory != "" {
+			limits["memory"] = memory
+		}
+		if cpus > 0 {
+			limits["cpus"] = cpus
+		}
+		if pids > 0 {
+			limits["pids"] = pids
+		}
+		if disk != "" {
+			limits["disk"] = disk
+		}
+		if len(limits) > 0 {
+			config["limits"] = limits
+		}
+		if len(config) > 0 {
+			body["config"] = config
+		}
+
+		client := newAPIClient()
+		data, status, err := client.post("/api/v1/nodes", body)
 		if err != nil {
 			return err
 		}
@@ -151,12 +197,403 @@ var nodeRemoveCmd = &cobra.Command{
 	},
 }
 
+var nodeUpdateCmd = &cobra.Command{
+	Use:   "update [id]",
+	Short: "Update an MCP server's image or config, rolling out a running server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		image, _ := cmd.Flags().GetString("image")
+		config, _ := cmd.Flags().GetString("config")
+
+		body := map[string]any{}
+		if image != "" {
+			body["image"] = image
+		}
+		if config != "" {
+			body["config"] = json.RawMessage(config)
+		}
+		if len(body) == 0 {
+			return fmt.Errorf("--image or --config is required")
+		}
+
+		client := newAPIClient()
+		data, status, err := client.patch("/api/v1/nodes/"+args[0], body)
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		var server struct {
+			Image    string `json:"image"`
+			Status   string `json:"status"`
+			Revision int    `json:"revision"`
+		}
+		if err := json.Unmarshal(data, &server); err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+
+		fmt.Printf("Revision: %d\nImage:    %s\nStatus:   %s\n", server.Revision, server.Image, server.Status)
+		return nil
+	},
+}
+
+var nodeRevisionsCmd = &cobra.Command{
+	Use:   "revisions [id]",
+	Short: "List the config revisions of an MCP server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newAPIClient()
+		data, status, err := client.get("/api/v1/nodes/" + args[0] + "/revisions")
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		var revisions []struct {
+			Revision   int       `json:"revision"`
+			Image      string    `json:"image"`
+			RollbackOf *int      `json:"rollback_of"`
+			CreatedAt  time.Time `json:"created_at"`
+		}
+		if err := json.Unmarshal(data, &revisions); err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REVISION\tIMAGE\tROLLBACK OF\tCREATED")
+		for _, r := range revisions {
+			rollbackOf := "-"
+			if r.RollbackOf != nil {
+				rollbackOf = fmt.Sprint(*r.RollbackOf)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Revision, r.Image, rollbackOf, r.CreatedAt.Local().Format(time.DateTime))
+		}
+		return w.Flush()
+	},
+}
+
+var nodeRollbackCmd = &cobra.Command{
+	Use:   "rollback [id] [revision]",
+	Short: "Restore an earlier revision of an MCP server",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		revision, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid revision %q", args[1])
+		}
+
+		client := newAPIClient()
+		data, status, err := client.post("/api/v1/nodes/"+args[0]+"/rollback", map[string]int{"revision": revision})
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		var server struct {
+			Revision int `json:"revision"`
+		}
+		if err := json.Unmarshal(data, &server); err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+
+		fmt.Printf("Rolled back to revision %d as revision %d\n", revision, server.Revision)
+		return nil
+	},
+}
+
+var nodeLogsCmd = &cobra.Command{
+	Use:   "logs [id]",
+	Short: "Print the logs of an MCP server's container",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		follow, _ := cmd.Flags().GetBool("follow")
+		since, _ := cmd.Flags().GetString("since")
+		tail, _ := cmd.Flags().GetString("tail")
+
+		query := url.Values{}
+		if follow {
+			query.Set("follow", "true")
+		}
+		if since != "" {
+			query.Set("since", since)
+		}
+		if tail != "" {
+			query.Set("tail", tail)
+		}
+		path := "/api/v1/nodes/" + args[0] + "/logs"
+		if len(query) > 0 {
+			path += "?" + query.Encode()
+		}
+
+		ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		client := newAPIClient()
+		body, data, status, err := client.stream(ctx, path)
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+		defer body.Close()
+
+		if _, err := io.Copy(os.Stdout, body); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("reading logs: %w", err)
+		}
+		return nil
+	},
+}
+
+var nodeStatsCmd = &cobra.Command{
+	Use:   "stats [id]",
+	Short: "Show the resource usage of an MCP server's container",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newAPIClient()
+		data, status, err := client.get("/api/v1/nodes/" + args[0] + "/stats")
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		var stats struct {
+			CPUPercent  float64 `json:"cpu_percent"`
+			MemoryUsage uint64  `json:"memory_usage"`
+			MemoryLimit uint64  `json:"memory_limit"`
+			Pids        uint64  `json:"pids"`
+			PidsLimit   uint64  `json:"pids_limit"`
+			NetworkRx   uint64  `json:"network_rx"`
+			NetworkTx   uint64  `json:"network_tx"`
+			BlockRead   uint64  `json:"block_read"`
+			BlockWrite  uint64  `json:"block_write"`
+		}
+		if err := json.Unmarshal(data, &stats); err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+
+		pidsLimit := "unlimited"
+		if stats.PidsLimit > 0 {
+			pidsLimit = fmt.Sprint(stats.PidsLimit)
+		}
+		fmt.Printf("CPU:       %.2f%%\n", stats.CPUPercent)
+		fmt.Printf("Memory:    %s / %s\n", units.BytesSize(float64(stats.MemoryUsage)), units.BytesSize(float64(stats.MemoryLimit)))
+		fmt.Printf("PIDs:      %d / %s\n", stats.Pids, pidsLimit)
+		fmt.Printf("Net I/O:   %s / %s\n", units.HumanSize(float64(stats.NetworkRx)), units.HumanSize(float64(stats.NetworkTx)))
+		fmt.Printf("Block I/O: %s / %s\n", units.HumanSize(float64(stats.BlockRead)), units.HumanSize(float64(stats.BlockWrite)))
+		return nil
+	},
+}
+
+var nodeGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove orphaned MCP server containers",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newAPIClient()
+		data, status, err := client.post("/api/v1/nodes/gc", nil)
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		var resp struct {
+			Removed []string `json:"removed"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+
+		for _, id := range resp.Removed {
+			fmt.Printf("Removed: %s\n", id)
+		}
+		fmt.Printf("%d orphaned container(s) removed\n", len(resp.Removed))
+		return nil
+	},
+}
+
+var nodePolicyCmd = &cobra.Command{
+	Use:   "policy [id]",
+	Short: "List tool access policies for an MCP server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newAPIClient()
+		data, status, err := client.get("/api/v1/nodes/" + args[0] + "/policies")
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		var policies []struct {
+			PrincipalID string   `json:"principal_id"`
+			Tools       []string `json:"tools"`
+			Resources   []string `json:"resources"`
+			Prompts     []string `json:"prompts"`
+		}
+		if err := json.Unmarshal(data, &policies); err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PRINCIPAL\tTOOLS\tRESOURCES\tPROMPTS")
+		for _, p := range policies {
+			principal := p.PrincipalID
+			if principal == "" {
+				principal = "default"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", principal, formatAllowlist(p.Tools), formatAllowlist(p.Resources), formatAllowlist(p.Prompts))
+		}
+		return w.Flush()
+	},
+}
+
+var nodePolicySetCmd = &cobra.Command{
+	Use:   "set [id]",
+	Short: "Set a tool access policy for a user or the server default",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		principal, _ := cmd.Flags().GetString("principal")
+
+		body := map[string]any{}
+		for _, kind := range []string{"tools", "resources", "prompts"} {
+			if cmd.Flags().Changed(kind) {
+				list, _ := cmd.Flags().GetStringSlice(kind)
+				body[kind] = list
+			}
+		}
+
+		client := newAPIClient()
+		data, status, err := client.put("/api/v1/nodes/"+args[0]+"/policies/"+principal, body)
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		fmt.Printf("Policy set for %s\n", principal)
+		return nil
+	},
+}
+
+var nodeGrantCmd = &cobra.Command{
+	Use:   "grant",
+	Short: "Manage the OAuth grants you gave MCP servers",
+}
+
+var nodeGrantListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List your OAuth grants",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, _ := cmd.Flags().GetString("server")
+
+		path := "/api/v1/nodes/oauth/grants"
+		if server != "" {
+			path += "?server_id=" + url.QueryEscape(server)
+		}
+		client := newAPIClient()
+		data, status, err := client.get(path)
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		var grants []struct {
+			ID        string     `json:"id"`
+			ServerID  string     `json:"server_id"`
+			Provider  string     `json:"provider"`
+			ExpiresAt *time.Time `json:"expires_at"`
+			CreatedAt time.Time  `json:"created_at"`
+		}
+		if err := json.Unmarshal(data, &grants); err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSERVER\tPROVIDER\tEXPIRES\tCREATED")
+		for _, g := range grants {
+			expires := "never"
+			if g.ExpiresAt != nil {
+				expires = g.ExpiresAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", g.ID, g.ServerID, g.Provider, expires, g.CreatedAt.Local().Format(time.DateTime))
+		}
+		return w.Flush()
+	},
+}
+
+var nodeGrantRevokeCmd = &cobra.Command{
+	Use:   "revoke [id]",
+	Short: "Revoke an OAuth grant at its provider and delete it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newAPIClient()
+		data, status, err := client.delete("/api/v1/nodes/oauth/grants/" + args[0])
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		fmt.Printf("Grant %s revoked\n", args[0])
+		return nil
+	},
+}
+
+// formatAllowlist renders an allowlist for display; nil means unrestricted.
+func formatAllowlist(list []string) string {
+	if list == nil {
+		return "*"
+	}
+	if len(list) == 0 {
+		return "(none)"
+	}
+	return strings.Join(list, ",")
+}
+
 func init() {
 	nodeRegisterCmd.Flags().String("name", "", "server name")
 	nodeRegisterCmd.Flags().String("image", "", "container image")
+	nodeRegisterCmd.Flags().String("transport", "", "MCP transport the image speaks: websocket (default) or stdio")
+	nodeRegisterCmd.Flags().Bool("pooled", false, "share one long-lived backend session among all clients")
+	nodeRegisterCmd.Flags().String("restart", "", "restart policy: never, on-failure (default) or always")
+	nodeRegisterCmd.Flags().String("memory", "", "memory limit, e.g. 512m")
+	nodeRegisterCmd.Flags().Float64("cpus", 0, "CPU limit in cores, e.g. 1.5")
+	nodeRegisterCmd.Flags().Int64("pids", 0, "process limit")
+	nodeRegisterCmd.Flags().String("disk", "", "writable layer size limit, e.g. 1g")
 	nodeRegisterCmd.MarkFlagRequired("name")
 	nodeRegisterCmd.MarkFlagRequired("image")
 
-	nodeCmd.AddCommand(nodeListCmd, nodeRegisterCmd, nodeStartCmd, nodeStopCmd, nodeRemoveCmd)
+	nodeUpdateCmd.Flags().String("image", "", "new container image")
+	nodeUpdateCmd.Flags().String("config", "", "new server spec as JSON, replacing the current one")
+
+	nodeLogsCmd.Flags().BoolP("follow", "f", false, "keep streaming new output")
+	nodeLogsCmd.Flags().String("since", "", "only output since a timestamp or duration ago, e.g. 10m")
+	nodeLogsCmd.Flags().String("tail", "", "number of lines from the end to show, or all")
+
+	nodePolicySetCmd.Flags().String("principal", "default", "user id the policy applies to, or \"default\"")
+	nodePolicySetCmd.Flags().StringSlice("tools", nil, "allowed tool name patterns (omit for unrestricted)")
+	nodePolicySetCmd.Flags().StringSlice("resources", nil, "allowed resource URI patterns (omit for unrestricted)")
+	nodePolicySetCmd.Flags().StringSlice("prompts", nil, "allowed prompt name patterns (omit for unrestricted)")
+
+	nodePolicyCmd.AddCommand(nodePolicySetCmd)
+
+	nodeGrantListCmd.Flags().String("server", "", "only list the grants of this server id")
+	nodeGrantCmd.AddCommand(nodeGrantListCmd, nodeGrantRevokeCmd)
+	nodeCmd.AddCommand(nodeListCmd, nodeRegisterCmd, nodeUpdateCmd, nodeRevisionsCmd, nodeRollbackCmd, nodeStartCmd, nodeStopCmd, nodeRemoveCmd, nodeLogsCmd, nodeStatsCmd, nodeGCCmd, nodePolicyCmd, nodeGrantCmd)
 	rootCmd.AddCommand(nodeCmd)
 }
@@ -87,13 +87,15 @@ var sentryRulesAddCmd = &cobra.Command{
 		name, _ := cmd.Flags().GetString("name")
 		pattern, _ := cmd.Flags().GetString("pattern")
 		action, _ := cmd.Flags().GetString("action")
+		severity, _ := cmd.Flags().GetString("severity")
 
 		client := newAPIClient()
 		data, status, err := client.post("/api/v1/sentry/rules", map[string]any{
-			"name":    name,
-			"pattern": pattern,
-			"action":  action,
-			"enabled": true,
+			"name":     name,
+			"pattern":  pattern,
+			"action":   action,
+			"severity": severity,
+			"enabled":  true,
 		})
 		if err != nil {
 			return err
@@ -183,10 +185,141 @@ var sentryBudgetSetCmd = &cobra.Command{
 	},
 }
 
+var sentryApprovalsCmd = &cobra.Command{
+	Use:   "approvals",
+	Short: "List pending approval requests",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statusFilter, _ := cmd.Flags().GetString("status")
+
+		client := newAPIClient()
+		data, status, err := client.get("/api/v1/sentry/approvals?status=" + statusFilter)
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		var approvals []struct {
+			ID        string `json:"id"`
+			ServerID  string `json:"server_id"`
+			Method    string `json:"method"`
+			Target    string `json:"target"`
+			Status    string `json:"status"`
+			ExpiresAt string `json:"expires_at"`
+		}
+		if err := json.Unmarshal(data, &approvals); err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSERVER\tMETHOD\tTARGET\tSTATUS\tEXPIRES AT")
+		for _, a := range approvals {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", a.ID, a.ServerID, a.Method, a.Target, a.Status, a.ExpiresAt)
+		}
+		return w.Flush()
+	},
+}
+
+func decideApproval(id, decision string) error {
+	client := newAPIClient()
+	data, status, err := client.post("/api/v1/sentry/approvals/"+id, map[string]string{
+		"decision": decision,
+	})
+	if err != nil {
+		return err
+	}
+	if checkError(data, status) {
+		return nil
+	}
+
+	var approval struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(data, &approval); err != nil {
+		return fmt.Errorf("parsing response: %w", err)
+	}
+
+	fmt.Printf("ID:     %s\nStatus: %s\n", approval.ID, approval.Status)
+	return nil
+}
+
+var sentryApproveCmd = &cobra.Command{
+	Use:   "approve [id]",
+	Short: "Approve a held request",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return decideApproval(args[0], "approve")
+	},
+}
+
+var sentryDenyCmd = &cobra.Command{
+	Use:   "deny [id]",
+	Short: "Deny a held request",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return decideApproval(args[0], "deny")
+	},
+}
+
+var sentryAlertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "View rule and anomaly alerts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newAPIClient()
+		data, status, err := client.get("/api/v1/sentry/alerts")
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		var alerts []struct {
+			ID        string `json:"id"`
+			Kind      string `json:"kind"`
+			Severity  string `json:"severity"`
+			Message   string `json:"message"`
+			CreatedAt string `json:"created_at"`
+		}
+		if err := json.Unmarshal(data, &alerts); err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tKIND\tSEVERITY\tMESSAGE\tCREATED AT")
+		for _, a := range alerts {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.ID, a.Kind, a.Severity, a.Message, a.CreatedAt)
+		}
+		return w.Flush()
+	},
+}
+
+var sentryReleaseCmd = &cobra.Command{
+	Use:   "release [server-id]",
+	Short: "Release a quarantined MCP server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newAPIClient()
+		data, status, err := client.post("/api/v1/sentry/quarantine/"+args[0]+"/release", nil)
+		if err != nil {
+			return err
+		}
+		if checkError(data, status) {
+			return nil
+		}
+
+		fmt.Printf("Server %s released\n", args[0])
+		return nil
+	},
+}
+
 func init() {
 	sentryRulesAddCmd.Flags().String("name", "", "rule name")
 	sentryRulesAddCmd.Flags().String("pattern", "", "match pattern")
-	sentryRulesAddCmd.Flags().String("action", "block", "rule action (block, allow, alert)")
+	sentryRulesAddCmd.Flags().String("action", "block", "rule action (block, allow, alert, require_approval)")
+	sentryRulesAddCmd.Flags().String("severity", "medium", "alert severity (low, medium, high, critical)")
 	sentryRulesAddCmd.MarkFlagRequired("name")
 	sentryRulesAddCmd.MarkFlagRequired("pattern")
 
@@ -194,8 +327,11 @@ func init() {
 	sentryBudgetSetCmd.Flags().String("period", "monthly", "budget period (daily, weekly, monthly)")
 	sentryBudgetSetCmd.MarkFlagRequired("max-tokens")
 
+	sentryApprovalsCmd.Flags().String("status", "pending", "filter by status (pending, approved, denied, expired)")
+
 	sentryRulesCmd.AddCommand(sentryRulesAddCmd)
 	sentryBudgetCmd.AddCommand(sentryBudgetSetCmd)
-	sentryCmd.AddCommand(sentryAuditCmd, sentryRulesCmd, sentryBudgetCmd)
+	sentryApprovalsCmd.AddCommand(sentryApproveCmd, sentryDenyCmd)
+	sentryCmd.AddCommand(sentryAuditCmd, sentryRulesCmd, sentryBudgetCmd, sentryApprovalsCmd, sentryAlertsCmd, sentryReleaseCmd)
 	rootCmd.AddCommand(sentryCmd)
 }
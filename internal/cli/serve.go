@@ -51,7 +51,7 @@ var serveCmd = &cobra.Command{
 		defer pool.Close()
 		slog.Info("database connected", "dsn", cfg.Database.DSN)
 
-		handler := app.New(cfg, logger, pool)
+		handler := app.New(ctx, cfg, logger, pool)
 
 		addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 		slog.Info("starting server", "addr", addr)
@@ -0,0 +1,119 @@
+package nodes
+
+import (
+	"encoding/json"
+	"path"
+)
+
+// listFields maps MCP list methods to the result field holding their items.
+var listFields = map[string]string{
+	"tools/list":     "tools",
+	"resources/list": "resources",
+	"prompts/list":   "prompts",
+}
+
+func allowed(patterns []string, name string) bool {
+	if patterns == nil {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsTool, allowsResource and allowsPrompt report whether the policy
+// permits the named item. A nil policy permits everything.
+func (p *AccessPolicy) allowsTool(name string) bool {
+	return p == nil || allowed(p.Tools, name)
+}
+
+func (p *AccessPolicy) allowsResource(uri string) bool {
+	return p == nil || allowed(p.Resources, uri)
+}
+
+func (p *AccessPolicy) allowsPrompt(name string) bool {
+	return p == nil || allowed(p.Prompts, name)
+}
+
+// allowsRequest reports whether a client request targets an item the policy
+// permits. Requests that do not name a tool, resource or prompt are allowed.
+func (p *AccessPolicy) allowsRequest(msg *rpcMessage) bool {
+	switch msg.Method {
+	case "tools/call":
+		return p.allowsTool(msg.target())
+	case "resources/read", "resources/subscribe", "resources/unsubscribe":
+		return p.allowsResource(msg.target())
+	case "prompts/get":
+		return p.allowsPrompt(msg.target())
+	}
+	return true
+}
+
+// hiddenError is the error returned for a request the policy disallows. It
+// matches what an MCP server reports for an item that does not exist.
+func hiddenError(msg *rpcMessage) *RPCError {
+	switch msg.Method {
+	case "tools/call":
+		return &RPCError{Code: codeInvalidParams, Message: "Unknown tool: " + msg.target()}
+	case "prompts/get":
+		return &RPCError{Code: codeInvalidParams, Message: "Unknown prompt: " + msg.target()}
+	default:
+		return &RPCError{Code: codeInvalidParams, Message: "Resource not found: " + msg.target()}
+	}
+}
+
+// filterList removes items the policy hides from the result of a tools/list,
+// resources/list or prompts/list response. Other fields, such as nextCursor,
+// are preserved. It returns the original result if nothing was removed or the
+// result cannot be parsed.
+func (p *AccessPolicy) filterList(method string, result json.RawMessage) json.RawMessage {
+	field, ok := listFields[method]
+	if !ok || p == nil {
+		return result
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(result, &fields); err != nil {
+		return result
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(fields[field], &items); err != nil {
+		return result
+	}
+
+	kept := make([]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		var id struct {
+			Name string `json:"name"`
+			URI  string `json:"uri"`
+		}
+		if err := json.Unmarshal(item, &id); err != nil {
+			continue
+		}
+		var ok bool
+		switch field {
+		case "tools":
+			ok = p.allowsTool(id.Name)
+		case "resources":
+			ok = p.allowsResource(id.URI)
+		case "prompts":
+			ok = p.allowsPrompt(id.Name)
+		}
+		if ok {
+			kept = append(kept, item)
+		}
+	}
+	if len(kept) == len(items) {
+		return result
+	}
+
+	fields[field], _ = json.Marshal(kept)
+	filtered, err := json.Marshal(fields)
+	if err != nil {
+		return result
+	}
+	return filtered
+}
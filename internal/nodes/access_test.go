@@ -0,0 +1,67 @@
+package nodes
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAccessPolicyFilterList(t *testing.T) {
+	policy := &AccessPolicy{Tools: []string{"read_*"}, Resources: []string{}}
+
+	result := json.RawMessage(`{"tools":[{"name":"read_file"},{"name":"delete_file"},{"name":"read_dir"}],"nextCursor":"abc"}`)
+	var got struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+		NextCursor string `json:"nextCursor"`
+	}
+	if err := json.Unmarshal(policy.filterList("tools/list", result), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.Tools) != 2 || got.Tools[0].Name != "read_file" || got.Tools[1].Name != "read_dir" {
+		t.Errorf("expected read tools only, got %+v", got.Tools)
+	}
+	if got.NextCursor != "abc" {
+		t.Errorf("expected nextCursor to be preserved, got %q", got.NextCursor)
+	}
+
+	resources := policy.filterList("resources/list", json.RawMessage(`{"resources":[{"uri":"file:///a"}]}`))
+	if string(resources) != `{"resources":[]}` {
+		t.Errorf("expected empty allowlist to hide all resources, got %s", resources)
+	}
+
+	prompts := json.RawMessage(`{"prompts":[{"name":"summarise"}]}`)
+	if string(policy.filterList("prompts/list", prompts)) != string(prompts) {
+		t.Error("expected nil prompt allowlist to leave prompts untouched")
+	}
+}
+
+func TestAccessPolicyAllowsRequest(t *testing.T) {
+	policy := &AccessPolicy{Tools: []string{"search"}, Resources: []string{"file:///public/*"}, Prompts: []string{}}
+
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{`{"method":"tools/call","params":{"name":"search"}}`, true},
+		{`{"method":"tools/call","params":{"name":"delete_repo"}}`, false},
+		{`{"method":"resources/read","params":{"uri":"file:///public/readme"}}`, true},
+		{`{"method":"resources/read","params":{"uri":"file:///etc/passwd"}}`, false},
+		{`{"method":"prompts/get","params":{"name":"any"}}`, false},
+		{`{"method":"tools/list"}`, true},
+	}
+	for _, tt := range tests {
+		var msg rpcMessage
+		json.Unmarshal([]byte(tt.raw), &msg)
+		if got := policy.allowsRequest(&msg); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.raw, tt.want, got)
+		}
+	}
+
+	var unrestricted *AccessPolicy
+	var msg rpcMessage
+	json.Unmarshal([]byte(`{"method":"tools/call","params":{"name":"anything"}}`), &msg)
+	if !unrestricted.allowsRequest(&msg) {
+		t.Error("expected nil policy to allow everything")
+	}
+}
@@ -0,0 +1,446 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// namespaceSep separates a member server's prefix from an item name in the
+// aggregated view, as in "github__create_issue".
+const namespaceSep = "__"
+
+// maxListPages bounds how many pages of a paginated list are fetched from each
+// member when merging.
+const maxListPages = 20
+
+// prefixSeparators matches runs of characters that become a single "_" in a
+// prefix, so a prefix never contains namespaceSep.
+var prefixSeparators = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// namespacePrefix derives an item prefix from a server name.
+func namespacePrefix(name string) string {
+	return strings.Trim(prefixSeparators.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// aggregateMember is one backend server behind an aggregate session. Requests
+// to it carry gateway-assigned IDs so responses can be correlated.
+type aggregateMember struct {
+	prefix string
+	proxy  *proxySession
+
+	mu      sync.Mutex
+	nextID  uint64
+	waiting map[string]chan *rpcMessage
+}
+
+// request sends method to the member under a gateway-assigned ID, subject to
+// the same checks as a proxied client request, and waits for the response.
+func (m *aggregateMember) request(ctx context.Context, method string, params json.RawMessage) (*rpcMessage, error) {
+	ch := make(chan *rpcMessage, 1)
+	m.mu.Lock()
+	m.nextID++
+	id := json.RawMessage(strconv.Quote("nexusclaw-" + strconv.FormatUint(m.nextID, 10)))
+	if m.waiting == nil {
+		m.waiting = make(map[string]chan *rpcMessage)
+	}
+	m.waiting[rpcID(id)] = ch
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.waiting, rpcID(id))
+		m.mu.Unlock()
+	}()
+
+	raw, err := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	forward, reply := m.proxy.inspect(ctx, raw)
+	if !forward {
+		var msg rpcMessage
+		if err := json.Unmarshal(reply, &msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+	if err := m.proxy.backend.WriteMessage(websocket.TextMessage, raw); err != nil {
+		return nil, err
+	}
+
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deliver hands a response to the request waiting for it.
+func (m *aggregateMember) deliver(msg *rpcMessage) {
+	m.mu.Lock()
+	ch := m.waiting[rpcID(msg.ID)]
+	m.mu.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- msg:
+	default:
+	}
+}
+
+// aggregateSession presents several backend MCP servers to one client
+// WebSocket as a single virtual server. The gateway answers initialize itself,
+// merges list results with item names prefixed by their server, and routes
+// each call to the owning backend. Every member request passes through that
+// member's proxy core, so access policies, schema validation, rate limits and
+// Sentry enforcement apply as on a direct connection.
+type aggregateSession struct {
+	client  *wsConn
+	members []*aggregateMember
+
+	// resources maps resource URIs to their owner, from the last resources/list.
+	mu        sync.Mutex
+	resources map[string]*aggregateMember
+}
+
+// run serves the client until it or any member disconnects.
+func (s *aggregateSession) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, m := range s.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.readMember(m)
+			cancel()
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		s.client.Close()
+		for _, m := range s.members {
+			m.proxy.backend.Close()
+		}
+	}()
+
+	for {
+		_, raw, err := s.client.ReadMessage()
+		if err != nil {
+			break
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			s.write(rpcErrorResponse(nil, &RPCError{Code: codeParseError, Message: "Parse error"}))
+			continue
+		}
+		switch {
+		case !msg.isRequest():
+			// The gateway sends no requests of its own to the client.
+		case msg.isNotification():
+			s.notify(ctx, &msg, raw)
+		default:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.handle(ctx, &msg)
+			}()
+		}
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+// close terminates the session, telling the client why.
+func (s *aggregateSession) close(code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = s.client.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	s.client.Close()
+	for _, m := range s.members {
+		m.proxy.backend.Close()
+	}
+}
+
+// readMember routes a member's messages until it disconnects: responses to
+// their waiting requests, notifications to the client.
+func (s *aggregateSession) readMember(m *aggregateMember) {
+	for {
+		_, raw, err := m.proxy.backend.ReadMessage()
+		if err != nil {
+			return
+		}
+		m.proxy.touch()
+		if m.proxy.policy != nil {
+			raw = m.proxy.filterResponse(raw)
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			continue
+		}
+		switch {
+		case !msg.isRequest():
+			m.deliver(&msg)
+		case msg.isNotification():
+			s.write(raw)
+		default:
+			// Server-initiated requests cannot be attributed to one client
+			// request, so they are refused.
+			reply := rpcErrorResponse(msg.ID, &RPCError{Code: codeMethodNotFound, Message: "Method not found"})
+			if err := m.proxy.backend.WriteMessage(websocket.TextMessage, reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// notify forwards a client notification to every member. Cancellations refer
+// to client request IDs the members never saw, so they are dropped.
+func (s *aggregateSession) notify(ctx context.Context, msg *rpcMessage, raw []byte) {
+	if msg.Method == "notifications/cancelled" {
+		return
+	}
+	for _, m := range s.members {
+		if forward, _ := m.proxy.inspect(ctx, raw); forward {
+			_ = m.proxy.backend.WriteMessage(websocket.TextMessage, raw)
+		}
+	}
+}
+
+func (s *aggregateSession) handle(ctx context.Context, msg *rpcMessage) {
+	result, rpcErr := s.dispatch(ctx, msg)
+	if rpcErr != nil {
+		s.write(rpcErrorResponse(msg.ID, rpcErr))
+		return
+	}
+	resp, err := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result})
+	if err != nil {
+		s.write(rpcErrorResponse(msg.ID, &RPCError{Code: codeInternalError, Message: "invalid backend result"}))
+		return
+	}
+	s.write(resp)
+}
+
+func (s *aggregateSession) write(msg []byte) {
+	_ = s.client.WriteMessage(websocket.TextMessage, msg)
+}
+
+func (s *aggregateSession) dispatch(ctx context.Context, msg *rpcMessage) (json.RawMessage, *RPCError) {
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(ctx, msg.Params)
+	case "ping":
+		return json.RawMessage(`{}`), nil
+	case "tools/list", "resources/list", "prompts/list":
+		return s.list(ctx, msg.Method)
+	case "tools/call", "prompts/get":
+		return s.callNamed(ctx, msg)
+	case "resources/read", "resources/subscribe", "resources/unsubscribe":
+		return s.callResource(ctx, msg)
+	default:
+		return nil, &RPCError{Code: codeMethodNotFound, Message: "Method not found: " + msg.Method}
+	}
+}
+
+// initialize initializes every member with the client's parameters and
+// answers with the union of their capabilities.
+func (s *aggregateSession) initialize(ctx context.Context, params json.RawMessage) (json.RawMessage, *RPCError) {
+	results := make([]*rpcMessage, len(s.members))
+	errs := make([]error, len(s.members))
+	var wg sync.WaitGroup
+	for i, m := range s.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = m.request(ctx, "initialize", params)
+		}()
+	}
+	wg.Wait()
+
+	var version string
+	caps := map[string]any{}
+	for i, m := range s.members {
+		if errs[i] != nil || results[i].Error != nil {
+			return nil, &RPCError{Code: codeInternalError, Message: "server " + m.prefix + " failed to initialize"}
+		}
+		var res struct {
+			ProtocolVersion string                     `json:"protocolVersion"`
+			Capabilities    map[string]json.RawMessage `json:"capabilities"`
+		}
+		if err := json.Unmarshal(results[i].Result, &res); err != nil {
+			return nil, &RPCError{Code: codeInternalError, Message: "server " + m.prefix + " returned an invalid initialize result"}
+		}
+		if version == "" {
+			version = res.ProtocolVersion
+		}
+		for _, c := range []string{"tools", "resources", "prompts"} {
+			if _, ok := res.Capabilities[c]; ok {
+				caps[c] = map[string]any{}
+			}
+		}
+	}
+
+	result, err := json.Marshal(map[string]any{
+		"protocolVersion": version,
+		"capabilities":    caps,
+		"serverInfo":      map[string]string{"name": "nexusclaw", "version": "1.0.0"},
+	})
+	if err != nil {
+		return nil, &RPCError{Code: codeInternalError, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// list merges a list method across members, prefixing each item's name with
+// its server. Members that fail are left out of the result.
+func (s *aggregateSession) list(ctx context.Context, method string) (json.RawMessage, *RPCError) {
+	field := listFields[method]
+	items := make([][]map[string]any, len(s.members))
+	var wg sync.WaitGroup
+	for i, m := range s.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := m.listAll(ctx, method, field)
+			if err != nil {
+				slog.Warn("aggregate list failed", "server", m.prefix, "method", method, "error", err)
+				return
+			}
+			items[i] = got
+		}()
+	}
+	wg.Wait()
+
+	merged := []map[string]any{}
+	owners := make(map[string]*aggregateMember)
+	for i, m := range s.members {
+		for _, item := range items[i] {
+			if name, ok := item["name"].(string); ok {
+				item["name"] = m.prefix + namespaceSep + name
+			}
+			if uri, ok := item["uri"].(string); ok && method == "resources/list" {
+				owners[uri] = m
+			}
+			merged = append(merged, item)
+		}
+	}
+	if method == "resources/list" {
+		s.mu.Lock()
+		s.resources = owners
+		s.mu.Unlock()
+	}
+
+	result, err := json.Marshal(map[string]any{field: merged})
+	if err != nil {
+		return nil, &RPCError{Code: codeInternalError, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// listAll fetches every page of a list method from the member.
+func (m *aggregateMember) listAll(ctx context.Context, method, field string) ([]map[string]any, error) {
+	var all []map[string]any
+	var cursor string
+	for range maxListPages {
+		var params json.RawMessage
+		if cursor != "" {
+			params, _ = json.Marshal(map[string]string{"cursor": cursor})
+		}
+		resp, err := m.request(ctx, method, params)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s: %s", method, resp.Error.Message)
+		}
+
+		var page map[string]json.RawMessage
+		if err := json.Unmarshal(resp.Result, &page); err != nil {
+			return nil, err
+		}
+		var items []map[string]any
+		if err := json.Unmarshal(page[field], &items); err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+
+		cursor = ""
+		if raw, ok := page["nextCursor"]; ok {
+			_ = json.Unmarshal(raw, &cursor)
+		}
+		if cursor == "" {
+			break
+		}
+	}
+	return all, nil
+}
+
+// callNamed routes tools/call or prompts/get to the member owning the
+// namespaced name, with the prefix stripped.
+func (s *aggregateSession) callNamed(ctx context.Context, msg *rpcMessage) (json.RawMessage, *RPCError) {
+	name := msg.target()
+	for _, m := range s.members {
+		local, ok := strings.CutPrefix(name, m.prefix+namespaceSep)
+		if !ok {
+			continue
+		}
+		params, err := setParam(msg.Params, "name", local)
+		if err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "Invalid params"}
+		}
+		return forwardResult(m.request(ctx, msg.Method, params))
+	}
+	return nil, hiddenError(msg)
+}
+
+// callResource routes a resource request to the member that listed its URI.
+// With a single member, unknown URIs go to it.
+func (s *aggregateSession) callResource(ctx context.Context, msg *rpcMessage) (json.RawMessage, *RPCError) {
+	s.mu.Lock()
+	m := s.resources[msg.target()]
+	s.mu.Unlock()
+	if m == nil && len(s.members) == 1 {
+		m = s.members[0]
+	}
+	if m == nil {
+		return nil, hiddenError(msg)
+	}
+	return forwardResult(m.request(ctx, msg.Method, msg.Params))
+}
+
+func forwardResult(resp *rpcMessage, err error) (json.RawMessage, *RPCError) {
+	if err != nil {
+		return nil, &RPCError{Code: codeInternalError, Message: "backend unavailable"}
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Result, nil
+}
+
+// setParam returns params with key set to value.
+func setParam(params json.RawMessage, key string, value any) (json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &fields); err != nil {
+			return nil, err
+		}
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields[key] = v
+	return json.Marshal(fields)
+}
@@ -0,0 +1,204 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/kapella-hub/NexusClaw/internal/platform/crypto"
+)
+
+// newStdioMCPServer starts a minimal stdio MCP server named name that serves
+// the given tools and one resource, and answers tools/call with the server
+// and tool names.
+func newStdioMCPServer(name string, tools ...string) *stdioServer {
+	return newStdioServer(func(line []byte) [][]byte {
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil || !msg.isRequest() || msg.isNotification() {
+			return nil
+		}
+		var result any
+		switch msg.Method {
+		case "initialize":
+			result = map[string]any{"protocolVersion": "2025-06-18", "capabilities": map[string]any{"tools": map[string]any{}}}
+		case "tools/list":
+			// Serve one tool per page to exercise pagination.
+			var params struct {
+				Cursor string `json:"cursor"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			i := 0
+			for i < len(tools) && params.Cursor != "" && tools[i] != params.Cursor {
+				i++
+			}
+			page := map[string]any{"tools": []any{}}
+			if i < len(tools) {
+				page["tools"] = []any{map[string]any{"name": tools[i], "inputSchema": map[string]any{"type": "object"}}}
+				if i+1 < len(tools) {
+					page["nextCursor"] = tools[i+1]
+				}
+			}
+			result = page
+		case "resources/list":
+			result = map[string]any{"resources": []any{map[string]any{"uri": "file:///" + name, "name": "readme"}}}
+		case "resources/read":
+			result = map[string]any{"contents": []any{map[string]any{"uri": msg.target(), "text": name}}}
+		case "tools/call":
+			result = map[string]any{"content": []any{map[string]any{"type": "text", "text": name + ":" + msg.target()}}}
+		default:
+			resp := rpcErrorResponse(msg.ID, &RPCError{Code: codeMethodNotFound, Message: "Method not found"})
+			return [][]byte{resp}
+		}
+		raw, _ := json.Marshal(result)
+		resp, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: raw})
+		return [][]byte{resp}
+	})
+}
+
+func dialGateway(t *testing.T, svc *mockService, ids ...uuid.UUID) *websocket.Conn {
+	t.Helper()
+	h := newTestHandler(svc)
+	ts := httptest.NewServer(h.Routes())
+	t.Cleanup(ts.Close)
+
+	servers := make([]string, len(ids))
+	for i, id := range ids {
+		servers[i] = id.String()
+	}
+	token, _ := crypto.IssueToken(uuid.New().String(), time.Hour, handlerTestSecret)
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/gateway?servers=" + strings.Join(servers, ",")
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dial gateway: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func aggregateTestService(names map[uuid.UUID]string, tools map[string][]string) *mockService {
+	return &mockService{
+		ConnectWebSocketFn: func(_ context.Context, _ uuid.UUID, _ http.ResponseWriter, _ *http.Request) error {
+			return nil
+		},
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, Name: names[id]}, nil
+		},
+		EffectivePolicyFn: func(_ context.Context, _, _ uuid.UUID) (*AccessPolicy, error) {
+			return nil, nil
+		},
+		OpenBackendFn: func(_ context.Context, server *MCPServer) (BackendConn, error) {
+			return newStdioConn(newStdioMCPServer(server.Name, tools[server.Name]...)), nil
+		},
+	}
+}
+
+func TestGatewayAggregatesServers(t *testing.T) {
+	github, jira := uuid.New(), uuid.New()
+	svc := aggregateTestService(
+		map[uuid.UUID]string{github: "GitHub", jira: "jira"},
+		map[string][]string{"GitHub": {"create_issue", "list_repos"}, "jira": {"create_issue"}},
+	)
+	conn := dialGateway(t, svc, github, jira)
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`))
+	msg := readRPC(t, conn)
+	if msg.Error != nil || !strings.Contains(string(msg.Result), `"tools":{}`) {
+		t.Fatalf("expected gateway initialize result, got %+v %s", msg, msg.Result)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	msg = readRPC(t, conn)
+	var list struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	json.Unmarshal(msg.Result, &list)
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+	}
+	if got := strings.Join(names, ","); got != "github__create_issue,github__list_repos,jira__create_issue" {
+		t.Errorf("unexpected merged tools %s", got)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":"call","method":"tools/call","params":{"name":"jira__create_issue","arguments":{}}}`))
+	msg = readRPC(t, conn)
+	if string(msg.ID) != `"call"` || !strings.Contains(string(msg.Result), "jira:create_issue") {
+		t.Errorf("expected call routed to jira, got %+v %s", msg, msg.Result)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"create_issue"}}`))
+	if msg = readRPC(t, conn); msg.Error == nil || msg.Error.Code != codeInvalidParams {
+		t.Errorf("expected unknown tool error for an unprefixed name, got %+v", msg)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":4,"method":"resources/list"}`))
+	readRPC(t, conn)
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":5,"method":"resources/read","params":{"uri":"file:///GitHub"}}`))
+	if msg = readRPC(t, conn); !strings.Contains(string(msg.Result), `"text":"GitHub"`) {
+		t.Errorf("expected read routed to the listing server, got %+v %s", msg, msg.Result)
+	}
+}
+
+func TestGatewayAppliesMemberPolicies(t *testing.T) {
+	github, jira := uuid.New(), uuid.New()
+	svc := aggregateTestService(
+		map[uuid.UUID]string{github: "github", jira: "jira"},
+		map[string][]string{"github": {"create_issue", "delete_repo"}, "jira": {"create_issue"}},
+	)
+	svc.EffectivePolicyFn = func(_ context.Context, serverID, _ uuid.UUID) (*AccessPolicy, error) {
+		if serverID == github {
+			return &AccessPolicy{Tools: []string{"create_*"}}, nil
+		}
+		return nil, nil
+	}
+	conn := dialGateway(t, svc, github, jira)
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	msg := readRPC(t, conn)
+	if strings.Contains(string(msg.Result), "delete_repo") {
+		t.Errorf("expected hidden tool to be filtered, got %s", msg.Result)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"github__delete_repo"}}`))
+	if msg = readRPC(t, conn); msg.Error == nil || msg.Error.Code != codeInvalidParams {
+		t.Errorf("expected hidden tool error, got %+v", msg)
+	}
+}
+
+func TestGatewayRejectsDuplicateNames(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	svc := aggregateTestService(map[uuid.UUID]string{a: "GitHub", b: "github"}, nil)
+	h := newTestHandler(svc)
+
+	req := authenticatedRequest(http.MethodGet, "/gateway?servers="+a.String()+","+b.String(), nil, uuid.New().String())
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestNamespacePrefix(t *testing.T) {
+	tests := map[string]string{
+		"github":        "github",
+		"My Server":     "my_server",
+		"a___b":         "a_b",
+		"  spaced out ": "spaced_out",
+	}
+	for name, want := range tests {
+		if got := namespacePrefix(name); got != want {
+			t.Errorf("namespacePrefix(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package nodes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// BackendConn is a message-oriented connection to an MCP server. Messages use
+// gorilla/websocket message types so WebSocket backends need no adaptation.
+type BackendConn interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// maxStdioMessage bounds a single newline-delimited message from a stdio server.
+const maxStdioMessage = 16 << 20
+
+// stdioConn frames newline-delimited JSON-RPC over a stdio stream, as used by
+// the MCP stdio transport.
+type stdioConn struct {
+	rwc io.ReadWriteCloser
+	r   *bufio.Reader
+
+	mu sync.Mutex
+}
+
+func newStdioConn(rwc io.ReadWriteCloser) *stdioConn {
+	return &stdioConn{rwc: rwc, r: bufio.NewReaderSize(rwc, 64<<10)}
+}
+
+// ReadMessage returns the next non-empty line, without its terminator.
+func (c *stdioConn) ReadMessage() (int, []byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := c.r.ReadLine()
+		if err != nil {
+			return 0, nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxStdioMessage {
+			return 0, nil, bufio.ErrTooLong
+		}
+		if isPrefix {
+			continue
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			return websocket.TextMessage, line, nil
+		}
+		line = line[:0]
+	}
+}
+
+// WriteMessage writes data as a single line. JSON is compacted so embedded
+// newlines do not split the message.
+func (c *stdioConn) WriteMessage(_ int, data []byte) error {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		buf.Reset()
+		buf.Write(bytes.ReplaceAll(data, []byte("\n"), []byte(" ")))
+	}
+	buf.WriteByte('\n')
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.rwc.Write(buf.Bytes())
+	return err
+}
+
+func (c *stdioConn) Close() error {
+	return c.rwc.Close()
+}
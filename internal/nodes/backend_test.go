@@ -0,0 +1,53 @@
+package nodes
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// stdioPipe is an in-memory stdio stream: reads come from in, writes go to out.
+type stdioPipe struct {
+	io.Reader
+	out    bytes.Buffer
+	closed bool
+}
+
+func (p *stdioPipe) Write(b []byte) (int, error) { return p.out.Write(b) }
+func (p *stdioPipe) Close() error                { p.closed = true; return nil }
+
+func TestStdioConnReadsLines(t *testing.T) {
+	pipe := &stdioPipe{Reader: strings.NewReader("{\"id\":1}\n\n  \n{\"id\":2}\r\n")}
+	conn := newStdioConn(pipe)
+
+	for _, want := range []string{`{"id":1}`, `{"id":2}`} {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		if string(msg) != want {
+			t.Errorf("expected %s, got %s", want, msg)
+		}
+	}
+	if _, _, err := conn.ReadMessage(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestStdioConnWritesSingleLines(t *testing.T) {
+	pipe := &stdioPipe{Reader: strings.NewReader("")}
+	conn := newStdioConn(pipe)
+
+	if err := conn.WriteMessage(1, []byte("{\n  \"jsonrpc\": \"2.0\",\n  \"id\": 1\n}")); err != nil {
+		t.Fatal(err)
+	}
+	if got := pipe.out.String(); got != "{\"jsonrpc\":\"2.0\",\"id\":1}\n" {
+		t.Errorf("unexpected frame %q", got)
+	}
+
+	conn.Close()
+	if !pipe.closed {
+		t.Error("expected underlying stream to be closed")
+	}
+}
@@ -2,16 +2,33 @@ package nodes
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -22,6 +39,67 @@ type ContainerManager interface {
 	Stop(ctx context.Context, containerID string) error
 	Remove(ctx context.Context, containerID string) error
 	Status(ctx context.Context, containerID string) (ServerStatus, error)
+	// Endpoint returns the host:port at which the gateway can reach the given
+	// container port, e.g. "8080" or "8080/tcp".
+	Endpoint(ctx context.Context, containerID, port string) (string, error)
+	// Attach connects to the stdin and stdout of a container created with
+	// ContainerConfig.Stdin. Only one attachment per container may be open at
+	// a time; ErrBackendBusy is returned while another is.
+	Attach(ctx context.Context, containerID string) (io.ReadWriteCloser, error)
+	// Events streams container exits until ctx is done. The error channel
+	// receives a value if the stream breaks.
+	Events(ctx context.Context) (<-chan ContainerEvent, <-chan error)
+	// List returns every container this gateway instance created, running or
+	// not, as identified by their labels.
+	List(ctx context.Context) ([]ManagedContainer, error)
+	// Logs returns the container's stdout and stderr, interleaved as written.
+	Logs(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error)
+	// Stats samples the container's current resource usage.
+	Stats(ctx context.Context, containerID string) (*ContainerStats, error)
+	// UpdateSecrets rewrites secret files the container was created with,
+	// keyed by container path, so the running container sees new contents.
+	UpdateSecrets(ctx context.Context, containerID string, files map[string]string) error
+}
+
+// LogOptions selects the container output returned by Logs.
+type LogOptions struct {
+	// Follow keeps the stream open for new output.
+	Follow bool
+	// Since limits output to lines written after a Unix or RFC 3339
+	// timestamp, or a duration before now such as "10m".
+	Since string
+	// Tail limits output to this many lines from the end, or "all".
+	Tail string
+}
+
+// Labels the gateway sets on the containers it creates. The managed and
+// instance labels are set by the container manager; the rest come from
+// ContainerConfig.Labels.
+const (
+	labelManaged    = "nexusclaw.managed"
+	labelInstance   = "nexusclaw.instance"
+	labelServerID   = "nexusclaw.server-id"
+	labelOwnerID    = "nexusclaw.owner-id"
+	labelConfigHash = "nexusclaw.config-hash"
+)
+
+// ManagedContainer is a gateway-created container found by List.
+type ManagedContainer struct {
+	ID   string
+	Name string
+	// ServerID and OwnerID are the server the container was created for and
+	// its owner, or uuid.Nil if the label is missing or invalid.
+	ServerID   uuid.UUID
+	OwnerID    uuid.UUID
+	ConfigHash string
+	Status     ServerStatus
+	Created    time.Time
+}
+
+// ContainerEvent reports that a container exited.
+type ContainerEvent struct {
+	ContainerID string
+	ExitCode    int
 }
 
 // DockerClient abstracts the Docker API methods we use (for testability).
@@ -32,24 +110,79 @@ type DockerClient interface {
 	ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error
 	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
 	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerLogs(ctx context.Context, container string, options container.LogsOptions) (io.ReadCloser, error)
+	ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error)
+	ContainerAttach(ctx context.Context, containerID string, options container.AttachOptions) (types.HijackedResponse, error)
+	NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+	NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error)
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+}
+
+// ContainerOptions controls how the gateway reaches the containers it starts.
+type ContainerOptions struct {
+	// Network is a Docker network the gateway attaches containers to and
+	// reaches them on by IP. It is created if missing. When empty, container
+	// ports are published on the host instead.
+	Network string
+	// Host is the address the gateway uses for host-published ports. It
+	// defaults to "localhost".
+	Host string
+	// InstanceID distinguishes this gateway's containers from those of other
+	// gateways sharing the Docker host. It is part of every container name
+	// and label set, and defaults to "default".
+	InstanceID string
+	// Internal creates Network as an internal network, with no route out of
+	// the Docker host; containers then reach the outside world only through
+	// the gateway's egress proxy. An existing network that is not internal
+	// is refused.
+	Internal bool
+	// SeccompDir holds the seccomp profiles servers may select by name, as
+	// <name>.json.
+	SeccompDir string
+	// SecretsDir is where secret files are written, one directory per
+	// container, and bind-mounted from. It should be on a tmpfs so secrets
+	// never reach disk.
+	SecretsDir string
+	// AllowUnconfined lets servers disable seccomp filtering, and AllowRoot
+	// lets them run as root. Without them such containers are refused.
+	AllowUnconfined bool
+	AllowRoot       bool
 }
 
+// invalidNameChars matches characters Docker does not allow in names.
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
 type dockerManager struct {
-	cli DockerClient
+	cli  DockerClient
+	opts ContainerOptions
+
+	networkMu sync.Mutex
+	networkOK bool
+
+	attachMu sync.Mutex
+	attached map[string]bool
 }
 
 // NewContainerManager creates a new Docker-backed container manager.
-func NewContainerManager() (ContainerManager, error) {
+func NewContainerManager(opts ContainerOptions) (ContainerManager, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return nil, fmt.Errorf("creating docker client: %w", err)
 	}
-	return &dockerManager{cli: cli}, nil
+	return newDockerManagerFromClient(cli, opts), nil
 }
 
 // newDockerManagerFromClient creates a dockerManager with a provided DockerClient (for testing).
-func newDockerManagerFromClient(cli DockerClient) ContainerManager {
-	return &dockerManager{cli: cli}
+func newDockerManagerFromClient(cli DockerClient, opts ContainerOptions) ContainerManager {
+	if opts.Host == "" {
+		opts.Host = "localhost"
+	}
+	opts.InstanceID = invalidNameChars.ReplaceAllString(opts.InstanceID, "-")
+	if opts.InstanceID == "" {
+		opts.InstanceID = "default"
+	}
+	return &dockerManager{cli: cli, opts: opts, attached: make(map[string]bool)}
 }
 
 func (dm *dockerManager) Create(ctx context.Context, cfg *ContainerConfig) (string, error) {
@@ -82,11 +215,43 @@ func (dm *dockerManager) Create(ctx context.Context, cfg *ContainerConfig) (stri
 		Image:        cfg.Image,
 		Env:          env,
 		ExposedPorts: exposedPorts,
+		OpenStdin:    cfg.Stdin,
+		AttachStdin:  cfg.Stdin,
+		AttachStdout: cfg.Stdin,
+		Labels:       make(map[string]string, len(cfg.Labels)+2),
 	}
+	for k, v := range cfg.Labels {
+		containerCfg.Labels[k] = v
+	}
+	containerCfg.Labels[labelManaged] = "true"
+	containerCfg.Labels[labelInstance] = dm.opts.InstanceID
 
 	hostCfg := &container.HostConfig{
 		PortBindings: portBindings,
 	}
+	if sec := cfg.Security; sec != nil {
+		if err := dm.applySecurity(containerCfg, hostCfg, sec); err != nil {
+			return "", err
+		}
+	}
+
+	// Either attach to the gateway network, or publish exposed ports without
+	// an explicit binding on ephemeral host ports so Endpoint can find them.
+	var networkCfg *network.NetworkingConfig
+	switch {
+	case cfg.Security != nil && cfg.Security.NoNetwork:
+		hostCfg.NetworkMode = network.NetworkNone
+	case dm.opts.Network != "":
+		if err := dm.ensureNetwork(ctx); err != nil {
+			return "", err
+		}
+		hostCfg.NetworkMode = container.NetworkMode(dm.opts.Network)
+		networkCfg = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{dm.opts.Network: {}},
+		}
+	default:
+		hostCfg.PublishAllPorts = true
+	}
 
 	// Apply resource limits.
 	if cfg.MemoryLimit > 0 {
@@ -95,14 +260,197 @@ func (dm *dockerManager) Create(ctx context.Context, cfg *ContainerConfig) (stri
 	if cfg.CPULimit > 0 {
 		hostCfg.Resources.NanoCPUs = int64(cfg.CPULimit * 1e9)
 	}
+	if cfg.DiskLimit > 0 {
+		hostCfg.StorageOpt = map[string]string{"size": strconv.FormatInt(cfg.DiskLimit, 10)}
+	}
 
-	resp, err := dm.cli.ContainerCreate(ctx, containerCfg, hostCfg, nil, nil, "")
+	var name string
+	if cfg.Name != "" {
+		name = dm.containerName(cfg.Name)
+	}
+	if len(cfg.SecretFiles) > 0 {
+		binds, err := dm.secretBinds(name, cfg.SecretFiles)
+		if err != nil {
+			return "", err
+		}
+		hostCfg.Binds = append(hostCfg.Binds, binds...)
+	}
+	resp, err := dm.cli.ContainerCreate(ctx, containerCfg, hostCfg, networkCfg, nil, name)
+	if cerrdefs.IsConflict(err) && name != "" {
+		// A container left over from an earlier run holds the name.
+		if err := dm.removeStale(ctx, name); err != nil {
+			return "", err
+		}
+		resp, err = dm.cli.ContainerCreate(ctx, containerCfg, hostCfg, networkCfg, nil, name)
+	}
 	if err != nil {
+		// The secrets directory may belong to a running container holding
+		// the name, so it is left alone.
 		return "", fmt.Errorf("creating container: %w", err)
 	}
+	// Only now that the name is this container's may its secrets directory
+	// be rewritten. Bind sources are resolved when the container starts.
+	if len(cfg.SecretFiles) > 0 {
+		if err := dm.writeSecrets(name, cfg.SecretFiles); err != nil {
+			if rmErr := dm.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true}); rmErr != nil {
+				slog.Warn("removing container without its secrets", "container", name, "error", rmErr)
+			}
+			return "", err
+		}
+	}
 	return resp.ID, nil
 }
 
+// secretBinds returns the read-only binds mounting a container's secret
+// files from its directory under SecretsDir.
+func (dm *dockerManager) secretBinds(name string, files map[string]string) ([]string, error) {
+	if dm.opts.SecretsDir == "" {
+		return nil, errors.New("file secrets requested but no secrets directory is configured")
+	}
+	if name == "" {
+		return nil, errors.New("file secrets require a named container")
+	}
+	dir := filepath.Join(dm.opts.SecretsDir, name)
+	targets := slices.Sorted(maps.Keys(files))
+	binds := make([]string, len(targets))
+	for i, target := range targets {
+		binds[i] = filepath.Join(dir, strconv.Itoa(i)) + ":" + target + ":ro"
+	}
+	return binds, nil
+}
+
+// writeSecrets writes a container's secret files to its directory under
+// SecretsDir, at the sources of the binds from secretBinds.
+func (dm *dockerManager) writeSecrets(name string, files map[string]string) error {
+	dir := filepath.Join(dm.opts.SecretsDir, name)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("clearing secrets of %s: %w", name, err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("creating secrets directory: %w", err)
+	}
+
+	for i, target := range slices.Sorted(maps.Keys(files)) {
+		// The directory keeps other host users out; the file must be
+		// readable by the container's unprivileged user.
+		src := filepath.Join(dir, strconv.Itoa(i))
+		if err := os.WriteFile(src, []byte(files[target]), 0o444); err != nil {
+			dm.removeSecrets(name)
+			return fmt.Errorf("writing secret file: %w", err)
+		}
+	}
+	return nil
+}
+
+// removeSecrets deletes the secret files of the named container.
+func (dm *dockerManager) removeSecrets(name string) {
+	if dm.opts.SecretsDir == "" || name == "" {
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(dm.opts.SecretsDir, name)); err != nil {
+		slog.Warn("removing container secrets", "container", name, "error", err)
+	}
+}
+
+func (dm *dockerManager) UpdateSecrets(ctx context.Context, containerID string, files map[string]string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	info, err := dm.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("inspecting container %s: %w", containerID, err)
+	}
+	if info.ContainerJSONBase == nil || info.HostConfig == nil {
+		return fmt.Errorf("container %s has no host config", containerID)
+	}
+	dir := filepath.Join(dm.opts.SecretsDir, strings.TrimPrefix(info.Name, "/"))
+	sources := make(map[string]string)
+	for _, bind := range info.HostConfig.Binds {
+		src, rest, _ := strings.Cut(bind, ":")
+		target, _, _ := strings.Cut(rest, ":")
+		if dm.opts.SecretsDir != "" && filepath.Dir(src) == dir {
+			sources[target] = src
+		}
+	}
+
+	for _, target := range slices.Sorted(maps.Keys(files)) {
+		src, ok := sources[target]
+		if !ok {
+			return fmt.Errorf("container %s has no secret file at %s", containerID, target)
+		}
+		// Rewrite the file in place: a bind mount keeps referring to the
+		// original inode, so replacing the file would go unnoticed.
+		if err := os.Chmod(src, 0o644); err != nil {
+			return fmt.Errorf("updating secret file: %w", err)
+		}
+		err := os.WriteFile(src, []byte(files[target]), 0o444)
+		if chmodErr := os.Chmod(src, 0o444); err == nil {
+			err = chmodErr
+		}
+		if err != nil {
+			return fmt.Errorf("updating secret file: %w", err)
+		}
+	}
+	return nil
+}
+
+// applySecurity applies a security profile to the container's configuration.
+func (dm *dockerManager) applySecurity(containerCfg *container.Config, hostCfg *container.HostConfig, sec *SecurityProfile) error {
+	if sec.Seccomp == "unconfined" && !dm.opts.AllowUnconfined {
+		return fmt.Errorf("%w: seccomp unconfined", ErrSecurityNotAllowed)
+	}
+	if runsAsRoot(sec.User) && !dm.opts.AllowRoot {
+		return fmt.Errorf("%w: running as root", ErrSecurityNotAllowed)
+	}
+	containerCfg.User = sec.User
+	hostCfg.ReadonlyRootfs = sec.ReadOnlyRootfs
+	if len(sec.Tmpfs) > 0 {
+		hostCfg.Tmpfs = make(map[string]string, len(sec.Tmpfs))
+		for _, p := range sec.Tmpfs {
+			hostCfg.Tmpfs[p] = "rw,noexec,nosuid,size=64m"
+		}
+	}
+	hostCfg.CapDrop = sec.CapDrop
+	hostCfg.CapAdd = sec.CapAdd
+	if sec.NoNewPrivileges {
+		hostCfg.SecurityOpt = append(hostCfg.SecurityOpt, "no-new-privileges:true")
+	}
+	seccomp, err := seccompOption(dm.opts.SeccompDir, sec.Seccomp)
+	if err != nil {
+		return err
+	}
+	if seccomp != "" {
+		hostCfg.SecurityOpt = append(hostCfg.SecurityOpt, seccomp)
+	}
+	if sec.PidsLimit > 0 {
+		limit := sec.PidsLimit
+		hostCfg.Resources.PidsLimit = &limit
+	}
+	return nil
+}
+
+// containerName returns the Docker name for a container of this instance.
+func (dm *dockerManager) containerName(name string) string {
+	return "nexusclaw-" + dm.opts.InstanceID + "-" + invalidNameChars.ReplaceAllString(name, "-")
+}
+
+// removeStale removes the named container unless it is running.
+func (dm *dockerManager) removeStale(ctx context.Context, name string) error {
+	info, err := dm.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return fmt.Errorf("inspecting container %s: %w", name, err)
+	}
+	if info.State != nil && info.State.Running {
+		return fmt.Errorf("container %s is already running: %w", name, cerrdefs.ErrConflict)
+	}
+	// Not Remove: the replacement's secrets are written to the same
+	// directory once it is created.
+	if err := dm.cli.ContainerRemove(ctx, name, container.RemoveOptions{Force: true}); err != nil {
+		return fmt.Errorf("removing container %s: %w", name, err)
+	}
+	return nil
+}
+
 func (dm *dockerManager) Start(ctx context.Context, containerID string) error {
 	if err := dm.cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
 		return fmt.Errorf("starting container %s: %w", containerID, err)
@@ -119,9 +467,16 @@ func (dm *dockerManager) Stop(ctx context.Context, containerID string) error {
 }
 
 func (dm *dockerManager) Remove(ctx context.Context, containerID string) error {
+	var name string
+	if dm.opts.SecretsDir != "" {
+		if info, err := dm.cli.ContainerInspect(ctx, containerID); err == nil && info.ContainerJSONBase != nil {
+			name = strings.TrimPrefix(info.Name, "/")
+		}
+	}
 	if err := dm.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true}); err != nil {
 		return fmt.Errorf("removing container %s: %w", containerID, err)
 	}
+	dm.removeSecrets(name)
 	return nil
 }
 
@@ -147,56 +502,308 @@ func (dm *dockerManager) Status(ctx context.Context, containerID string) (Server
 	}
 }
 
-// parsePortConfig converts port specs like "8080:80/tcp" into Docker-compatible
-// exposed ports and port binding maps.
-func parsePortConfig(ports []string) (nat.PortSet, nat.PortMap, error) {
-	if len(ports) == 0 {
-		return nil, nil, nil
+func (dm *dockerManager) List(ctx context.Context) ([]ManagedContainer, error) {
+	list, err := dm.cli.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", labelManaged+"=true"),
+			filters.Arg("label", labelInstance+"="+dm.opts.InstanceID),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
 	}
 
-	// nat.ParsePortSpecs expects format "hostPort:containerPort/protocol"
-	// or "containerPort/protocol" or just "containerPort".
-	exposedPorts, bindings, err := nat.ParsePortSpecs(ports)
+	containers := make([]ManagedContainer, 0, len(list))
+	for _, c := range list {
+		mc := ManagedContainer{
+			ID:         c.ID,
+			ConfigHash: c.Labels[labelConfigHash],
+			Status:     StatusStopped,
+			Created:    time.Unix(c.Created, 0),
+		}
+		if len(c.Names) > 0 {
+			mc.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if id, err := uuid.Parse(c.Labels[labelServerID]); err == nil {
+			mc.ServerID = id
+		}
+		if id, err := uuid.Parse(c.Labels[labelOwnerID]); err == nil {
+			mc.OwnerID = id
+		}
+		switch c.State {
+		case container.StateRunning:
+			mc.Status = StatusRunning
+		case container.StateRestarting:
+			mc.Status = StatusStarting
+		case container.StateDead:
+			mc.Status = StatusError
+		}
+		containers = append(containers, mc)
+	}
+	return containers, nil
+}
+
+func (dm *dockerManager) Endpoint(ctx context.Context, containerID, port string) (string, error) {
+	info, err := dm.cli.ContainerInspect(ctx, containerID)
 	if err != nil {
-		return nil, nil, err
+		return "", fmt.Errorf("inspecting container %s: %w", containerID, err)
+	}
+	if info.NetworkSettings == nil {
+		return "", fmt.Errorf("container %s has no network settings", containerID)
 	}
 
-	return exposedPorts, bindings, nil
+	if !strings.Contains(port, "/") {
+		port += "/tcp"
+	}
+	p := nat.Port(port)
+
+	if dm.opts.Network != "" {
+		ep, ok := info.NetworkSettings.Networks[dm.opts.Network]
+		if !ok || ep == nil || ep.IPAddress == "" {
+			return "", fmt.Errorf("container %s has no address on network %s", containerID, dm.opts.Network)
+		}
+		return net.JoinHostPort(ep.IPAddress, p.Port()), nil
+	}
+
+	for _, b := range info.NetworkSettings.Ports[p] {
+		if b.HostPort == "" {
+			continue
+		}
+		host := b.HostIP
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = dm.opts.Host
+		}
+		return net.JoinHostPort(host, b.HostPort), nil
+	}
+	return "", fmt.Errorf("container %s does not publish port %s", containerID, port)
 }
 
-// extractEnv converts a config map to environment variable key=value pairs.
-// It looks for string values at the top level and for an "env" sub-map.
-func extractEnv(config map[string]any) map[string]string {
-	env := make(map[string]string)
-	if config == nil {
-		return env
+func (dm *dockerManager) Attach(ctx context.Context, containerID string) (io.ReadWriteCloser, error) {
+	dm.attachMu.Lock()
+	if dm.attached[containerID] {
+		dm.attachMu.Unlock()
+		return nil, ErrBackendBusy
 	}
+	dm.attached[containerID] = true
+	dm.attachMu.Unlock()
 
-	// Check for an "env" key that holds a map of env vars.
-	if envMap, ok := config["env"]; ok {
-		switch em := envMap.(type) {
-		case map[string]any:
-			for k, v := range em {
-				env[k] = fmt.Sprintf("%v", v)
-			}
-		case map[string]string:
-			for k, v := range em {
-				env[k] = v
+	resp, err := dm.cli.ContainerAttach(ctx, containerID, container.AttachOptions{
+		Stream: true,
+		Stdin:  true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		dm.detach(containerID)
+		return nil, fmt.Errorf("attaching to container %s: %w", containerID, err)
+	}
+
+	// Without a TTY, stdout and stderr are multiplexed on one stream. Only
+	// stdout carries protocol messages; stderr is diagnostic output.
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, io.Discard, resp.Reader)
+		pw.CloseWithError(err)
+	}()
+
+	return &attachment{
+		Reader: pr,
+		resp:   resp,
+		done: func() {
+			pr.Close()
+			dm.detach(containerID)
+		},
+	}, nil
+}
+
+func (dm *dockerManager) Events(ctx context.Context) (<-chan ContainerEvent, <-chan error) {
+	msgs, errs := dm.cli.Events(ctx, events.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("event", string(events.ActionDie)),
+		),
+	})
+
+	out := make(chan ContainerEvent)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				code, _ := strconv.Atoi(msg.Actor.Attributes["exitCode"])
+				select {
+				case out <- ContainerEvent{ContainerID: msg.Actor.ID, ExitCode: code}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
 			}
 		}
+	}()
+	return out, errs
+}
+
+func (dm *dockerManager) Logs(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error) {
+	rc, err := dm.cli.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Since:      opts.Since,
+		Tail:       opts.Tail,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading logs of container %s: %w", containerID, err)
 	}
 
-	// Also check for top-level string values that look like env vars (UPPER_CASE keys).
-	for k, v := range config {
-		if k == "env" {
-			continue
+	// Without a TTY, stdout and stderr are multiplexed on one stream.
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, rc)
+		pw.CloseWithError(err)
+	}()
+	return &logStream{PipeReader: pr, rc: rc}, nil
+}
+
+// logStream is a demultiplexed container log stream.
+type logStream struct {
+	*io.PipeReader
+	rc io.ReadCloser
+}
+
+func (l *logStream) Close() error {
+	l.PipeReader.Close()
+	return l.rc.Close()
+}
+
+func (dm *dockerManager) detach(containerID string) {
+	dm.attachMu.Lock()
+	delete(dm.attached, containerID)
+	dm.attachMu.Unlock()
+}
+
+// attachment is an open stdio attachment to a container.
+type attachment struct {
+	io.Reader
+	resp types.HijackedResponse
+	once sync.Once
+	done func()
+}
+
+func (a *attachment) Write(p []byte) (int, error) {
+	return a.resp.Conn.Write(p)
+}
+
+func (a *attachment) Close() error {
+	a.once.Do(func() {
+		a.resp.Close()
+		a.done()
+	})
+	return nil
+}
+
+// Stats samples the container's resource usage. Docker takes two samples a
+// second apart so CPU usage can be computed.
+func (dm *dockerManager) Stats(ctx context.Context, containerID string) (*ContainerStats, error) {
+	resp, err := dm.cli.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, fmt.Errorf("getting container stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var raw container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decoding container stats: %w", err)
+	}
+	return statsFromDocker(&raw), nil
+}
+
+// statsFromDocker summarises a Docker stats sample the way docker stats does.
+func statsFromDocker(raw *container.StatsResponse) *ContainerStats {
+	stats := &ContainerStats{
+		MemoryUsage: raw.MemoryStats.Usage,
+		MemoryLimit: raw.MemoryStats.Limit,
+		Pids:        raw.PidsStats.Current,
+		PidsLimit:   raw.PidsStats.Limit,
+		ReadAt:      raw.Read,
+	}
+
+	cpuDelta := float64(raw.CPUStats.CPUUsage.TotalUsage) - float64(raw.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(raw.CPUStats.SystemUsage) - float64(raw.PreCPUStats.SystemUsage)
+	cpus := float64(raw.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(raw.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	// cgroup v1 reports the cache as total_inactive_file, v2 as inactive_file.
+	for _, key := range []string{"total_inactive_file", "inactive_file"} {
+		if v, ok := raw.MemoryStats.Stats[key]; ok && v < stats.MemoryUsage {
+			stats.MemoryUsage -= v
+			break
 		}
-		if strings.ToUpper(k) == k && strings.Contains(k, "_") {
-			if s, ok := v.(string); ok {
-				env[k] = s
-			}
+	}
+
+	for _, n := range raw.Networks {
+		stats.NetworkRx += n.RxBytes
+		stats.NetworkTx += n.TxBytes
+	}
+	for _, e := range raw.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			stats.BlockRead += e.Value
+		case "write":
+			stats.BlockWrite += e.Value
 		}
 	}
+	return stats
+}
+
+// ensureNetwork creates the gateway network on first use if it does not exist.
+func (dm *dockerManager) ensureNetwork(ctx context.Context) error {
+	dm.networkMu.Lock()
+	defer dm.networkMu.Unlock()
+	if dm.networkOK {
+		return nil
+	}
+
+	info, err := dm.cli.NetworkInspect(ctx, dm.opts.Network, network.InspectOptions{})
+	switch {
+	case cerrdefs.IsNotFound(err):
+		_, err = dm.cli.NetworkCreate(ctx, dm.opts.Network, network.CreateOptions{
+			Driver:   "bridge",
+			Internal: dm.opts.Internal,
+			Labels:   map[string]string{"io.nexusclaw.managed": "true"},
+		})
+	case err == nil && dm.opts.Internal && !info.Internal:
+		return fmt.Errorf("network %s exists but is not internal; remove it or choose another network", dm.opts.Network)
+	}
+	if err != nil {
+		return fmt.Errorf("ensuring network %s: %w", dm.opts.Network, err)
+	}
+	dm.networkOK = true
+	return nil
+}
 
-	return env
+// parsePortConfig converts port specs like "8080:80/tcp" into Docker-compatible
+// exposed ports and port binding maps.
+func parsePortConfig(ports []string) (nat.PortSet, nat.PortMap, error) {
+	if len(ports) == 0 {
+		return nil, nil, nil
+	}
+
+	// nat.ParsePortSpecs expects format "hostPort:containerPort/protocol"
+	// or "containerPort/protocol" or just "containerPort".
+	exposedPorts, bindings, err := nat.ParsePortSpecs(ports)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return exposedPorts, bindings, nil
 }
@@ -1,16 +1,27 @@
 package nodes
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"io"
+	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -22,6 +33,13 @@ type mockDockerClient struct {
 	ContainerStopFn   func(ctx context.Context, containerID string, options container.StopOptions) error
 	ContainerRemoveFn func(ctx context.Context, containerID string, options container.RemoveOptions) error
 	ContainerInspectFn func(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	NetworkInspectFn  func(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+	NetworkCreateFn   func(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error)
+	ContainerAttachFn func(ctx context.Context, containerID string, options container.AttachOptions) (types.HijackedResponse, error)
+	EventsFn          func(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+	ContainerListFn   func(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+	ContainerLogsFn   func(ctx context.Context, container string, options container.LogsOptions) (io.ReadCloser, error)
+	ContainerStatsFn  func(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error)
 }
 
 func (m *mockDockerClient) ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error) {
@@ -42,6 +60,27 @@ func (m *mockDockerClient) ContainerRemove(ctx context.Context, containerID stri
 func (m *mockDockerClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
 	return m.ContainerInspectFn(ctx, containerID)
 }
+func (m *mockDockerClient) ContainerAttach(ctx context.Context, containerID string, options container.AttachOptions) (types.HijackedResponse, error) {
+	return m.ContainerAttachFn(ctx, containerID, options)
+}
+func (m *mockDockerClient) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
+	return m.NetworkInspectFn(ctx, networkID, options)
+}
+func (m *mockDockerClient) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	return m.EventsFn(ctx, options)
+}
+func (m *mockDockerClient) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	return m.ContainerListFn(ctx, options)
+}
+func (m *mockDockerClient) ContainerLogs(ctx context.Context, container string, options container.LogsOptions) (io.ReadCloser, error) {
+	return m.ContainerLogsFn(ctx, container, options)
+}
+func (m *mockDockerClient) ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error) {
+	return m.ContainerStatsFn(ctx, containerID, stream)
+}
+func (m *mockDockerClient) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	return m.NetworkCreateFn(ctx, name, options)
+}
 
 func newMockDocker() *mockDockerClient {
 	return &mockDockerClient{
@@ -67,12 +106,18 @@ func newMockDocker() *mockDockerClient {
 				},
 			}, nil
 		},
+		NetworkInspectFn: func(_ context.Context, networkID string, _ network.InspectOptions) (network.Inspect, error) {
+			return network.Inspect{Name: networkID}, nil
+		},
+		NetworkCreateFn: func(_ context.Context, _ string, _ network.CreateOptions) (network.CreateResponse, error) {
+			return network.CreateResponse{ID: "test-network"}, nil
+		},
 	}
 }
 
 func TestContainerCreate(t *testing.T) {
 	mock := newMockDocker()
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	cfg := &ContainerConfig{
 		Image: "mcp-server:latest",
@@ -88,9 +133,81 @@ func TestContainerCreate(t *testing.T) {
 	}
 }
 
+func TestContainerCreateNamesAndLabels(t *testing.T) {
+	mock := newMockDocker()
+	var cfg *container.Config
+	var name string
+	mock.ContainerCreateFn = func(_ context.Context, c *container.Config, _ *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+		cfg, name = c, containerName
+		return container.CreateResponse{ID: "test-container-123"}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{InstanceID: "gw 1"})
+
+	_, err := mgr.Create(context.Background(), &ContainerConfig{
+		Name:   "server-1",
+		Image:  "mcp-server:latest",
+		Labels: map[string]string{labelServerID: "server-1", labelManaged: "false"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if name != "nexusclaw-gw-1-server-1" {
+		t.Errorf("expected name nexusclaw-gw-1-server-1, got %q", name)
+	}
+	if cfg.Labels[labelManaged] != "true" || cfg.Labels[labelInstance] != "gw-1" || cfg.Labels[labelServerID] != "server-1" {
+		t.Errorf("unexpected labels %v", cfg.Labels)
+	}
+}
+
+func TestContainerCreateReplacesStaleContainer(t *testing.T) {
+	mock := newMockDocker()
+	attempts := 0
+	mock.ContainerCreateFn = func(_ context.Context, _ *container.Config, _ *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, _ string) (container.CreateResponse, error) {
+		attempts++
+		if attempts == 1 {
+			return container.CreateResponse{}, cerrdefs.ErrConflict
+		}
+		return container.CreateResponse{ID: "test-container-123"}, nil
+	}
+	mock.ContainerInspectFn = func(_ context.Context, _ string) (types.ContainerJSON, error) {
+		return types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{Status: "exited"}},
+		}, nil
+	}
+	var removed string
+	mock.ContainerRemoveFn = func(_ context.Context, id string, _ container.RemoveOptions) error {
+		removed = id
+		return nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
+
+	id, err := mgr.Create(context.Background(), &ContainerConfig{Name: "server-1", Image: "mcp-server:latest"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if id != "test-container-123" || removed != "nexusclaw-default-server-1" {
+		t.Errorf("expected the stale container to be replaced, got id %q removed %q", id, removed)
+	}
+
+	// A running container with the name is left alone.
+	attempts = 0
+	removed = ""
+	mock.ContainerInspectFn = func(_ context.Context, _ string) (types.ContainerJSON, error) {
+		return types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{Running: true}},
+		}, nil
+	}
+	if _, err := mgr.Create(context.Background(), &ContainerConfig{Name: "server-1", Image: "mcp-server:latest"}); !cerrdefs.IsConflict(err) {
+		t.Errorf("expected a conflict for a running container, got %v", err)
+	}
+	if removed != "" {
+		t.Errorf("expected the running container to be kept, removed %q", removed)
+	}
+}
+
 func TestContainerCreateWithPorts(t *testing.T) {
 	mock := newMockDocker()
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	cfg := &ContainerConfig{
 		Image: "mcp-server:latest",
@@ -111,7 +228,7 @@ func TestContainerCreateImagePullFails(t *testing.T) {
 	mock.ImagePullFn = func(_ context.Context, _ string, _ image.PullOptions) (io.ReadCloser, error) {
 		return nil, errors.New("pull failed")
 	}
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	_, err := mgr.Create(context.Background(), &ContainerConfig{Image: "bad:image"})
 	if err == nil {
@@ -122,9 +239,169 @@ func TestContainerCreateImagePullFails(t *testing.T) {
 	}
 }
 
+func TestContainerCreateOnNetwork(t *testing.T) {
+	mock := newMockDocker()
+	mock.NetworkInspectFn = func(_ context.Context, _ string, _ network.InspectOptions) (network.Inspect, error) {
+		return network.Inspect{}, cerrdefs.ErrNotFound
+	}
+	var created string
+	mock.NetworkCreateFn = func(_ context.Context, name string, _ network.CreateOptions) (network.CreateResponse, error) {
+		created = name
+		return network.CreateResponse{ID: "net-1"}, nil
+	}
+	var hostCfg *container.HostConfig
+	var netCfg *network.NetworkingConfig
+	mock.ContainerCreateFn = func(_ context.Context, _ *container.Config, hc *container.HostConfig, nc *network.NetworkingConfig, _ *ocispec.Platform, _ string) (container.CreateResponse, error) {
+		hostCfg, netCfg = hc, nc
+		return container.CreateResponse{ID: "test-container-123"}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{Network: "nexusclaw"})
+
+	if _, err := mgr.Create(context.Background(), &ContainerConfig{Image: "mcp-server:latest"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created != "nexusclaw" {
+		t.Errorf("expected network nexusclaw to be created, got %q", created)
+	}
+	if hostCfg.NetworkMode != "nexusclaw" || hostCfg.PublishAllPorts {
+		t.Errorf("expected container on nexusclaw without published ports, got mode %q publish %v", hostCfg.NetworkMode, hostCfg.PublishAllPorts)
+	}
+	if _, ok := netCfg.EndpointsConfig["nexusclaw"]; !ok {
+		t.Error("expected endpoint config for nexusclaw")
+	}
+}
+
+func TestContainerCreateOnInternalNetwork(t *testing.T) {
+	mock := newMockDocker()
+	mock.NetworkInspectFn = func(_ context.Context, _ string, _ network.InspectOptions) (network.Inspect, error) {
+		return network.Inspect{}, cerrdefs.ErrNotFound
+	}
+	var opts network.CreateOptions
+	mock.NetworkCreateFn = func(_ context.Context, _ string, o network.CreateOptions) (network.CreateResponse, error) {
+		opts = o
+		return network.CreateResponse{ID: "net-1"}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{Network: "nexusclaw", Internal: true})
+
+	if _, err := mgr.Create(context.Background(), &ContainerConfig{Image: "mcp-server:latest"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !opts.Internal {
+		t.Error("expected the network to be created internal")
+	}
+}
+
+func TestContainerCreateRefusesExternalNetwork(t *testing.T) {
+	mock := newMockDocker()
+	mock.NetworkInspectFn = func(_ context.Context, _ string, _ network.InspectOptions) (network.Inspect, error) {
+		return network.Inspect{Name: "nexusclaw"}, nil
+	}
+	created := false
+	mock.ContainerCreateFn = func(_ context.Context, _ *container.Config, _ *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, _ string) (container.CreateResponse, error) {
+		created = true
+		return container.CreateResponse{ID: "test-container-123"}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{Network: "nexusclaw", Internal: true})
+
+	_, err := mgr.Create(context.Background(), &ContainerConfig{Image: "mcp-server:latest"})
+	if err == nil || !strings.Contains(err.Error(), "not internal") {
+		t.Fatalf("expected a not internal error, got %v", err)
+	}
+	if created {
+		t.Error("expected no container on a network with a route out")
+	}
+}
+
+func TestContainerEndpointPublishedPort(t *testing.T) {
+	mock := newMockDocker()
+	mock.ContainerInspectFn = func(_ context.Context, _ string) (types.ContainerJSON, error) {
+		return types.ContainerJSON{
+			NetworkSettings: &types.NetworkSettings{
+				NetworkSettingsBase: types.NetworkSettingsBase{
+					Ports: nat.PortMap{
+						"8080/tcp": {{HostIP: "0.0.0.0", HostPort: "49153"}},
+					},
+				},
+			},
+		}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
+
+	ep, err := mgr.Endpoint(context.Background(), "abc", "8080")
+	if err != nil {
+		t.Fatalf("Endpoint failed: %v", err)
+	}
+	if ep != "localhost:49153" {
+		t.Errorf("expected localhost:49153, got %s", ep)
+	}
+
+	if _, err := mgr.Endpoint(context.Background(), "abc", "9090"); err == nil {
+		t.Error("expected error for unpublished port")
+	}
+}
+
+func TestContainerEndpointNetworkAddress(t *testing.T) {
+	mock := newMockDocker()
+	mock.ContainerInspectFn = func(_ context.Context, _ string) (types.ContainerJSON, error) {
+		return types.ContainerJSON{
+			NetworkSettings: &types.NetworkSettings{
+				Networks: map[string]*network.EndpointSettings{
+					"bridge":    {IPAddress: "172.17.0.5"},
+					"nexusclaw": {IPAddress: "172.20.0.3"},
+				},
+			},
+		}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{Network: "nexusclaw"})
+
+	ep, err := mgr.Endpoint(context.Background(), "abc", "8080")
+	if err != nil {
+		t.Fatalf("Endpoint failed: %v", err)
+	}
+	if ep != "172.20.0.3:8080" {
+		t.Errorf("expected 172.20.0.3:8080, got %s", ep)
+	}
+}
+
+func TestContainerAttach(t *testing.T) {
+	var stream bytes.Buffer
+	stdcopy.NewStdWriter(&stream, stdcopy.Stderr).Write([]byte("starting\n"))
+	stdcopy.NewStdWriter(&stream, stdcopy.Stdout).Write([]byte("{\"id\":1}\n"))
+
+	gateway, peer := net.Pipe()
+	defer peer.Close()
+	mock := newMockDocker()
+	mock.ContainerAttachFn = func(_ context.Context, _ string, opts container.AttachOptions) (types.HijackedResponse, error) {
+		if !opts.Stdin || !opts.Stdout || !opts.Stream {
+			t.Errorf("expected a streaming stdin/stdout attach, got %+v", opts)
+		}
+		return types.HijackedResponse{Conn: gateway, Reader: bufio.NewReader(&stream)}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
+
+	rwc, err := mgr.Attach(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	out, _ := io.ReadAll(rwc)
+	if string(out) != "{\"id\":1}\n" {
+		t.Errorf("expected only stdout, got %q", out)
+	}
+
+	if _, err := mgr.Attach(context.Background(), "abc"); !errors.Is(err, ErrBackendBusy) {
+		t.Errorf("expected ErrBackendBusy for second attach, got %v", err)
+	}
+	rwc.Close()
+
+	stream.Reset()
+	if _, err := mgr.Attach(context.Background(), "abc"); err != nil {
+		t.Errorf("expected attach after close to succeed, got %v", err)
+	}
+}
+
 func TestContainerStart(t *testing.T) {
 	mock := newMockDocker()
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	if err := mgr.Start(context.Background(), "abc123"); err != nil {
 		t.Fatalf("Start failed: %v", err)
@@ -136,7 +413,7 @@ func TestContainerStartFails(t *testing.T) {
 	mock.ContainerStartFn = func(_ context.Context, _ string, _ container.StartOptions) error {
 		return errors.New("start failed")
 	}
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	err := mgr.Start(context.Background(), "abc123")
 	if err == nil {
@@ -146,7 +423,7 @@ func TestContainerStartFails(t *testing.T) {
 
 func TestContainerStop(t *testing.T) {
 	mock := newMockDocker()
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	if err := mgr.Stop(context.Background(), "abc123"); err != nil {
 		t.Fatalf("Stop failed: %v", err)
@@ -155,16 +432,107 @@ func TestContainerStop(t *testing.T) {
 
 func TestContainerRemove(t *testing.T) {
 	mock := newMockDocker()
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	if err := mgr.Remove(context.Background(), "abc123"); err != nil {
 		t.Fatalf("Remove failed: %v", err)
 	}
 }
 
+func TestContainerCreateMountsSecretFiles(t *testing.T) {
+	mock := newMockDocker()
+	var hostCfg *container.HostConfig
+	mock.ContainerCreateFn = func(_ context.Context, _ *container.Config, h *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, _ string) (container.CreateResponse, error) {
+		hostCfg = h
+		return container.CreateResponse{ID: "test-container-123"}, nil
+	}
+	mock.ContainerInspectFn = func(_ context.Context, _ string) (types.ContainerJSON, error) {
+		return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{Name: "/nexusclaw-default-server-1", HostConfig: hostCfg}}, nil
+	}
+	dir := t.TempDir()
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{SecretsDir: dir})
+
+	_, err := mgr.Create(context.Background(), &ContainerConfig{
+		Name:        "server-1",
+		Image:       "mcp-server:latest",
+		SecretFiles: map[string]string{"/run/secrets/token": "s3cr3t"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	src := filepath.Join(dir, "nexusclaw-default-server-1", "0")
+	if len(hostCfg.Binds) != 1 || hostCfg.Binds[0] != src+":/run/secrets/token:ro" {
+		t.Fatalf("expected a read-only bind of the secret file, got %v", hostCfg.Binds)
+	}
+	if data, err := os.ReadFile(src); err != nil || string(data) != "s3cr3t" {
+		t.Errorf("expected the secret file to hold the secret, got %q %v", data, err)
+	}
+
+	if err := mgr.UpdateSecrets(context.Background(), "test-container-123", map[string]string{"/run/secrets/token": "r0tated"}); err != nil {
+		t.Fatalf("UpdateSecrets failed: %v", err)
+	}
+	if data, err := os.ReadFile(src); err != nil || string(data) != "r0tated" {
+		t.Errorf("expected the secret file to be rewritten, got %q %v", data, err)
+	}
+	if info, err := os.Stat(src); err != nil {
+		t.Errorf("stat secret file: %v", err)
+	} else if info.Mode().Perm() != 0o444 {
+		t.Errorf("expected the secret file to stay read-only, got %v", info.Mode())
+	}
+	if err := mgr.UpdateSecrets(context.Background(), "test-container-123", map[string]string{"/run/secrets/other": "x"}); err == nil {
+		t.Error("expected an error updating a secret file the container lacks")
+	}
+
+	if err := mgr.Remove(context.Background(), "test-container-123"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(src)); !os.IsNotExist(err) {
+		t.Errorf("expected the secrets to be removed with the container, got %v", err)
+	}
+
+	noDir := newDockerManagerFromClient(mock, ContainerOptions{})
+	_, err = noDir.Create(context.Background(), &ContainerConfig{Name: "server-1", Image: "mcp-server:latest", SecretFiles: map[string]string{"/token": "x"}})
+	if err == nil || !strings.Contains(err.Error(), "no secrets directory") {
+		t.Errorf("expected an error without a secrets directory, got %v", err)
+	}
+}
+
+func TestContainerCreateKeepsSecretsOfRunningContainer(t *testing.T) {
+	mock := newMockDocker()
+	mock.ContainerCreateFn = func(_ context.Context, _ *container.Config, _ *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, _ string) (container.CreateResponse, error) {
+		return container.CreateResponse{}, cerrdefs.ErrConflict
+	}
+	mock.ContainerInspectFn = func(_ context.Context, _ string) (types.ContainerJSON, error) {
+		return types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{Running: true}},
+		}, nil
+	}
+	dir := t.TempDir()
+	live := filepath.Join(dir, "nexusclaw-default-server-1", "0")
+	if err := os.MkdirAll(filepath.Dir(live), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(live, []byte("live"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{SecretsDir: dir})
+
+	_, err := mgr.Create(context.Background(), &ContainerConfig{
+		Name:        "server-1",
+		Image:       "mcp-server:latest",
+		SecretFiles: map[string]string{"/run/secrets/token": "s3cr3t"},
+	})
+	if !cerrdefs.IsConflict(err) {
+		t.Fatalf("expected a conflict for a running container, got %v", err)
+	}
+	if data, err := os.ReadFile(live); err != nil || string(data) != "live" {
+		t.Errorf("expected the running container's secrets to be kept, got %q %v", data, err)
+	}
+}
+
 func TestContainerStatusRunning(t *testing.T) {
 	mock := newMockDocker()
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	status, err := mgr.Status(context.Background(), "abc123")
 	if err != nil {
@@ -184,7 +552,7 @@ func TestContainerStatusStopped(t *testing.T) {
 			},
 		}, nil
 	}
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	status, err := mgr.Status(context.Background(), "abc123")
 	if err != nil {
@@ -202,7 +570,7 @@ func TestContainerStatusNilState(t *testing.T) {
 			ContainerJSONBase: &types.ContainerJSONBase{State: nil},
 		}, nil
 	}
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	status, err := mgr.Status(context.Background(), "abc123")
 	if err != nil {
@@ -222,7 +590,7 @@ func TestContainerStatusDead(t *testing.T) {
 			},
 		}, nil
 	}
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	status, err := mgr.Status(context.Background(), "abc123")
 	if err != nil {
@@ -240,12 +608,13 @@ func TestContainerCreateWithResourceLimits(t *testing.T) {
 		capturedHost = hc
 		return container.CreateResponse{ID: "limited-123"}, nil
 	}
-	mgr := newDockerManagerFromClient(mock)
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
 
 	cfg := &ContainerConfig{
 		Image:       "mcp:latest",
 		MemoryLimit: 512 * 1024 * 1024,
 		CPULimit:    1.5,
+		DiskLimit:   1 << 30,
 	}
 
 	_, err := mgr.Create(context.Background(), cfg)
@@ -259,38 +628,47 @@ func TestContainerCreateWithResourceLimits(t *testing.T) {
 	if capturedHost.Resources.NanoCPUs != 1500000000 {
 		t.Errorf("expected 1.5 CPU in nanocpus, got %d", capturedHost.Resources.NanoCPUs)
 	}
-}
-
-func TestExtractEnvFromConfig(t *testing.T) {
-	config := map[string]any{
-		"env": map[string]any{
-			"DB_HOST": "localhost",
-			"DB_PORT": 5432,
-		},
-		"API_KEY":   "secret",
-		"localonly":  "should be ignored",
-	}
-
-	env := extractEnv(config)
-
-	if env["DB_HOST"] != "localhost" {
-		t.Errorf("expected DB_HOST=localhost, got %s", env["DB_HOST"])
-	}
-	if env["DB_PORT"] != "5432" {
-		t.Errorf("expected DB_PORT=5432, got %s", env["DB_PORT"])
-	}
-	if env["API_KEY"] != "secret" {
-		t.Errorf("expected API_KEY=secret, got %s", env["API_KEY"])
-	}
-	if _, ok := env["localonly"]; ok {
-		t.Error("expected localonly to be excluded (not UPPER_CASE with underscore)")
+	if capturedHost.StorageOpt["size"] != "1073741824" {
+		t.Errorf("expected a 1GiB storage size, got %v", capturedHost.StorageOpt)
 	}
 }
 
-func TestExtractEnvNilConfig(t *testing.T) {
-	env := extractEnv(nil)
-	if len(env) != 0 {
-		t.Errorf("expected empty env, got %d entries", len(env))
+func TestContainerStats(t *testing.T) {
+	mock := newMockDocker()
+	mock.ContainerStatsFn = func(_ context.Context, _ string, stream bool) (container.StatsResponseReader, error) {
+		if stream {
+			t.Error("expected a single stats sample")
+		}
+		return container.StatsResponseReader{Body: io.NopCloser(strings.NewReader(`{
+			"read": "2026-01-02T03:04:05Z",
+			"cpu_stats": {"cpu_usage": {"total_usage": 3000}, "system_cpu_usage": 20000, "online_cpus": 2},
+			"precpu_stats": {"cpu_usage": {"total_usage": 1000}, "system_cpu_usage": 10000},
+			"memory_stats": {"usage": 1000, "limit": 4000, "stats": {"inactive_file": 200}},
+			"pids_stats": {"current": 7, "limit": 256},
+			"networks": {"eth0": {"rx_bytes": 10, "tx_bytes": 20}, "eth1": {"rx_bytes": 1, "tx_bytes": 2}},
+			"blkio_stats": {"io_service_bytes_recursive": [{"op": "read", "value": 5}, {"op": "write", "value": 6}, {"op": "Read", "value": 1}]}
+		}`))}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
+
+	stats, err := mgr.Stats(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	want := ContainerStats{
+		CPUPercent:  40,
+		MemoryUsage: 800,
+		MemoryLimit: 4000,
+		Pids:        7,
+		PidsLimit:   256,
+		NetworkRx:   11,
+		NetworkTx:   22,
+		BlockRead:   6,
+		BlockWrite:  6,
+		ReadAt:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if *stats != want {
+		t.Errorf("expected %+v, got %+v", want, *stats)
 	}
 }
 
@@ -316,3 +694,159 @@ func TestParsePortConfigEmpty(t *testing.T) {
 		t.Error("expected nil results for empty ports")
 	}
 }
+
+func TestDockerEventsParsesExitCode(t *testing.T) {
+	mock := newMockDocker()
+	msgs := make(chan events.Message, 1)
+	mock.EventsFn = func(_ context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+		if !options.Filters.ExactMatch("event", string(events.ActionDie)) {
+			t.Errorf("expected a die event filter, got %v", options.Filters)
+		}
+		return msgs, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out, _ := mgr.Events(ctx)
+	msgs <- events.Message{Actor: events.Actor{ID: "abc123", Attributes: map[string]string{"exitCode": "137"}}}
+
+	ev := <-out
+	if ev.ContainerID != "abc123" || ev.ExitCode != 137 {
+		t.Errorf("expected abc123 exit 137, got %+v", ev)
+	}
+}
+
+func TestDockerListManagedContainers(t *testing.T) {
+	serverID := uuid.New()
+	mock := newMockDocker()
+	mock.ContainerListFn = func(_ context.Context, options container.ListOptions) ([]container.Summary, error) {
+		if !options.All || !options.Filters.ExactMatch("label", labelManaged+"=true") ||
+			!options.Filters.ExactMatch("label", labelInstance+"=default") {
+			t.Errorf("expected all containers with the managed label, got %+v", options)
+		}
+		return []container.Summary{
+			{ID: "a", Names: []string{"/nexusclaw-default-" + serverID.String()}, State: container.StateRunning, Labels: map[string]string{labelServerID: serverID.String()}},
+			{ID: "b", State: container.StateExited},
+		}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
+
+	list, err := mgr.List(context.Background())
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(list))
+	}
+	if list[0].ServerID != serverID || list[0].Status != StatusRunning || list[0].Name != "nexusclaw-default-"+serverID.String() {
+		t.Errorf("unexpected first container %+v", list[0])
+	}
+	if list[1].ServerID != uuid.Nil || list[1].Status != StatusStopped {
+		t.Errorf("unexpected second container %+v", list[1])
+	}
+}
+
+func TestDockerLogsDemultiplexes(t *testing.T) {
+	var raw bytes.Buffer
+	stdcopy.NewStdWriter(&raw, stdcopy.Stdout).Write([]byte("out line\n"))
+	stdcopy.NewStdWriter(&raw, stdcopy.Stderr).Write([]byte("err line\n"))
+
+	mock := newMockDocker()
+	mock.ContainerLogsFn = func(_ context.Context, id string, options container.LogsOptions) (io.ReadCloser, error) {
+		if id != "abc123" || !options.Follow || options.Tail != "10" || !options.ShowStdout || !options.ShowStderr {
+			t.Errorf("unexpected logs request %s %+v", id, options)
+		}
+		return io.NopCloser(&raw), nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
+
+	logs, err := mgr.Logs(context.Background(), "abc123", LogOptions{Follow: true, Tail: "10"})
+	if err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	defer logs.Close()
+	out, _ := io.ReadAll(logs)
+	if string(out) != "out line\nerr line\n" {
+		t.Errorf("expected demultiplexed output, got %q", out)
+	}
+}
+
+func TestContainerCreateAppliesSecurityProfile(t *testing.T) {
+	mock := newMockDocker()
+	var cfg *container.Config
+	var hostCfg *container.HostConfig
+	mock.ContainerCreateFn = func(_ context.Context, c *container.Config, hc *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, _ string) (container.CreateResponse, error) {
+		cfg, hostCfg = c, hc
+		return container.CreateResponse{ID: "test-container-123"}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{})
+
+	security := DefaultSecurityProfile()
+	if _, err := mgr.Create(context.Background(), &ContainerConfig{Image: "mcp-server:latest", Security: &security}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !hostCfg.ReadonlyRootfs || hostCfg.Tmpfs["/tmp"] == "" || cfg.User != "65534:65534" {
+		t.Errorf("expected a read-only rootfs with /tmp and a non-root user, got %+v user %q", hostCfg, cfg.User)
+	}
+	if len(hostCfg.CapDrop) != 1 || hostCfg.CapDrop[0] != "ALL" {
+		t.Errorf("expected all capabilities dropped, got %v", hostCfg.CapDrop)
+	}
+	if len(hostCfg.SecurityOpt) != 1 || hostCfg.SecurityOpt[0] != "no-new-privileges:true" {
+		t.Errorf("expected no-new-privileges, got %v", hostCfg.SecurityOpt)
+	}
+	if hostCfg.Resources.PidsLimit == nil || *hostCfg.Resources.PidsLimit != 256 {
+		t.Errorf("expected a pids limit of 256, got %v", hostCfg.Resources.PidsLimit)
+	}
+	if !hostCfg.PublishAllPorts {
+		t.Error("expected ports to be published")
+	}
+}
+
+func TestContainerCreateRefusesUnallowedSecurity(t *testing.T) {
+	mock := newMockDocker()
+	created := false
+	mock.ContainerCreateFn = func(_ context.Context, _ *container.Config, _ *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, _ string) (container.CreateResponse, error) {
+		created = true
+		return container.CreateResponse{ID: "test-container-123"}, nil
+	}
+	profiles := map[string]SecurityProfile{
+		"unconfined": {Seccomp: "unconfined"},
+		"root":       {User: "root"},
+		"uid 0":      {User: "0:0"},
+	}
+	for name, security := range profiles {
+		created = false
+		mgr := newDockerManagerFromClient(mock, ContainerOptions{})
+		if _, err := mgr.Create(context.Background(), &ContainerConfig{Image: "mcp-server:latest", Security: &security}); !errors.Is(err, ErrSecurityNotAllowed) {
+			t.Errorf("%s: expected ErrSecurityNotAllowed, got %v", name, err)
+		}
+		if created {
+			t.Errorf("%s: expected no container to be created", name)
+		}
+
+		mgr = newDockerManagerFromClient(mock, ContainerOptions{AllowUnconfined: true, AllowRoot: true})
+		if _, err := mgr.Create(context.Background(), &ContainerConfig{Image: "mcp-server:latest", Security: &security}); err != nil {
+			t.Errorf("%s: expected the operator to allow it, got %v", name, err)
+		}
+	}
+}
+
+func TestContainerCreateNoNetwork(t *testing.T) {
+	mock := newMockDocker()
+	var hostCfg *container.HostConfig
+	var netCfg *network.NetworkingConfig
+	mock.ContainerCreateFn = func(_ context.Context, _ *container.Config, hc *container.HostConfig, nc *network.NetworkingConfig, _ *ocispec.Platform, _ string) (container.CreateResponse, error) {
+		hostCfg, netCfg = hc, nc
+		return container.CreateResponse{ID: "test-container-123"}, nil
+	}
+	mgr := newDockerManagerFromClient(mock, ContainerOptions{Network: "nexusclaw"})
+
+	security := SecurityProfile{NoNetwork: true}
+	if _, err := mgr.Create(context.Background(), &ContainerConfig{Image: "mcp-server:latest", Stdin: true, Security: &security}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if hostCfg.NetworkMode != network.NetworkNone || netCfg != nil || hostCfg.PublishAllPorts {
+		t.Errorf("expected no network, got mode %q net %v publish %v", hostCfg.NetworkMode, netCfg, hostCfg.PublishAllPorts)
+	}
+}
@@ -0,0 +1,376 @@
+package nodes
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+)
+
+// hopHeaders are connection-specific headers a proxy must not forward.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// domainAllowed reports whether host and port match an allowlist entry. An
+// entry without a port only allows defaultPort, the standard port of the
+// request's scheme.
+func domainAllowed(allow []string, host, port, defaultPort string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, d := range allow {
+		d = strings.ToLower(d)
+		want := defaultPort
+		if h, p, err := net.SplitHostPort(d); err == nil {
+			d, want = h, p
+		}
+		if port != want {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(d, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == d {
+			return true
+		}
+	}
+	return false
+}
+
+// errInternalDestination is returned when an allowed name resolves to an
+// address inside the gateway's networks.
+var errInternalDestination = errors.New("destination address is not public")
+
+// refuseInternal is the dialer's Control hook. It sees the address actually
+// dialed, after name resolution, so a name cannot be pointed at the host,
+// the container network or a cloud metadata service.
+func refuseInternal(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", errInternalDestination, ip)
+	}
+	return nil
+}
+
+// EgressProxy is the HTTP(S) forward proxy through which server containers on
+// the gateway's internal network reach the outside world. Each server
+// authenticates with credentials derived from its ID, may only reach the
+// domains on its allowlist, and every request it makes is written to the
+// audit log.
+type EgressProxy struct {
+	// Alerter, if set, raises an alert for every refused request. It must
+	// be set before the proxy serves.
+	Alerter sentry.Alerter
+
+	repo   Repository
+	audit  sentry.AuditLogger
+	secret []byte
+	url    *url.URL
+
+	dialer    net.Dialer
+	transport http.RoundTripper
+}
+
+// NewEgressProxy creates an egress proxy. proxyURL is the proxy's address as
+// seen from containers, e.g. "http://nexusclaw:3128"; secret keys the
+// per-server proxy credentials.
+func NewEgressProxy(repo Repository, audit sentry.AuditLogger, secret []byte, proxyURL string) (*EgressProxy, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Scheme != "http" || u.Host == "" {
+		return nil, fmt.Errorf("egress proxy URL must be http://host:port, got %q", proxyURL)
+	}
+	p := &EgressProxy{
+		repo:   repo,
+		audit:  audit,
+		secret: secret,
+		url:    u,
+		dialer: net.Dialer{Timeout: 10 * time.Second, Control: refuseInternal},
+	}
+	p.transport = &http.Transport{
+		DialContext:           p.dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return p, nil
+}
+
+// Env returns the environment variables that route a server's outbound HTTP
+// and HTTPS traffic through the proxy.
+func (p *EgressProxy) Env(serverID uuid.UUID) map[string]string {
+	u := *p.url
+	u.User = url.UserPassword(serverID.String(), p.token(serverID))
+	proxy := u.String()
+	return map[string]string{
+		"HTTP_PROXY":  proxy,
+		"HTTPS_PROXY": proxy,
+		"http_proxy":  proxy,
+		"https_proxy": proxy,
+	}
+}
+
+// token is the proxy password of a server.
+func (p *EgressProxy) token(serverID uuid.UUID) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte("egress:" + serverID.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// authenticate returns the server whose credentials the request carries.
+func (p *EgressProxy) authenticate(r *http.Request) (*MCPServer, bool) {
+	auth, ok := strings.CutPrefix(r.Header.Get("Proxy-Authorization"), "Basic ")
+	if !ok {
+		return nil, false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return nil, false
+	}
+	user, pass, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, false
+	}
+	id, err := uuid.Parse(user)
+	if err != nil || !hmac.Equal([]byte(pass), []byte(p.token(id))) {
+		return nil, false
+	}
+	server, err := p.repo.GetServer(r.Context(), id)
+	if err != nil {
+		return nil, false
+	}
+	return server, true
+}
+
+func (p *EgressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server, ok := p.authenticate(r)
+	if !ok {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="nexusclaw"`)
+		http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+		return
+	}
+
+	host, port, target := r.URL.Hostname(), r.URL.Port(), r.URL.Scheme+"://"+r.URL.Host+r.URL.Path
+	defaultPort := "80"
+	if r.Method == http.MethodConnect {
+		host, port, target = hostOnly(r.Host), portOnly(r.Host), r.Host
+		defaultPort = "443"
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	allowed := domainAllowed(server.Config.Egress.Allow, host, port, defaultPort)
+	p.log(r.Context(), server, r.Method, host, target, allowed)
+	if !allowed {
+		p.alert(r.Context(), server, host, "not on the allowlist")
+		http.Error(w, "destination not allowed", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r, server, host)
+		return
+	}
+	p.forward(w, r, server, host)
+}
+
+// tunnel relays a CONNECT request's connection to its destination.
+func (p *EgressProxy) tunnel(w http.ResponseWriter, r *http.Request, server *MCPServer, host string) {
+	upstream, err := p.dialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		p.dialFailed(w, r, server, host, err)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunneling unsupported", http.StatusInternalServerError)
+		return
+	}
+	client, rw, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	rw.WriteString("HTTP/1.1 200 Connection Established\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	relay := func(dst net.Conn, src io.Reader) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// Half-close so the other direction can finish.
+		if tc, ok := dst.(interface{ CloseWrite() error }); ok {
+			tc.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	go relay(upstream, rw.Reader)
+	go relay(client, upstream)
+	wg.Wait()
+	client.Close()
+	upstream.Close()
+}
+
+// forward sends a plain HTTP request on to its destination.
+func (p *EgressProxy) forward(w http.ResponseWriter, r *http.Request, server *MCPServer, host string) {
+	if r.URL.Scheme != "http" || r.URL.Host == "" {
+		http.Error(w, "absolute http URL required", http.StatusBadRequest)
+		return
+	}
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	removeHopHeaders(out.Header)
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		p.dialFailed(w, r, server, host, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// dialFailed reports an error reaching the destination.
+func (p *EgressProxy) dialFailed(w http.ResponseWriter, r *http.Request, server *MCPServer, host string, err error) {
+	if errors.Is(err, errInternalDestination) {
+		p.alert(r.Context(), server, host, "resolves to an internal address")
+		http.Error(w, "destination not allowed", http.StatusForbidden)
+		return
+	}
+	http.Error(w, "reaching destination failed", http.StatusBadGateway)
+}
+
+// alert raises an alert for a refused request. Unlike rule and anomaly
+// alerts, which clients trigger, it is about what the server itself did, so
+// it counts towards quarantine.
+func (p *EgressProxy) alert(ctx context.Context, server *MCPServer, host, why string) {
+	if p.Alerter == nil {
+		return
+	}
+	serverID := server.ID
+	alert := &sentry.Alert{
+		UserID:   server.OwnerID,
+		ServerID: &serverID,
+		Kind:     sentry.AlertKindEgress,
+		Message:  fmt.Sprintf("server %s tried to reach %s, which %s", server.Name, host, why),
+		Severity: sentry.SeverityHigh,
+		Metadata: map[string]any{"host": host},
+	}
+	if err := p.Alerter.Raise(context.WithoutCancel(ctx), alert); err != nil {
+		slog.Error("raising egress alert", "server_id", server.ID, "error", err)
+	}
+}
+
+// log writes an outbound request to the audit log.
+func (p *EgressProxy) log(ctx context.Context, server *MCPServer, method, host, target string, allowed bool) {
+	action := "egress.request"
+	if !allowed {
+		action = "egress.blocked"
+		slog.Warn("blocked egress request", "server_id", server.ID, "host", host)
+	}
+	entry := &sentry.AuditEntry{
+		UserID:   &server.OwnerID,
+		Action:   action,
+		Resource: server.ID.String() + "/" + host,
+		Metadata: map[string]any{
+			"server_id": server.ID.String(),
+			"method":    method,
+			"host":      host,
+			"target":    target,
+			"allowed":   allowed,
+		},
+	}
+	if err := p.audit.Log(context.WithoutCancel(ctx), entry); err != nil {
+		slog.Error("writing egress audit entry", "error", err)
+	}
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+}
+
+// hostOnly strips the port from a host:port authority.
+func hostOnly(authority string) string {
+	host, _, err := net.SplitHostPort(authority)
+	if err != nil {
+		return authority
+	}
+	return host
+}
+
+// portOnly returns the port of a host:port authority, or "" if it has none.
+func portOnly(authority string) string {
+	_, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		return ""
+	}
+	return port
+}
+
+// ServeEgress runs the proxy on addr until ctx is done.
+func ServeEgress(ctx context.Context, addr string, proxy *EgressProxy) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening for egress proxy: %w", err)
+	}
+	srv := &http.Server{Handler: proxy, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
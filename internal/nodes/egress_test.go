@@ -0,0 +1,255 @@
+package nodes
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+)
+
+func TestDomainAllowed(t *testing.T) {
+	allow := []string{"api.github.com", "*.example.com", "git.example.com:8443"}
+	tests := []struct {
+		host, port string
+		want       bool
+	}{
+		{"api.github.com", "443", true},
+		{"API.GitHub.com.", "443", true},
+		{"api.github.com", "22", false},
+		{"github.com", "443", false},
+		{"files.example.com", "443", true},
+		{"a.b.example.com", "443", true},
+		{"example.com", "443", false},
+		{"evilexample.com", "443", false},
+		{"git.example.com", "8443", true},
+		{"files.example.com", "8443", false},
+	}
+	for _, tt := range tests {
+		if got := domainAllowed(allow, tt.host, tt.port, "443"); got != tt.want {
+			t.Errorf("domainAllowed(%q, %q) = %v, want %v", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+// newTestEgressProxy serves an egress proxy for server, which may reach the
+// domains in allow. Unlike in production, the proxy may dial loopback
+// addresses so that it can reach test backends.
+func newTestEgressProxy(t *testing.T, server *MCPServer, allow ...string) (*EgressProxy, *recordingAudit, string) {
+	t.Helper()
+	server.Config.Egress.Allow = allow
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			if id != server.ID {
+				return nil, ErrNotFound
+			}
+			return server, nil
+		},
+	}
+	audit := &recordingAudit{}
+
+	srv := httptest.NewUnstartedServer(nil)
+	proxyURL := "http://" + srv.Listener.Addr().String()
+	proxy, err := NewEgressProxy(repo, audit, []byte("test-secret"), proxyURL)
+	if err != nil {
+		t.Fatalf("NewEgressProxy: %v", err)
+	}
+	proxy.dialer.Control = nil
+	srv.Config.Handler = proxy
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return proxy, audit, proxyURL
+}
+
+// proxiedClient returns a client that sends its requests through proxy.
+func proxiedClient(t *testing.T, proxy string) *http.Client {
+	t.Helper()
+	u, err := url.Parse(proxy)
+	if err != nil {
+		t.Fatalf("parsing proxy URL: %v", err)
+	}
+	return &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(u),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+}
+
+func TestEgressProxyRequiresCredentials(t *testing.T) {
+	server := &MCPServer{ID: uuid.New()}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer backend.Close()
+	_, audit, proxyURL := newTestEgressProxy(t, server, backend.Listener.Addr().String())
+
+	resp, err := proxiedClient(t, proxyURL).Get(backend.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		t.Errorf("expected 407 without credentials, got %d", resp.StatusCode)
+	}
+
+	u, _ := url.Parse(proxyURL)
+	u.User = url.UserPassword(server.ID.String(), "wrong")
+	resp, err = proxiedClient(t, u.String()).Get(backend.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		t.Errorf("expected 407 for a wrong token, got %d", resp.StatusCode)
+	}
+	if len(audit.entries) != 0 {
+		t.Errorf("expected unauthenticated requests not to be audited, got %d entries", len(audit.entries))
+	}
+}
+
+func TestEgressProxyForwardsAllowedHTTP(t *testing.T) {
+	server := &MCPServer{ID: uuid.New(), OwnerID: uuid.New()}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "" {
+			t.Error("expected proxy credentials not to reach the destination")
+		}
+		io.WriteString(w, "hello")
+	}))
+	defer backend.Close()
+	proxy, audit, _ := newTestEgressProxy(t, server, backend.Listener.Addr().String())
+
+	client := proxiedClient(t, proxy.Env(server.ID)["HTTP_PROXY"])
+	resp, err := client.Get(backend.URL + "/data?q=1")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatalf("expected 200 hello, got %d %q", resp.StatusCode, body)
+	}
+
+	if len(audit.entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(audit.entries))
+	}
+	e := audit.entries[0]
+	if e.Action != "egress.request" || e.Metadata["host"] != "127.0.0.1" || e.Metadata["allowed"] != true {
+		t.Errorf("unexpected audit entry: %s %v", e.Action, e.Metadata)
+	}
+	if e.Metadata["target"] != backend.URL+"/data" {
+		t.Errorf("expected the target without query, got %v", e.Metadata["target"])
+	}
+	if e.UserID == nil || *e.UserID != server.OwnerID {
+		t.Error("expected the entry to be attributed to the server's owner")
+	}
+}
+
+func TestEgressProxyBlocksUnlistedDomains(t *testing.T) {
+	server := &MCPServer{ID: uuid.New()}
+	proxy, audit, _ := newTestEgressProxy(t, server, "api.github.com")
+	alerter := &recordingAlerter{}
+	proxy.Alerter = alerter
+	reached := false
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		reached = true
+	}))
+	defer backend.Close()
+
+	client := proxiedClient(t, proxy.Env(server.ID)["HTTPS_PROXY"])
+	if _, err := client.Get(backend.URL); err == nil {
+		t.Fatal("expected the CONNECT to be refused")
+	}
+	if reached {
+		t.Error("expected the destination not to be reached")
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != "egress.blocked" {
+		t.Fatalf("expected one egress.blocked entry, got %v", audit.entries)
+	}
+	if audit.entries[0].Metadata["method"] != http.MethodConnect {
+		t.Errorf("expected a CONNECT entry, got %v", audit.entries[0].Metadata["method"])
+	}
+	if len(alerter.alerts) != 1 || alerter.alerts[0].Kind != sentry.AlertKindEgress || *alerter.alerts[0].ServerID != server.ID {
+		t.Errorf("expected one egress alert about the server, got %v", alerter.alerts)
+	}
+}
+
+func TestEgressProxyTunnelsAllowedHTTPS(t *testing.T) {
+	server := &MCPServer{ID: uuid.New()}
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "secure")
+	}))
+	defer backend.Close()
+	proxy, audit, _ := newTestEgressProxy(t, server, backend.Listener.Addr().String())
+
+	client := proxiedClient(t, proxy.Env(server.ID)["HTTPS_PROXY"])
+	resp, err := client.Get(backend.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "secure" {
+		t.Errorf("expected body secure, got %q", body)
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != "egress.request" {
+		t.Fatalf("expected one egress.request entry, got %v", audit.entries)
+	}
+}
+
+func TestEgressProxyLimitsTunnelPorts(t *testing.T) {
+	server := &MCPServer{ID: uuid.New()}
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("expected the destination not to be reached")
+	}))
+	defer backend.Close()
+	// Without a port, the entry only allows 443.
+	proxy, audit, _ := newTestEgressProxy(t, server, "127.0.0.1")
+
+	client := proxiedClient(t, proxy.Env(server.ID)["HTTPS_PROXY"])
+	if _, err := client.Get(backend.URL); err == nil {
+		t.Fatal("expected the CONNECT to another port to be refused")
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != "egress.blocked" {
+		t.Fatalf("expected one egress.blocked entry, got %v", audit.entries)
+	}
+}
+
+func TestEgressProxyRefusesInternalAddresses(t *testing.T) {
+	server := &MCPServer{ID: uuid.New()}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("expected the destination not to be reached")
+	}))
+	defer backend.Close()
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	proxy, _, _ := newTestEgressProxy(t, server, "localhost:"+port)
+	proxy.dialer.Control = refuseInternal
+	alerter := &recordingAlerter{}
+	proxy.Alerter = alerter
+
+	client := proxiedClient(t, proxy.Env(server.ID)["HTTP_PROXY"])
+	resp, err := client.Get("http://localhost:" + port)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a name resolving to loopback, got %d", resp.StatusCode)
+	}
+	if len(alerter.alerts) != 1 || alerter.alerts[0].Kind != sentry.AlertKindEgress {
+		t.Errorf("expected one egress alert, got %v", alerter.alerts)
+	}
+
+	for _, addr := range []string{"127.0.0.1:443", "10.0.0.1:443", "169.254.169.254:80", "[::1]:443", "[::ffff:192.168.1.1]:443", "0.0.0.0:80"} {
+		if err := refuseInternal("tcp", addr, nil); !errors.Is(err, errInternalDestination) {
+			t.Errorf("refuseInternal(%s) = %v, want errInternalDestination", addr, err)
+		}
+	}
+	if err := refuseInternal("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("expected a public address to be allowed, got %v", err)
+	}
+}
@@ -1,9 +1,16 @@
 package nodes
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -11,6 +18,7 @@ import (
 
 	mw "github.com/kapella-hub/NexusClaw/internal/platform/middleware"
 	"github.com/kapella-hub/NexusClaw/internal/platform/respond"
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
 )
 
 var wsUpgrader = websocket.Upgrader{
@@ -23,6 +31,22 @@ type Handler struct {
 	Registry    Registry
 	AuthMW      func(http.Handler) http.Handler
 	RateLimiter *RateLimiter
+	Enforcer    sentry.Enforcer
+	Audit       sentry.AuditLogger
+	Sessions    *SessionRegistry
+	Schemas     *SchemaCache
+
+	// HTTPSessions enables the Streamable HTTP MCP endpoint when set.
+	HTTPSessions *HTTPSessions
+	// Pool multiplexes clients of servers configured as pooled over one
+	// backend session each. Without it every client opens its own.
+	Pool *BackendPool
+	// Scaler is told of proxied traffic and wakes servers that were scaled
+	// to zero. Without it such servers stay stopped until started by hand.
+	Scaler *Scaler
+	// Admins may run gateway-wide operations such as removing orphaned
+	// containers. Without any, those endpoints are refused.
+	Admins []uuid.UUID
 }
 
 // Routes returns a chi.Router with all MCP server routes mounted.
@@ -36,11 +60,25 @@ func (h *Handler) Routes() chi.Router {
 	r.Get("/", h.ListServers)
 	r.Post("/", h.RegisterServer)
 	r.Get("/discover", h.DiscoverServers)
+	r.Get("/gateway", h.ConnectGateway)
+	r.Post("/gc", h.RemoveOrphans)
 	r.Get("/{id}", h.GetServer)
+	r.Patch("/{id}", h.UpdateServer)
 	r.Delete("/{id}", h.RemoveServer)
+	r.Get("/{id}/revisions", h.ListRevisions)
+	r.Get("/{id}/revisions/{revision}", h.GetRevision)
+	r.Post("/{id}/rollback", h.RollbackServer)
 	r.Post("/{id}/start", h.StartServer)
 	r.Post("/{id}/stop", h.StopServer)
+	r.Get("/{id}/logs", h.GetLogs)
+	r.Get("/{id}/stats", h.GetStats)
 	r.Get("/{id}/ws", h.ConnectWebSocket)
+	r.Post("/{id}/mcp", h.PostMCP)
+	r.Get("/{id}/mcp", h.GetMCP)
+	r.Delete("/{id}/mcp", h.DeleteMCP)
+	r.Get("/{id}/policies", h.ListAccessPolicies)
+	r.Put("/{id}/policies/{principal}", h.PutAccessPolicy)
+	r.Delete("/{id}/policies/{principal}", h.DeleteAccessPolicy)
 
 	return r
 }
@@ -75,14 +113,31 @@ func (h *Handler) DiscoverServers(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) RegisterServer(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name   string         `json:"name"`
-		Image  string         `json:"image"`
-		Config map[string]any `json:"config"`
+		Name   string          `json:"name"`
+		Image  string          `json:"image"`
+		Config json.RawMessage `json:"config"`
 	}
 	if !respond.Decode(w, r, &req) {
 		return
 	}
 
+	var fields []FieldError
+	if req.Name == "" {
+		fields = append(fields, FieldError{Field: "name", Message: "is required"})
+	}
+	if req.Image == "" {
+		fields = append(fields, FieldError{Field: "image", Message: "is required"})
+	}
+	spec, err := ParseSpec(req.Config)
+	var specErr *SpecError
+	if errors.As(err, &specErr) {
+		fields = append(fields, specErr.Fields...)
+	}
+	if len(fields) > 0 {
+		respondSpecError(w, &SpecError{Fields: fields})
+		return
+	}
+
 	ownerID, err := uuid.Parse(mw.GetUserID(r.Context()))
 	if err != nil {
 		respond.Error(w, http.StatusBadRequest, "invalid user id")
@@ -93,10 +148,18 @@ func (h *Handler) RegisterServer(w http.ResponseWriter, r *http.Request) {
 		OwnerID: ownerID,
 		Name:    req.Name,
 		Image:   req.Image,
-		Config:  req.Config,
+		Config:  spec,
 	}
 
 	if err := h.Service.RegisterServer(r.Context(), server); err != nil {
+		if errors.As(err, &specErr) {
+			respondSpecError(w, specErr)
+			return
+		}
+		if errors.Is(err, ErrLimitExceeded) {
+			respond.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		respond.Error(w, http.StatusInternalServerError, "failed to register server")
 		return
 	}
@@ -120,6 +183,131 @@ func (h *Handler) GetServer(w http.ResponseWriter, r *http.Request) {
 	respond.JSON(w, http.StatusOK, server)
 }
 
+// UpdateServer changes a server's image or config, replacing the whole config
+// if one is given. A running server is switched over to the new revision
+// before the response is sent.
+func (h *Handler) UpdateServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.ownServerID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Image  string          `json:"image"`
+		Config json.RawMessage `json:"config"`
+	}
+	if !respond.Decode(w, r, &req) {
+		return
+	}
+	if req.Image == "" && len(req.Config) == 0 {
+		respond.Error(w, http.StatusBadRequest, "image or config is required")
+		return
+	}
+
+	userID, err := uuid.Parse(mw.GetUserID(r.Context()))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	update := &ServerUpdate{Image: req.Image, UpdatedBy: userID}
+	if len(req.Config) > 0 {
+		spec, err := ParseSpec(req.Config)
+		var specErr *SpecError
+		if errors.As(err, &specErr) {
+			respondSpecError(w, specErr)
+			return
+		}
+		update.Config = &spec
+	}
+
+	server, err := h.Service.UpdateServer(r.Context(), id, update)
+	if err != nil {
+		var specErr *SpecError
+		if errors.As(err, &specErr) {
+			respondSpecError(w, specErr)
+			return
+		}
+		handleServiceError(w, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, server)
+}
+
+func (h *Handler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.ownServerID(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.Service.ListServerRevisions(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, revisions)
+}
+
+func (h *Handler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.ownServerID(w, r)
+	if !ok {
+		return
+	}
+	revision, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil || revision < 1 {
+		respond.Error(w, http.StatusBadRequest, "invalid revision")
+		return
+	}
+
+	rev, err := h.Service.GetServerRevision(r.Context(), id, revision)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, rev)
+}
+
+// RollbackServer restores the image and config of an earlier revision as a
+// new revision, switching a running server over like UpdateServer.
+func (h *Handler) RollbackServer(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.ownServerID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Revision int `json:"revision"`
+	}
+	if !respond.Decode(w, r, &req) {
+		return
+	}
+	if req.Revision < 1 {
+		respond.Error(w, http.StatusBadRequest, "revision is required")
+		return
+	}
+
+	userID, err := uuid.Parse(mw.GetUserID(r.Context()))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	server, err := h.Service.RollbackServer(r.Context(), id, req.Revision, userID)
+	if err != nil {
+		var specErr *SpecError
+		if errors.As(err, &specErr) {
+			respondSpecError(w, specErr)
+			return
+		}
+		handleServiceError(w, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, server)
+}
+
 func (h *Handler) RemoveServer(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
@@ -165,6 +353,93 @@ func (h *Handler) StopServer(w http.ResponseWriter, r *http.Request) {
 	respond.JSON(w, http.StatusOK, map[string]string{"status": "stopped"})
 }
 
+// GetStats returns the current resource usage of a server's container.
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.ownedServerID(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := h.Service.Stats(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respond.JSON(w, http.StatusOK, stats)
+}
+
+// GetLogs streams the output of a server's container with secrets redacted:
+// as SSE if the client accepts it, and as chunked plain text otherwise.
+func (h *Handler) GetLogs(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseLogOptions(r.URL.Query())
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Redaction is best-effort, so only the owner may read the output.
+	id, ok := h.ownedServerID(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respond.Error(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	logs, err := h.Service.Logs(r.Context(), id, opts)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	defer logs.Close()
+
+	sse := acceptsEventStream(r)
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var seq int
+	err = redactLines(logs, func(line string) error {
+		var err error
+		if sse {
+			seq++
+			_, err = fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", seq, line)
+		} else {
+			_, err = fmt.Fprintln(w, line)
+		}
+		flusher.Flush()
+		return err
+	})
+	if err != nil && r.Context().Err() == nil {
+		slog.Warn("streaming server logs", "server_id", id, "error", err)
+	}
+}
+
+// RemoveOrphans removes containers left behind by deleted or restarted
+// servers.
+func (h *Handler) RemoveOrphans(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		respond.Error(w, http.StatusForbidden, "only an admin can do this")
+		return
+	}
+
+	removed, err := h.Service.RemoveOrphans(r.Context())
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]any{"removed": removed})
+}
+
 func (h *Handler) ConnectWebSocket(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
@@ -172,8 +447,14 @@ func (h *Handler) ConnectWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate server is running.
-	if err := h.Service.ConnectWebSocket(r.Context(), id, w, r); err != nil {
+	// Validate server is running, or scaled to zero so it can be woken.
+	err = h.Service.ConnectWebSocket(r.Context(), id, w, r)
+	if errors.Is(err, ErrContainerNotAvailable) && h.Scaler != nil {
+		if server, getErr := h.Service.GetServer(r.Context(), id); getErr == nil && asleep(server) {
+			err = nil
+		}
+	}
+	if err != nil {
 		handleServiceError(w, err)
 		return
 	}
@@ -186,7 +467,7 @@ func (h *Handler) ConnectWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer clientConn.Close()
 
-	// Get server to find container port for backend connection.
+	// Get server to find the container endpoint for the backend connection.
 	server, err := h.Service.GetServer(r.Context(), id)
 	if err != nil {
 		clientConn.WriteMessage(websocket.CloseMessage,
@@ -194,68 +475,521 @@ func (h *Handler) ConnectWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine backend WebSocket URL from server config.
-	backendPort := "8080"
-	if p, ok := server.Config["ws_port"].(string); ok {
-		backendPort = p
-	}
-	backendURL := "ws://localhost:" + backendPort
-
-	// Connect to backend MCP server.
-	backendConn, _, err := websocket.DefaultDialer.Dial(backendURL, nil)
+	userID, _ := uuid.Parse(mw.GetUserID(r.Context()))
+	session, err := h.newProxySession(r.Context(), server, userID)
 	if err != nil {
 		clientConn.WriteMessage(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "backend connection failed"))
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "access policy unavailable"))
 		return
 	}
-	defer backendConn.Close()
+	session.client = &wsConn{Conn: clientConn}
 
-	// Bidirectional proxy.
-	done := make(chan struct{})
+	// The session survives idle stops of the server and its move to a new
+	// container when an update is rolled out, reconnecting when the client
+	// next sends a message.
+	session.wake = func(ctx context.Context) (*MCPServer, BackendConn, error) {
+		server, err := h.runningServer(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		backend, err := h.openBackend(ctx, server, userID)
+		return server, backend, err
+	}
+	session.lookup = func(ctx context.Context) (*MCPServer, error) {
+		return h.Service.GetServer(ctx, id)
+	}
+	session.resumed = make(chan BackendConn, 1)
+	if h.Scaler != nil && asleep(server) {
+		session.suspended = true
+	}
 
-	// Client → Backend
-	go func() {
-		defer close(done)
-		for {
-			mt, msg, err := clientConn.ReadMessage()
-			if err != nil {
-				return
-			}
+	// Connect to backend MCP server over its transport.
+	if !session.suspended {
+		backendConn, err := h.openBackend(r.Context(), server, userID)
+		if errors.Is(err, ErrBackendBusy) {
+			clientConn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "server busy"))
+			return
+		}
+		if err != nil {
+			clientConn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "backend connection failed"))
+			return
+		}
+		defer backendConn.Close()
+		session.backend = backendConn
+		session.containerID = server.ContainerID
+	}
 
-			if h.RateLimiter != nil && !h.RateLimiter.Allow(server.ID) {
-				clientConn.WriteMessage(mt, []byte(`{"jsonrpc":"2.0","error":{"code":-32005,"message":"Rate limit exceeded"},"id":null}`))
-				continue
-			}
+	if h.Sessions != nil {
+		defer h.Sessions.add(server.ID, session)()
+	}
+	session.run(r.Context())
+}
 
-			if err := backendConn.WriteMessage(mt, msg); err != nil {
-				return
-			}
+// runningServer returns the server if it is running, waking it first if it
+// was scaled to zero.
+func (h *Handler) runningServer(ctx context.Context, id uuid.UUID) (*MCPServer, error) {
+	if h.Scaler != nil {
+		return h.Scaler.Wake(ctx, id)
+	}
+	server, err := h.Service.GetServer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if server.Status != StatusRunning {
+		return nil, ErrContainerNotAvailable
+	}
+	return server, nil
+}
+
+// ConnectGateway serves a WebSocket presenting the servers listed in the
+// servers query parameter (comma-separated IDs) as one MCP server, with tool,
+// resource and prompt names prefixed by their server's name.
+func (h *Handler) ConnectGateway(w http.ResponseWriter, r *http.Request) {
+	var ids []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, s := range strings.Split(r.URL.Query().Get("servers"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
 		}
-	}()
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respond.Error(w, http.StatusBadRequest, "invalid server id "+s)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		respond.Error(w, http.StatusBadRequest, "servers is required")
+		return
+	}
+
+	// Validate every server is running, waking those scaled to zero, and has
+	// a distinct prefix.
+	servers := make([]*MCPServer, 0, len(ids))
+	prefixes := make(map[string]bool)
+	for _, id := range ids {
+		server, err := h.connectableServer(w, r, id)
+		if err != nil {
+			handleServiceError(w, err)
+			return
+		}
+		prefix := namespacePrefix(server.Name)
+		if prefix == "" || prefixes[prefix] {
+			respond.Error(w, http.StatusBadRequest, "servers must have distinct names")
+			return
+		}
+		prefixes[prefix] = true
+		servers = append(servers, server)
+	}
+
+	clientConn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Error("websocket upgrade failed", "error", err)
+		return
+	}
+	defer clientConn.Close()
 
-	// Backend → Client
-	for {
-		mt, msg, err := backendConn.ReadMessage()
+	userID, _ := uuid.Parse(mw.GetUserID(r.Context()))
+	session := &aggregateSession{client: &wsConn{Conn: clientConn}}
+	defer func() {
+		for _, m := range session.members {
+			m.proxy.backend.Close()
+		}
+	}()
+	for _, server := range servers {
+		proxy, err := h.newProxySession(r.Context(), server, userID)
+		if err != nil {
+			clientConn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "access policy unavailable"))
+			return
+		}
+		backendConn, err := h.openBackend(r.Context(), server, userID)
+		if errors.Is(err, ErrBackendBusy) {
+			clientConn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "server "+server.Name+" busy"))
+			return
+		}
 		if err != nil {
-			break
+			clientConn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "backend connection failed"))
+			return
 		}
-		if err := clientConn.WriteMessage(mt, msg); err != nil {
-			break
+		proxy.backend = backendConn
+		session.members = append(session.members, &aggregateMember{prefix: namespacePrefix(server.Name), proxy: proxy})
+	}
+
+	if h.Sessions != nil {
+		for _, server := range servers {
+			defer h.Sessions.add(server.ID, session)()
 		}
 	}
+	session.run(r.Context())
+}
+
+// connectableServer validates that a client may connect to the server and
+// returns it, first waking it if it was scaled to zero.
+func (h *Handler) connectableServer(w http.ResponseWriter, r *http.Request, id uuid.UUID) (*MCPServer, error) {
+	err := h.Service.ConnectWebSocket(r.Context(), id, w, r)
+	if errors.Is(err, ErrContainerNotAvailable) && h.Scaler != nil {
+		return h.Scaler.Wake(r.Context(), id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.Service.GetServer(r.Context(), id)
+}
+
+// openBackend connects to the server's backend: through the pool for pooled
+// servers, directly otherwise.
+func (h *Handler) openBackend(ctx context.Context, server *MCPServer, userID uuid.UUID) (BackendConn, error) {
+	if h.Pool != nil && serverPooled(server) {
+		return h.Pool.Connect(ctx, server, userID)
+	}
+	return h.Service.OpenBackend(ctx, server)
+}
+
+// newProxySession builds the policy, validation and enforcement core shared by
+// every client transport. The caller attaches the client and backend.
+func (h *Handler) newProxySession(ctx context.Context, server *MCPServer, userID uuid.UUID) (*proxySession, error) {
+	policy, err := h.Service.EffectivePolicy(ctx, server.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &proxySession{
+		server:   server,
+		userID:   userID,
+		limiter:  h.RateLimiter,
+		policy:   policy,
+		enforcer: h.Enforcer,
+		audit:    h.Audit,
+	}
+	if h.Scaler != nil && scalesToZero(server) {
+		session.scaler = h.Scaler
+	}
+	if h.Schemas != nil {
+		session.schemas = h.Schemas.forServer(server)
+	}
+	return session, nil
+}
+
+// PostMCP accepts client JSON-RPC messages over the Streamable HTTP transport.
+// A POST without an Mcp-Session-Id header must carry initialize and opens a
+// session. Responses are returned as an SSE stream or a JSON body depending on
+// the Accept header; a POST of only notifications and responses gets 202.
+func (h *Handler) PostMCP(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid server id")
+		return
+	}
+	if h.HTTPSessions == nil {
+		handleServiceError(w, ErrNotImplemented)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMCPBody))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	msgs, batch, err := splitBatch(body)
+	if err != nil {
+		respond.JSON(w, http.StatusBadRequest, json.RawMessage(rpcErrorResponse(nil, &RPCError{Code: codeParseError, Message: "Parse error"})))
+		return
+	}
+
+	userID, _ := uuid.Parse(mw.GetUserID(r.Context()))
+	var session *httpSession
+	if sid := r.Header.Get(mcpSessionHeader); sid != "" {
+		if session = h.HTTPSessions.get(sid, id, userID); session == nil {
+			respond.Error(w, http.StatusNotFound, "session not found")
+			return
+		}
+	} else {
+		if !hasInitialize(msgs) {
+			respond.Error(w, http.StatusBadRequest, "missing "+mcpSessionHeader+" header")
+			return
+		}
+		if session = h.openHTTPSession(w, r, id, userID); session == nil {
+			return
+		}
+		w.Header().Set(mcpSessionHeader, session.id)
+	}
+
+	stream, ok := session.send(r.Context(), msgs)
+	if !ok {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if acceptsEventStream(r) {
+		session.follow(r.Context(), w, stream, 0)
+		return
+	}
+	session.collect(r.Context(), w, stream, batch)
+}
+
+// GetMCP opens the session's standalone SSE stream for server-initiated
+// messages, or resumes a stream from the Last-Event-ID header.
+func (h *Handler) GetMCP(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.httpSession(w, r)
+	if !ok {
+		return
+	}
+	if !acceptsEventStream(r) {
+		respond.Error(w, http.StatusNotAcceptable, "client must accept text/event-stream")
+		return
+	}
+
+	stream, after := uint64(0), session.sentOn(0)
+	if last := r.Header.Get(lastEventIDHeader); last != "" {
+		if stream, after, ok = parseEventID(last); !ok {
+			respond.Error(w, http.StatusBadRequest, "invalid "+lastEventIDHeader+" header")
+			return
+		}
+	}
+	session.follow(r.Context(), w, stream, after)
+}
+
+// DeleteMCP terminates a Streamable HTTP session.
+func (h *Handler) DeleteMCP(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.httpSession(w, r)
+	if !ok {
+		return
+	}
+	session.close(websocket.CloseNormalClosure, "session terminated")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// openHTTPSession validates the server and opens a backend connection for a new
+// Streamable HTTP session. It writes an error response and returns nil on
+// failure.
+func (h *Handler) openHTTPSession(w http.ResponseWriter, r *http.Request, serverID, userID uuid.UUID) *httpSession {
+	server, err := h.connectableServer(w, r, serverID)
+	if err != nil {
+		handleServiceError(w, err)
+		return nil
+	}
+	proxy, err := h.newProxySession(r.Context(), server, userID)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "access policy unavailable")
+		return nil
+	}
+
+	// The backend connection outlives this request.
+	backend, err := h.openBackend(context.WithoutCancel(r.Context()), server, userID)
+	if errors.Is(err, ErrBackendBusy) {
+		respond.Error(w, http.StatusConflict, "server busy")
+		return nil
+	}
+	if err != nil {
+		respond.Error(w, http.StatusBadGateway, "backend connection failed")
+		return nil
+	}
+	proxy.backend = backend
+
+	session := newHTTPSession(proxy, backend)
+	unregister := func() {}
+	if h.Sessions != nil {
+		unregister = h.Sessions.add(server.ID, session)
+	}
+	session.onClose = func() {
+		h.HTTPSessions.remove(session.id)
+		unregister()
+	}
+	h.HTTPSessions.add(session)
+	go session.readBackend()
+	return session
+}
+
+// httpSession looks up the session named by the Mcp-Session-Id header for the
+// caller and the {id} server. It writes an error response and returns false if
+// there is none.
+func (h *Handler) httpSession(w http.ResponseWriter, r *http.Request) (*httpSession, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid server id")
+		return nil, false
+	}
+	if h.HTTPSessions == nil {
+		handleServiceError(w, ErrNotImplemented)
+		return nil, false
+	}
+	sid := r.Header.Get(mcpSessionHeader)
+	if sid == "" {
+		respond.Error(w, http.StatusBadRequest, "missing "+mcpSessionHeader+" header")
+		return nil, false
+	}
+	userID, _ := uuid.Parse(mw.GetUserID(r.Context()))
+	session := h.HTTPSessions.get(sid, id, userID)
+	if session == nil {
+		respond.Error(w, http.StatusNotFound, "session not found")
+		return nil, false
+	}
+	return session, true
+}
+
+func (h *Handler) ListAccessPolicies(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.ownedServerID(w, r)
+	if !ok {
+		return
+	}
+
+	policies, err := h.Service.ListAccessPolicies(r.Context(), id)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to list access policies")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, policies)
+}
+
+func (h *Handler) PutAccessPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.ownedServerID(w, r)
+	if !ok {
+		return
+	}
+	principal, ok := parsePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Tools     []string `json:"tools"`
+		Resources []string `json:"resources"`
+		Prompts   []string `json:"prompts"`
+	}
+	if !respond.Decode(w, r, &req) {
+		return
+	}
+
+	policy := &AccessPolicy{
+		ServerID:    id,
+		PrincipalID: principal,
+		Tools:       req.Tools,
+		Resources:   req.Resources,
+		Prompts:     req.Prompts,
+	}
+	if err := h.Service.PutAccessPolicy(r.Context(), policy); err != nil {
+		handleServiceError(w, err)
+		return
+	}
 
-	<-done
+	respond.JSON(w, http.StatusOK, policy)
+}
+
+func (h *Handler) DeleteAccessPolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.ownedServerID(w, r)
+	if !ok {
+		return
+	}
+	principal, ok := parsePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.DeleteAccessPolicy(r.Context(), id, principal); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isAdmin reports whether the caller is one of the gateway's admins.
+func (h *Handler) isAdmin(r *http.Request) bool {
+	id, err := uuid.Parse(mw.GetUserID(r.Context()))
+	return err == nil && slices.Contains(h.Admins, id)
+}
+
+// ownedServerID parses the {id} URL parameter and checks that the caller owns
+// the server. It writes an error response and returns false otherwise.
+func (h *Handler) ownedServerID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid server id")
+		return uuid.Nil, false
+	}
+
+	server, err := h.Service.GetServer(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return uuid.Nil, false
+	}
+	if server.OwnerID.String() != mw.GetUserID(r.Context()) {
+		respond.Error(w, http.StatusForbidden, "only the server owner can do this")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// ownServerID is ownedServerID for endpoints that must not reveal a server
+// to anyone but its owner: other users get 404, as if it did not exist.
+func (h *Handler) ownServerID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid server id")
+		return uuid.Nil, false
+	}
+
+	server, err := h.Service.GetServer(r.Context(), id)
+	if err == nil && server.OwnerID.String() != mw.GetUserID(r.Context()) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		handleServiceError(w, err)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// parsePrincipal parses the {principal} URL parameter: a user ID, or
+// "default" for the server-wide policy (returned as nil).
+func parsePrincipal(w http.ResponseWriter, r *http.Request) (*uuid.UUID, bool) {
+	p := chi.URLParam(r, "principal")
+	if p == "default" {
+		return nil, true
+	}
+	id, err := uuid.Parse(p)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "principal must be a user id or \"default\"")
+		return nil, false
+	}
+	return &id, true
 }
 
 func handleServiceError(w http.ResponseWriter, err error) {
 	switch {
 	case errors.Is(err, ErrNotFound):
 		respond.Error(w, http.StatusNotFound, "not found")
+	case errors.Is(err, ErrQuarantined):
+		respond.Error(w, http.StatusForbidden, "server is quarantined")
+	case errors.Is(err, ErrNotQuarantined):
+		respond.Error(w, http.StatusConflict, "server is not quarantined")
 	case errors.Is(err, ErrContainerNotAvailable):
 		respond.Error(w, http.StatusServiceUnavailable, "container runtime not available")
+	case errors.Is(err, ErrRolloutInProgress), errors.Is(err, ErrServerRunning):
+		respond.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrRolloutFailed):
+		respond.Error(w, http.StatusBadGateway, err.Error())
 	case errors.Is(err, ErrNotImplemented):
 		respond.Error(w, http.StatusNotImplemented, "not implemented")
+	case errors.Is(err, ErrLimitExceeded), errors.Is(err, ErrSecretUnavailable), errors.Is(err, ErrSecurityNotAllowed):
+		respond.Error(w, http.StatusBadRequest, err.Error())
 	default:
 		respond.Error(w, http.StatusInternalServerError, "internal server error")
 	}
 }
+
+// respondSpecError writes a 400 listing the invalid fields of a server spec.
+func respondSpecError(w http.ResponseWriter, err *SpecError) {
+	respond.JSON(w, http.StatusBadRequest, map[string]any{
+		"error":  "invalid server config",
+		"fields": err.Fields,
+	})
+}
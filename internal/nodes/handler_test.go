@@ -5,9 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -36,7 +39,24 @@ type mockService struct {
 	RemoveServerFn     func(ctx context.Context, id uuid.UUID) error
 	StartServerFn      func(ctx context.Context, id uuid.UUID) error
 	StopServerFn       func(ctx context.Context, id uuid.UUID) error
+	QuarantineServerFn func(ctx context.Context, id uuid.UUID, stop bool) error
+	ReleaseServerFn    func(ctx context.Context, id uuid.UUID) error
 	ConnectWebSocketFn func(ctx context.Context, serverID uuid.UUID, w http.ResponseWriter, r *http.Request) error
+
+	ListAccessPoliciesFn func(ctx context.Context, serverID uuid.UUID) ([]AccessPolicy, error)
+	PutAccessPolicyFn    func(ctx context.Context, policy *AccessPolicy) error
+	DeleteAccessPolicyFn func(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) error
+	EffectivePolicyFn    func(ctx context.Context, serverID, userID uuid.UUID) (*AccessPolicy, error)
+	OpenBackendFn        func(ctx context.Context, server *MCPServer) (BackendConn, error)
+	RemoveOrphansFn      func(ctx context.Context) ([]string, error)
+	LogsFn               func(ctx context.Context, id uuid.UUID, opts LogOptions) (io.ReadCloser, error)
+	StatsFn              func(ctx context.Context, id uuid.UUID) (*ContainerStats, error)
+	ReloadOAuthSecretsFn func(ctx context.Context, id uuid.UUID, provider string) error
+
+	UpdateServerFn        func(ctx context.Context, id uuid.UUID, update *ServerUpdate) (*MCPServer, error)
+	RollbackServerFn      func(ctx context.Context, id uuid.UUID, revision int, userID uuid.UUID) (*MCPServer, error)
+	ListServerRevisionsFn func(ctx context.Context, id uuid.UUID) ([]ServerRevision, error)
+	GetServerRevisionFn   func(ctx context.Context, id uuid.UUID, revision int) (*ServerRevision, error)
 }
 
 func (m *mockService) ListServers(ctx context.Context, ownerID uuid.UUID) ([]MCPServer, error) {
@@ -57,9 +77,69 @@ func (m *mockService) StartServer(ctx context.Context, id uuid.UUID) error {
 func (m *mockService) StopServer(ctx context.Context, id uuid.UUID) error {
 	return m.StopServerFn(ctx, id)
 }
+func (m *mockService) QuarantineServer(ctx context.Context, id uuid.UUID, stop bool) error {
+	return m.QuarantineServerFn(ctx, id, stop)
+}
+func (m *mockService) ReleaseServer(ctx context.Context, id uuid.UUID) error {
+	return m.ReleaseServerFn(ctx, id)
+}
+func (m *mockService) ListAccessPolicies(ctx context.Context, serverID uuid.UUID) ([]AccessPolicy, error) {
+	return m.ListAccessPoliciesFn(ctx, serverID)
+}
+func (m *mockService) PutAccessPolicy(ctx context.Context, policy *AccessPolicy) error {
+	return m.PutAccessPolicyFn(ctx, policy)
+}
+func (m *mockService) DeleteAccessPolicy(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) error {
+	return m.DeleteAccessPolicyFn(ctx, serverID, principalID)
+}
+func (m *mockService) EffectivePolicy(ctx context.Context, serverID, userID uuid.UUID) (*AccessPolicy, error) {
+	return m.EffectivePolicyFn(ctx, serverID, userID)
+}
 func (m *mockService) ConnectWebSocket(ctx context.Context, serverID uuid.UUID, w http.ResponseWriter, r *http.Request) error {
 	return m.ConnectWebSocketFn(ctx, serverID, w, r)
 }
+func (m *mockService) OpenBackend(ctx context.Context, server *MCPServer) (BackendConn, error) {
+	return m.OpenBackendFn(ctx, server)
+}
+
+func (m *mockService) RemoveOrphans(ctx context.Context) ([]string, error) {
+	return m.RemoveOrphansFn(ctx)
+}
+
+func (m *mockService) Logs(ctx context.Context, id uuid.UUID, opts LogOptions) (io.ReadCloser, error) {
+	return m.LogsFn(ctx, id, opts)
+}
+
+func (m *mockService) Stats(ctx context.Context, id uuid.UUID) (*ContainerStats, error) {
+	return m.StatsFn(ctx, id)
+}
+
+func (m *mockService) ReloadOAuthSecrets(ctx context.Context, id uuid.UUID, provider string) error {
+	return m.ReloadOAuthSecretsFn(ctx, id, provider)
+}
+
+func (m *mockService) UpdateServer(ctx context.Context, id uuid.UUID, update *ServerUpdate) (*MCPServer, error) {
+	return m.UpdateServerFn(ctx, id, update)
+}
+
+func (m *mockService) RollbackServer(ctx context.Context, id uuid.UUID, revision int, userID uuid.UUID) (*MCPServer, error) {
+	return m.RollbackServerFn(ctx, id, revision, userID)
+}
+
+func (m *mockService) ListServerRevisions(ctx context.Context, id uuid.UUID) ([]ServerRevision, error) {
+	return m.ListServerRevisionsFn(ctx, id)
+}
+
+func (m *mockService) GetServerRevision(ctx context.Context, id uuid.UUID, revision int) (*ServerRevision, error) {
+	return m.GetServerRevisionFn(ctx, id, revision)
+}
+
+// ownedBy returns a GetServerFn for servers owned by ownerID.
+func ownedBy(ownerID uuid.UUID) func(context.Context, uuid.UUID) (*MCPServer, error) {
+	return func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+		return &MCPServer{ID: id, OwnerID: ownerID}, nil
+	}
+}
 
 func newTestHandler(svc *mockService) *Handler {
 	return &Handler{
@@ -116,6 +196,112 @@ func TestRegisterServerHandler(t *testing.T) {
 	}
 }
 
+func TestRegisterServerHandlerLimitExceeded(t *testing.T) {
+	svc := &mockService{
+		RegisterServerFn: func(_ context.Context, _ *MCPServer) error {
+			return fmt.Errorf("%w of tier default: cpus 8 above 1", ErrLimitExceeded)
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	body := `{"name":"my-server","image":"mcp:latest","config":{"limits":{"cpus":8}}}`
+	req := authenticatedRequest(http.MethodPost, "/", bytes.NewBufferString(body), uuid.New().String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "cpus 8 above 1") {
+		t.Fatalf("expected 400 naming the exceeded limit, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRegisterServerHandlerRejectsUnknownTransport(t *testing.T) {
+	h := newTestHandler(&mockService{})
+	router := h.Routes()
+
+	body := `{"name":"my-server","image":"mcp:latest","config":{"transport":"grpc"}}`
+	req := authenticatedRequest(http.MethodPost, "/", bytes.NewBufferString(body), uuid.New().String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	body = `{"name":"my-server","image":"mcp:latest","config":{"pooled":"yes"}}`
+	req = authenticatedRequest(http.MethodPost, "/", bytes.NewBufferString(body), uuid.New().String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for non-boolean pooled, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	body = `{"name":"my-server","image":"mcp:latest","config":{"restart":"sometimes"}}`
+	req = authenticatedRequest(http.MethodPost, "/", bytes.NewBufferString(body), uuid.New().String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown restart policy, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	body = `{"name":"my-server","image":"mcp:latest","config":{"security":{"no_network":true}}}`
+	req = authenticatedRequest(http.MethodPost, "/", bytes.NewBufferString(body), uuid.New().String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid security profile, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	body = `{"name":"my-server","image":"mcp:latest","config":{"egress":{"allow":"api.github.com"}}}`
+	req = authenticatedRequest(http.MethodPost, "/", bytes.NewBufferString(body), uuid.New().String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a malformed egress allowlist, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	body = `{"name":"my-server","image":"mcp:latest","config":{"limits":{"memory":"lots"}}}`
+	req = authenticatedRequest(http.MethodPost, "/", bytes.NewBufferString(body), uuid.New().String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid limits, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRegisterServerHandlerReportsFieldErrors(t *testing.T) {
+	router := newTestHandler(&mockService{}).Routes()
+
+	body := `{"image":"mcp:latest","config":{"port":70000,"env":{"not-valid":"x"},"limits":{"memory":"lots"}}}`
+	req := authenticatedRequest(http.MethodPost, "/", bytes.NewBufferString(body), uuid.New().String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	want := []FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "config.port", Message: "must be between 1 and 65535"},
+		{Field: "config.env.not-valid", Message: "is not a valid environment variable name"},
+		{Field: "config.limits.memory", Message: "must be a size in bytes or such as 512m"},
+	}
+	if !reflect.DeepEqual(resp.Fields, want) {
+		t.Errorf("expected fields %+v, got %+v", want, resp.Fields)
+	}
+}
+
 func TestGetServerHandler(t *testing.T) {
 	serverID := uuid.New()
 	userID := uuid.New()
@@ -156,6 +342,168 @@ func TestRemoveServerHandler(t *testing.T) {
 	}
 }
 
+func TestUpdateServerHandler(t *testing.T) {
+	serverID := uuid.New()
+	userID := uuid.New()
+	var got *ServerUpdate
+	svc := &mockService{
+		GetServerFn: ownedBy(userID),
+		UpdateServerFn: func(_ context.Context, id uuid.UUID, update *ServerUpdate) (*MCPServer, error) {
+			got = update
+			return &MCPServer{ID: id, Image: update.Image, Revision: 2}, nil
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	body := `{"image":"mcp:v2","config":{"port":9000}}`
+	req := authenticatedRequest(http.MethodPatch, "/"+serverID.String(), bytes.NewBufferString(body), userID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got.Image != "mcp:v2" || got.Config == nil || got.Config.Port != 9000 || got.UpdatedBy != userID {
+		t.Errorf("expected the image, config and user to be passed on, got %+v", got)
+	}
+}
+
+func TestUpdateServerHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{"empty", `{}`, nil, http.StatusBadRequest},
+		{"invalid config", `{"config":{"transport":"carrier-pigeon"}}`, nil, http.StatusBadRequest},
+		{"in progress", `{"image":"mcp:v2"}`, ErrRolloutInProgress, http.StatusConflict},
+		{"rollout failed", `{"image":"mcp:v2"}`, fmt.Errorf("%w: no ping response", ErrRolloutFailed), http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			svc := &mockService{
+				GetServerFn: ownedBy(userID),
+				UpdateServerFn: func(context.Context, uuid.UUID, *ServerUpdate) (*MCPServer, error) {
+					if tt.err == nil {
+						t.Error("expected the update to be rejected before the service")
+					}
+					return nil, tt.err
+				},
+			}
+			router := newTestHandler(svc).Routes()
+
+			req := authenticatedRequest(http.MethodPatch, "/"+uuid.NewString(), bytes.NewBufferString(tt.body), userID.String())
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRollbackServerHandler(t *testing.T) {
+	serverID := uuid.New()
+	userID := uuid.New()
+	var gotRevision int
+	svc := &mockService{
+		GetServerFn: ownedBy(userID),
+		RollbackServerFn: func(_ context.Context, id uuid.UUID, revision int, by uuid.UUID) (*MCPServer, error) {
+			gotRevision = revision
+			if by != userID {
+				t.Errorf("expected the rollback to be made by %s, got %s", userID, by)
+			}
+			return &MCPServer{ID: id, Revision: 4}, nil
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	req := authenticatedRequest(http.MethodPost, "/"+serverID.String()+"/rollback", bytes.NewBufferString(`{"revision":2}`), userID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || gotRevision != 2 {
+		t.Fatalf("expected 200 rolling back to revision 2, got %d for %d: %s", rec.Code, gotRevision, rec.Body.String())
+	}
+
+	req = authenticatedRequest(http.MethodPost, "/"+serverID.String()+"/rollback", bytes.NewBufferString(`{}`), userID.String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a revision, got %d", rec.Code)
+	}
+}
+
+func TestGetRevisionHandler(t *testing.T) {
+	serverID := uuid.New()
+	userID := uuid.New()
+	svc := &mockService{
+		GetServerFn: ownedBy(userID),
+		GetServerRevisionFn: func(_ context.Context, id uuid.UUID, revision int) (*ServerRevision, error) {
+			if revision != 3 {
+				return nil, ErrNotFound
+			}
+			return &ServerRevision{ServerID: id, Revision: revision, Image: "mcp:v3"}, nil
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	for path, want := range map[string]int{"3": http.StatusOK, "7": http.StatusNotFound, "latest": http.StatusBadRequest} {
+		req := authenticatedRequest(http.MethodGet, "/"+serverID.String()+"/revisions/"+path, nil, userID.String())
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("revision %s: expected %d, got %d: %s", path, want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestRevisionHandlersHideServersFromOtherUsers(t *testing.T) {
+	serverID := uuid.New()
+	svc := &mockService{
+		GetServerFn: ownedBy(uuid.New()),
+		UpdateServerFn: func(context.Context, uuid.UUID, *ServerUpdate) (*MCPServer, error) {
+			t.Error("expected no update by a non-owner")
+			return nil, nil
+		},
+		RollbackServerFn: func(context.Context, uuid.UUID, int, uuid.UUID) (*MCPServer, error) {
+			t.Error("expected no rollback by a non-owner")
+			return nil, nil
+		},
+		ListServerRevisionsFn: func(context.Context, uuid.UUID) ([]ServerRevision, error) {
+			t.Error("expected no revisions listed for a non-owner")
+			return nil, nil
+		},
+		GetServerRevisionFn: func(context.Context, uuid.UUID, int) (*ServerRevision, error) {
+			t.Error("expected no revision read by a non-owner")
+			return nil, nil
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	requests := []struct{ method, path, body string }{
+		{http.MethodPatch, "", `{"image":"attacker/mcp"}`},
+		{http.MethodPost, "/rollback", `{"revision":1}`},
+		{http.MethodGet, "/revisions", ""},
+		{http.MethodGet, "/revisions/1", ""},
+	}
+	for _, rq := range requests {
+		var body io.Reader
+		if rq.body != "" {
+			body = bytes.NewBufferString(rq.body)
+		}
+		req := authenticatedRequest(rq.method, "/"+serverID.String()+rq.path, body, uuid.NewString())
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404 for a non-owner, got %d: %s", rq.method, rq.path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
 func TestStartServerHandlerUnavailable(t *testing.T) {
 	serverID := uuid.New()
 	userID := uuid.New()
@@ -196,6 +544,168 @@ func TestStopServerHandlerUnavailable(t *testing.T) {
 	}
 }
 
+func TestRemoveOrphansHandler(t *testing.T) {
+	svc := &mockService{
+		RemoveOrphansFn: func(_ context.Context) ([]string, error) {
+			return []string{"abc123"}, nil
+		},
+	}
+	adminID := uuid.New()
+	h := newTestHandler(svc)
+	h.Admins = []uuid.UUID{adminID}
+	router := h.Routes()
+
+	req := authenticatedRequest(http.MethodPost, "/gc", nil, uuid.New().String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = authenticatedRequest(http.MethodPost, "/gc", nil, adminID.String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Removed []string `json:"removed"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if len(resp.Removed) != 1 || resp.Removed[0] != "abc123" {
+		t.Errorf("unexpected response %s", rec.Body.String())
+	}
+}
+
+func TestGetLogsHandler(t *testing.T) {
+	serverID := uuid.New()
+	userID := uuid.New()
+	var got LogOptions
+	svc := &mockService{
+		GetServerFn: ownedBy(userID),
+		LogsFn: func(_ context.Context, id uuid.UUID, opts LogOptions) (io.ReadCloser, error) {
+			got = opts
+			return io.NopCloser(strings.NewReader("starting\nusing API_KEY=abcd1234\n")), nil
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	req := authenticatedRequest(http.MethodGet, "/"+serverID.String()+"/logs?follow=true&tail=50&since=10m", nil, userID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !got.Follow || got.Tail != "50" || got.Since != "10m" {
+		t.Errorf("unexpected log options %+v", got)
+	}
+	if body := rec.Body.String(); body != "starting\nusing API_KEY=[REDACTED:credential]\n" {
+		t.Errorf("expected redacted plain text, got %q", body)
+	}
+
+	req = authenticatedRequest(http.MethodGet, "/"+serverID.String()+"/logs", nil, userID.String())
+	req.Header.Set("Accept", "text/event-stream")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream, got %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "id: 2\nevent: log\ndata: using API_KEY=[REDACTED:credential]\n\n") {
+		t.Errorf("unexpected event stream %q", rec.Body.String())
+	}
+}
+
+func TestGetLogsHandlerRequiresOwner(t *testing.T) {
+	svc := &mockService{
+		GetServerFn: ownedBy(uuid.New()),
+		LogsFn: func(context.Context, uuid.UUID, LogOptions) (io.ReadCloser, error) {
+			t.Error("expected no logs for a non-owner")
+			return nil, nil
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	req := authenticatedRequest(http.MethodGet, "/"+uuid.NewString()+"/logs", nil, uuid.NewString())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-owner, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetStatsHandler(t *testing.T) {
+	serverID := uuid.New()
+	userID := uuid.New()
+	svc := &mockService{
+		GetServerFn: ownedBy(userID),
+		StatsFn: func(_ context.Context, id uuid.UUID) (*ContainerStats, error) {
+			if id != serverID {
+				return nil, ErrNotFound
+			}
+			return &ContainerStats{CPUPercent: 12.5, MemoryUsage: 1024, Pids: 3}, nil
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	req := authenticatedRequest(http.MethodGet, "/"+serverID.String()+"/stats", nil, userID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var stats ContainerStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("decoding stats: %v", err)
+	}
+	if stats.CPUPercent != 12.5 || stats.MemoryUsage != 1024 || stats.Pids != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	req = authenticatedRequest(http.MethodGet, "/"+uuid.New().String()+"/stats", nil, userID.String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown server, got %d", rec.Code)
+	}
+}
+
+func TestGetStatsHandlerRequiresOwner(t *testing.T) {
+	svc := &mockService{
+		GetServerFn: ownedBy(uuid.New()),
+		StatsFn: func(context.Context, uuid.UUID) (*ContainerStats, error) {
+			t.Error("expected no stats for a non-owner")
+			return nil, nil
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	req := authenticatedRequest(http.MethodGet, "/"+uuid.NewString()+"/stats", nil, uuid.NewString())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-owner, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetLogsHandlerInvalidOptions(t *testing.T) {
+	router := newTestHandler(&mockService{}).Routes()
+	for _, query := range []string{"follow=maybe", "tail=-1", "since=yesterday"} {
+		req := authenticatedRequest(http.MethodGet, "/"+uuid.New().String()+"/logs?"+query, nil, uuid.New().String())
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
 func TestConnectWebSocketHandlerNotImplemented(t *testing.T) {
 	serverID := uuid.New()
 	userID := uuid.New()
@@ -267,3 +777,36 @@ func TestGetServerHandlerInternalError(t *testing.T) {
 		t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body.String())
 	}
 }
+
+func TestPutAccessPolicyHandlerRequiresOwner(t *testing.T) {
+	ownerID, serverID := uuid.New(), uuid.New()
+	var stored *AccessPolicy
+	svc := &mockService{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, OwnerID: ownerID}, nil
+		},
+		PutAccessPolicyFn: func(_ context.Context, p *AccessPolicy) error {
+			stored = p
+			return nil
+		},
+	}
+	router := newTestHandler(svc).Routes()
+
+	body := `{"tools":["search"]}`
+	req := authenticatedRequest(http.MethodPut, "/"+serverID.String()+"/policies/default", bytes.NewBufferString(body), uuid.New().String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-owner, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = authenticatedRequest(http.MethodPut, "/"+serverID.String()+"/policies/default", bytes.NewBufferString(body), ownerID.String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored == nil || stored.PrincipalID != nil || len(stored.Tools) != 1 || stored.Resources != nil {
+		t.Errorf("unexpected stored policy: %+v", stored)
+	}
+}
@@ -0,0 +1,84 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSON-RPC error codes returned by the gateway proxy.
+const (
+	codeParseError       = -32700
+	codeInvalidRequest   = -32600
+	codeMethodNotFound   = -32601
+	codeInvalidParams    = -32602
+	codeInternalError    = -32603
+	codeRequestBlocked   = -32001
+	codeApprovalDenied   = -32002
+	codeApprovalTimedOut = -32003
+	codeRateLimited      = -32005
+)
+
+// RPCError is a JSON-RPC 2.0 error object.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string { return e.Message }
+
+// rpcMessage is a JSON-RPC 2.0 envelope as seen by the proxy. A message with a
+// method is a request (or a notification when ID is empty); one without is a
+// response.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+func (m *rpcMessage) isRequest() bool {
+	return m.Method != ""
+}
+
+func (m *rpcMessage) isNotification() bool {
+	return m.Method != "" && len(m.ID) == 0
+}
+
+// target returns the tool name, resource URI or prompt name a request acts on.
+func (m *rpcMessage) target() string {
+	if len(m.Params) == 0 {
+		return ""
+	}
+	var p struct {
+		Name string `json:"name"`
+		URI  string `json:"uri"`
+	}
+	if err := json.Unmarshal(m.Params, &p); err != nil {
+		return ""
+	}
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.URI
+}
+
+// rpcID normalises a raw JSON-RPC ID for use as a map key.
+func rpcID(id json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, id); err != nil {
+		return string(id)
+	}
+	return buf.String()
+}
+
+// rpcErrorResponse encodes a JSON-RPC error response for the given request ID.
+func rpcErrorResponse(id json.RawMessage, rpcErr *RPCError) []byte {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	b, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: id, Error: rpcErr})
+	return b
+}
@@ -0,0 +1,74 @@
+package nodes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ResourceLimits bound the resources of a server's container. Zero leaves a
+// resource unlimited.
+type ResourceLimits struct {
+	Memory ByteSize `json:"memory,omitempty"`
+	CPUs   float64  `json:"cpus,omitempty"`
+	Pids   int64    `json:"pids,omitempty"`
+	// Disk caps the container's writable layer. It needs a Docker storage
+	// driver that supports size quotas.
+	Disk ByteSize `json:"disk,omitempty"`
+}
+
+// LimitTiers holds the resource maximums admins allow per user tier. Servers
+// may ask for less than their owner's tier allows; limits they leave unset
+// get the tier maximum.
+type LimitTiers struct {
+	// Tiers maps tier names to their maximums.
+	Tiers map[string]ResourceLimits
+	// Users assigns users to tiers. Everyone else is in DefaultTier.
+	Users       map[uuid.UUID]string
+	DefaultTier string
+}
+
+// tier returns the name and maximums of the owner's tier, if any.
+func (t *LimitTiers) tier(owner uuid.UUID) (string, ResourceLimits, bool) {
+	name, ok := t.Users[owner]
+	if !ok {
+		name = t.DefaultTier
+	}
+	max, ok := t.Tiers[name]
+	return name, max, ok
+}
+
+// Apply checks limits against the owner's tier and returns them with unset
+// limits raised to the tier maximum. The error wraps ErrLimitExceeded.
+func (t *LimitTiers) Apply(owner uuid.UUID, limits ResourceLimits) (ResourceLimits, error) {
+	if t == nil {
+		return limits, nil
+	}
+	name, max, ok := t.tier(owner)
+	if !ok {
+		return limits, nil
+	}
+
+	var over []string
+	capLimit(&over, "memory", &limits.Memory, max.Memory)
+	capLimit(&over, "cpus", &limits.CPUs, max.CPUs)
+	capLimit(&over, "pids", &limits.Pids, max.Pids)
+	capLimit(&over, "disk", &limits.Disk, max.Disk)
+
+	if len(over) > 0 {
+		return limits, fmt.Errorf("%w of tier %s: %s", ErrLimitExceeded, name, strings.Join(over, ", "))
+	}
+	return limits, nil
+}
+
+// capLimit raises an unset limit to max, or records that it is above max.
+func capLimit[T ByteSize | float64 | int64](over *[]string, field string, v *T, max T) {
+	switch {
+	case max == 0:
+	case *v == 0:
+		*v = max
+	case *v > max:
+		*over = append(*over, fmt.Sprintf("%s %v above %v", field, *v, max))
+	}
+}
@@ -0,0 +1,49 @@
+package nodes
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestLimitTiersApply(t *testing.T) {
+	pro := uuid.New()
+	tiers := &LimitTiers{
+		Tiers: map[string]ResourceLimits{
+			"default": {Memory: 1 << 30, CPUs: 1, Pids: 256},
+			"pro":     {Memory: 4 << 30, CPUs: 4},
+		},
+		Users:       map[uuid.UUID]string{pro: "pro"},
+		DefaultTier: "default",
+	}
+
+	limits, err := tiers.Apply(uuid.New(), ResourceLimits{Memory: 512 << 20})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if limits != (ResourceLimits{Memory: 512 << 20, CPUs: 1, Pids: 256}) {
+		t.Errorf("expected unset limits to take the tier maximum, got %+v", limits)
+	}
+
+	_, err = tiers.Apply(uuid.New(), ResourceLimits{Memory: 2 << 30, CPUs: 2})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+	if got := err.Error(); got != "resource limits exceed the maximum of tier default: memory 2GiB above 1GiB, cpus 2 above 1" {
+		t.Errorf("unexpected error message %q", got)
+	}
+
+	limits, err = tiers.Apply(pro, ResourceLimits{Memory: 2 << 30, CPUs: 2, Pids: 4096})
+	if err != nil {
+		t.Fatalf("expected the pro tier to allow the limits, got %v", err)
+	}
+	if limits.Pids != 4096 {
+		t.Errorf("expected pids to stay as requested, got %d", limits.Pids)
+	}
+
+	var none *LimitTiers
+	if limits, err := none.Apply(uuid.New(), ResourceLimits{}); err != nil || limits != (ResourceLimits{}) {
+		t.Errorf("expected no tiers to leave limits alone, got %+v %v", limits, err)
+	}
+}
@@ -0,0 +1,100 @@
+package nodes
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+)
+
+// maxLogLine bounds a log line; longer lines are passed on in pieces.
+const maxLogLine = 64 * 1024
+
+// logRedactor scrubs secrets from container output before it leaves the
+// gateway.
+var logRedactor = sentry.NewRedactor()
+
+// parseLogOptions reads the follow, since and tail query parameters.
+func parseLogOptions(q url.Values) (LogOptions, error) {
+	var opts LogOptions
+	if v := q.Get("follow"); v != "" {
+		follow, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, errors.New("follow must be a boolean")
+		}
+		opts.Follow = follow
+	}
+	if v := q.Get("tail"); v != "" {
+		if n, err := strconv.Atoi(v); v != "all" && (err != nil || n < 0) {
+			return opts, errors.New("tail must be a non-negative number or all")
+		}
+		opts.Tail = v
+	}
+	if v := q.Get("since"); v != "" {
+		if !validSince(v) {
+			return opts, errors.New("since must be a duration, Unix timestamp or RFC 3339 time")
+		}
+		opts.Since = v
+	}
+	return opts, nil
+}
+
+// validSince reports whether Docker accepts v as a log start time.
+func validSince(v string) bool {
+	if _, err := time.ParseDuration(v); err == nil {
+		return true
+	}
+	if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return true
+	}
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil
+}
+
+// redactLines calls emit with each line of r, secrets redacted, until r ends
+// or emit returns an error.
+func redactLines(r io.Reader, emit func(line string) error) error {
+	br := bufio.NewReaderSize(r, maxLogLine)
+	var lr lineRedactor
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(chunk) > 0 {
+			line := strings.TrimRight(string(chunk), "\r\n")
+			if err := emit(lr.redact(line)); err != nil {
+				return err
+			}
+		}
+		switch {
+		case err == nil, errors.Is(err, bufio.ErrBufferFull):
+		case errors.Is(err, io.EOF):
+			return nil
+		default:
+			return fmt.Errorf("reading logs: %w", err)
+		}
+	}
+}
+
+// lineRedactor redacts log lines one at a time. It remembers when a private
+// key block has begun so the key's body lines are redacted too.
+type lineRedactor struct {
+	inKey bool
+}
+
+func (lr *lineRedactor) redact(line string) string {
+	if lr.inKey {
+		if strings.Contains(line, "-----END") {
+			lr.inKey = false
+		}
+		return "[REDACTED:private_key]"
+	}
+	if strings.Contains(line, "-----BEGIN") && strings.Contains(line, "PRIVATE KEY-----") && !strings.Contains(line, "-----END") {
+		lr.inKey = true
+	}
+	return logRedactor.Redact(line)
+}
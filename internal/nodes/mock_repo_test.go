@@ -2,19 +2,45 @@ package nodes
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type mockRepo struct {
-	ListServersFn      func(ctx context.Context, ownerID uuid.UUID) ([]MCPServer, error)
-	GetServerFn        func(ctx context.Context, id uuid.UUID) (*MCPServer, error)
-	CreateServerFn     func(ctx context.Context, server *MCPServer) error
-	UpdateServerFn     func(ctx context.Context, server *MCPServer) error
-	DeleteServerFn     func(ctx context.Context, id uuid.UUID) error
-	SearchServersFn    func(ctx context.Context, query string) ([]MCPServer, error)
-	CreateOAuthGrantFn func(ctx context.Context, grant *OAuthGrant) error
-	GetOAuthGrantFn    func(ctx context.Context, id uuid.UUID) (*OAuthGrant, error)
+	ListServersFn              func(ctx context.Context, ownerID uuid.UUID) ([]MCPServer, error)
+	GetServerFn                func(ctx context.Context, id uuid.UUID) (*MCPServer, error)
+	CreateServerFn             func(ctx context.Context, server *MCPServer) error
+	UpdateServerFn             func(ctx context.Context, server *MCPServer) error
+	UpdateServerCapabilitiesFn func(ctx context.Context, id uuid.UUID, tools, resources []any) error
+	DeleteServerFn             func(ctx context.Context, id uuid.UUID) error
+	SearchServersFn            func(ctx context.Context, query string) ([]MCPServer, error)
+	CreateOAuthGrantFn         func(ctx context.Context, grant *OAuthGrant) error
+	GetOAuthGrantFn            func(ctx context.Context, id uuid.UUID) (*OAuthGrant, error)
+
+	CreateServerRevisionFn func(ctx context.Context, rev *ServerRevision) error
+	ListServerRevisionsFn  func(ctx context.Context, serverID uuid.UUID) ([]ServerRevision, error)
+	GetServerRevisionFn    func(ctx context.Context, serverID uuid.UUID, revision int) (*ServerRevision, error)
+
+	GetServerOAuthGrantFn        func(ctx context.Context, serverID uuid.UUID, provider string) (*OAuthGrant, error)
+	ListRefreshableOAuthGrantsFn func(ctx context.Context, before time.Time) ([]OAuthGrant, error)
+	UpdateOAuthGrantTokensFn     func(ctx context.Context, grant *OAuthGrant) error
+	ListOAuthGrantsFn            func(ctx context.Context, ownerID uuid.UUID) ([]OAuthGrant, error)
+	DeleteOAuthGrantFn           func(ctx context.Context, id uuid.UUID) error
+	CreateOAuthStateFn           func(ctx context.Context, state *OAuthState) error
+	ConsumeOAuthStateFn          func(ctx context.Context, state string) (*OAuthState, error)
+
+	ListAccessPoliciesFn func(ctx context.Context, serverID uuid.UUID) ([]AccessPolicy, error)
+	GetAccessPolicyFn    func(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) (*AccessPolicy, error)
+	UpsertAccessPolicyFn func(ctx context.Context, policy *AccessPolicy) error
+	DeleteAccessPolicyFn func(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) error
+
+	ListPortAllocationsFn  func(ctx context.Context) ([]PortAllocation, error)
+	CreatePortAllocationFn func(ctx context.Context, alloc *PortAllocation) error
+	DeletePortAllocationFn func(ctx context.Context, serverID uuid.UUID) error
+
+	DeleteStandbyPortAllocationFn func(ctx context.Context, serverID uuid.UUID) error
+	PromotePortAllocationFn       func(ctx context.Context, serverID uuid.UUID) error
 }
 
 func (m *mockRepo) ListServers(ctx context.Context, ownerID uuid.UUID) ([]MCPServer, error) {
@@ -33,6 +59,10 @@ func (m *mockRepo) UpdateServer(ctx context.Context, server *MCPServer) error {
 	return m.UpdateServerFn(ctx, server)
 }
 
+func (m *mockRepo) UpdateServerCapabilities(ctx context.Context, id uuid.UUID, tools, resources []any) error {
+	return m.UpdateServerCapabilitiesFn(ctx, id, tools, resources)
+}
+
 func (m *mockRepo) DeleteServer(ctx context.Context, id uuid.UUID) error {
 	return m.DeleteServerFn(ctx, id)
 }
@@ -41,6 +71,18 @@ func (m *mockRepo) SearchServers(ctx context.Context, query string) ([]MCPServer
 	return m.SearchServersFn(ctx, query)
 }
 
+func (m *mockRepo) CreateServerRevision(ctx context.Context, rev *ServerRevision) error {
+	return m.CreateServerRevisionFn(ctx, rev)
+}
+
+func (m *mockRepo) ListServerRevisions(ctx context.Context, serverID uuid.UUID) ([]ServerRevision, error) {
+	return m.ListServerRevisionsFn(ctx, serverID)
+}
+
+func (m *mockRepo) GetServerRevision(ctx context.Context, serverID uuid.UUID, revision int) (*ServerRevision, error) {
+	return m.GetServerRevisionFn(ctx, serverID, revision)
+}
+
 func (m *mockRepo) CreateOAuthGrant(ctx context.Context, grant *OAuthGrant) error {
 	return m.CreateOAuthGrantFn(ctx, grant)
 }
@@ -48,3 +90,67 @@ func (m *mockRepo) CreateOAuthGrant(ctx context.Context, grant *OAuthGrant) erro
 func (m *mockRepo) GetOAuthGrant(ctx context.Context, id uuid.UUID) (*OAuthGrant, error) {
 	return m.GetOAuthGrantFn(ctx, id)
 }
+
+func (m *mockRepo) GetServerOAuthGrant(ctx context.Context, serverID uuid.UUID, provider string) (*OAuthGrant, error) {
+	return m.GetServerOAuthGrantFn(ctx, serverID, provider)
+}
+
+func (m *mockRepo) ListRefreshableOAuthGrants(ctx context.Context, before time.Time) ([]OAuthGrant, error) {
+	return m.ListRefreshableOAuthGrantsFn(ctx, before)
+}
+
+func (m *mockRepo) UpdateOAuthGrantTokens(ctx context.Context, grant *OAuthGrant) error {
+	return m.UpdateOAuthGrantTokensFn(ctx, grant)
+}
+
+func (m *mockRepo) ListOAuthGrants(ctx context.Context, ownerID uuid.UUID) ([]OAuthGrant, error) {
+	return m.ListOAuthGrantsFn(ctx, ownerID)
+}
+
+func (m *mockRepo) DeleteOAuthGrant(ctx context.Context, id uuid.UUID) error {
+	return m.DeleteOAuthGrantFn(ctx, id)
+}
+
+func (m *mockRepo) CreateOAuthState(ctx context.Context, state *OAuthState) error {
+	return m.CreateOAuthStateFn(ctx, state)
+}
+
+func (m *mockRepo) ConsumeOAuthState(ctx context.Context, state string) (*OAuthState, error) {
+	return m.ConsumeOAuthStateFn(ctx, state)
+}
+
+func (m *mockRepo) ListAccessPolicies(ctx context.Context, serverID uuid.UUID) ([]AccessPolicy, error) {
+	return m.ListAccessPoliciesFn(ctx, serverID)
+}
+
+func (m *mockRepo) GetAccessPolicy(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) (*AccessPolicy, error) {
+	return m.GetAccessPolicyFn(ctx, serverID, principalID)
+}
+
+func (m *mockRepo) UpsertAccessPolicy(ctx context.Context, policy *AccessPolicy) error {
+	return m.UpsertAccessPolicyFn(ctx, policy)
+}
+
+func (m *mockRepo) DeleteAccessPolicy(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) error {
+	return m.DeleteAccessPolicyFn(ctx, serverID, principalID)
+}
+
+func (m *mockRepo) ListPortAllocations(ctx context.Context) ([]PortAllocation, error) {
+	return m.ListPortAllocationsFn(ctx)
+}
+
+func (m *mockRepo) CreatePortAllocation(ctx context.Context, alloc *PortAllocation) error {
+	return m.CreatePortAllocationFn(ctx, alloc)
+}
+
+func (m *mockRepo) DeletePortAllocation(ctx context.Context, serverID uuid.UUID) error {
+	return m.DeletePortAllocationFn(ctx, serverID)
+}
+
+func (m *mockRepo) DeleteStandbyPortAllocation(ctx context.Context, serverID uuid.UUID) error {
+	return m.DeleteStandbyPortAllocationFn(ctx, serverID)
+}
+
+func (m *mockRepo) PromotePortAllocation(ctx context.Context, serverID uuid.UUID) error {
+	return m.PromotePortAllocationFn(ctx, serverID)
+}
@@ -6,21 +6,36 @@ import (
 	"github.com/google/uuid"
 )
 
-// MCPServer represents a managed MCP server instance.
+// MCPServer represents a managed MCP server instance. Revision is the number
+// of the image and config revision it runs.
 type MCPServer struct {
-	ID          uuid.UUID      `json:"id"`
-	OwnerID     uuid.UUID      `json:"owner_id"`
-	Name        string         `json:"name"`
-	Image       string         `json:"image"`
-	Status      ServerStatus   `json:"status"`
-	Config      map[string]any `json:"config"`
-	ContainerID string         `json:"container_id,omitempty"`
-	Tools       []any          `json:"tools,omitempty"`
-	Resources   []any          `json:"resources,omitempty"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
+	ID          uuid.UUID    `json:"id"`
+	OwnerID     uuid.UUID    `json:"owner_id"`
+	Name        string       `json:"name"`
+	Image       string       `json:"image"`
+	Status      ServerStatus `json:"status"`
+	Config      ServerSpec   `json:"config"`
+	Revision    int          `json:"revision"`
+	ContainerID string       `json:"container_id,omitempty"`
+	// Endpoint is the host:port the gateway dials to reach the running
+	// container's MCP port.
+	Endpoint  string `json:"endpoint,omitempty"`
+	Tools     []any  `json:"tools,omitempty"`
+	Resources []any  `json:"resources,omitempty"`
+	// RestartCount is how many times the supervisor has restarted the
+	// server, and LastExitCode the exit code of its last crashed container.
+	RestartCount int       `json:"restart_count"`
+	LastExitCode *int      `json:"last_exit_code,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// Transports a server's MCP endpoint can speak, set via Config["transport"].
+const (
+	TransportWebSocket = "websocket"
+	TransportStdio     = "stdio"
+)
+
 // ServerStatus represents the lifecycle state of an MCP server.
 type ServerStatus string
 
@@ -30,12 +45,57 @@ const (
 	StatusRunning  ServerStatus = "running"
 	StatusStopping ServerStatus = "stopping"
 	StatusError    ServerStatus = "error"
+	// StatusQuarantined means Sentry has isolated the server; it refuses
+	// connections and cannot be started until an admin releases it.
+	StatusQuarantined ServerStatus = "quarantined"
 )
 
-// OAuthGrant stores encrypted OAuth tokens for an MCP server.
+// AccessPolicy restricts which tools, resources and prompts a principal may see
+// and use on a server. Entries are path.Match patterns matched against tool and
+// prompt names and resource URIs. A nil list leaves that kind unrestricted; an
+// empty list hides everything of that kind.
+type AccessPolicy struct {
+	ID       uuid.UUID `json:"id"`
+	ServerID uuid.UUID `json:"server_id"`
+	// PrincipalID is the user the policy applies to. A nil principal is the
+	// server's default policy for users without one of their own.
+	PrincipalID *uuid.UUID `json:"principal_id,omitempty"`
+	Tools       []string   `json:"tools"`
+	Resources   []string   `json:"resources"`
+	Prompts     []string   `json:"prompts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// PortAllocation records the host port published for a server's container.
+// A standby port is held by the new container of a blue/green update until it
+// replaces the server's current one.
+type PortAllocation struct {
+	Port      int       `json:"port"`
+	ServerID  uuid.UUID `json:"server_id"`
+	Standby   bool      `json:"standby,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ServerRevision is a stored version of a server's image and config.
+// Revisions are numbered from 1 in the order they were made.
+type ServerRevision struct {
+	ServerID uuid.UUID  `json:"server_id"`
+	Revision int        `json:"revision"`
+	Image    string     `json:"image"`
+	Config   ServerSpec `json:"config"`
+	// RollbackOf is the earlier revision this one restored, if any.
+	RollbackOf *int       `json:"rollback_of,omitempty"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// OAuthGrant stores encrypted OAuth tokens for an MCP server. OwnerID is the
+// user who authorized it, the server's owner.
 type OAuthGrant struct {
 	ID              uuid.UUID  `json:"id"`
 	ServerID        uuid.UUID  `json:"server_id"`
+	OwnerID         uuid.UUID  `json:"owner_id"`
 	Provider        string     `json:"provider"`
 	AccessTokenEnc  []byte     `json:"-"`
 	RefreshTokenEnc []byte     `json:"-"`
@@ -43,11 +103,58 @@ type OAuthGrant struct {
 	CreatedAt       time.Time  `json:"created_at"`
 }
 
+// OAuthState is a pending OAuth flow for a server, recorded when the user
+// starts it and consumed by the provider's redirect to the callback.
+type OAuthState struct {
+	// State is the random state parameter identifying the flow.
+	State    string
+	UserID   uuid.UUID
+	ServerID uuid.UUID
+	Provider string
+	// Verifier is the PKCE code verifier whose S256 challenge was sent to
+	// the provider.
+	Verifier  string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
 // ContainerConfig holds settings for spinning up an MCP server container.
 type ContainerConfig struct {
-	Image       string            `json:"image"`
-	Env         map[string]string `json:"env,omitempty"`
-	Ports       []string          `json:"ports,omitempty"`
-	MemoryLimit int64             `json:"memory_limit,omitempty"`
-	CPULimit    float64           `json:"cpu_limit,omitempty"`
+	// Name identifies the container among this gateway's containers; the
+	// container manager qualifies it with the gateway instance ID.
+	Name  string            `json:"name,omitempty"`
+	Image string            `json:"image"`
+	Env   map[string]string `json:"env,omitempty"`
+	Ports []string          `json:"ports,omitempty"`
+	// Stdin keeps the container's stdin open for stdio-transport servers.
+	Stdin bool `json:"stdin,omitempty"`
+	// Labels are added to the container's own labels, which mark it as
+	// created by the gateway.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Security hardens the container; nil leaves Docker's defaults.
+	Security    *SecurityProfile `json:"security,omitempty"`
+	MemoryLimit int64            `json:"memory_limit,omitempty"`
+	CPULimit    float64          `json:"cpu_limit,omitempty"`
+	// DiskLimit caps the size of the container's writable layer in bytes.
+	DiskLimit int64 `json:"disk_limit,omitempty"`
+	// SecretFiles maps container paths to the contents of read-only secret
+	// files. It is never serialized.
+	SecretFiles map[string]string `json:"-"`
+}
+
+// ContainerStats is a snapshot of a server container's resource usage.
+type ContainerStats struct {
+	// CPUPercent is relative to a single CPU, so a container keeping two CPUs
+	// busy reports 200.
+	CPUPercent float64 `json:"cpu_percent"`
+	// MemoryUsage excludes the page cache, like docker stats.
+	MemoryUsage uint64    `json:"memory_usage"`
+	MemoryLimit uint64    `json:"memory_limit"`
+	Pids        uint64    `json:"pids"`
+	PidsLimit   uint64    `json:"pids_limit,omitempty"`
+	NetworkRx   uint64    `json:"network_rx"`
+	NetworkTx   uint64    `json:"network_tx"`
+	BlockRead   uint64    `json:"block_read"`
+	BlockWrite  uint64    `json:"block_write"`
+	ReadAt      time.Time `json:"read_at"`
 }
@@ -1,40 +1,63 @@
 package nodes
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/kapella-hub/NexusClaw/internal/platform/crypto"
+	mw "github.com/kapella-hub/NexusClaw/internal/platform/middleware"
 	"github.com/kapella-hub/NexusClaw/internal/platform/respond"
 	"golang.org/x/oauth2"
 )
 
-// OAuthHandler manages OAuth flows for MCP server integrations.
+// oauthStateTTL bounds how long a user may take to complete an OAuth flow.
+const oauthStateTTL = 10 * time.Minute
+
+// OAuthHandler manages OAuth flows for MCP server integrations and the grants
+// they produce. Each flow authorizes a grant for one server on behalf of its
+// owner.
 type OAuthHandler struct {
 	Providers map[string]*oauth2.Config
-	Repo      Repository
-	VaultKey  []byte
-	AuthMW    func(http.Handler) http.Handler
+	// RevocationURLs holds the RFC 7009 token revocation endpoints of the
+	// providers that have one. Deleting a grant revokes it there first.
+	RevocationURLs map[string]string
+	Repo           Repository
+	VaultKey       []byte
+	AuthMW         func(http.Handler) http.Handler
 }
 
-// Routes returns a chi.Router with OAuth-related routes.
+// Routes returns a chi.Router with OAuth-related routes. The callback is
+// reached by the provider's redirect and is authenticated by its state.
 func (h *OAuthHandler) Routes() chi.Router {
 	r := chi.NewRouter()
 
-	if h.AuthMW != nil {
-		r.Use(h.AuthMW)
-	}
-
-	r.Get("/initiate/{provider}", h.InitiateFlow)
+	r.Group(func(r chi.Router) {
+		if h.AuthMW != nil {
+			r.Use(h.AuthMW)
+		}
+		r.Get("/initiate/{provider}", h.InitiateFlow)
+		r.Get("/grants", h.ListGrants)
+		r.Get("/grants/{id}", h.GetGrant)
+		r.Delete("/grants/{id}", h.DeleteGrant)
+	})
 	r.Get("/callback/{provider}", h.HandleCallback)
 
 	return r
 }
 
+// InitiateFlow redirects to the provider to authorize a grant for the server
+// named by the server_id query parameter, which the user must own.
 func (h *OAuthHandler) InitiateFlow(w http.ResponseWriter, r *http.Request) {
 	providerName := chi.URLParam(r, "provider")
 
@@ -44,23 +67,51 @@ func (h *OAuthHandler) InitiateFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	serverID, err := uuid.Parse(r.URL.Query().Get("server_id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "server_id must be a server id")
+		return
+	}
+	userID, err := uuid.Parse(mw.GetUserID(r.Context()))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if _, ok := h.ownedServer(w, r.Context(), serverID, userID); !ok {
+		return
+	}
+
 	state, err := randomState()
 	if err != nil {
 		respond.Error(w, http.StatusInternalServerError, "failed to generate state")
 		return
 	}
+	now := time.Now()
+	pending := &OAuthState{
+		State:     state,
+		UserID:    userID,
+		ServerID:  serverID,
+		Provider:  providerName,
+		Verifier:  oauth2.GenerateVerifier(),
+		ExpiresAt: now.Add(oauthStateTTL),
+		CreatedAt: now,
+	}
+	if err := h.Repo.CreateOAuthState(r.Context(), pending); err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to store state")
+		return
+	}
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     "oauth_state",
 		Value:    state,
 		Path:     "/",
-		MaxAge:   600, // 10 minutes
+		MaxAge:   int(oauthStateTTL.Seconds()),
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteLaxMode,
 	})
 
-	http.Redirect(w, r, oauthCfg.AuthCodeURL(state), http.StatusTemporaryRedirect)
+	http.Redirect(w, r, oauthCfg.AuthCodeURL(state, oauth2.S256ChallengeOption(pending.Verifier)), http.StatusTemporaryRedirect)
 }
 
 func (h *OAuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
@@ -82,6 +133,22 @@ func (h *OAuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusForbidden, "state mismatch")
 		return
 	}
+	state, err := h.Repo.ConsumeOAuthState(r.Context(), cookie.Value)
+	if errors.Is(err, ErrNotFound) {
+		respond.Error(w, http.StatusForbidden, "invalid or expired state")
+		return
+	}
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to get state")
+		return
+	}
+	if state.Provider != providerName || !state.ExpiresAt.After(time.Now()) {
+		respond.Error(w, http.StatusForbidden, "invalid or expired state")
+		return
+	}
+	if _, ok := h.ownedServer(w, r.Context(), state.ServerID, state.UserID); !ok {
+		return
+	}
 
 	code := r.URL.Query().Get("code")
 	if code == "" {
@@ -89,7 +156,7 @@ func (h *OAuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := oauthCfg.Exchange(r.Context(), code)
+	token, err := oauthCfg.Exchange(r.Context(), code, oauth2.VerifierOption(state.Verifier))
 	if err != nil {
 		respond.Error(w, http.StatusBadGateway, "token exchange failed")
 		return
@@ -103,11 +170,19 @@ func (h *OAuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 
 	grant := &OAuthGrant{
 		ID:             uuid.New(),
-		ServerID:       uuid.Nil,
+		ServerID:       state.ServerID,
+		OwnerID:        state.UserID,
 		Provider:       providerName,
 		AccessTokenEnc: encToken,
 		CreatedAt:      time.Now(),
 	}
+	if token.RefreshToken != "" {
+		grant.RefreshTokenEnc, err = crypto.Seal([]byte(token.RefreshToken), h.VaultKey)
+		if err != nil {
+			respond.Error(w, http.StatusInternalServerError, "failed to encrypt token")
+			return
+		}
+	}
 	if token.Expiry.IsZero() {
 		grant.ExpiresAt = nil
 	} else {
@@ -130,11 +205,137 @@ func (h *OAuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	})
 
 	respond.JSON(w, http.StatusOK, map[string]string{
-		"status":   "connected",
-		"provider": providerName,
+		"status":    "connected",
+		"provider":  providerName,
+		"server_id": state.ServerID.String(),
 	})
 }
 
+// ListGrants returns the user's grants, optionally only those of the server
+// named by the server_id query parameter.
+func (h *OAuthHandler) ListGrants(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mw.GetUserID(r.Context()))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	var serverID uuid.UUID
+	if q := r.URL.Query().Get("server_id"); q != "" {
+		if serverID, err = uuid.Parse(q); err != nil {
+			respond.Error(w, http.StatusBadRequest, "server_id must be a server id")
+			return
+		}
+	}
+
+	grants, err := h.Repo.ListOAuthGrants(r.Context(), userID)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to list grants")
+		return
+	}
+	if serverID != uuid.Nil {
+		grants = slices.DeleteFunc(grants, func(g OAuthGrant) bool { return g.ServerID != serverID })
+	}
+	respond.JSON(w, http.StatusOK, grants)
+}
+
+func (h *OAuthHandler) GetGrant(w http.ResponseWriter, r *http.Request) {
+	grant, ok := h.ownedGrant(w, r)
+	if !ok {
+		return
+	}
+	respond.JSON(w, http.StatusOK, grant)
+}
+
+// DeleteGrant revokes a grant at its provider, if the provider has a
+// revocation endpoint, and deletes it. The grant is kept if revocation fails.
+func (h *OAuthHandler) DeleteGrant(w http.ResponseWriter, r *http.Request) {
+	grant, ok := h.ownedGrant(w, r)
+	if !ok {
+		return
+	}
+	if err := h.revoke(r.Context(), grant); err != nil {
+		slog.Warn("revoking oauth grant", "grant_id", grant.ID, "provider", grant.Provider, "error", err)
+		respond.Error(w, http.StatusBadGateway, "token revocation failed")
+		return
+	}
+	if err := h.Repo.DeleteOAuthGrant(r.Context(), grant.ID); err != nil && !errors.Is(err, ErrNotFound) {
+		respond.Error(w, http.StatusInternalServerError, "failed to delete grant")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedGrant returns the grant named by the id URL parameter if the user
+// authorized it, and otherwise responds with an error.
+func (h *OAuthHandler) ownedGrant(w http.ResponseWriter, r *http.Request) (*OAuthGrant, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid grant id")
+		return nil, false
+	}
+	grant, err := h.Repo.GetOAuthGrant(r.Context(), id)
+	if errors.Is(err, ErrNotFound) || (err == nil && grant.OwnerID.String() != mw.GetUserID(r.Context())) {
+		respond.Error(w, http.StatusNotFound, "grant not found")
+		return nil, false
+	}
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to get grant")
+		return nil, false
+	}
+	return grant, true
+}
+
+// revoke asks the grant's provider to revoke its refresh token, which also
+// ends its access tokens, or its access token if it has none.
+func (h *OAuthHandler) revoke(ctx context.Context, grant *OAuthGrant) error {
+	endpoint := h.RevocationURLs[grant.Provider]
+	oauthCfg := h.Providers[grant.Provider]
+	if endpoint == "" || oauthCfg == nil {
+		return nil
+	}
+
+	sealed, hint := grant.AccessTokenEnc, "access_token"
+	if grant.RefreshTokenEnc != nil {
+		sealed, hint = grant.RefreshTokenEnc, "refresh_token"
+	}
+	token, err := crypto.Open(sealed, h.VaultKey)
+	if err != nil {
+		return fmt.Errorf("decrypting %s: %w", hint, err)
+	}
+
+	form := url.Values{"token": {string(token)}, "token_type_hint": {hint}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(oauthCfg.ClientID), url.QueryEscape(oauthCfg.ClientSecret))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("revocation endpoint returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ownedServer returns the server if it belongs to userID, and otherwise
+// responds with 404.
+func (h *OAuthHandler) ownedServer(w http.ResponseWriter, ctx context.Context, serverID, userID uuid.UUID) (*MCPServer, bool) {
+	server, err := h.Repo.GetServer(ctx, serverID)
+	if errors.Is(err, ErrNotFound) || (err == nil && server.OwnerID != userID) {
+		respond.Error(w, http.StatusNotFound, "server not found")
+		return nil, false
+	}
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to get server")
+		return nil, false
+	}
+	return server, true
+}
+
 func randomState() (string, error) {
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
@@ -142,3 +343,37 @@ func randomState() (string, error) {
 	}
 	return hex.EncodeToString(b), nil
 }
+
+// OAuthTokens reads the access tokens of the OAuth grants bound to servers.
+type OAuthTokens struct {
+	repo     Repository
+	vaultKey []byte
+}
+
+// NewOAuthTokens creates an OAuthTokens reading grants sealed with vaultKey.
+func NewOAuthTokens(repo Repository, vaultKey []byte) *OAuthTokens {
+	return &OAuthTokens{repo: repo, vaultKey: vaultKey}
+}
+
+// AccessToken returns the access token of the server's grant for provider.
+// It fails with ErrSecretUnavailable if the server has no usable grant.
+func (t *OAuthTokens) AccessToken(ctx context.Context, server *MCPServer, provider string) (string, error) {
+	grant, err := t.repo.GetServerOAuthGrant(ctx, server.ID, provider)
+	if errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("%w: no %s oauth grant for the server", ErrSecretUnavailable, provider)
+	}
+	if err != nil {
+		return "", fmt.Errorf("getting %s oauth grant: %w", provider, err)
+	}
+	if grant.OwnerID != server.OwnerID {
+		return "", fmt.Errorf("%w: the %s oauth grant was authorized by another user", ErrSecretUnavailable, provider)
+	}
+	if grant.ExpiresAt != nil && !grant.ExpiresAt.After(time.Now()) {
+		return "", fmt.Errorf("%w: the %s oauth grant has expired", ErrSecretUnavailable, provider)
+	}
+	token, err := crypto.Open(grant.AccessTokenEnc, t.vaultKey)
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s oauth grant: %w", provider, err)
+	}
+	return string(token), nil
+}
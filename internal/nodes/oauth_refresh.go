@@ -0,0 +1,182 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+
+	"github.com/kapella-hub/NexusClaw/internal/platform/crypto"
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+)
+
+// OAuthRefreshOptions tunes the OAuthRefresher.
+type OAuthRefreshOptions struct {
+	// Interval is how often grants are checked (1m).
+	Interval time.Duration
+	// Window is how long before it expires a grant is renewed (5m).
+	Window time.Duration
+}
+
+func (o *OAuthRefreshOptions) setDefaults() {
+	if o.Interval <= 0 {
+		o.Interval = time.Minute
+	}
+	if o.Window <= 0 {
+		o.Window = 5 * time.Minute
+	}
+}
+
+// OAuthRefresher renews OAuth grants with their refresh tokens before they
+// expire and passes the new access tokens to the running servers the grants
+// are bound to. A failed refresh raises an alert for the grant's owner.
+type OAuthRefresher struct {
+	repo      Repository
+	providers map[string]*oauth2.Config
+	vaultKey  []byte
+	svc       Service
+	alerter   sentry.Alerter
+	opts      OAuthRefreshOptions
+
+	mu sync.Mutex
+	// failing holds the grants whose last refresh failed, so each run of
+	// failures raises a single alert.
+	failing map[uuid.UUID]bool
+}
+
+// NewOAuthRefresher creates a refresher. svc reloads the secrets of running
+// servers; alerter may be nil.
+func NewOAuthRefresher(repo Repository, providers map[string]*oauth2.Config, vaultKey []byte, svc Service, alerter sentry.Alerter, opts OAuthRefreshOptions) *OAuthRefresher {
+	opts.setDefaults()
+	return &OAuthRefresher{
+		repo:      repo,
+		providers: providers,
+		vaultKey:  vaultKey,
+		svc:       svc,
+		alerter:   alerter,
+		opts:      opts,
+		failing:   make(map[uuid.UUID]bool),
+	}
+}
+
+// Run refreshes grants until ctx is done.
+func (r *OAuthRefresher) Run(ctx context.Context) {
+	r.RefreshDue(ctx)
+
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.RefreshDue(ctx)
+		}
+	}
+}
+
+// RefreshDue renews the grants that expire within the refresh window.
+func (r *OAuthRefresher) RefreshDue(ctx context.Context) {
+	grants, err := r.repo.ListRefreshableOAuthGrants(ctx, time.Now().Add(r.opts.Window))
+	if err != nil {
+		slog.Error("listing oauth grants to refresh", "error", err)
+		return
+	}
+	for i := range grants {
+		grant := &grants[i]
+		err := r.refresh(ctx, grant)
+		if errors.Is(err, ErrNotFound) {
+			// The grant was replaced or deleted while it was refreshed.
+			continue
+		}
+		if err != nil {
+			r.fail(ctx, grant, err)
+			continue
+		}
+
+		r.mu.Lock()
+		delete(r.failing, grant.ID)
+		r.mu.Unlock()
+		slog.Info("oauth grant refreshed", "server_id", grant.ServerID, "provider", grant.Provider)
+
+		err = r.svc.ReloadOAuthSecrets(ctx, grant.ServerID, grant.Provider)
+		if err != nil && !errors.Is(err, ErrContainerNotAvailable) {
+			slog.Error("passing refreshed oauth token to server", "server_id", grant.ServerID, "provider", grant.Provider, "error", err)
+		}
+	}
+}
+
+// refresh exchanges the grant's refresh token for new tokens and stores them.
+func (r *OAuthRefresher) refresh(ctx context.Context, grant *OAuthGrant) error {
+	cfg, ok := r.providers[grant.Provider]
+	if !ok {
+		return fmt.Errorf("provider %s is not configured", grant.Provider)
+	}
+	refreshToken, err := crypto.Open(grant.RefreshTokenEnc, r.vaultKey)
+	if err != nil {
+		return fmt.Errorf("decrypting refresh token: %w", err)
+	}
+
+	// A token without an access token is never valid, so the source always
+	// asks the provider for a new one. It keeps the old refresh token if the
+	// provider does not rotate it.
+	token, err := cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: string(refreshToken)}).Token()
+	if err != nil {
+		return err
+	}
+
+	grant.AccessTokenEnc, err = crypto.Seal([]byte(token.AccessToken), r.vaultKey)
+	if err != nil {
+		return fmt.Errorf("encrypting access token: %w", err)
+	}
+	grant.RefreshTokenEnc, err = crypto.Seal([]byte(token.RefreshToken), r.vaultKey)
+	if err != nil {
+		return fmt.Errorf("encrypting refresh token: %w", err)
+	}
+	grant.ExpiresAt = nil
+	if !token.Expiry.IsZero() {
+		grant.ExpiresAt = &token.Expiry
+	}
+	return r.repo.UpdateOAuthGrantTokens(ctx, grant)
+}
+
+// fail logs a failed refresh and alerts the grant's owner, unless the
+// previous refresh of the grant failed too.
+func (r *OAuthRefresher) fail(ctx context.Context, grant *OAuthGrant, err error) {
+	slog.Warn("refreshing oauth grant", "server_id", grant.ServerID, "provider", grant.Provider, "error", err)
+
+	r.mu.Lock()
+	alerted := r.failing[grant.ID]
+	r.failing[grant.ID] = true
+	r.mu.Unlock()
+	if alerted || r.alerter == nil {
+		return
+	}
+
+	severity := sentry.SeverityMedium
+	if grant.ExpiresAt != nil && !grant.ExpiresAt.After(time.Now()) {
+		// Servers can no longer start with the grant.
+		severity = sentry.SeverityHigh
+	}
+	serverID := grant.ServerID
+	alert := &sentry.Alert{
+		UserID:   grant.OwnerID,
+		ServerID: &serverID,
+		Kind:     sentry.AlertKindOAuth,
+		Message:  fmt.Sprintf("refreshing the %s oauth grant failed: %v", grant.Provider, err),
+		Severity: severity,
+		Metadata: map[string]any{
+			"provider":   grant.Provider,
+			"grant_id":   grant.ID.String(),
+			"expires_at": grant.ExpiresAt,
+		},
+	}
+	if err := r.alerter.Raise(ctx, alert); err != nil {
+		slog.Error("raising oauth refresh alert", "server_id", grant.ServerID, "error", err)
+	}
+}
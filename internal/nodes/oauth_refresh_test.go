@@ -0,0 +1,131 @@
+package nodes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kapella-hub/NexusClaw/internal/platform/crypto"
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+	"golang.org/x/oauth2"
+)
+
+// recordingAlerter collects raised alerts.
+type recordingAlerter struct {
+	alerts []*sentry.Alert
+}
+
+func (a *recordingAlerter) Raise(_ context.Context, alert *sentry.Alert) error {
+	a.alerts = append(a.alerts, alert)
+	return nil
+}
+
+// fakeTokenEndpoint serves refresh_token grants for the refresh token "rt-1",
+// rotating it to "rt-2", and rejects any other as invalid_grant.
+func fakeTokenEndpoint(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "rt-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"gho_renewed","refresh_token":"rt-2","token_type":"bearer","expires_in":3600}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func refreshableGrant(t *testing.T, refreshToken string, expiresIn time.Duration) OAuthGrant {
+	t.Helper()
+	access, _ := crypto.Seal([]byte("gho_old"), oauthTestVaultKey)
+	refresh, _ := crypto.Seal([]byte(refreshToken), oauthTestVaultKey)
+	expires := time.Now().Add(expiresIn)
+	return OAuthGrant{
+		ID:              uuid.New(),
+		ServerID:        uuid.New(),
+		OwnerID:         uuid.New(),
+		Provider:        "github",
+		AccessTokenEnc:  access,
+		RefreshTokenEnc: refresh,
+		ExpiresAt:       &expires,
+	}
+}
+
+func TestOAuthRefresherRenewsGrants(t *testing.T) {
+	tokenSrv := fakeTokenEndpoint(t)
+	grant := refreshableGrant(t, "rt-1", time.Minute)
+	var window time.Time
+	var updated *OAuthGrant
+	repo := &mockRepo{
+		ListRefreshableOAuthGrantsFn: func(_ context.Context, before time.Time) ([]OAuthGrant, error) {
+			window = before
+			return []OAuthGrant{grant}, nil
+		},
+		UpdateOAuthGrantTokensFn: func(_ context.Context, g *OAuthGrant) error {
+			updated = g
+			return nil
+		},
+	}
+	var reloaded []string
+	svc := &mockService{ReloadOAuthSecretsFn: func(_ context.Context, id uuid.UUID, provider string) error {
+		reloaded = append(reloaded, id.String()+"/"+provider)
+		return nil
+	}}
+	providers := map[string]*oauth2.Config{"github": {ClientID: "client", Endpoint: oauth2.Endpoint{TokenURL: tokenSrv.URL}}}
+	r := NewOAuthRefresher(repo, providers, oauthTestVaultKey, svc, nil, OAuthRefreshOptions{Window: 10 * time.Minute})
+
+	r.RefreshDue(context.Background())
+
+	if until := time.Until(window); until < 9*time.Minute || until > 10*time.Minute {
+		t.Errorf("expected grants expiring within the window to be listed, got %v", until)
+	}
+	if updated == nil || updated.ID != grant.ID {
+		t.Fatalf("expected the grant's tokens to be updated, got %+v", updated)
+	}
+	access, _ := crypto.Open(updated.AccessTokenEnc, oauthTestVaultKey)
+	refresh, _ := crypto.Open(updated.RefreshTokenEnc, oauthTestVaultKey)
+	if string(access) != "gho_renewed" || string(refresh) != "rt-2" {
+		t.Errorf("expected the renewed tokens, got %q and %q", access, refresh)
+	}
+	if updated.ExpiresAt == nil || time.Until(*updated.ExpiresAt) < 59*time.Minute {
+		t.Errorf("expected the new expiry, got %v", updated.ExpiresAt)
+	}
+	if len(reloaded) != 1 || reloaded[0] != grant.ServerID.String()+"/github" {
+		t.Errorf("expected the server's secrets to be reloaded, got %v", reloaded)
+	}
+}
+
+func TestOAuthRefresherAlertsOnFailure(t *testing.T) {
+	tokenSrv := fakeTokenEndpoint(t)
+	grant := refreshableGrant(t, "rt-revoked", -time.Minute)
+	repo := &mockRepo{
+		ListRefreshableOAuthGrantsFn: func(_ context.Context, _ time.Time) ([]OAuthGrant, error) {
+			return []OAuthGrant{grant}, nil
+		},
+		UpdateOAuthGrantTokensFn: func(_ context.Context, g *OAuthGrant) error {
+			t.Errorf("expected no update after a failed refresh, got %+v", g)
+			return nil
+		},
+	}
+	svc := &mockService{}
+	alerter := &recordingAlerter{}
+	providers := map[string]*oauth2.Config{"github": {ClientID: "client", Endpoint: oauth2.Endpoint{TokenURL: tokenSrv.URL}}}
+	r := NewOAuthRefresher(repo, providers, oauthTestVaultKey, svc, alerter, OAuthRefreshOptions{})
+
+	r.RefreshDue(context.Background())
+	r.RefreshDue(context.Background())
+
+	if len(alerter.alerts) != 1 {
+		t.Fatalf("expected a single alert for repeated failures, got %d", len(alerter.alerts))
+	}
+	alert := alerter.alerts[0]
+	if alert.Kind != sentry.AlertKindOAuth || alert.UserID != grant.OwnerID || *alert.ServerID != grant.ServerID || alert.Severity != sentry.SeverityHigh {
+		t.Errorf("expected a high oauth alert for the grant's owner and server, got %+v", alert)
+	}
+}
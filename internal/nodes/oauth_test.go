@@ -0,0 +1,328 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kapella-hub/NexusClaw/internal/platform/crypto"
+	"github.com/kapella-hub/NexusClaw/internal/platform/middleware"
+	"golang.org/x/oauth2"
+)
+
+var oauthTestVaultKey = []byte("0123456789abcdef0123456789abcdef")
+
+// newOAuthTestHandler returns an OAuthHandler for a github provider whose
+// token endpoint issues the token "gho_issued" with the refresh token
+// "rt-issued" if given the PKCE verifier of the last flow started. Pending
+// flows are kept in memory.
+func newOAuthTestHandler(t *testing.T, repo *mockRepo) *OAuthHandler {
+	t.Helper()
+	states := make(map[string]*OAuthState)
+	var verifier string
+	repo.CreateOAuthStateFn = func(_ context.Context, st *OAuthState) error {
+		states[st.State] = st
+		verifier = st.Verifier
+		return nil
+	}
+	repo.ConsumeOAuthStateFn = func(_ context.Context, state string) (*OAuthState, error) {
+		st, ok := states[state]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		delete(states, state)
+		return st, nil
+	}
+
+	h := &OAuthHandler{
+		Repo:     repo,
+		VaultKey: oauthTestVaultKey,
+		AuthMW:   middleware.Auth(handlerTestSecret),
+	}
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code_verifier") != verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"gho_issued","refresh_token":"rt-issued","token_type":"bearer","expires_in":3600}`))
+	}))
+	t.Cleanup(tokenSrv.Close)
+	h.Providers = map[string]*oauth2.Config{"github": {
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://github.example/authorize", TokenURL: tokenSrv.URL},
+	}}
+	return h
+}
+
+// initiateOAuth starts a flow for serverID and returns the response.
+func initiateOAuth(h *OAuthHandler, serverID, userID uuid.UUID) *httptest.ResponseRecorder {
+	req := authenticatedRequest("GET", "/initiate/github?server_id="+serverID.String(), nil, userID.String())
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+	return rec
+}
+
+// oauthCallback completes a flow with the given state in the query and cookie.
+func oauthCallback(h *OAuthHandler, state string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/callback/github?code=abc&state="+url.QueryEscape(state), nil)
+	req.AddCookie(&http.Cookie{Name: "oauth_state", Value: state})
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOAuthFlowBindsGrantToServer(t *testing.T) {
+	serverID, ownerID := uuid.New(), uuid.New()
+	var stored *OAuthGrant
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, OwnerID: ownerID}, nil
+		},
+		CreateOAuthGrantFn: func(_ context.Context, grant *OAuthGrant) error {
+			stored = grant
+			return nil
+		},
+	}
+	h := newOAuthTestHandler(t, repo)
+
+	rec := initiateOAuth(h, serverID, ownerID)
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected 307, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	state := location.Query().Get("state")
+	if location.Query().Get("code_challenge_method") != "S256" || location.Query().Get("code_challenge") == "" {
+		t.Errorf("expected an S256 code challenge, got %s", location)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != state {
+		t.Fatalf("expected the state in a cookie, got %v", cookies)
+	}
+
+	rec = oauthCallback(h, state)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored == nil || stored.ServerID != serverID || stored.OwnerID != ownerID || stored.Provider != "github" {
+		t.Fatalf("expected a github grant bound to the server and owner, got %+v", stored)
+	}
+	token, err := crypto.Open(stored.AccessTokenEnc, oauthTestVaultKey)
+	if err != nil || string(token) != "gho_issued" {
+		t.Errorf("expected the sealed issued token, got %q %v", token, err)
+	}
+	refresh, err := crypto.Open(stored.RefreshTokenEnc, oauthTestVaultKey)
+	if err != nil || string(refresh) != "rt-issued" {
+		t.Errorf("expected the sealed refresh token, got %q %v", refresh, err)
+	}
+	if stored.ExpiresAt == nil {
+		t.Error("expected the grant to expire")
+	}
+
+	// A state is accepted only once.
+	if rec := oauthCallback(h, state); rec.Code != http.StatusForbidden {
+		t.Errorf("expected a replayed state to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestOAuthInitiateRequiresOwnedServer(t *testing.T) {
+	ownerID := uuid.New()
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, OwnerID: ownerID}, nil
+		},
+	}
+	h := newOAuthTestHandler(t, repo)
+
+	if rec := initiateOAuth(h, uuid.New(), uuid.New()); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's server, got %d", rec.Code)
+	}
+
+	req := authenticatedRequest("GET", "/initiate/github", nil, ownerID.String())
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a server_id, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/initiate/github?server_id="+uuid.NewString(), nil)
+	rec = httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rec.Code)
+	}
+}
+
+func TestOAuthCallbackRejectsInvalidState(t *testing.T) {
+	serverID, ownerID := uuid.New(), uuid.New()
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, OwnerID: ownerID}, nil
+		},
+		CreateOAuthGrantFn: func(_ context.Context, grant *OAuthGrant) error {
+			t.Errorf("expected no grant to be stored, got %+v", grant)
+			return nil
+		},
+	}
+	h := newOAuthTestHandler(t, repo)
+
+	pending := func(provider string, userID uuid.UUID, ttl time.Duration) string {
+		state, _ := randomState()
+		repo.CreateOAuthStateFn(context.Background(), &OAuthState{
+			State: state, UserID: userID, ServerID: serverID, Provider: provider,
+			Verifier: oauth2.GenerateVerifier(), ExpiresAt: time.Now().Add(ttl),
+		})
+		return state
+	}
+	for name, state := range map[string]string{
+		"unknown":        "0123456789abcdef0123456789abcdef",
+		"expired":        pending("github", ownerID, -time.Minute),
+		"other provider": pending("google", ownerID, time.Minute),
+		"other user":     pending("github", uuid.New(), time.Minute),
+	} {
+		if rec := oauthCallback(h, state); rec.Code != http.StatusForbidden && rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected the callback to be rejected, got %d", name, rec.Code)
+		}
+	}
+
+	state := pending("github", ownerID, time.Minute)
+	req := httptest.NewRequest("GET", "/callback/github?code=abc&state="+state, nil)
+	req.AddCookie(&http.Cookie{Name: "oauth_state", Value: pending("github", ownerID, time.Minute)})
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected a state not matching the cookie to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestOAuthGrants(t *testing.T) {
+	ownerID, serverID := uuid.New(), uuid.New()
+	access, _ := crypto.Seal([]byte("gho_token"), oauthTestVaultKey)
+	refresh, _ := crypto.Seal([]byte("rt-token"), oauthTestVaultKey)
+	grants := map[uuid.UUID]*OAuthGrant{}
+	for _, g := range []*OAuthGrant{
+		{ID: uuid.New(), ServerID: serverID, OwnerID: ownerID, Provider: "github", AccessTokenEnc: access, RefreshTokenEnc: refresh},
+		{ID: uuid.New(), ServerID: uuid.New(), OwnerID: ownerID, Provider: "github", AccessTokenEnc: access},
+		{ID: uuid.New(), ServerID: serverID, OwnerID: uuid.New(), Provider: "github", AccessTokenEnc: access},
+	} {
+		grants[g.ID] = g
+	}
+	repo := &mockRepo{
+		ListOAuthGrantsFn: func(_ context.Context, owner uuid.UUID) ([]OAuthGrant, error) {
+			var out []OAuthGrant
+			for _, g := range grants {
+				if g.OwnerID == owner {
+					out = append(out, *g)
+				}
+			}
+			return out, nil
+		},
+		GetOAuthGrantFn: func(_ context.Context, id uuid.UUID) (*OAuthGrant, error) {
+			if g, ok := grants[id]; ok {
+				return g, nil
+			}
+			return nil, ErrNotFound
+		},
+		DeleteOAuthGrantFn: func(_ context.Context, id uuid.UUID) error {
+			delete(grants, id)
+			return nil
+		},
+	}
+	var revoked url.Values
+	revocation := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, _ := r.BasicAuth(); user != "client" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.ParseForm()
+		revoked = r.PostForm
+	}))
+	defer revocation.Close()
+	h := newOAuthTestHandler(t, repo)
+	h.RevocationURLs = map[string]string{"github": revocation.URL}
+
+	do := func(method, path string, userID uuid.UUID) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.Routes().ServeHTTP(rec, authenticatedRequest(method, path, nil, userID.String()))
+		return rec
+	}
+
+	rec := do("GET", "/grants?server_id="+serverID.String(), ownerID)
+	var listed []OAuthGrant
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].ServerID != serverID || listed[0].OwnerID != ownerID {
+		t.Fatalf("expected the owner's grant for the server, got %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "token") {
+		t.Errorf("expected no tokens in the listing, got %s", rec.Body.String())
+	}
+	if rec := do("GET", "/grants", ownerID); !strings.Contains(rec.Body.String(), listed[0].ID.String()) || strings.Count(rec.Body.String(), `"id"`) != 2 {
+		t.Errorf("expected both of the owner's grants, got %s", rec.Body.String())
+	}
+
+	if rec := do("DELETE", "/grants/"+listed[0].ID.String(), uuid.New()); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting another user's grant, got %d", rec.Code)
+	}
+	if rec := do("DELETE", "/grants/"+listed[0].ID.String(), ownerID); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if revoked.Get("token") != "rt-token" || revoked.Get("token_type_hint") != "refresh_token" {
+		t.Errorf("expected the refresh token to be revoked, got %v", revoked)
+	}
+	if rec := do("GET", "/grants/"+listed[0].ID.String(), ownerID); rec.Code != http.StatusNotFound {
+		t.Errorf("expected the grant to be deleted, got %d", rec.Code)
+	}
+
+	h.RevocationURLs["github"] = revocation.URL + "/missing"
+	h.Providers["github"].ClientID = "other"
+	var remaining uuid.UUID
+	for id, g := range grants {
+		if g.OwnerID == ownerID {
+			remaining = id
+		}
+	}
+	if rec := do("DELETE", "/grants/"+remaining.String(), ownerID); rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 when revocation fails, got %d", rec.Code)
+	}
+	if _, ok := grants[remaining]; !ok {
+		t.Error("expected the grant to be kept when revocation fails")
+	}
+}
+
+func TestOAuthTokensAccessToken(t *testing.T) {
+	ownerID := uuid.New()
+	sealed, _ := crypto.Seal([]byte("gho_token"), oauthTestVaultKey)
+	future, past := time.Now().Add(time.Hour), time.Now().Add(-time.Hour)
+	grants := map[string]*OAuthGrant{
+		"github":  {OwnerID: ownerID, AccessTokenEnc: sealed, ExpiresAt: &future},
+		"expired": {OwnerID: ownerID, AccessTokenEnc: sealed, ExpiresAt: &past},
+		"foreign": {OwnerID: uuid.New(), AccessTokenEnc: sealed},
+	}
+	repo := &mockRepo{
+		GetServerOAuthGrantFn: func(_ context.Context, _ uuid.UUID, provider string) (*OAuthGrant, error) {
+			if g, ok := grants[provider]; ok {
+				return g, nil
+			}
+			return nil, ErrNotFound
+		},
+	}
+	tokens := NewOAuthTokens(repo, oauthTestVaultKey)
+	server := &MCPServer{ID: uuid.New(), OwnerID: ownerID}
+
+	token, err := tokens.AccessToken(context.Background(), server, "github")
+	if err != nil || token != "gho_token" {
+		t.Errorf("expected the grant's token, got %q %v", token, err)
+	}
+	for _, provider := range []string{"expired", "foreign", "missing"} {
+		if _, err := tokens.AccessToken(context.Background(), server, provider); !errors.Is(err, ErrSecretUnavailable) {
+			t.Errorf("%s: expected ErrSecretUnavailable, got %v", provider, err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+)
+
+// ServerOwners implements sentry.ServerOwners from the server records.
+type ServerOwners struct {
+	Repo Repository
+}
+
+var _ sentry.ServerOwners = (*ServerOwners)(nil)
+
+func (o *ServerOwners) ServerOwner(ctx context.Context, serverID uuid.UUID) (uuid.UUID, error) {
+	server, err := o.Repo.GetServer(ctx, serverID)
+	if errors.Is(err, ErrNotFound) {
+		return uuid.Nil, sentry.ErrNotFound
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return server.OwnerID, nil
+}
@@ -0,0 +1,541 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// pooledQueueSize bounds the messages buffered for a pooled client. When a
+// client falls this far behind, the shared backend reader waits for it.
+const pooledQueueSize = 256
+
+// serverPooled reports whether the server's clients share one pooled backend
+// session instead of each opening their own.
+func serverPooled(server *MCPServer) bool {
+	return server.Config.Pooled
+}
+
+// BackendPool holds one long-lived, initialized backend session per pooled
+// server and multiplexes client connections over it. Request IDs are
+// rewritten on the way to the backend so responses can be correlated back to
+// the client that sent the request.
+type BackendPool struct {
+	open func(ctx context.Context, server *MCPServer) (BackendConn, error)
+
+	mu       sync.Mutex
+	backends map[uuid.UUID]*pooledBackend
+}
+
+// NewBackendPool creates a pool that opens backend sessions with open.
+func NewBackendPool(open func(ctx context.Context, server *MCPServer) (BackendConn, error)) *BackendPool {
+	return &BackendPool{
+		open:     open,
+		backends: make(map[uuid.UUID]*pooledBackend),
+	}
+}
+
+// Connect returns a client connection for userID multiplexed over the
+// server's pooled backend session, opening the session if there is none.
+func (p *BackendPool) Connect(ctx context.Context, server *MCPServer, userID uuid.UUID) (BackendConn, error) {
+	p.mu.Lock()
+	b := p.backends[server.ID]
+	p.mu.Unlock()
+	if b != nil {
+		return b.attach(userID), nil
+	}
+
+	// The session outlives the request that opened it.
+	conn, err := p.open(context.WithoutCancel(ctx), server)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if existing := p.backends[server.ID]; existing != nil {
+		p.mu.Unlock()
+		conn.Close()
+		return existing.attach(userID), nil
+	}
+	b = newPooledBackend(conn)
+	p.backends[server.ID] = b
+	p.mu.Unlock()
+
+	go func() {
+		b.read()
+		p.mu.Lock()
+		if p.backends[server.ID] == b {
+			delete(p.backends, server.ID)
+		}
+		p.mu.Unlock()
+	}()
+	return b.attach(userID), nil
+}
+
+// pooledRoute records where the response to a rewritten request goes.
+type pooledRoute struct {
+	client *pooledConn
+	id     json.RawMessage
+	// progress is the gateway's progress token for the request, if any.
+	progress string
+	// init is set when the request is the session's initialize handshake.
+	init *pooledInit
+}
+
+// pooledInit is a backend session's initialize handshake, performed once on
+// behalf of the first client and replayed to later ones.
+type pooledInit struct {
+	done   chan struct{}
+	result json.RawMessage
+	err    *RPCError
+}
+
+// pooledBackend is one shared backend session and the clients attached to it.
+type pooledBackend struct {
+	conn BackendConn
+
+	mu          sync.Mutex
+	clients     map[*pooledConn]struct{}
+	latest      *pooledConn
+	nextID      uint64
+	routes      map[string]pooledRoute
+	progress    map[string]pooledRoute
+	init        *pooledInit
+	initialized bool
+	closed      bool
+}
+
+func newPooledBackend(conn BackendConn) *pooledBackend {
+	return &pooledBackend{
+		conn:     conn,
+		clients:  make(map[*pooledConn]struct{}),
+		routes:   make(map[string]pooledRoute),
+		progress: make(map[string]pooledRoute),
+	}
+}
+
+func (b *pooledBackend) attach(userID uuid.UUID) *pooledConn {
+	c := &pooledConn{
+		backend:       b,
+		userID:        userID,
+		subscriptions: make(map[string]bool),
+		in:            make(chan []byte, pooledQueueSize),
+		done:          make(chan struct{}),
+	}
+	b.mu.Lock()
+	if b.closed {
+		close(c.done)
+	} else {
+		b.clients[c] = struct{}{}
+		b.latest = c
+	}
+	b.mu.Unlock()
+	return c
+}
+
+// detach forgets c and the requests it has in flight.
+func (b *pooledBackend) detach(c *pooledConn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.clients, c)
+	for id, route := range b.routes {
+		if route.client == c {
+			delete(b.routes, id)
+			delete(b.progress, route.progress)
+		}
+	}
+	if b.latest == c {
+		b.latest = nil
+		for other := range b.clients {
+			b.latest = other
+			break
+		}
+	}
+}
+
+// read dispatches backend messages until the backend disconnects, then closes
+// every attached client.
+func (b *pooledBackend) read() {
+	for {
+		_, raw, err := b.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		b.dispatch(raw)
+	}
+
+	b.mu.Lock()
+	b.closed = true
+	clients := make([]*pooledConn, 0, len(b.clients))
+	for c := range b.clients {
+		clients = append(clients, c)
+	}
+	b.mu.Unlock()
+
+	b.conn.Close()
+	for _, c := range clients {
+		c.Close()
+	}
+}
+
+func (b *pooledBackend) dispatch(raw []byte) {
+	var msg rpcMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return
+	}
+
+	switch {
+	case !msg.isRequest():
+		key := rpcID(msg.ID)
+		b.mu.Lock()
+		route, ok := b.routes[key]
+		delete(b.routes, key)
+		delete(b.progress, route.progress)
+		b.mu.Unlock()
+		if !ok {
+			return
+		}
+		if route.init != nil {
+			route.init.result, route.init.err = msg.Result, msg.Error
+			if msg.Error != nil {
+				// Let the next client retry the handshake.
+				b.mu.Lock()
+				b.init = nil
+				b.mu.Unlock()
+			}
+			close(route.init.done)
+		}
+		msg.ID = route.id
+		route.client.deliverMessage(&msg)
+
+	case msg.Method == "notifications/progress":
+		var p struct {
+			Token json.RawMessage `json:"progressToken"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		b.mu.Lock()
+		route, ok := b.progress[rpcID(p.Token)]
+		b.mu.Unlock()
+		if !ok {
+			return
+		}
+		params, err := setParam(msg.Params, "progressToken", json.RawMessage(route.id))
+		if err != nil {
+			return
+		}
+		msg.Params = params
+		route.client.deliverMessage(&msg)
+
+	case msg.Method == "notifications/resources/updated":
+		// Resource updates go to the clients subscribed to the resource.
+		uri := resourceURI(msg.Params)
+		b.mu.Lock()
+		clients := make([]*pooledConn, 0, len(b.clients))
+		for c := range b.clients {
+			if c.subscriptions[uri] {
+				clients = append(clients, c)
+			}
+		}
+		b.mu.Unlock()
+		for _, c := range clients {
+			c.deliver(raw)
+		}
+
+	case msg.isNotification():
+		// List changes and log messages concern every client of the session.
+		b.mu.Lock()
+		clients := make([]*pooledConn, 0, len(b.clients))
+		for c := range b.clients {
+			clients = append(clients, c)
+		}
+		b.mu.Unlock()
+		for _, c := range clients {
+			c.deliver(raw)
+		}
+
+	default:
+		// Server-initiated requests go to one client; its response passes
+		// back with the backend's own ID.
+		c, err := b.requester()
+		if err != nil {
+			b.conn.WriteMessage(websocket.TextMessage, rpcErrorResponse(msg.ID, err))
+			return
+		}
+		c.deliver(raw)
+	}
+}
+
+// requester picks the client a server-initiated request such as sampling or
+// elicitation goes to: the client with the most recent request in flight,
+// which the server is most likely acting for, or with none in flight the most
+// recently attached client. The request is refused unless all those clients
+// belong to the same user, so that no user sees or answers a request made on
+// behalf of another.
+func (b *pooledBackend) requester() (*pooledConn, *RPCError) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	users := make(map[uuid.UUID]bool)
+	var c *pooledConn
+	var newest uint64
+	for id, route := range b.routes {
+		users[route.client.userID] = true
+		if n, _ := strconv.ParseUint(id, 10, 64); n > newest {
+			newest, c = n, route.client
+		}
+	}
+	if c == nil {
+		for other := range b.clients {
+			users[other.userID] = true
+		}
+		c = b.latest
+	}
+	switch {
+	case c == nil:
+		return nil, &RPCError{Code: codeMethodNotFound, Message: "no client attached"}
+	case len(users) > 1:
+		return nil, &RPCError{Code: codeMethodNotFound, Message: "request cannot be attributed to a single user of the pooled session"}
+	}
+	return c, nil
+}
+
+// send writes a client message to the backend.
+func (b *pooledBackend) send(c *pooledConn, raw []byte) error {
+	var msg rpcMessage
+	if err := json.Unmarshal(raw, &msg); err != nil || !msg.isRequest() {
+		// Responses to server-initiated requests carry the backend's IDs.
+		return b.conn.WriteMessage(websocket.TextMessage, raw)
+	}
+
+	switch {
+	case msg.Method == "notifications/initialized":
+		b.mu.Lock()
+		sent := b.initialized
+		b.initialized = true
+		b.mu.Unlock()
+		if sent {
+			return nil
+		}
+	case msg.Method == "notifications/cancelled":
+		return b.cancel(c, &msg)
+	case msg.Method == "initialize":
+		return b.initialize(c, &msg)
+	case msg.Method == "resources/subscribe":
+		b.mu.Lock()
+		c.subscriptions[resourceURI(msg.Params)] = true
+		b.mu.Unlock()
+		return b.forward(c, &msg, nil)
+	case msg.Method == "resources/unsubscribe":
+		return b.unsubscribe(c, &msg)
+	case !msg.isNotification():
+		return b.forward(c, &msg, nil)
+	}
+	return b.conn.WriteMessage(websocket.TextMessage, raw)
+}
+
+// unsubscribe drops the client's subscription to a resource. The backend is
+// only unsubscribed once no other client is subscribed to it.
+func (b *pooledBackend) unsubscribe(c *pooledConn, msg *rpcMessage) error {
+	uri := resourceURI(msg.Params)
+	b.mu.Lock()
+	delete(c.subscriptions, uri)
+	shared := false
+	for other := range b.clients {
+		if other.subscriptions[uri] {
+			shared = true
+			break
+		}
+	}
+	b.mu.Unlock()
+	if shared {
+		c.deliverMessage(&rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{}`)})
+		return nil
+	}
+	return b.forward(c, msg, nil)
+}
+
+// forward rewrites a client request's ID, and its progress token if it has
+// one, to gateway-assigned values and writes it to the backend.
+func (b *pooledBackend) forward(c *pooledConn, msg *rpcMessage, init *pooledInit) error {
+	route := pooledRoute{client: c, id: msg.ID, init: init}
+
+	b.mu.Lock()
+	b.nextID++
+	id := strconv.FormatUint(b.nextID, 10)
+	if token := progressToken(msg.Params); token != nil {
+		route.progress = strconv.Quote("nexusclaw-" + id)
+		b.progress[route.progress] = pooledRoute{client: c, id: token}
+	}
+	b.routes[id] = route
+	b.mu.Unlock()
+
+	msg.ID = json.RawMessage(id)
+	if route.progress != "" {
+		params, err := withProgressToken(msg.Params, json.RawMessage(route.progress))
+		if err != nil {
+			return err
+		}
+		msg.Params = params
+	}
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.conn.WriteMessage(websocket.TextMessage, out)
+}
+
+// initialize performs the session's handshake for the first client and
+// answers later clients with the backend's original result.
+func (b *pooledBackend) initialize(c *pooledConn, msg *rpcMessage) error {
+	b.mu.Lock()
+	init := b.init
+	first := init == nil
+	if first {
+		init = &pooledInit{done: make(chan struct{})}
+		b.init = init
+	}
+	b.mu.Unlock()
+	if first {
+		return b.forward(c, msg, init)
+	}
+
+	go func() {
+		select {
+		case <-init.done:
+		case <-c.done:
+			return
+		}
+		c.deliverMessage(&rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: init.result, Error: init.err})
+	}()
+	return nil
+}
+
+// cancel translates the request ID of a client's cancellation. Cancellations
+// of requests that are not in flight are dropped.
+func (b *pooledBackend) cancel(c *pooledConn, msg *rpcMessage) error {
+	var p struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if err := json.Unmarshal(msg.Params, &p); err != nil {
+		return nil
+	}
+
+	var id string
+	b.mu.Lock()
+	for gwID, route := range b.routes {
+		if route.client == c && rpcID(route.id) == rpcID(p.RequestID) {
+			id = gwID
+			break
+		}
+	}
+	b.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+
+	params, err := setParam(msg.Params, "requestId", json.RawMessage(id))
+	if err != nil {
+		return nil
+	}
+	msg.Params = params
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.conn.WriteMessage(websocket.TextMessage, out)
+}
+
+// pooledConn is one client's view of a pooled backend session.
+type pooledConn struct {
+	backend *pooledBackend
+	userID  uuid.UUID
+	// subscriptions holds the URIs of the resources the client subscribed
+	// to. It is guarded by the backend's mutex.
+	subscriptions map[string]bool
+	in            chan []byte
+	done          chan struct{}
+	once          sync.Once
+}
+
+func (c *pooledConn) ReadMessage() (int, []byte, error) {
+	select {
+	case msg := <-c.in:
+		return websocket.TextMessage, msg, nil
+	case <-c.done:
+		return 0, nil, io.EOF
+	}
+}
+
+func (c *pooledConn) WriteMessage(_ int, data []byte) error {
+	select {
+	case <-c.done:
+		return io.ErrClosedPipe
+	default:
+	}
+	return c.backend.send(c, data)
+}
+
+// Close detaches the client. The backend session stays open for others.
+func (c *pooledConn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+		c.backend.detach(c)
+	})
+	return nil
+}
+
+func (c *pooledConn) deliver(msg []byte) {
+	select {
+	case c.in <- msg:
+	case <-c.done:
+	}
+}
+
+func (c *pooledConn) deliverMessage(msg *rpcMessage) {
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	c.deliver(out)
+}
+
+// resourceURI returns the uri in the params of a resource subscription or
+// update.
+func resourceURI(params json.RawMessage) string {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	json.Unmarshal(params, &p)
+	return p.URI
+}
+
+// progressToken returns the progress token in a request's params, if any.
+func progressToken(params json.RawMessage) json.RawMessage {
+	var p struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+	return p.Meta.ProgressToken
+}
+
+// withProgressToken returns params with _meta.progressToken set to token.
+func withProgressToken(params, token json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(params, &fields); err != nil {
+		return nil, err
+	}
+	meta, err := setParam(fields["_meta"], "progressToken", token)
+	if err != nil {
+		return nil, err
+	}
+	return setParam(params, "_meta", meta)
+}
@@ -0,0 +1,229 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// newStdioStatefulServer starts a stdio MCP server that counts initialize
+// requests, reports progress for requests carrying a progress token, and
+// emits a list_changed notification on "notify", an update of file:///a on
+// "update", and a sampling request before its response on "sample" and after
+// it on "sample-later". Answers to its requests are echoed as
+// notifications/answered.
+func newStdioStatefulServer(inits *atomic.Int32) *stdioServer {
+	return newStdioServer(func(line []byte) [][]byte {
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil || msg.isNotification() {
+			return nil
+		}
+		if !msg.isRequest() {
+			answered, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: "notifications/answered", Params: line})
+			return [][]byte{answered}
+		}
+		var out [][]byte
+		if msg.Method == "initialize" {
+			inits.Add(1)
+		}
+		if token := progressToken(msg.Params); token != nil {
+			out = append(out, []byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":`+string(token)+`,"progress":1}}`))
+		}
+		sampling := []byte(`{"jsonrpc":"2.0","id":"s1","method":"sampling/createMessage","params":{}}`)
+		switch msg.Method {
+		case "notify":
+			out = append(out, []byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`))
+		case "update":
+			out = append(out, []byte(`{"jsonrpc":"2.0","method":"notifications/resources/updated","params":{"uri":"file:///a"}}`))
+		case "sample":
+			out = append(out, sampling)
+		}
+		result, _ := json.Marshal(map[string]string{"method": msg.Method, "id": string(msg.ID)})
+		resp, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		out = append(out, resp)
+		if msg.Method == "sample-later" {
+			out = append(out, sampling)
+		}
+		return out
+	})
+}
+
+func readPooled(t *testing.T, conn BackendConn) rpcMessage {
+	t.Helper()
+	type read struct {
+		msg []byte
+		err error
+	}
+	ch := make(chan read, 1)
+	go func() {
+		_, msg, err := conn.ReadMessage()
+		ch <- read{msg, err}
+	}()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			t.Fatalf("read: %v", r.err)
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(r.msg, &msg); err != nil {
+			t.Fatalf("decode %s: %v", r.msg, err)
+		}
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out reading pooled connection")
+		return rpcMessage{}
+	}
+}
+
+func newTestPool(t *testing.T, inits *atomic.Int32, opens *atomic.Int32) (*BackendPool, *MCPServer) {
+	t.Helper()
+	pool := NewBackendPool(func(_ context.Context, _ *MCPServer) (BackendConn, error) {
+		opens.Add(1)
+		return newStdioConn(newStdioStatefulServer(inits)), nil
+	})
+	return pool, &MCPServer{ID: uuid.New(), Config: ServerSpec{Pooled: true}}
+}
+
+func TestBackendPoolSharesInitializedSession(t *testing.T) {
+	var inits, opens atomic.Int32
+	pool, server := newTestPool(t, &inits, &opens)
+
+	a, err := pool.Connect(context.Background(), server, uuid.New())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer a.Close()
+	b, _ := pool.Connect(context.Background(), server, uuid.New())
+	defer b.Close()
+
+	a.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if msg := readPooled(t, a); string(msg.ID) != "1" || msg.Result == nil {
+		t.Fatalf("expected initialize result for a, got %+v", msg)
+	}
+	b.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":"init","method":"initialize","params":{}}`))
+	if msg := readPooled(t, b); string(msg.ID) != `"init"` || msg.Result == nil {
+		t.Fatalf("expected replayed initialize result for b, got %+v", msg)
+	}
+	if n := inits.Load(); n != 1 {
+		t.Errorf("expected the backend to be initialized once, got %d", n)
+	}
+	if n := opens.Load(); n != 1 {
+		t.Errorf("expected one backend session, got %d", n)
+	}
+
+	// Both clients use the same request ID; each gets its own response.
+	a.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":7,"method":"tools/list"}`))
+	b.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":7,"method":"prompts/list"}`))
+	if msg := readPooled(t, a); string(msg.ID) != "7" || !strings.Contains(string(msg.Result), "tools/list") {
+		t.Errorf("expected a's tools/list response, got %+v %s", msg, msg.Result)
+	}
+	if msg := readPooled(t, b); string(msg.ID) != "7" || !strings.Contains(string(msg.Result), "prompts/list") {
+		t.Errorf("expected b's prompts/list response, got %+v %s", msg, msg.Result)
+	}
+}
+
+func TestBackendPoolRoutesNotifications(t *testing.T) {
+	var inits, opens atomic.Int32
+	pool, server := newTestPool(t, &inits, &opens)
+	a, _ := pool.Connect(context.Background(), server, uuid.New())
+	defer a.Close()
+	b, _ := pool.Connect(context.Background(), server, uuid.New())
+	defer b.Close()
+
+	a.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"x","_meta":{"progressToken":"tok"}}}`))
+	if msg := readPooled(t, a); msg.Method != "notifications/progress" || !strings.Contains(string(msg.Params), `"progressToken":"tok"`) {
+		t.Errorf("expected progress with a's own token, got %+v %s", msg, msg.Params)
+	}
+	readPooled(t, a)
+
+	b.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"notify"}`))
+	if msg := readPooled(t, a); msg.Method != "notifications/tools/list_changed" {
+		t.Errorf("expected list_changed fanned out to a, got %+v", msg)
+	}
+	if msg := readPooled(t, b); msg.Method != "notifications/tools/list_changed" {
+		t.Errorf("expected list_changed for b, got %+v", msg)
+	}
+}
+
+func TestBackendPoolKeepsSessionAcrossClients(t *testing.T) {
+	var inits, opens atomic.Int32
+	pool, server := newTestPool(t, &inits, &opens)
+
+	a, _ := pool.Connect(context.Background(), server, uuid.New())
+	a.Close()
+	if _, _, err := a.ReadMessage(); err == nil {
+		t.Error("expected reads on a closed client to fail")
+	}
+
+	b, _ := pool.Connect(context.Background(), server, uuid.New())
+	defer b.Close()
+	b.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	readPooled(t, b)
+	if n := opens.Load(); n != 1 {
+		t.Errorf("expected the backend session to survive a client leaving, got %d opens", n)
+	}
+}
+
+func TestBackendPoolSendsResourceUpdatesToSubscribers(t *testing.T) {
+	var inits, opens atomic.Int32
+	pool, server := newTestPool(t, &inits, &opens)
+	a, _ := pool.Connect(context.Background(), server, uuid.New())
+	defer a.Close()
+	b, _ := pool.Connect(context.Background(), server, uuid.New())
+	defer b.Close()
+
+	a.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"resources/subscribe","params":{"uri":"file:///a"}}`))
+	readPooled(t, a)
+
+	b.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"update"}`))
+	if msg := readPooled(t, a); msg.Method != "notifications/resources/updated" {
+		t.Errorf("expected the update for the subscriber, got %+v", msg)
+	}
+	if msg := readPooled(t, b); string(msg.ID) != "2" {
+		t.Errorf("expected no update for the client that did not subscribe, got %+v", msg)
+	}
+}
+
+func TestBackendPoolRoutesServerRequestsWithinOneUser(t *testing.T) {
+	var inits, opens atomic.Int32
+	pool, server := newTestPool(t, &inits, &opens)
+	userA := uuid.New()
+	a, _ := pool.Connect(context.Background(), server, userA)
+	defer a.Close()
+	b, _ := pool.Connect(context.Background(), server, uuid.New())
+	defer b.Close()
+
+	// The request goes to the client whose request the server acts on, not
+	// to b, the most recently attached client.
+	a.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"sample"}`))
+	if msg := readPooled(t, a); msg.Method != "sampling/createMessage" {
+		t.Errorf("expected the sampling request for a, got %+v", msg)
+	}
+	readPooled(t, a)
+
+	// With no request in flight the request cannot be told apart between
+	// two users and is refused.
+	b.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"sample-later"}`))
+	readPooled(t, b)
+	for _, c := range []BackendConn{a, b} {
+		if msg := readPooled(t, c); msg.Method != "notifications/answered" || !strings.Contains(string(msg.Params), `"error"`) {
+			t.Errorf("expected the sampling request to be refused, got %+v", msg)
+		}
+	}
+
+	// Among one user's clients it goes to the most recently attached.
+	b.Close()
+	a2, _ := pool.Connect(context.Background(), server, userA)
+	defer a2.Close()
+	a.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":4,"method":"sample-later"}`))
+	readPooled(t, a)
+	if msg := readPooled(t, a2); msg.Method != "sampling/createMessage" {
+		t.Errorf("expected the sampling request for a's user, got %+v", msg)
+	}
+}
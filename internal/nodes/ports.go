@@ -0,0 +1,153 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PortAllocator assigns host ports from a fixed range to server containers so
+// several servers with the same internal port can run side by side.
+// Assignments are persisted and survive gateway restarts.
+type PortAllocator struct {
+	repo     Repository
+	min, max int
+
+	mu sync.Mutex
+}
+
+// NewPortAllocator creates an allocator for the inclusive range [min, max].
+func NewPortAllocator(repo Repository, min, max int) (*PortAllocator, error) {
+	if min <= 0 || max > 65535 || min > max {
+		return nil, fmt.Errorf("invalid port range %d-%d", min, max)
+	}
+	return &PortAllocator{repo: repo, min: min, max: max}, nil
+}
+
+// Allocate returns the host port assigned to serverID, assigning the lowest
+// free port in the range if it has none. It returns ErrNoFreePorts when the
+// range is exhausted.
+func (a *PortAllocator) Allocate(ctx context.Context, serverID uuid.UUID) (int, error) {
+	return a.allocate(ctx, serverID, false)
+}
+
+// AllocateStandby is Allocate for the standby port of a server's new
+// container during a blue/green update.
+func (a *PortAllocator) AllocateStandby(ctx context.Context, serverID uuid.UUID) (int, error) {
+	return a.allocate(ctx, serverID, true)
+}
+
+func (a *PortAllocator) allocate(ctx context.Context, serverID uuid.UUID, standby bool) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	allocs, err := a.repo.ListPortAllocations(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("listing port allocations: %w", err)
+	}
+	used := make(map[int]bool, len(allocs))
+	for _, alloc := range allocs {
+		if alloc.ServerID == serverID && alloc.Standby == standby {
+			return alloc.Port, nil
+		}
+		used[alloc.Port] = true
+	}
+
+	for port := a.min; port <= a.max; port++ {
+		if used[port] {
+			continue
+		}
+		err := a.repo.CreatePortAllocation(ctx, &PortAllocation{
+			Port:      port,
+			ServerID:  serverID,
+			Standby:   standby,
+			CreatedAt: time.Now(),
+		})
+		if errors.Is(err, ErrPortTaken) {
+			// Taken by another gateway instance since we listed.
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("allocating port %d: %w", port, err)
+		}
+		return port, nil
+	}
+	return 0, ErrNoFreePorts
+}
+
+// Release frees the port assigned to serverID, if any. The standby port of an
+// update in progress is kept for the new container.
+func (a *PortAllocator) Release(ctx context.Context, serverID uuid.UUID) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.repo.DeletePortAllocation(ctx, serverID); err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("releasing port: %w", err)
+	}
+	return nil
+}
+
+// ReleaseStandby frees the standby port assigned to serverID, if any.
+func (a *PortAllocator) ReleaseStandby(ctx context.Context, serverID uuid.UUID) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.repo.DeleteStandbyPortAllocation(ctx, serverID); err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("releasing standby port: %w", err)
+	}
+	return nil
+}
+
+// Promote makes the standby port of serverID its port once the new container
+// has replaced the old one, freeing the old container's port.
+func (a *PortAllocator) Promote(ctx context.Context, serverID uuid.UUID) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.repo.PromotePortAllocation(ctx, serverID); err != nil {
+		return fmt.Errorf("promoting standby port: %w", err)
+	}
+	return nil
+}
+
+// Reconcile releases allocations held by servers that no longer have a
+// container, such as those left behind when the gateway stopped mid-start,
+// and standby ports of updates the gateway stopped in the middle of. Servers
+// whose containers are still up keep their ports.
+func (a *PortAllocator) Reconcile(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	allocs, err := a.repo.ListPortAllocations(ctx)
+	if err != nil {
+		return fmt.Errorf("listing port allocations: %w", err)
+	}
+
+	released := 0
+	for _, alloc := range allocs {
+		server, err := a.repo.GetServer(ctx, alloc.ServerID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("getting server %s: %w", alloc.ServerID, err)
+		}
+		if server != nil && server.ContainerID != "" && !alloc.Standby {
+			continue
+		}
+		release := a.repo.DeletePortAllocation
+		if alloc.Standby {
+			release = a.repo.DeleteStandbyPortAllocation
+		}
+		if err := release(ctx, alloc.ServerID); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("releasing port %d: %w", alloc.Port, err)
+		}
+		released++
+	}
+	if released > 0 {
+		slog.Info("released stale port allocations", "count", released)
+	}
+	return nil
+}
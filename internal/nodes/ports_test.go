@@ -0,0 +1,226 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// portRepo returns a mockRepo that stores port allocations in memory and
+// serves servers from the given map.
+func portRepo(servers map[uuid.UUID]*MCPServer) *mockRepo {
+	allocs := map[int]PortAllocation{}
+	return &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			if s, ok := servers[id]; ok {
+				return s, nil
+			}
+			return nil, ErrNotFound
+		},
+		ListPortAllocationsFn: func(_ context.Context) ([]PortAllocation, error) {
+			var out []PortAllocation
+			for _, a := range allocs {
+				out = append(out, a)
+			}
+			sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+			return out, nil
+		},
+		CreatePortAllocationFn: func(_ context.Context, a *PortAllocation) error {
+			if _, ok := allocs[a.Port]; ok {
+				return ErrPortTaken
+			}
+			for _, b := range allocs {
+				if b.ServerID == a.ServerID && b.Standby == a.Standby {
+					return ErrPortTaken
+				}
+			}
+			allocs[a.Port] = *a
+			return nil
+		},
+		DeletePortAllocationFn: func(_ context.Context, id uuid.UUID) error {
+			found := false
+			for port, a := range allocs {
+				if a.ServerID == id && !a.Standby {
+					delete(allocs, port)
+					found = true
+				}
+			}
+			if !found {
+				return ErrNotFound
+			}
+			return nil
+		},
+		DeleteStandbyPortAllocationFn: func(_ context.Context, id uuid.UUID) error {
+			for port, a := range allocs {
+				if a.ServerID == id && a.Standby {
+					delete(allocs, port)
+					return nil
+				}
+			}
+			return ErrNotFound
+		},
+		PromotePortAllocationFn: func(_ context.Context, id uuid.UUID) error {
+			standby := -1
+			for port, a := range allocs {
+				if a.ServerID == id && a.Standby {
+					standby = port
+				}
+			}
+			if standby < 0 {
+				return ErrNotFound
+			}
+			for port, a := range allocs {
+				if a.ServerID == id && !a.Standby {
+					delete(allocs, port)
+				}
+			}
+			a := allocs[standby]
+			a.Standby = false
+			allocs[standby] = a
+			return nil
+		},
+	}
+}
+
+func TestPortAllocatorAllocatesDistinctPorts(t *testing.T) {
+	ports, err := NewPortAllocator(portRepo(nil), 20000, 20001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+
+	pa, err := ports.Allocate(ctx, a)
+	if err != nil || pa != 20000 {
+		t.Fatalf("expected 20000, got %d (%v)", pa, err)
+	}
+	if again, _ := ports.Allocate(ctx, a); again != pa {
+		t.Errorf("expected existing allocation %d to be reused, got %d", pa, again)
+	}
+	pb, err := ports.Allocate(ctx, b)
+	if err != nil || pb != 20001 {
+		t.Fatalf("expected 20001, got %d (%v)", pb, err)
+	}
+	if _, err := ports.Allocate(ctx, c); !errors.Is(err, ErrNoFreePorts) {
+		t.Fatalf("expected ErrNoFreePorts, got %v", err)
+	}
+
+	if err := ports.Release(ctx, a); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := ports.Release(ctx, a); err != nil {
+		t.Errorf("releasing twice should be a no-op, got %v", err)
+	}
+	if pc, err := ports.Allocate(ctx, c); err != nil || pc != 20000 {
+		t.Errorf("expected released port 20000 to be reused, got %d (%v)", pc, err)
+	}
+}
+
+func TestPortAllocatorReconcile(t *testing.T) {
+	running, stopped := uuid.New(), uuid.New()
+	repo := portRepo(map[uuid.UUID]*MCPServer{
+		running: {ID: running, Status: StatusRunning, ContainerID: "c1"},
+		stopped: {ID: stopped, Status: StatusStopped},
+	})
+	ports, _ := NewPortAllocator(repo, 20000, 20010)
+	ctx := context.Background()
+	for _, id := range []uuid.UUID{running, stopped, uuid.New()} {
+		if _, err := ports.Allocate(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ports.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	allocs, _ := repo.ListPortAllocations(ctx)
+	if len(allocs) != 1 || allocs[0].ServerID != running {
+		t.Errorf("expected only the running server to keep its port, got %+v", allocs)
+	}
+}
+
+func TestPortAllocatorStandbyPorts(t *testing.T) {
+	id := uuid.New()
+	repo := portRepo(map[uuid.UUID]*MCPServer{
+		id: {ID: id, Status: StatusRunning, ContainerID: "c1"},
+	})
+	ports, _ := NewPortAllocator(repo, 20000, 20010)
+	ctx := context.Background()
+
+	active, _ := ports.Allocate(ctx, id)
+	standby, err := ports.AllocateStandby(ctx, id)
+	if err != nil || standby == active {
+		t.Fatalf("expected a second port for the standby container, got %d and %d (%v)", active, standby, err)
+	}
+	if again, _ := ports.Allocate(ctx, id); again != active {
+		t.Errorf("expected Allocate to keep returning the active port %d, got %d", active, again)
+	}
+
+	// An update interrupted by a gateway restart leaves its standby port.
+	if err := ports.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if allocs, _ := repo.ListPortAllocations(ctx); len(allocs) != 1 || allocs[0].Port != active {
+		t.Errorf("expected Reconcile to release only the standby port, got %+v", allocs)
+	}
+
+	// Stopping the server mid-update must not free the new container's port.
+	standby, _ = ports.AllocateStandby(ctx, id)
+	if err := ports.Release(ctx, id); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if allocs, _ := repo.ListPortAllocations(ctx); len(allocs) != 1 || allocs[0].Port != standby || !allocs[0].Standby {
+		t.Errorf("expected Release to keep the standby port, got %+v", allocs)
+	}
+
+	ports.Allocate(ctx, id)
+	if err := ports.Promote(ctx, id); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	if allocs, _ := repo.ListPortAllocations(ctx); len(allocs) != 1 || allocs[0].Port != standby || allocs[0].Standby {
+		t.Errorf("expected the standby port to replace the active one, got %+v", allocs)
+	}
+}
+
+func TestNewPortAllocatorRejectsBadRange(t *testing.T) {
+	if _, err := NewPortAllocator(nil, 30000, 20000); err == nil {
+		t.Error("expected error for inverted range")
+	}
+	if _, err := NewPortAllocator(nil, 0, 100); err == nil {
+		t.Error("expected error for port 0")
+	}
+}
+
+func TestStartServerPublishesAllocatedPort(t *testing.T) {
+	serverID := uuid.New()
+	server := &MCPServer{ID: serverID, Image: "mcp:latest", Status: StatusStopped}
+	repo := portRepo(map[uuid.UUID]*MCPServer{serverID: server})
+	repo.UpdateServerFn = func(_ context.Context, _ *MCPServer) error { return nil }
+	ports, _ := NewPortAllocator(repo, 20000, 20010)
+
+	cm := newMockContainerMgr()
+	var spec []string
+	cm.CreateFn = func(_ context.Context, cfg *ContainerConfig) (string, error) {
+		spec = cfg.Ports
+		return "container-abc", nil
+	}
+	svc := NewService(repo, cm, ports, nil, nil, nil, nil)
+	ctx := context.Background()
+
+	if err := svc.StartServer(ctx, serverID); err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+	if len(spec) != 1 || spec[0] != "20000:8080" {
+		t.Errorf("expected port spec 20000:8080, got %v", spec)
+	}
+
+	if err := svc.StopServer(ctx, serverID); err != nil {
+		t.Fatalf("StopServer failed: %v", err)
+	}
+	if allocs, _ := repo.ListPortAllocations(ctx); len(allocs) != 0 {
+		t.Errorf("expected port to be released on stop, got %+v", allocs)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -22,7 +24,7 @@ func NewPgRepository(pool *pgxpool.Pool) *PgRepository {
 
 func (r *PgRepository) ListServers(ctx context.Context, ownerID uuid.UUID) ([]MCPServer, error) {
 	rows, err := r.pool.Query(ctx,
-		`SELECT id, owner_id, name, image, status, config, container_id, tools, resources, created_at, updated_at
+		`SELECT id, owner_id, name, image, status, config, revision, container_id, endpoint, tools, resources, restart_count, last_exit_code, created_at, updated_at
 		 FROM mcp_servers WHERE owner_id = $1
 		 ORDER BY created_at DESC`,
 		ownerID,
@@ -36,7 +38,7 @@ func (r *PgRepository) ListServers(ctx context.Context, ownerID uuid.UUID) ([]MC
 	for rows.Next() {
 		var s MCPServer
 		var configBytes, toolsBytes, resourcesBytes []byte
-		if err := rows.Scan(&s.ID, &s.OwnerID, &s.Name, &s.Image, &s.Status, &configBytes, &s.ContainerID, &toolsBytes, &resourcesBytes, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.OwnerID, &s.Name, &s.Image, &s.Status, &configBytes, &s.Revision, &s.ContainerID, &s.Endpoint, &toolsBytes, &resourcesBytes, &s.RestartCount, &s.LastExitCode, &s.CreatedAt, &s.UpdatedAt); err != nil {
 			return nil, err
 		}
 		if configBytes != nil {
@@ -71,10 +73,10 @@ func (r *PgRepository) GetServer(ctx context.Context, id uuid.UUID) (*MCPServer,
 	var s MCPServer
 	var configBytes, toolsBytes, resourcesBytes []byte
 	err := r.pool.QueryRow(ctx,
-		`SELECT id, owner_id, name, image, status, config, container_id, tools, resources, created_at, updated_at
+		`SELECT id, owner_id, name, image, status, config, revision, container_id, endpoint, tools, resources, restart_count, last_exit_code, created_at, updated_at
 		 FROM mcp_servers WHERE id = $1`,
 		id,
-	).Scan(&s.ID, &s.OwnerID, &s.Name, &s.Image, &s.Status, &configBytes, &s.ContainerID, &toolsBytes, &resourcesBytes, &s.CreatedAt, &s.UpdatedAt)
+	).Scan(&s.ID, &s.OwnerID, &s.Name, &s.Image, &s.Status, &configBytes, &s.Revision, &s.ContainerID, &s.Endpoint, &toolsBytes, &resourcesBytes, &s.RestartCount, &s.LastExitCode, &s.CreatedAt, &s.UpdatedAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNotFound
 	}
@@ -120,9 +122,9 @@ func (r *PgRepository) CreateServer(ctx context.Context, server *MCPServer) erro
 	}
 
 	_, err = r.pool.Exec(ctx,
-		`INSERT INTO mcp_servers (id, owner_id, name, image, status, config, container_id, tools, resources, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
-		server.ID, server.OwnerID, server.Name, server.Image, server.Status, configBytes, server.ContainerID, toolsBytes, resourcesBytes, server.CreatedAt, server.UpdatedAt,
+		`INSERT INTO mcp_servers (id, owner_id, name, image, status, config, revision, container_id, endpoint, tools, resources, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		server.ID, server.OwnerID, server.Name, server.Image, server.Status, configBytes, server.Revision, server.ContainerID, server.Endpoint, toolsBytes, resourcesBytes, server.CreatedAt, server.UpdatedAt,
 	)
 	return err
 }
@@ -148,9 +150,40 @@ func (r *PgRepository) UpdateServer(ctx context.Context, server *MCPServer) erro
 	}
 
 	tag, err := r.pool.Exec(ctx,
-		`UPDATE mcp_servers SET name = $2, image = $3, status = $4, config = $5, container_id = $6, tools = $7, resources = $8, updated_at = $9
+		`UPDATE mcp_servers SET name = $2, image = $3, status = $4, config = $5, container_id = $6, endpoint = $7, tools = $8, resources = $9,
+		 restart_count = $10, last_exit_code = $11, updated_at = $12, revision = $13
 		 WHERE id = $1`,
-		server.ID, server.Name, server.Image, server.Status, configBytes, server.ContainerID, toolsBytes, resourcesBytes, server.UpdatedAt,
+		server.ID, server.Name, server.Image, server.Status, configBytes, server.ContainerID, server.Endpoint, toolsBytes, resourcesBytes,
+		server.RestartCount, server.LastExitCode, server.UpdatedAt, server.Revision,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) UpdateServerCapabilities(ctx context.Context, id uuid.UUID, tools, resources []any) error {
+	if tools == nil {
+		tools = []any{}
+	}
+	if resources == nil {
+		resources = []any{}
+	}
+	toolsBytes, err := json.Marshal(tools)
+	if err != nil {
+		return err
+	}
+	resourcesBytes, err := json.Marshal(resources)
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE mcp_servers SET tools = $2, resources = $3, updated_at = $4 WHERE id = $1`,
+		id, toolsBytes, resourcesBytes, time.Now(),
 	)
 	if err != nil {
 		return err
@@ -178,12 +211,12 @@ func (r *PgRepository) SearchServers(ctx context.Context, query string) ([]MCPSe
 
 	if query == "" {
 		rows, err = r.pool.Query(ctx,
-			`SELECT id, owner_id, name, image, status, config, container_id, tools, resources, created_at, updated_at
+			`SELECT id, owner_id, name, image, status, config, revision, container_id, endpoint, tools, resources, restart_count, last_exit_code, created_at, updated_at
 			 FROM mcp_servers ORDER BY created_at DESC`)
 	} else {
 		pattern := "%" + query + "%"
 		rows, err = r.pool.Query(ctx,
-			`SELECT id, owner_id, name, image, status, config, container_id, tools, resources, created_at, updated_at
+			`SELECT id, owner_id, name, image, status, config, revision, container_id, endpoint, tools, resources, restart_count, last_exit_code, created_at, updated_at
 			 FROM mcp_servers WHERE name ILIKE $1 OR image ILIKE $1
 			 ORDER BY created_at DESC`, pattern)
 	}
@@ -196,7 +229,7 @@ func (r *PgRepository) SearchServers(ctx context.Context, query string) ([]MCPSe
 	for rows.Next() {
 		var s MCPServer
 		var configBytes, toolsBytes, resourcesBytes []byte
-		if err := rows.Scan(&s.ID, &s.OwnerID, &s.Name, &s.Image, &s.Status, &configBytes, &s.ContainerID, &toolsBytes, &resourcesBytes, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.OwnerID, &s.Name, &s.Image, &s.Status, &configBytes, &s.Revision, &s.ContainerID, &s.Endpoint, &toolsBytes, &resourcesBytes, &s.RestartCount, &s.LastExitCode, &s.CreatedAt, &s.UpdatedAt); err != nil {
 			return nil, err
 		}
 		if configBytes != nil {
@@ -226,22 +259,193 @@ func (r *PgRepository) SearchServers(ctx context.Context, query string) ([]MCPSe
 	return servers, nil
 }
 
+func (r *PgRepository) CreateServerRevision(ctx context.Context, rev *ServerRevision) error {
+	configBytes, err := json.Marshal(rev.Config)
+	if err != nil {
+		return err
+	}
+	return r.pool.QueryRow(ctx,
+		`INSERT INTO mcp_server_revisions (server_id, revision, image, config, rollback_of, created_by, created_at)
+		 SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, $6 FROM mcp_server_revisions WHERE server_id = $1
+		 RETURNING revision`,
+		rev.ServerID, rev.Image, configBytes, rev.RollbackOf, rev.CreatedBy, rev.CreatedAt,
+	).Scan(&rev.Revision)
+}
+
+func (r *PgRepository) ListServerRevisions(ctx context.Context, serverID uuid.UUID) ([]ServerRevision, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT server_id, revision, image, config, rollback_of, created_by, created_at
+		 FROM mcp_server_revisions WHERE server_id = $1
+		 ORDER BY revision DESC`,
+		serverID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []ServerRevision{}
+	for rows.Next() {
+		rev, err := scanServerRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (r *PgRepository) GetServerRevision(ctx context.Context, serverID uuid.UUID, revision int) (*ServerRevision, error) {
+	rev, err := scanServerRevision(r.pool.QueryRow(ctx,
+		`SELECT server_id, revision, image, config, rollback_of, created_by, created_at
+		 FROM mcp_server_revisions WHERE server_id = $1 AND revision = $2`,
+		serverID, revision,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return rev, err
+}
+
+func scanServerRevision(row pgx.Row) (*ServerRevision, error) {
+	var rev ServerRevision
+	var configBytes []byte
+	if err := row.Scan(&rev.ServerID, &rev.Revision, &rev.Image, &configBytes, &rev.RollbackOf, &rev.CreatedBy, &rev.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(configBytes, &rev.Config); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
 func (r *PgRepository) CreateOAuthGrant(ctx context.Context, grant *OAuthGrant) error {
 	_, err := r.pool.Exec(ctx,
-		`INSERT INTO oauth_grants (id, server_id, provider, access_token_enc, refresh_token_enc, expires_at, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		grant.ID, grant.ServerID, grant.Provider, grant.AccessTokenEnc, grant.RefreshTokenEnc, grant.ExpiresAt, grant.CreatedAt,
+		`INSERT INTO oauth_grants (id, server_id, owner_id, provider, access_token_enc, refresh_token_enc, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (server_id, provider) DO UPDATE SET
+		   id = EXCLUDED.id, owner_id = EXCLUDED.owner_id, access_token_enc = EXCLUDED.access_token_enc,
+		   refresh_token_enc = EXCLUDED.refresh_token_enc, expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at`,
+		grant.ID, grant.ServerID, grant.OwnerID, grant.Provider, grant.AccessTokenEnc, grant.RefreshTokenEnc, grant.ExpiresAt, grant.CreatedAt,
 	)
 	return err
 }
 
 func (r *PgRepository) GetOAuthGrant(ctx context.Context, id uuid.UUID) (*OAuthGrant, error) {
+	return r.getOAuthGrant(ctx, `WHERE id = $1`, id)
+}
+
+func (r *PgRepository) GetServerOAuthGrant(ctx context.Context, serverID uuid.UUID, provider string) (*OAuthGrant, error) {
+	return r.getOAuthGrant(ctx, `WHERE server_id = $1 AND provider = $2`, serverID, provider)
+}
+
+func (r *PgRepository) ListRefreshableOAuthGrants(ctx context.Context, before time.Time) ([]OAuthGrant, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, server_id, owner_id, provider, access_token_enc, refresh_token_enc, expires_at, created_at
+		 FROM oauth_grants WHERE refresh_token_enc IS NOT NULL AND expires_at < $1
+		 ORDER BY expires_at`,
+		before,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []OAuthGrant
+	for rows.Next() {
+		var g OAuthGrant
+		if err := rows.Scan(&g.ID, &g.ServerID, &g.OwnerID, &g.Provider, &g.AccessTokenEnc, &g.RefreshTokenEnc, &g.ExpiresAt, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (r *PgRepository) UpdateOAuthGrantTokens(ctx context.Context, grant *OAuthGrant) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE oauth_grants SET access_token_enc = $2, refresh_token_enc = $3, expires_at = $4
+		 WHERE id = $1`,
+		grant.ID, grant.AccessTokenEnc, grant.RefreshTokenEnc, grant.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) ListOAuthGrants(ctx context.Context, ownerID uuid.UUID) ([]OAuthGrant, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, server_id, owner_id, provider, access_token_enc, refresh_token_enc, expires_at, created_at
+		 FROM oauth_grants WHERE owner_id = $1
+		 ORDER BY created_at DESC`,
+		ownerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []OAuthGrant{}
+	for rows.Next() {
+		var g OAuthGrant
+		if err := rows.Scan(&g.ID, &g.ServerID, &g.OwnerID, &g.Provider, &g.AccessTokenEnc, &g.RefreshTokenEnc, &g.ExpiresAt, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (r *PgRepository) DeleteOAuthGrant(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM oauth_grants WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) CreateOAuthState(ctx context.Context, state *OAuthState) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM oauth_states WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO oauth_states (state, user_id, server_id, provider, verifier, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		state.State, state.UserID, state.ServerID, state.Provider, state.Verifier, state.ExpiresAt, state.CreatedAt,
+	)
+	return err
+}
+
+func (r *PgRepository) ConsumeOAuthState(ctx context.Context, state string) (*OAuthState, error) {
+	var st OAuthState
+	err := r.pool.QueryRow(ctx,
+		`DELETE FROM oauth_states WHERE state = $1
+		 RETURNING state, user_id, server_id, provider, verifier, expires_at, created_at`,
+		state,
+	).Scan(&st.State, &st.UserID, &st.ServerID, &st.Provider, &st.Verifier, &st.ExpiresAt, &st.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (r *PgRepository) getOAuthGrant(ctx context.Context, where string, args ...any) (*OAuthGrant, error) {
 	var g OAuthGrant
 	err := r.pool.QueryRow(ctx,
-		`SELECT id, server_id, provider, access_token_enc, refresh_token_enc, expires_at, created_at
-		 FROM oauth_grants WHERE id = $1`,
-		id,
-	).Scan(&g.ID, &g.ServerID, &g.Provider, &g.AccessTokenEnc, &g.RefreshTokenEnc, &g.ExpiresAt, &g.CreatedAt)
+		`SELECT id, server_id, owner_id, provider, access_token_enc, refresh_token_enc, expires_at, created_at
+		 FROM oauth_grants `+where,
+		args...,
+	).Scan(&g.ID, &g.ServerID, &g.OwnerID, &g.Provider, &g.AccessTokenEnc, &g.RefreshTokenEnc, &g.ExpiresAt, &g.CreatedAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNotFound
 	}
@@ -250,3 +454,160 @@ func (r *PgRepository) GetOAuthGrant(ctx context.Context, id uuid.UUID) (*OAuthG
 	}
 	return &g, nil
 }
+
+func (r *PgRepository) ListAccessPolicies(ctx context.Context, serverID uuid.UUID) ([]AccessPolicy, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, server_id, principal_id, tools, resources, prompts, created_at, updated_at
+		 FROM mcp_access_policies WHERE server_id = $1
+		 ORDER BY principal_id NULLS FIRST, created_at`,
+		serverID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []AccessPolicy
+	for rows.Next() {
+		var p AccessPolicy
+		if err := rows.Scan(&p.ID, &p.ServerID, &p.PrincipalID, &p.Tools, &p.Resources, &p.Prompts, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if policies == nil {
+		policies = []AccessPolicy{}
+	}
+	return policies, nil
+}
+
+func (r *PgRepository) GetAccessPolicy(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) (*AccessPolicy, error) {
+	var p AccessPolicy
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, server_id, principal_id, tools, resources, prompts, created_at, updated_at
+		 FROM mcp_access_policies WHERE server_id = $1 AND principal_id IS NOT DISTINCT FROM $2`,
+		serverID, principalID,
+	).Scan(&p.ID, &p.ServerID, &p.PrincipalID, &p.Tools, &p.Resources, &p.Prompts, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *PgRepository) UpsertAccessPolicy(ctx context.Context, policy *AccessPolicy) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE mcp_access_policies SET tools = $3, resources = $4, prompts = $5, updated_at = $6
+		 WHERE server_id = $1 AND principal_id IS NOT DISTINCT FROM $2`,
+		policy.ServerID, policy.PrincipalID, policy.Tools, policy.Resources, policy.Prompts, policy.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	_, err = r.pool.Exec(ctx,
+		`INSERT INTO mcp_access_policies (id, server_id, principal_id, tools, resources, prompts, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		policy.ID, policy.ServerID, policy.PrincipalID, policy.Tools, policy.Resources, policy.Prompts, policy.CreatedAt, policy.UpdatedAt,
+	)
+	return err
+}
+
+func (r *PgRepository) DeleteAccessPolicy(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) error {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM mcp_access_policies WHERE server_id = $1 AND principal_id IS NOT DISTINCT FROM $2`,
+		serverID, principalID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) ListPortAllocations(ctx context.Context) ([]PortAllocation, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT port, server_id, standby, created_at FROM mcp_port_allocations ORDER BY port`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocs []PortAllocation
+	for rows.Next() {
+		var a PortAllocation
+		if err := rows.Scan(&a.Port, &a.ServerID, &a.Standby, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		allocs = append(allocs, a)
+	}
+	return allocs, rows.Err()
+}
+
+func (r *PgRepository) CreatePortAllocation(ctx context.Context, alloc *PortAllocation) error {
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO mcp_port_allocations (port, server_id, standby, created_at) VALUES ($1, $2, $3, $4)`,
+		alloc.Port, alloc.ServerID, alloc.Standby, alloc.CreatedAt,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrPortTaken
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *PgRepository) DeletePortAllocation(ctx context.Context, serverID uuid.UUID) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM mcp_port_allocations WHERE server_id = $1 AND NOT standby`, serverID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) DeleteStandbyPortAllocation(ctx context.Context, serverID uuid.UUID) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM mcp_port_allocations WHERE server_id = $1 AND standby`, serverID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) PromotePortAllocation(ctx context.Context, serverID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mcp_port_allocations WHERE server_id = $1 AND NOT standby`, serverID); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `UPDATE mcp_port_allocations SET standby = FALSE WHERE server_id = $1 AND standby`, serverID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
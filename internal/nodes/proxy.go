@@ -0,0 +1,299 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+)
+
+// wsConn serialises writes to a WebSocket connection, which gorilla/websocket
+// does not allow concurrently.
+type wsConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *wsConn) WriteMessage(mt int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteMessage(mt, data)
+}
+
+// proxySession relays JSON-RPC messages between one client WebSocket and its
+// backend MCP server, over whichever transport the backend speaks. Client
+// requests are rate limited, checked against the user's access policy, have
+// tools/call arguments validated against the tool's inputSchema, and are
+// passed through the Sentry enforcer before being forwarded; a request held
+// for approval blocks the session's client-to-backend stream until it is
+// decided. List responses are filtered by the access policy on the way back.
+type proxySession struct {
+	server   *MCPServer
+	userID   uuid.UUID
+	client   *wsConn
+	backend  BackendConn
+	limiter  *RateLimiter
+	policy   *AccessPolicy
+	schemas  toolSchemas
+	enforcer sentry.Enforcer
+	audit    sentry.AuditLogger
+
+	// pending maps in-flight list request IDs to their method so the
+	// responses can be filtered.
+	mu      sync.Mutex
+	pending map[string]string
+
+	// scaler, if set, is told of the session's traffic.
+	scaler *Scaler
+	// wake, if set, returns the running server, starting it if it was scaled
+	// to zero, and connects to it, so the session can be suspended while the
+	// server is stopped or moves to a new container. A resumed backend is
+	// passed to the relay on resumed.
+	wake    func(ctx context.Context) (*MCPServer, BackendConn, error)
+	resumed chan BackendConn
+	// lookup, if set, returns the server's current record, to tell whether
+	// a failed backend was replaced by an update.
+	lookup func(ctx context.Context) (*MCPServer, error)
+
+	// backendMu guards backend, containerID, suspended and the client's
+	// recorded initialize handshake.
+	backendMu sync.Mutex
+	// containerID is the container the backend connects to.
+	containerID string
+	suspended   bool
+	initialize  []byte
+	initialized []byte
+}
+
+// run proxies until either side disconnects.
+func (p *proxySession) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+
+	// Client → Backend
+	go func() {
+		defer close(done)
+		defer cancel()
+		for {
+			mt, msg, err := p.client.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			forward, reply := p.inspect(ctx, msg)
+			if reply != nil {
+				if err := p.client.WriteMessage(websocket.TextMessage, reply); err != nil {
+					return
+				}
+			}
+			if !forward {
+				continue
+			}
+
+			if err := p.forward(ctx, mt, msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Backend → Client
+	go func() {
+		<-ctx.Done()
+		p.closeBackend()
+	}()
+	backend := p.attached()
+session:
+	for {
+		if backend != nil {
+			p.relay(backend)
+		}
+		// A suspended session waits for its server to be woken.
+		if !p.isSuspended() && !p.redeployed(ctx, backend) {
+			break
+		}
+		select {
+		case backend = <-p.resumed:
+		case <-ctx.Done():
+			break session
+		}
+	}
+
+	p.client.Close()
+	<-done
+}
+
+// relay copies messages from the backend to the client until either fails.
+func (p *proxySession) relay(backend BackendConn) {
+	for {
+		mt, msg, err := backend.ReadMessage()
+		if err != nil {
+			return
+		}
+		p.touch()
+		if p.policy != nil {
+			msg = p.filterResponse(msg)
+		}
+		if err := p.client.WriteMessage(mt, msg); err != nil {
+			return
+		}
+	}
+}
+
+// close terminates the session, telling the client why.
+func (p *proxySession) close(code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = p.client.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	p.client.Close()
+	p.closeBackend()
+}
+
+// inspect decides whether a client message may be forwarded. When it may not,
+// reply holds the JSON-RPC error to send back (nil for notifications).
+func (p *proxySession) inspect(ctx context.Context, raw []byte) (forward bool, reply []byte) {
+	p.touch()
+
+	var msg rpcMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		// Batches and other frames that are not a single message would
+		// bypass every check below.
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			return false, rpcErrorResponse(nil, &RPCError{Code: codeInvalidRequest, Message: "Batch requests are not supported"})
+		}
+		return false, rpcErrorResponse(nil, &RPCError{Code: codeParseError, Message: "Parse error"})
+	}
+	if !msg.isRequest() {
+		// Responses to server-initiated requests pass through.
+		if len(msg.ID) == 0 || (msg.Result == nil && msg.Error == nil) {
+			return false, rpcErrorResponse(nil, &RPCError{Code: codeInvalidRequest, Message: "Invalid request"})
+		}
+		return true, nil
+	}
+
+	if p.limiter != nil && !p.limiter.Allow(p.server.ID) {
+		return false, p.reject(&msg, &RPCError{Code: codeRateLimited, Message: "Rate limit exceeded"})
+	}
+
+	// Hidden items are reported as unknown so their existence is not revealed.
+	if !p.policy.allowsRequest(&msg) {
+		return false, p.reject(&msg, hiddenError(&msg))
+	}
+
+	if msg.Method == "tools/call" && p.schemas != nil {
+		if rpcErr := p.schemas.validateToolCall(msg.Params); rpcErr != nil {
+			p.recordInvalid(ctx, &msg, rpcErr)
+			return false, p.reject(&msg, rpcErr)
+		}
+	}
+
+	if p.enforcer != nil {
+		err := p.enforcer.Enforce(ctx, &sentry.Request{
+			UserID:   p.userID,
+			ServerID: p.server.ID,
+			Method:   msg.Method,
+			Target:   msg.target(),
+			Params:   msg.Params,
+		})
+		if err != nil {
+			return false, p.reject(&msg, enforcementError(err))
+		}
+	}
+
+	if _, ok := listFields[msg.Method]; ok && p.policy != nil && !msg.isNotification() {
+		p.mu.Lock()
+		if p.pending == nil {
+			p.pending = make(map[string]string)
+		}
+		p.pending[rpcID(msg.ID)] = msg.Method
+		p.mu.Unlock()
+	}
+
+	return true, nil
+}
+
+// filterResponse hides items the access policy disallows from list responses
+// to requests recorded by inspect. Other messages are returned unchanged.
+func (p *proxySession) filterResponse(raw []byte) []byte {
+	p.mu.Lock()
+	empty := len(p.pending) == 0
+	p.mu.Unlock()
+	if empty {
+		return raw
+	}
+
+	var msg rpcMessage
+	if err := json.Unmarshal(raw, &msg); err != nil || msg.isRequest() || len(msg.ID) == 0 {
+		return raw
+	}
+
+	key := rpcID(msg.ID)
+	p.mu.Lock()
+	method, ok := p.pending[key]
+	delete(p.pending, key)
+	p.mu.Unlock()
+	if !ok || msg.Result == nil {
+		return raw
+	}
+
+	msg.Result = p.policy.filterList(method, msg.Result)
+	filtered, err := json.Marshal(msg)
+	if err != nil {
+		return raw
+	}
+	return filtered
+}
+
+// recordInvalid writes an audit entry for a request rejected by schema validation.
+func (p *proxySession) recordInvalid(ctx context.Context, msg *rpcMessage, rpcErr *RPCError) {
+	if p.audit == nil {
+		return
+	}
+	target := msg.target()
+	userID := p.userID
+	entry := &sentry.AuditEntry{
+		UserID:   &userID,
+		Action:   "mcp.invalid_params",
+		Resource: p.server.ID.String() + "/" + target,
+		Metadata: map[string]any{
+			"server_id": p.server.ID.String(),
+			"method":    msg.Method,
+			"target":    target,
+			"error":     rpcErr.Message,
+			"details":   rpcErr.Data,
+		},
+	}
+	if err := p.audit.Log(context.WithoutCancel(ctx), entry); err != nil {
+		slog.Error("writing invalid params audit entry", "error", err)
+	}
+}
+
+func (p *proxySession) reject(msg *rpcMessage, rpcErr *RPCError) []byte {
+	if msg.isNotification() {
+		return nil
+	}
+	return rpcErrorResponse(msg.ID, rpcErr)
+}
+
+// enforcementError maps a Sentry enforcement failure to a JSON-RPC error.
+func enforcementError(err error) *RPCError {
+	switch {
+	case errors.Is(err, sentry.ErrBlocked):
+		return &RPCError{Code: codeRequestBlocked, Message: err.Error()}
+	case errors.Is(err, sentry.ErrApprovalDenied):
+		return &RPCError{Code: codeApprovalDenied, Message: err.Error()}
+	case errors.Is(err, sentry.ErrApprovalExpired):
+		return &RPCError{Code: codeApprovalTimedOut, Message: err.Error()}
+	default:
+		slog.Error("sentry enforcement failed", "error", err)
+		return &RPCError{Code: codeInternalError, Message: "request could not be evaluated"}
+	}
+}
@@ -0,0 +1,395 @@
+package nodes
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+)
+
+// mockEnforcer implements sentry.Enforcer for proxy tests.
+type mockEnforcer struct {
+	EnforceFn func(ctx context.Context, req *sentry.Request) error
+}
+
+func (m *mockEnforcer) Enforce(ctx context.Context, req *sentry.Request) error {
+	return m.EnforceFn(ctx, req)
+}
+
+// newEchoBackend starts a WebSocket server that echoes every message back.
+func newEchoBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, msg); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// newToolsBackend starts a WebSocket MCP backend that answers tools/list with
+// the given tools and echoes everything else.
+func newToolsBackend(t *testing.T, tools ...string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, raw, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg rpcMessage
+			json.Unmarshal(raw, &msg)
+			if msg.Method == "tools/list" {
+				list := make([]map[string]string, len(tools))
+				for i, name := range tools {
+					list[i] = map[string]string{"name": name}
+				}
+				result, _ := json.Marshal(map[string]any{"tools": list})
+				raw, _ = json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result})
+			}
+			if err := conn.WriteMessage(mt, raw); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// newProxyTestServer starts a gateway-side WebSocket endpoint that proxies
+// each client to backendURL using a session built by configure.
+func newProxyTestServer(t *testing.T, backendURL string, configure func(*proxySession)) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientConn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		backendConn, _, err := websocket.DefaultDialer.Dial(backendURL, nil)
+		if err != nil {
+			clientConn.Close()
+			return
+		}
+		session := &proxySession{
+			server:  &MCPServer{ID: uuid.New()},
+			userID:  uuid.New(),
+			client:  &wsConn{Conn: clientConn},
+			backend: &wsConn{Conn: backendConn},
+		}
+		configure(session)
+		session.run(r.Context())
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func dialTestProxy(t *testing.T, ts *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readRPC(t *testing.T, conn *websocket.Conn) rpcMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg rpcMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+func TestProxyForwardsAllowedRequests(t *testing.T) {
+	backend := newEchoBackend(t)
+	var seen *sentry.Request
+	proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(backend.URL, "http"), func(p *proxySession) {
+		p.enforcer = &mockEnforcer{EnforceFn: func(_ context.Context, req *sentry.Request) error {
+			seen = req
+			return nil
+		}}
+	})
+	conn := dialTestProxy(t, proxy)
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file"}}`))
+	msg := readRPC(t, conn)
+
+	if msg.Method != "tools/call" {
+		t.Fatalf("expected echoed request, got %+v", msg)
+	}
+	if seen == nil || seen.Target != "read_file" {
+		t.Errorf("expected enforcer to see target read_file, got %+v", seen)
+	}
+}
+
+// stdioServer is an in-process stdio MCP server. It is the gateway's end of
+// the stream.
+type stdioServer struct {
+	io.Reader
+	io.Writer
+	closers []io.Closer
+}
+
+func (s *stdioServer) Close() error {
+	for _, c := range s.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// newStdioServer starts a stdio server that writes the lines returned by reply
+// for each line it receives.
+func newStdioServer(reply func(line []byte) [][]byte) *stdioServer {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	go func() {
+		defer outW.Close()
+		scanner := bufio.NewScanner(inR)
+		for scanner.Scan() {
+			for _, out := range reply(scanner.Bytes()) {
+				if _, err := outW.Write(append(out, '\n')); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return &stdioServer{Reader: outR, Writer: inW, closers: []io.Closer{inW, outR}}
+}
+
+// newStdioEchoServer starts a stdio server that echoes each line it receives.
+func newStdioEchoServer() *stdioServer {
+	return newStdioServer(func(line []byte) [][]byte { return [][]byte{line} })
+}
+
+func TestProxyOverStdioBackend(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientConn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		session := &proxySession{
+			server:  &MCPServer{ID: uuid.New()},
+			client:  &wsConn{Conn: clientConn},
+			backend: newStdioConn(newStdioEchoServer()),
+			policy:  &AccessPolicy{Tools: []string{"read_*"}},
+		}
+		session.run(r.Context())
+	}))
+	t.Cleanup(proxy.Close)
+	conn := dialTestProxy(t, proxy)
+
+	conn.WriteMessage(websocket.TextMessage, []byte("{\n \"jsonrpc\": \"2.0\", \"id\": 1, \"method\": \"tools/call\", \"params\": {\"name\": \"read_file\"}\n}"))
+	if msg := readRPC(t, conn); msg.Method != "tools/call" || string(msg.ID) != "1" {
+		t.Fatalf("expected echoed request over stdio, got %+v", msg)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"delete_file"}}`))
+	if msg := readRPC(t, conn); msg.Error == nil || msg.Error.Code != codeInvalidParams {
+		t.Fatalf("expected hidden tool error, got %+v", msg)
+	}
+}
+
+func TestProxyRejectsEnforcedRequests(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{sentry.ErrBlocked, codeRequestBlocked},
+		{sentry.ErrApprovalDenied, codeApprovalDenied},
+		{sentry.ErrApprovalExpired, codeApprovalTimedOut},
+	}
+	for _, tt := range tests {
+		backend := newEchoBackend(t)
+		proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(backend.URL, "http"), func(p *proxySession) {
+			p.enforcer = &mockEnforcer{EnforceFn: func(_ context.Context, _ *sentry.Request) error {
+				return tt.err
+			}}
+		})
+		conn := dialTestProxy(t, proxy)
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":"abc","method":"tools/call","params":{"name":"deploy"}}`))
+		msg := readRPC(t, conn)
+
+		if msg.Error == nil || msg.Error.Code != tt.code {
+			t.Fatalf("%v: expected error code %d, got %+v", tt.err, tt.code, msg)
+		}
+		if string(msg.ID) != `"abc"` {
+			t.Errorf("%v: expected id \"abc\", got %s", tt.err, msg.ID)
+		}
+	}
+}
+
+func TestProxyRejectsBatchedRequests(t *testing.T) {
+	backend := newEchoBackend(t)
+	proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(backend.URL, "http"), func(p *proxySession) {
+		p.enforcer = &mockEnforcer{EnforceFn: func(_ context.Context, _ *sentry.Request) error {
+			return sentry.ErrBlocked
+		}}
+	})
+	conn := dialTestProxy(t, proxy)
+
+	frames := []struct {
+		frame string
+		code  int
+	}{
+		{`[{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"deploy"}}]`, codeInvalidRequest},
+		{`tools/call deploy`, codeParseError},
+		{`{"jsonrpc":"2.0"}`, codeInvalidRequest},
+	}
+	for _, f := range frames {
+		conn.WriteMessage(websocket.TextMessage, []byte(f.frame))
+		if msg := readRPC(t, conn); msg.Error == nil || msg.Error.Code != f.code || msg.Method != "" {
+			t.Errorf("%s: expected error code %d instead of reaching the backend, got %+v", f.frame, f.code, msg)
+		}
+	}
+}
+
+func TestProxyRateLimitsRequests(t *testing.T) {
+	backend := newEchoBackend(t)
+	proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(backend.URL, "http"), func(p *proxySession) {
+		p.limiter = NewRateLimiter(0, 0)
+	})
+	conn := dialTestProxy(t, proxy)
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":7,"method":"tools/list"}`))
+	msg := readRPC(t, conn)
+
+	if msg.Error == nil || msg.Error.Code != codeRateLimited {
+		t.Fatalf("expected rate limit error, got %+v", msg)
+	}
+	var id int
+	if err := json.Unmarshal(msg.ID, &id); err != nil || id != 7 {
+		t.Errorf("expected id 7, got %s", msg.ID)
+	}
+}
+
+func TestProxyAppliesAccessPolicy(t *testing.T) {
+	backend := newToolsBackend(t, "search", "delete_repo")
+	proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(backend.URL, "http"), func(p *proxySession) {
+		p.policy = &AccessPolicy{Tools: []string{"search"}}
+	})
+	conn := dialTestProxy(t, proxy)
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	msg := readRPC(t, conn)
+	var result struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		t.Fatalf("unmarshal result: %v", err)
+	}
+	if len(result.Tools) != 1 || result.Tools[0].Name != "search" {
+		t.Errorf("expected only search to be listed, got %+v", result.Tools)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"delete_repo"}}`))
+	msg = readRPC(t, conn)
+	if msg.Error == nil || msg.Error.Code != codeInvalidParams || msg.Error.Message != "Unknown tool: delete_repo" {
+		t.Errorf("expected hidden tool to be reported unknown, got %+v", msg)
+	}
+}
+
+// recordingAudit collects audit entries written by the proxy.
+type recordingAudit struct {
+	mu      sync.Mutex
+	entries []*sentry.AuditEntry
+}
+
+func (r *recordingAudit) Log(_ context.Context, e *sentry.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e)
+	return nil
+}
+
+func TestProxyRejectsInvalidToolArguments(t *testing.T) {
+	backend := newEchoBackend(t)
+	audit := &recordingAudit{}
+	enforced := false
+	proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(backend.URL, "http"), func(p *proxySession) {
+		p.server = testToolServer()
+		p.schemas = compileToolSchemas(p.server)
+		p.audit = audit
+		p.enforcer = &mockEnforcer{EnforceFn: func(_ context.Context, _ *sentry.Request) error {
+			enforced = true
+			return nil
+		}}
+	})
+	conn := dialTestProxy(t, proxy)
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"read_file","arguments":{"limit":0}}}`))
+	msg := readRPC(t, conn)
+
+	if msg.Error == nil || msg.Error.Code != codeInvalidParams {
+		t.Fatalf("expected -32602 error, got %+v", msg)
+	}
+	if enforced {
+		t.Error("expected invalid call to be rejected before enforcement")
+	}
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	if len(audit.entries) != 1 || audit.entries[0].Action != "mcp.invalid_params" {
+		t.Errorf("expected mcp.invalid_params audit entry, got %+v", audit.entries)
+	}
+}
+
+func TestSessionRegistryClosesServerSessions(t *testing.T) {
+	backend := newEchoBackend(t)
+	sessions := NewSessionRegistry()
+	serverID := uuid.New()
+	proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(backend.URL, "http"), func(p *proxySession) {
+		p.server = &MCPServer{ID: serverID}
+		sessions.add(serverID, p)
+	})
+	conn := dialTestProxy(t, proxy)
+
+	// Round-trip once so the session is registered before closing it.
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	readRPC(t, conn)
+
+	if n := sessions.CloseServer(uuid.New(), websocket.ClosePolicyViolation, "other"); n != 0 {
+		t.Errorf("expected no sessions closed for another server, got %d", n)
+	}
+	if n := sessions.CloseServer(serverID, websocket.ClosePolicyViolation, "server quarantined"); n != 1 {
+		t.Fatalf("expected 1 session closed, got %d", n)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("expected policy violation close, got %v", err)
+	}
+}
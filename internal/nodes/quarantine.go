@@ -0,0 +1,67 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+)
+
+// Quarantiner implements sentry.QuarantineController for managed MCP servers.
+// Quarantining marks the server, optionally stops its container and closes
+// every live proxy session to it.
+type Quarantiner struct {
+	Service       Service
+	Repo          Repository
+	Sessions      *SessionRegistry
+	StopContainer bool
+}
+
+var _ sentry.QuarantineController = (*Quarantiner)(nil)
+
+func (q *Quarantiner) Quarantine(ctx context.Context, serverID uuid.UUID, reason string) error {
+	if err := q.Service.QuarantineServer(ctx, serverID, q.StopContainer); err != nil {
+		return quarantineError(err)
+	}
+
+	closed := 0
+	if q.Sessions != nil {
+		closed = q.Sessions.CloseServer(serverID, websocket.ClosePolicyViolation, "server quarantined")
+	}
+	slog.Warn("server quarantined", "server_id", serverID, "reason", reason, "sessions_closed", closed)
+	return nil
+}
+
+func (q *Quarantiner) Release(ctx context.Context, serverID uuid.UUID) error {
+	return quarantineError(q.Service.ReleaseServer(ctx, serverID))
+}
+
+func (q *Quarantiner) Quarantined(ctx context.Context) ([]uuid.UUID, error) {
+	servers, err := q.Repo.SearchServers(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
+	for _, server := range servers {
+		if server.Status == StatusQuarantined {
+			ids = append(ids, server.ID)
+		}
+	}
+	return ids, nil
+}
+
+// quarantineError translates nodes errors into their sentry equivalents.
+func quarantineError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return sentry.ErrNotFound
+	case errors.Is(err, ErrNotQuarantined):
+		return sentry.ErrNotQuarantined
+	default:
+		return err
+	}
+}
@@ -0,0 +1,162 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/google/uuid"
+
+	"github.com/kapella-hub/NexusClaw/internal/sentry"
+)
+
+// ReconcileReport describes what a reconciliation pass found and changed.
+type ReconcileReport struct {
+	Servers    int               `json:"servers"`
+	Containers int               `json:"containers"`
+	Updated    []ReconcileChange `json:"updated,omitempty"`
+	Adopted    []ReconcileChange `json:"adopted,omitempty"`
+	// Removed lists the IDs of orphaned containers that were removed.
+	Removed []string `json:"removed,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// ReconcileChange records a server whose status or container was corrected.
+type ReconcileChange struct {
+	ServerID    uuid.UUID    `json:"server_id"`
+	ContainerID string       `json:"container_id,omitempty"`
+	From        ServerStatus `json:"from"`
+	To          ServerStatus `json:"to"`
+}
+
+// Reconcile brings server records in line with Docker after a gateway
+// restart. Each server's status is corrected from its container's state, and
+// servers whose container is gone lose their container ID. Containers of this
+// gateway instance that no server tracks are adopted by the server in their
+// labels if it has no container, they are running and their config hash is
+// current, and removed otherwise. The report is written to the audit log.
+func Reconcile(ctx context.Context, repo Repository, container ContainerManager, audit sentry.AuditLogger) (*ReconcileReport, error) {
+	servers, err := repo.SearchServers(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("listing servers: %w", err)
+	}
+	containers, err := container.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &ReconcileReport{Servers: len(servers), Containers: len(containers)}
+
+	byID := make(map[uuid.UUID]*MCPServer, len(servers))
+	tracked := make(map[string]bool, len(servers))
+	for i := range servers {
+		server := &servers[i]
+		byID[server.ID] = server
+		if server.ContainerID == "" {
+			continue
+		}
+		containerID := server.ContainerID
+		tracked[containerID] = true
+
+		from := server.Status
+		status, err := container.Status(ctx, containerID)
+		switch {
+		case cerrdefs.IsNotFound(err):
+			server.ContainerID = ""
+			server.Endpoint = ""
+			if from == StatusRunning || from == StatusStarting {
+				server.Status = StatusError
+			} else if from != StatusQuarantined {
+				server.Status = StatusStopped
+			}
+		case err != nil:
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		case from != StatusQuarantined:
+			server.Status = status
+		}
+		if server.ContainerID != "" && server.Status == from {
+			continue
+		}
+
+		server.UpdatedAt = time.Now()
+		if err := repo.UpdateServer(ctx, server); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("updating server %s: %v", server.ID, err))
+			continue
+		}
+		report.Updated = append(report.Updated, ReconcileChange{
+			ServerID:    server.ID,
+			ContainerID: containerID,
+			From:        from,
+			To:          server.Status,
+		})
+	}
+
+	for _, c := range containers {
+		if tracked[c.ID] {
+			continue
+		}
+		if server := byID[c.ServerID]; server != nil && server.ContainerID == "" &&
+			server.Status != StatusQuarantined && c.Status == StatusRunning &&
+			c.ConfigHash == configHash(server) {
+			change, err := adopt(ctx, repo, container, server, c.ID)
+			if err == nil {
+				report.Adopted = append(report.Adopted, change)
+				continue
+			}
+			report.Errors = append(report.Errors, err.Error())
+		}
+		if err := container.Remove(ctx, c.ID); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.Removed = append(report.Removed, c.ID)
+	}
+
+	slog.Info("reconciled servers with containers",
+		"servers", report.Servers,
+		"updated", len(report.Updated),
+		"adopted", len(report.Adopted),
+		"removed", len(report.Removed),
+		"errors", len(report.Errors))
+
+	entry := &sentry.AuditEntry{
+		Action:   "nodes.reconcile",
+		Resource: "nodes",
+		Metadata: map[string]any{
+			"servers":    report.Servers,
+			"containers": report.Containers,
+			"updated":    report.Updated,
+			"adopted":    report.Adopted,
+			"removed":    report.Removed,
+			"errors":     report.Errors,
+		},
+	}
+	if err := audit.Log(ctx, entry); err != nil {
+		return report, fmt.Errorf("writing reconcile report: %w", err)
+	}
+	return report, nil
+}
+
+// adopt makes containerID the server's running container.
+func adopt(ctx context.Context, repo Repository, container ContainerManager, server *MCPServer, containerID string) (ReconcileChange, error) {
+	var endpoint string
+	if serverTransport(server) != TransportStdio {
+		var err error
+		endpoint, err = container.Endpoint(ctx, containerID, backendPort(server))
+		if err != nil {
+			return ReconcileChange{}, err
+		}
+	}
+
+	change := ReconcileChange{ServerID: server.ID, ContainerID: containerID, From: server.Status, To: StatusRunning}
+	server.ContainerID = containerID
+	server.Endpoint = endpoint
+	server.Status = StatusRunning
+	server.UpdatedAt = time.Now()
+	if err := repo.UpdateServer(ctx, server); err != nil {
+		return ReconcileChange{}, fmt.Errorf("updating server %s: %w", server.ID, err)
+	}
+	return change, nil
+}
@@ -0,0 +1,158 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/google/uuid"
+)
+
+func TestReconcile(t *testing.T) {
+	gone := MCPServer{ID: uuid.New(), Status: StatusRunning, ContainerID: "gone", Endpoint: "172.20.0.2:8080"}
+	exited := MCPServer{ID: uuid.New(), Status: StatusRunning, ContainerID: "exited"}
+	healthy := MCPServer{ID: uuid.New(), Status: StatusRunning, ContainerID: "healthy"}
+	untracked := MCPServer{ID: uuid.New(), Status: StatusStopped}
+
+	updated := map[uuid.UUID]MCPServer{}
+	repo := &mockRepo{
+		SearchServersFn: func(_ context.Context, _ string) ([]MCPServer, error) {
+			return []MCPServer{gone, exited, healthy, untracked}, nil
+		},
+		UpdateServerFn: func(_ context.Context, s *MCPServer) error {
+			updated[s.ID] = *s
+			return nil
+		},
+	}
+
+	cm := newMockContainerMgr()
+	cm.StatusFn = func(_ context.Context, id string) (ServerStatus, error) {
+		switch id {
+		case "gone":
+			return "", cerrdefs.ErrNotFound
+		case "exited":
+			return StatusStopped, nil
+		}
+		return StatusRunning, nil
+	}
+	cm.ListFn = func(_ context.Context) ([]ManagedContainer, error) {
+		return []ManagedContainer{
+			{ID: "exited", ServerID: exited.ID, Status: StatusStopped},
+			{ID: "healthy", ServerID: healthy.ID, Status: StatusRunning},
+			{ID: "adoptable", ServerID: untracked.ID, Status: StatusRunning, ConfigHash: configHash(&untracked)},
+			{ID: "orphan", ServerID: uuid.New(), Status: StatusRunning},
+		}, nil
+	}
+	var removed []string
+	cm.RemoveFn = func(_ context.Context, id string) error {
+		removed = append(removed, id)
+		return nil
+	}
+	audit := &recordingAudit{}
+
+	report, err := Reconcile(context.Background(), repo, cm, audit)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if s := updated[gone.ID]; s.Status != StatusError || s.ContainerID != "" || s.Endpoint != "" {
+		t.Errorf("expected the server with a missing container to be cleared, got %+v", s)
+	}
+	if s := updated[exited.ID]; s.Status != StatusStopped || s.ContainerID != "exited" {
+		t.Errorf("expected the exited server to be marked stopped, got %+v", s)
+	}
+	if _, ok := updated[healthy.ID]; ok {
+		t.Error("expected the healthy server to be left alone")
+	}
+	if s := updated[untracked.ID]; s.Status != StatusRunning || s.ContainerID != "adoptable" || s.Endpoint == "" {
+		t.Errorf("expected the untracked container to be adopted, got %+v", s)
+	}
+	if len(removed) != 1 || removed[0] != "orphan" {
+		t.Errorf("expected only the orphan to be removed, got %v", removed)
+	}
+	if len(report.Updated) != 2 || len(report.Adopted) != 1 || len(report.Removed) != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if len(audit.entries) != 1 || audit.entries[0].Action != "nodes.reconcile" {
+		t.Fatalf("expected one reconcile audit entry, got %+v", audit.entries)
+	}
+}
+
+func TestReconcileRemovesOrphanOfQuarantinedServer(t *testing.T) {
+	server := MCPServer{ID: uuid.New(), Status: StatusQuarantined}
+	repo := &mockRepo{
+		SearchServersFn: func(_ context.Context, _ string) ([]MCPServer, error) {
+			return []MCPServer{server}, nil
+		},
+		UpdateServerFn: func(_ context.Context, _ *MCPServer) error {
+			t.Error("expected a quarantined server not to be updated")
+			return nil
+		},
+	}
+	cm := newMockContainerMgr()
+	cm.ListFn = func(_ context.Context) ([]ManagedContainer, error) {
+		return []ManagedContainer{{ID: "c1", ServerID: server.ID, Status: StatusRunning, ConfigHash: configHash(&server)}}, nil
+	}
+	var removed string
+	cm.RemoveFn = func(_ context.Context, id string) error {
+		removed = id
+		return nil
+	}
+
+	if _, err := Reconcile(context.Background(), repo, cm, &recordingAudit{}); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if removed != "c1" {
+		t.Errorf("expected the quarantined server's container to be removed, got %q", removed)
+	}
+}
+
+func TestReconcileRemovesContainerWithStaleConfig(t *testing.T) {
+	server := MCPServer{ID: uuid.New(), Status: StatusStopped, Image: "mcp:v2"}
+	stale := server
+	stale.Image = "mcp:v1"
+	repo := &mockRepo{
+		SearchServersFn: func(_ context.Context, _ string) ([]MCPServer, error) {
+			return []MCPServer{server}, nil
+		},
+	}
+	cm := newMockContainerMgr()
+	cm.ListFn = func(_ context.Context) ([]ManagedContainer, error) {
+		return []ManagedContainer{{ID: "c1", ServerID: server.ID, Status: StatusRunning, ConfigHash: configHash(&stale)}}, nil
+	}
+	var removed string
+	cm.RemoveFn = func(_ context.Context, id string) error {
+		removed = id
+		return nil
+	}
+
+	report, err := Reconcile(context.Background(), repo, cm, &recordingAudit{})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if removed != "c1" || len(report.Adopted) != 0 {
+		t.Errorf("expected a container from an old config to be removed, got removed %q report %+v", removed, report)
+	}
+}
+
+func TestReconcileListError(t *testing.T) {
+	repo := &mockRepo{
+		SearchServersFn: func(_ context.Context, _ string) ([]MCPServer, error) {
+			return nil, nil
+		},
+	}
+	cm := newMockContainerMgr()
+	cm.ListFn = func(_ context.Context) ([]ManagedContainer, error) {
+		return nil, errors.New("docker unavailable")
+	}
+	audit := &recordingAudit{}
+
+	if _, err := Reconcile(context.Background(), repo, cm, audit); err == nil {
+		t.Fatal("expected an error when containers cannot be listed")
+	}
+	if len(audit.entries) != 0 {
+		t.Error("expected no report when reconciliation did not run")
+	}
+}
@@ -3,6 +3,7 @@ package nodes
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -16,14 +17,97 @@ var ErrNotImplemented = errors.New("not implemented")
 // ErrContainerNotAvailable is returned when container runtime is not available.
 var ErrContainerNotAvailable = errors.New("container runtime not available")
 
+// ErrQuarantined is returned when connecting to or starting a quarantined server.
+var ErrQuarantined = errors.New("server is quarantined")
+
+// ErrNotQuarantined is returned when releasing a server that is not quarantined.
+var ErrNotQuarantined = errors.New("server is not quarantined")
+
+// ErrServerRunning is returned when starting a server that is already
+// running, starting or stopping.
+var ErrServerRunning = errors.New("server is already running")
+
+// ErrBackendBusy is returned when a stdio server already has a client attached.
+var ErrBackendBusy = errors.New("server backend is in use")
+
+// ErrPortTaken is returned when allocating a host port that is already assigned.
+var ErrPortTaken = errors.New("port already allocated")
+
+// ErrNoFreePorts is returned when every port in the allocation range is in use.
+var ErrNoFreePorts = errors.New("no free host ports in range")
+
+// ErrLimitExceeded is returned when a server asks for more resources than its
+// owner's tier allows.
+var ErrLimitExceeded = errors.New("resource limits exceed the maximum")
+
+// ErrSecretUnavailable is returned when a secret a server references cannot be
+// read from its owner's vault or OAuth grants.
+var ErrSecretUnavailable = errors.New("secret unavailable")
+
+// ErrSecurityNotAllowed is returned when a server's security profile asks for
+// something the operator has not allowed.
+var ErrSecurityNotAllowed = errors.New("security profile not allowed")
+
+// ErrRolloutInProgress is returned when a server is updated or rolled back
+// while an earlier update of it is still being rolled out.
+var ErrRolloutInProgress = errors.New("server update already in progress")
+
+// ErrRolloutFailed is returned when the container of an updated server's new
+// revision fails to start or to become ready. The old container keeps
+// serving and the update is not recorded.
+var ErrRolloutFailed = errors.New("new revision failed to become ready")
+
 // Repository defines persistence operations for MCP servers and OAuth grants.
 type Repository interface {
 	ListServers(ctx context.Context, ownerID uuid.UUID) ([]MCPServer, error)
 	GetServer(ctx context.Context, id uuid.UUID) (*MCPServer, error)
 	CreateServer(ctx context.Context, server *MCPServer) error
 	UpdateServer(ctx context.Context, server *MCPServer) error
+	// UpdateServerCapabilities stores the tools and resources a server
+	// advertises, leaving the rest of its record alone.
+	UpdateServerCapabilities(ctx context.Context, id uuid.UUID, tools, resources []any) error
 	DeleteServer(ctx context.Context, id uuid.UUID) error
 	SearchServers(ctx context.Context, query string) ([]MCPServer, error)
+	// CreateServerRevision stores a revision of a server's image and config,
+	// numbering it after the server's latest revision.
+	CreateServerRevision(ctx context.Context, rev *ServerRevision) error
+	// ListServerRevisions returns the server's revisions, newest first.
+	ListServerRevisions(ctx context.Context, serverID uuid.UUID) ([]ServerRevision, error)
+	GetServerRevision(ctx context.Context, serverID uuid.UUID, revision int) (*ServerRevision, error)
+	// CreateOAuthGrant stores a grant, replacing the server's earlier grant
+	// for the same provider.
 	CreateOAuthGrant(ctx context.Context, grant *OAuthGrant) error
 	GetOAuthGrant(ctx context.Context, id uuid.UUID) (*OAuthGrant, error)
+	GetServerOAuthGrant(ctx context.Context, serverID uuid.UUID, provider string) (*OAuthGrant, error)
+	// ListRefreshableOAuthGrants returns the grants with a refresh token that
+	// expire before the given time.
+	ListRefreshableOAuthGrants(ctx context.Context, before time.Time) ([]OAuthGrant, error)
+	// UpdateOAuthGrantTokens stores a grant's renewed tokens and expiry. It
+	// returns ErrNotFound if the grant has since been replaced or deleted.
+	UpdateOAuthGrantTokens(ctx context.Context, grant *OAuthGrant) error
+	// ListOAuthGrants returns the grants the user authorized.
+	ListOAuthGrants(ctx context.Context, ownerID uuid.UUID) ([]OAuthGrant, error)
+	DeleteOAuthGrant(ctx context.Context, id uuid.UUID) error
+	// CreateOAuthState records a pending OAuth flow, discarding expired ones.
+	CreateOAuthState(ctx context.Context, state *OAuthState) error
+	// ConsumeOAuthState deletes and returns a pending OAuth flow, so each
+	// state is accepted at most once.
+	ConsumeOAuthState(ctx context.Context, state string) (*OAuthState, error)
+	ListAccessPolicies(ctx context.Context, serverID uuid.UUID) ([]AccessPolicy, error)
+	// GetAccessPolicy returns the policy for exactly principalID, where nil
+	// selects the server default.
+	GetAccessPolicy(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) (*AccessPolicy, error)
+	UpsertAccessPolicy(ctx context.Context, policy *AccessPolicy) error
+	DeleteAccessPolicy(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) error
+	ListPortAllocations(ctx context.Context) ([]PortAllocation, error)
+	// CreatePortAllocation returns ErrPortTaken if the port or server already
+	// has an allocation.
+	CreatePortAllocation(ctx context.Context, alloc *PortAllocation) error
+	// DeletePortAllocation frees the server's port, leaving the standby port
+	// of an update in progress to DeleteStandbyPortAllocation.
+	DeletePortAllocation(ctx context.Context, serverID uuid.UUID) error
+	DeleteStandbyPortAllocation(ctx context.Context, serverID uuid.UUID) error
+	// PromotePortAllocation frees the server's port and makes its standby
+	// port the server's port. It returns ErrNotFound if there is no standby.
+	PromotePortAllocation(ctx context.Context, serverID uuid.UUID) error
 }
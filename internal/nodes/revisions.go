@@ -0,0 +1,235 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// rolloutReadyTimeout bounds how long an update waits for the new revision's
+// container to answer an MCP ping before giving up on it.
+const rolloutReadyTimeout = time.Minute
+
+// ServerUpdate changes a server's image or config. An empty Image keeps the
+// current image; a non-nil Config replaces the whole spec.
+type ServerUpdate struct {
+	Image  string
+	Config *ServerSpec
+	// UpdatedBy is the user making the change, recorded on the revision. It
+	// must be the server's owner.
+	UpdatedBy uuid.UUID
+}
+
+func (s *service) ListServerRevisions(ctx context.Context, id uuid.UUID) ([]ServerRevision, error) {
+	if _, err := s.repo.GetServer(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListServerRevisions(ctx, id)
+}
+
+func (s *service) GetServerRevision(ctx context.Context, id uuid.UUID, revision int) (*ServerRevision, error) {
+	return s.repo.GetServerRevision(ctx, id, revision)
+}
+
+func (s *service) UpdateServer(ctx context.Context, id uuid.UUID, update *ServerUpdate) (*MCPServer, error) {
+	return s.update(ctx, id, update, nil)
+}
+
+func (s *service) RollbackServer(ctx context.Context, id uuid.UUID, revision int, userID uuid.UUID) (*MCPServer, error) {
+	rev, err := s.repo.GetServerRevision(ctx, id, revision)
+	if err != nil {
+		return nil, err
+	}
+	return s.update(ctx, id, &ServerUpdate{Image: rev.Image, Config: &rev.Config, UpdatedBy: userID}, &revision)
+}
+
+// update applies the change as a new revision of the server. A running
+// server is switched over blue/green: the new revision's container is
+// started next to the old one and must answer an MCP ping before the server
+// is pointed at it and the old container is stopped. Proxied WebSocket
+// sessions follow the server to the new container. An update that changes
+// nothing returns the server as is.
+func (s *service) update(ctx context.Context, id uuid.UUID, update *ServerUpdate, rollbackOf *int) (*MCPServer, error) {
+	if !s.beginRollout(id) {
+		return nil, ErrRolloutInProgress
+	}
+	defer s.endRollout(id)
+
+	server, err := s.repo.GetServer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// The new config's secrets resolve from the owner's vault and grants,
+	// so nobody else may write it.
+	if update.UpdatedBy != server.OwnerID {
+		return nil, ErrNotFound
+	}
+	if server.Status == StatusQuarantined {
+		return nil, ErrQuarantined
+	}
+
+	next := *server
+	if update.Image != "" {
+		next.Image = update.Image
+	}
+	if update.Config != nil {
+		next.Config = *update.Config
+	}
+	if next.Config.Version == 0 {
+		next.Config.Version = SpecVersion
+	}
+	if fields := next.Config.Validate(); len(fields) > 0 {
+		return nil, &SpecError{Fields: fields}
+	}
+	if _, err := s.limits.Apply(next.OwnerID, next.Config.Limits); err != nil {
+		return nil, err
+	}
+	if configHash(&next) == configHash(server) {
+		return server, nil
+	}
+
+	running := server.Status == StatusRunning
+	if running {
+		if s.container == nil {
+			return nil, ErrContainerNotAvailable
+		}
+		if next.ContainerID, next.Endpoint, err = s.startStandby(ctx, &next); err != nil {
+			return nil, err
+		}
+	}
+	// The change is applied to the server as it is now, so that whatever
+	// happened to it while the new container came up is kept. A server that
+	// was stopped, started or restarted meanwhile fails the update.
+	current, err := s.repo.GetServer(ctx, id)
+	if err != nil || current.Status != server.Status || current.ContainerID != server.ContainerID {
+		if running {
+			s.discardStandby(ctx, &next)
+		}
+		return nil, fmt.Errorf("%w: server changed during the update", ErrRolloutFailed)
+	}
+
+	rev := &ServerRevision{
+		ServerID:   id,
+		Image:      next.Image,
+		Config:     next.Config,
+		RollbackOf: rollbackOf,
+		CreatedAt:  time.Now(),
+	}
+	if update.UpdatedBy != uuid.Nil {
+		rev.CreatedBy = &update.UpdatedBy
+	}
+	if err := s.repo.CreateServerRevision(ctx, rev); err != nil {
+		if running {
+			s.discardStandby(ctx, &next)
+		}
+		return nil, fmt.Errorf("storing revision: %w", err)
+	}
+	current.Image = next.Image
+	current.Config = next.Config
+	current.Revision = rev.Revision
+	if running {
+		current.ContainerID = next.ContainerID
+		current.Endpoint = next.Endpoint
+	}
+	current.UpdatedAt = time.Now()
+	if err := s.repo.UpdateServer(ctx, current); err != nil {
+		if running {
+			s.discardStandby(ctx, &next)
+		}
+		return nil, fmt.Errorf("updating server: %w", err)
+	}
+
+	if running {
+		s.retire(ctx, server, current)
+		s.syncCapabilitiesLater(id)
+	}
+	slog.Info("server updated", "server_id", id, "revision", current.Revision, "rolled_out", running)
+	return current, nil
+}
+
+// startStandby starts the container of the server's new revision next to
+// the running one and waits until it answers an MCP ping, unless the new
+// revision disables health checks.
+func (s *service) startStandby(ctx context.Context, next *MCPServer) (containerID, endpoint string, err error) {
+	name := next.ID.String() + "-" + configHash(next)[:12]
+	containerID, endpoint, err = s.launch(ctx, next, name, true)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrRolloutFailed, err)
+	}
+	if next.Config.Healthcheck.Disabled {
+		return containerID, endpoint, nil
+	}
+
+	green := *next
+	green.ContainerID = containerID
+	green.Endpoint = endpoint
+	if err := waitReady(ctx, s, &green, rolloutReadyTimeout); err != nil {
+		s.discardStandby(ctx, &green)
+		return "", "", fmt.Errorf("%w: %w", ErrRolloutFailed, err)
+	}
+	return containerID, endpoint, nil
+}
+
+// discardStandby removes the new revision's container of a failed update
+// and frees its port.
+func (s *service) discardStandby(ctx context.Context, next *MCPServer) {
+	if err := s.container.Stop(ctx, next.ContainerID); err != nil {
+		slog.Warn("stopping standby container", "server_id", next.ID, "container_id", next.ContainerID, "error", err)
+	}
+	if err := s.container.Remove(ctx, next.ContainerID); err != nil {
+		slog.Warn("removing standby container", "server_id", next.ID, "container_id", next.ContainerID, "error", err)
+	}
+	s.releaseStandbyPort(ctx, next.ID)
+}
+
+// retire stops the old container once the server points at the new one and
+// hands the old container's host port over to the new one's. Failures are
+// logged; a leftover container is removed as an orphan.
+func (s *service) retire(ctx context.Context, old, next *MCPServer) {
+	if old.ContainerID != "" {
+		if err := s.container.Stop(ctx, old.ContainerID); err != nil {
+			slog.Error("stopping replaced container", "server_id", old.ID, "container_id", old.ContainerID, "error", err)
+		}
+		if err := s.container.Remove(ctx, old.ContainerID); err != nil {
+			slog.Error("removing replaced container", "server_id", old.ID, "container_id", old.ContainerID, "error", err)
+		}
+	}
+	if s.ports == nil {
+		return
+	}
+	if serverTransport(next) == TransportStdio {
+		s.releasePort(ctx, next.ID)
+		return
+	}
+	if err := s.ports.Promote(ctx, next.ID); err != nil {
+		slog.Error("promoting standby host port", "server_id", next.ID, "error", err)
+	}
+}
+
+// beginRollout marks an update of the server in progress and reports whether
+// none already was.
+func (s *service) beginRollout(id uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rolling[id] {
+		return false
+	}
+	s.rolling[id] = true
+	return true
+}
+
+func (s *service) endRollout(id uuid.UUID) {
+	s.mu.Lock()
+	delete(s.rolling, id)
+	s.mu.Unlock()
+}
+
+// rollingOut reports whether an update of the server is in progress.
+func (s *service) rollingOut(id uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rolling[id]
+}
@@ -0,0 +1,269 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// revisionRepo is portRepo extended with an in-memory server row and its
+// revisions, starting at revision 1.
+func revisionRepo(server *MCPServer) (*mockRepo, *[]ServerRevision) {
+	server.Revision = 1
+	revisions := []ServerRevision{{ServerID: server.ID, Revision: 1, Image: server.Image, Config: server.Config}}
+	repo := portRepo(map[uuid.UUID]*MCPServer{server.ID: server})
+	repo.GetServerFn = func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+		if id != server.ID {
+			return nil, ErrNotFound
+		}
+		copied := *server
+		return &copied, nil
+	}
+	repo.UpdateServerFn = func(_ context.Context, s *MCPServer) error {
+		*server = *s
+		return nil
+	}
+	repo.CreateServerRevisionFn = func(_ context.Context, rev *ServerRevision) error {
+		rev.Revision = len(revisions) + 1
+		revisions = append(revisions, *rev)
+		return nil
+	}
+	repo.GetServerRevisionFn = func(_ context.Context, _ uuid.UUID, revision int) (*ServerRevision, error) {
+		if revision < 1 || revision > len(revisions) {
+			return nil, ErrNotFound
+		}
+		rev := revisions[revision-1]
+		return &rev, nil
+	}
+	return repo, &revisions
+}
+
+func TestUpdateServerRollsOutRunningServer(t *testing.T) {
+	green := newRPCBackend(t, nil)
+	server := &MCPServer{ID: uuid.New(), Image: "mcp:v1", Status: StatusRunning, ContainerID: "container-blue", Endpoint: "172.20.0.2:8080"}
+	repo, revisions := revisionRepo(server)
+	ports, _ := NewPortAllocator(repo, 20000, 20010)
+	if _, err := ports.Allocate(context.Background(), server.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := newMockContainerMgr()
+	var created *ContainerConfig
+	cm.CreateFn = func(_ context.Context, cfg *ContainerConfig) (string, error) {
+		created = cfg
+		return "container-green", nil
+	}
+	cm.EndpointFn = func(context.Context, string, string) (string, error) {
+		return strings.TrimPrefix(green.URL, "http://"), nil
+	}
+	var stopped []string
+	cm.StopFn = func(_ context.Context, id string) error {
+		// The server must point at the new container before the old one
+		// goes away.
+		if server.ContainerID != "container-green" {
+			t.Errorf("expected the switch-over before %s was stopped", id)
+		}
+		stopped = append(stopped, id)
+		return nil
+	}
+	svc := NewService(repo, cm, ports, nil, nil, nil, nil)
+
+	updated, err := svc.UpdateServer(context.Background(), server.ID, &ServerUpdate{Image: "mcp:v2"})
+	if err != nil {
+		t.Fatalf("UpdateServer failed: %v", err)
+	}
+	if created.Image != "mcp:v2" || !strings.HasPrefix(created.Name, server.ID.String()+"-") || created.Ports[0] != "20001:8080" {
+		t.Errorf("expected a second container of the new image on the standby port, got %q %q %v", created.Name, created.Image, created.Ports)
+	}
+	if updated.Revision != 2 || server.Revision != 2 || server.ContainerID != "container-green" || server.Status != StatusRunning {
+		t.Errorf("expected the server to run revision 2 in the new container, got %+v", server)
+	}
+	if len(stopped) != 1 || stopped[0] != "container-blue" {
+		t.Errorf("expected only the old container to be stopped, got %v", stopped)
+	}
+	if len(*revisions) != 2 || (*revisions)[1].Image != "mcp:v2" {
+		t.Errorf("expected revision 2 to be stored, got %+v", *revisions)
+	}
+	allocs, _ := repo.ListPortAllocations(context.Background())
+	if len(allocs) != 1 || allocs[0].Port != 20001 || allocs[0].Standby {
+		t.Errorf("expected the new container's port to replace the old one's, got %+v", allocs)
+	}
+}
+
+func TestUpdateServerKeepsOldContainerWhenRolloutFails(t *testing.T) {
+	server := &MCPServer{ID: uuid.New(), Image: "mcp:v1", Status: StatusRunning, ContainerID: "container-blue"}
+	repo, revisions := revisionRepo(server)
+	ports, _ := NewPortAllocator(repo, 20000, 20010)
+	ports.Allocate(context.Background(), server.ID)
+
+	cm := newMockContainerMgr()
+	cm.CreateFn = func(context.Context, *ContainerConfig) (string, error) { return "container-green", nil }
+	cm.StartFn = func(context.Context, string) error { return errors.New("image entrypoint not found") }
+	cm.StopFn = func(_ context.Context, id string) error {
+		t.Errorf("expected no container to be stopped, got %s", id)
+		return nil
+	}
+	svc := NewService(repo, cm, ports, nil, nil, nil, nil)
+
+	_, err := svc.UpdateServer(context.Background(), server.ID, &ServerUpdate{Image: "mcp:broken"})
+	if !errors.Is(err, ErrRolloutFailed) {
+		t.Fatalf("expected ErrRolloutFailed, got %v", err)
+	}
+	if server.ContainerID != "container-blue" || server.Image != "mcp:v1" || server.Revision != 1 || len(*revisions) != 1 {
+		t.Errorf("expected the server to be left on revision 1, got %+v", server)
+	}
+	allocs, _ := repo.ListPortAllocations(context.Background())
+	if len(allocs) != 1 || allocs[0].Port != 20000 {
+		t.Errorf("expected the standby port to be released, got %+v", allocs)
+	}
+}
+
+func TestUpdateServerKeepsChangesMadeDuringRollout(t *testing.T) {
+	green := newRPCBackend(t, nil)
+	server := &MCPServer{ID: uuid.New(), Image: "mcp:v1", Status: StatusRunning, ContainerID: "container-blue"}
+	repo, _ := revisionRepo(server)
+
+	cm := newMockContainerMgr()
+	cm.CreateFn = func(context.Context, *ContainerConfig) (string, error) { return "container-green", nil }
+	cm.EndpointFn = func(context.Context, string, string) (string, error) {
+		// A capability sync and a health check land while the new
+		// container comes up.
+		server.Tools = []any{"search"}
+		server.RestartCount = 2
+		return strings.TrimPrefix(green.URL, "http://"), nil
+	}
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
+
+	if _, err := svc.UpdateServer(context.Background(), server.ID, &ServerUpdate{Image: "mcp:v2"}); err != nil {
+		t.Fatalf("UpdateServer failed: %v", err)
+	}
+	if server.Image != "mcp:v2" || server.ContainerID != "container-green" || len(server.Tools) != 1 || server.RestartCount != 2 {
+		t.Errorf("expected the update to keep the changes made meanwhile, got %+v", server)
+	}
+}
+
+func TestUpdateAndRollbackStoppedServer(t *testing.T) {
+	userID := uuid.New()
+	server := &MCPServer{ID: uuid.New(), OwnerID: userID, Image: "mcp:v1", Status: StatusStopped}
+	repo, revisions := revisionRepo(server)
+	cm := newMockContainerMgr()
+	cm.CreateFn = func(context.Context, *ContainerConfig) (string, error) {
+		t.Error("expected no container for a stopped server")
+		return "", nil
+	}
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
+
+	if _, err := svc.UpdateServer(context.Background(), server.ID, &ServerUpdate{Image: "mcp:v2", UpdatedBy: userID}); err != nil {
+		t.Fatalf("UpdateServer failed: %v", err)
+	}
+	if server.Image != "mcp:v2" || server.Revision != 2 || server.Status != StatusStopped {
+		t.Errorf("expected revision 2 to be stored, got %+v", server)
+	}
+
+	updated, err := svc.RollbackServer(context.Background(), server.ID, 1, userID)
+	if err != nil {
+		t.Fatalf("RollbackServer failed: %v", err)
+	}
+	if updated.Image != "mcp:v1" || updated.Revision != 3 {
+		t.Errorf("expected the rollback as revision 3 of the old image, got %+v", updated)
+	}
+	rev := (*revisions)[2]
+	if rev.RollbackOf == nil || *rev.RollbackOf != 1 || rev.CreatedBy == nil || *rev.CreatedBy != userID {
+		t.Errorf("expected revision 3 to record the rollback of 1 by the user, got %+v", rev)
+	}
+
+	if _, err := svc.RollbackServer(context.Background(), server.ID, 9, userID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown revision, got %v", err)
+	}
+}
+
+func TestUpdateServerRefusesNonOwners(t *testing.T) {
+	server := &MCPServer{ID: uuid.New(), OwnerID: uuid.New(), Image: "mcp:v1", Status: StatusStopped}
+	repo, revisions := revisionRepo(server)
+	svc := NewService(repo, newMockContainerMgr(), nil, nil, nil, nil, nil)
+	attacker := uuid.New()
+
+	spec := ServerSpec{Secrets: map[string]SecretRef{"TOKEN": {Vault: "github", Field: "access_token"}}}
+	if _, err := svc.UpdateServer(context.Background(), server.ID, &ServerUpdate{Image: "attacker/mcp", Config: &spec, UpdatedBy: attacker}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an update by a non-owner, got %v", err)
+	}
+	if _, err := svc.RollbackServer(context.Background(), server.ID, 1, attacker); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a rollback by a non-owner, got %v", err)
+	}
+	if server.Image != "mcp:v1" || len(*revisions) != 1 {
+		t.Errorf("expected the server to be left unchanged, got %+v", server)
+	}
+}
+
+func TestUpdateServerWithoutChangesKeepsRevision(t *testing.T) {
+	server := &MCPServer{ID: uuid.New(), Image: "mcp:v1", Status: StatusRunning, ContainerID: "container-blue"}
+	server.Config.Version = SpecVersion
+	repo, revisions := revisionRepo(server)
+	svc := NewService(repo, newMockContainerMgr(), nil, nil, nil, nil, nil)
+
+	updated, err := svc.UpdateServer(context.Background(), server.ID, &ServerUpdate{Image: "mcp:v1"})
+	if err != nil || updated.Revision != 1 || len(*revisions) != 1 {
+		t.Errorf("expected no new revision, got %v and %+v", err, updated)
+	}
+}
+
+func TestProxyFollowsServerToNewContainer(t *testing.T) {
+	// The old container answers the client's initialize, then goes away.
+	blue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msg rpcMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		conn.WriteJSON(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{}`)})
+	}))
+	t.Cleanup(blue.Close)
+	methods := make(chan string, 10)
+	green := newRPCBackend(t, methods)
+
+	sessions := make(chan *proxySession, 1)
+	proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(blue.URL, "http"), func(p *proxySession) {
+		p.containerID = "container-blue"
+		p.lookup = func(context.Context) (*MCPServer, error) {
+			return &MCPServer{Status: StatusRunning, ContainerID: "container-green"}, nil
+		}
+		p.wake = func(ctx context.Context) (*MCPServer, BackendConn, error) {
+			backend, err := dialBackend(ctx, green)
+			return &MCPServer{ContainerID: "container-green"}, backend, err
+		}
+		p.resumed = make(chan BackendConn, 1)
+		sessions <- p
+	})
+	conn := dialTestProxy(t, proxy)
+	session := <-sessions
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	readRPC(t, conn)
+	deadline := time.Now().Add(2 * time.Second)
+	for !session.isSuspended() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the session to be suspended once the old container went away")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	if msg := readRPC(t, conn); rpcID(msg.ID) != "2" {
+		t.Errorf("expected the tools/list response from the new container, got %+v", msg)
+	}
+	if got := <-methods + "," + <-methods; got != "initialize,tools/list" {
+		t.Errorf("expected the handshake to be replayed on the new container, got %s", got)
+	}
+}
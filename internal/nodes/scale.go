@@ -0,0 +1,398 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// scalesToZero reports whether the server has an idle timeout, so it is
+// stopped when unused and started again on demand.
+func scalesToZero(server *MCPServer) bool {
+	return server.Config.IdleTimeout > 0
+}
+
+// asleep reports whether the server was scaled to zero and can be woken.
+func asleep(server *MCPServer) bool {
+	return scalesToZero(server) && server.Status == StatusStopped
+}
+
+// ScalerOptions tunes the Scaler. Zero values take the defaults noted.
+type ScalerOptions struct {
+	// Interval is how often running servers are checked for idleness (30s).
+	Interval time.Duration
+	// ReadyTimeout bounds how long a cold start waits for the server to
+	// answer an MCP ping (1m).
+	ReadyTimeout time.Duration
+}
+
+func (o *ScalerOptions) setDefaults() {
+	if o.Interval <= 0 {
+		o.Interval = 30 * time.Second
+	}
+	if o.ReadyTimeout <= 0 {
+		o.ReadyTimeout = time.Minute
+	}
+}
+
+// Scaler scales servers with an idle timeout to zero. It stops a server once
+// no traffic has been proxied to it for its idle timeout, and starts it again
+// when a client needs it. WebSocket sessions to the server survive the stop
+// and resume on the new container; other sessions are closed.
+type Scaler struct {
+	repo     Repository
+	svc      Service
+	sessions *SessionRegistry
+	opts     ScalerOptions
+
+	mu sync.Mutex
+	// active holds the time of each server's last proxied traffic.
+	active map[uuid.UUID]time.Time
+	// locks serialise the stops and starts of each server.
+	locks map[uuid.UUID]*sync.Mutex
+}
+
+// NewScaler creates a scaler. svc stops, starts and pings servers; sessions
+// may be nil.
+func NewScaler(repo Repository, svc Service, sessions *SessionRegistry, opts ScalerOptions) *Scaler {
+	opts.setDefaults()
+	return &Scaler{
+		repo:     repo,
+		svc:      svc,
+		sessions: sessions,
+		opts:     opts,
+		active:   make(map[uuid.UUID]time.Time),
+		locks:    make(map[uuid.UUID]*sync.Mutex),
+	}
+}
+
+// Run stops idle servers until ctx is done.
+func (s *Scaler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.StopIdle(ctx)
+		}
+	}
+}
+
+// touch records traffic proxied to the server.
+func (s *Scaler) touch(id uuid.UUID) {
+	s.mu.Lock()
+	s.active[id] = time.Now()
+	s.mu.Unlock()
+}
+
+// idleFor returns how long the server has gone without traffic. A server
+// not seen before counts as active now, so one started by hand or before a
+// gateway restart gets its full idle timeout.
+func (s *Scaler) idleFor(id uuid.UUID) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.active[id]
+	if !ok {
+		s.active[id] = time.Now()
+		return 0
+	}
+	return time.Since(last)
+}
+
+func (s *Scaler) lockFor(id uuid.UUID) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.locks[id]
+	if l == nil {
+		l = &sync.Mutex{}
+		s.locks[id] = l
+	}
+	return l
+}
+
+// StopIdle stops the running servers that have gone without traffic for
+// their idle timeout.
+func (s *Scaler) StopIdle(ctx context.Context) {
+	servers, err := s.repo.SearchServers(ctx, "")
+	if err != nil {
+		slog.Error("scaler listing servers", "error", err)
+		return
+	}
+	for i := range servers {
+		server := &servers[i]
+		if server.Status == StatusRunning && scalesToZero(server) &&
+			s.idleFor(server.ID) >= time.Duration(server.Config.IdleTimeout) {
+			s.stop(ctx, server.ID)
+		}
+	}
+}
+
+// stop suspends the server's sessions and stops it, unless it was used,
+// stopped or reconfigured in the meantime.
+func (s *Scaler) stop(ctx context.Context, id uuid.UUID) {
+	lock := s.lockFor(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	server, err := s.repo.GetServer(ctx, id)
+	if err != nil {
+		return
+	}
+	if server.Status != StatusRunning || !scalesToZero(server) {
+		return
+	}
+	idle := s.idleFor(id)
+	if idle < time.Duration(server.Config.IdleTimeout) {
+		return
+	}
+
+	suspended, closed := 0, 0
+	if s.sessions != nil {
+		suspended, closed = s.sessions.suspendServer(id, websocket.CloseGoingAway, "server idle")
+	}
+	if err := s.svc.StopServer(ctx, id); err != nil {
+		slog.Error("stopping idle server", "server_id", id, "error", err)
+		return
+	}
+	s.mu.Lock()
+	delete(s.active, id)
+	s.mu.Unlock()
+	slog.Info("idle server stopped", "server_id", id, "idle", idle.Round(time.Second), "sessions_suspended", suspended, "sessions_closed", closed)
+}
+
+// Wake starts a server that was scaled to zero and waits until it answers an
+// MCP ping. A running server is returned as is; a server that is neither
+// running nor asleep gives ErrContainerNotAvailable.
+func (s *Scaler) Wake(ctx context.Context, id uuid.UUID) (*MCPServer, error) {
+	lock := s.lockFor(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	server, err := s.repo.GetServer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if server.Status == StatusRunning {
+		return server, nil
+	}
+	if !asleep(server) {
+		return nil, ErrContainerNotAvailable
+	}
+
+	started := time.Now()
+	if err := s.svc.StartServer(ctx, id); err != nil {
+		return nil, err
+	}
+	s.touch(id)
+	if server, err = s.repo.GetServer(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := waitReady(ctx, s.svc, server, s.opts.ReadyTimeout); err != nil {
+		return nil, err
+	}
+	slog.Info("server woken", "server_id", id, "took", time.Since(started).Round(time.Millisecond))
+	return server, nil
+}
+
+// handshakeTimeout bounds the replay of a client's initialize handshake.
+const handshakeTimeout = 30 * time.Second
+
+// touch records the session's traffic with the scaler.
+func (p *proxySession) touch() {
+	if p.scaler != nil {
+		p.scaler.touch(p.server.ID)
+	}
+}
+
+// attached returns the session's backend, nil while it is suspended.
+func (p *proxySession) attached() BackendConn {
+	p.backendMu.Lock()
+	defer p.backendMu.Unlock()
+	return p.backend
+}
+
+func (p *proxySession) isSuspended() bool {
+	p.backendMu.Lock()
+	defer p.backendMu.Unlock()
+	return p.suspended
+}
+
+// closeBackend closes the session's backend, if it has one.
+func (p *proxySession) closeBackend() {
+	if backend := p.attached(); backend != nil {
+		backend.Close()
+	}
+}
+
+// suspend detaches a session that can wake its server from its backend
+// ahead of an idle stop, and reports whether it did. The session resumes
+// when the client next sends a message.
+func (p *proxySession) suspend() bool {
+	if p.wake == nil {
+		return false
+	}
+	p.backendMu.Lock()
+	backend := p.backend
+	p.backend = nil
+	p.suspended = true
+	p.backendMu.Unlock()
+	if backend != nil {
+		backend.Close()
+	}
+	return true
+}
+
+// forward writes a client message to the backend, first waking the server
+// and resuming the session if it is suspended.
+func (p *proxySession) forward(ctx context.Context, mt int, msg []byte) error {
+	backend, err := p.resume(ctx)
+	if err != nil {
+		return err
+	}
+	err = backend.WriteMessage(mt, msg)
+	if err != nil && (p.isSuspended() || p.redeployed(ctx, backend)) {
+		// The server was stopped or replaced while the message was sent.
+		if backend, err = p.resume(ctx); err != nil {
+			return err
+		}
+		err = backend.WriteMessage(mt, msg)
+	}
+	if err == nil && p.wake != nil {
+		p.recordHandshake(msg)
+	}
+	return err
+}
+
+// resume returns the session's backend. A suspended session wakes its
+// server, replays the client's initialize handshake on the new backend and
+// hands it to the backend-to-client relay.
+func (p *proxySession) resume(ctx context.Context) (BackendConn, error) {
+	p.backendMu.Lock()
+	backend, suspended := p.backend, p.suspended
+	p.backendMu.Unlock()
+	if !suspended {
+		return backend, nil
+	}
+
+	// The backend lock is not held while waking: the scaler may be
+	// suspending this session to stop the server.
+	server, backend, err := p.wake(ctx)
+	if err != nil {
+		slog.Warn("waking server", "server_id", p.server.ID, "error", err)
+		p.close(websocket.CloseTryAgainLater, "server unavailable")
+		return nil, err
+	}
+	if err := p.replayHandshake(backend); err != nil {
+		backend.Close()
+		slog.Warn("replaying initialize", "server_id", p.server.ID, "error", err)
+		p.close(websocket.CloseTryAgainLater, "server unavailable")
+		return nil, err
+	}
+
+	p.backendMu.Lock()
+	p.backend = backend
+	p.containerID = server.ContainerID
+	p.suspended = false
+	p.backendMu.Unlock()
+	select {
+	case p.resumed <- backend:
+	case <-ctx.Done():
+		backend.Close()
+		return nil, ctx.Err()
+	}
+	return backend, nil
+}
+
+// redeployed reports whether the server moved to a new container while the
+// session was connected to the failed backend, as it does when an update is
+// rolled out, and if so suspends the session so it resumes on the new
+// container.
+func (p *proxySession) redeployed(ctx context.Context, failed BackendConn) bool {
+	if p.lookup == nil || p.wake == nil {
+		return false
+	}
+	server, err := p.lookup(ctx)
+	if err != nil || server.Status != StatusRunning {
+		return false
+	}
+	p.backendMu.Lock()
+	defer p.backendMu.Unlock()
+	if p.suspended {
+		// The other direction of the session noticed first.
+		return true
+	}
+	if p.backend != failed || server.ContainerID == p.containerID {
+		return false
+	}
+	p.backend = nil
+	p.suspended = true
+	return true
+}
+
+// recordHandshake keeps the client's initialize request and initialized
+// notification for replay.
+func (p *proxySession) recordHandshake(raw []byte) {
+	var msg rpcMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return
+	}
+	p.backendMu.Lock()
+	defer p.backendMu.Unlock()
+	switch msg.Method {
+	case "initialize":
+		p.initialize = raw
+	case "notifications/initialized":
+		p.initialized = raw
+	}
+}
+
+// replayHandshake sends the client's recorded initialize request to a new
+// backend, discards the response the client already has, and sends the
+// initialized notification. A client that has not initialized yet does so
+// through the new backend itself.
+func (p *proxySession) replayHandshake(backend BackendConn) error {
+	p.backendMu.Lock()
+	initialize, initialized := p.initialize, p.initialized
+	p.backendMu.Unlock()
+	if initialize == nil {
+		return nil
+	}
+	var req rpcMessage
+	if err := json.Unmarshal(initialize, &req); err != nil {
+		return err
+	}
+
+	// Closing the connection unblocks a pending read.
+	timer := time.AfterFunc(handshakeTimeout, func() { backend.Close() })
+	defer timer.Stop()
+
+	if err := backend.WriteMessage(websocket.TextMessage, initialize); err != nil {
+		return err
+	}
+	for {
+		_, raw, err := backend.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(raw, &msg); err != nil || msg.isRequest() || rpcID(msg.ID) != rpcID(req.ID) {
+			continue
+		}
+		if msg.Error != nil {
+			return errors.New(msg.Error.Message)
+		}
+		break
+	}
+	if initialized != nil {
+		return backend.WriteMessage(websocket.TextMessage, initialized)
+	}
+	return nil
+}
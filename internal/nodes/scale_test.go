@@ -0,0 +1,227 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// newRPCBackend starts a WebSocket MCP backend that answers every request
+// with an empty result and sends the method of each message it receives on
+// methods, if set.
+func newRPCBackend(t *testing.T, methods chan<- string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, raw, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg rpcMessage
+			json.Unmarshal(raw, &msg)
+			if methods != nil {
+				methods <- msg.Method
+			}
+			if msg.isNotification() {
+				continue
+			}
+			reply, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{}`)})
+			if err := conn.WriteMessage(websocket.TextMessage, reply); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func dialBackend(ctx context.Context, ts *httptest.Server) (BackendConn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, "ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &wsConn{Conn: conn}, nil
+}
+
+func TestScalerStopsIdleServers(t *testing.T) {
+	row := newSupervisedServer("")
+	row.server.Config.IdleTimeout = Duration(time.Minute)
+	var stopped []uuid.UUID
+	svc := &mockService{StopServerFn: func(_ context.Context, id uuid.UUID) error {
+		stopped = append(stopped, id)
+		return nil
+	}}
+	s := NewScaler(row.repo(), svc, nil, ScalerOptions{})
+
+	// The first check only starts the server's idle clock.
+	s.StopIdle(context.Background())
+	if len(stopped) != 0 {
+		t.Fatalf("expected a server seen for the first time to be kept, got %v", stopped)
+	}
+
+	s.touch(row.server.ID)
+	s.StopIdle(context.Background())
+	if len(stopped) != 0 {
+		t.Fatalf("expected a recently used server to be kept, got %v", stopped)
+	}
+
+	s.active[row.server.ID] = time.Now().Add(-2 * time.Minute)
+	s.StopIdle(context.Background())
+	if len(stopped) != 1 || stopped[0] != row.server.ID {
+		t.Fatalf("expected the idle server to be stopped, got %v", stopped)
+	}
+}
+
+func TestScalerIgnoresServersWithoutIdleTimeout(t *testing.T) {
+	row := newSupervisedServer("")
+	svc := &mockService{StopServerFn: func(_ context.Context, id uuid.UUID) error {
+		t.Errorf("expected the server to be kept, got a stop of %s", id)
+		return nil
+	}}
+	s := NewScaler(row.repo(), svc, nil, ScalerOptions{})
+	s.active[row.server.ID] = time.Now().Add(-24 * time.Hour)
+
+	s.StopIdle(context.Background())
+}
+
+func TestScalerWakesStoppedServer(t *testing.T) {
+	backend := newRPCBackend(t, nil)
+	row := newSupervisedServer("")
+	row.server.Status = StatusStopped
+	row.server.ContainerID = ""
+	row.server.Config.IdleTimeout = Duration(time.Minute)
+	repo := row.repo()
+	starts := 0
+	svc := &mockService{
+		StartServerFn: func(ctx context.Context, id uuid.UUID) error {
+			starts++
+			server, _ := repo.GetServer(ctx, id)
+			server.Status = StatusRunning
+			server.ContainerID = "container-2"
+			return repo.UpdateServer(ctx, server)
+		},
+		OpenBackendFn: func(ctx context.Context, _ *MCPServer) (BackendConn, error) {
+			return dialBackend(ctx, backend)
+		},
+	}
+	s := NewScaler(repo, svc, nil, ScalerOptions{ReadyTimeout: 2 * time.Second})
+
+	server, err := s.Wake(context.Background(), row.server.ID)
+	if err != nil {
+		t.Fatalf("Wake failed: %v", err)
+	}
+	if server.Status != StatusRunning || server.ContainerID != "container-2" {
+		t.Errorf("expected the started server, got %+v", server)
+	}
+	if _, err := s.Wake(context.Background(), row.server.ID); err != nil || starts != 1 {
+		t.Errorf("expected a running server to be returned without a start, got %v after %d starts", err, starts)
+	}
+}
+
+func TestScalerWakeRejectsServersNotAsleep(t *testing.T) {
+	row := newSupervisedServer("")
+	row.server.Status = StatusStopped
+	svc := &mockService{StartServerFn: func(_ context.Context, id uuid.UUID) error {
+		t.Errorf("expected no start of %s", id)
+		return nil
+	}}
+	s := NewScaler(row.repo(), svc, nil, ScalerOptions{})
+
+	if _, err := s.Wake(context.Background(), row.server.ID); !errors.Is(err, ErrContainerNotAvailable) {
+		t.Errorf("expected ErrContainerNotAvailable for a server without an idle timeout, got %v", err)
+	}
+}
+
+func TestProxyResumesSuspendedSession(t *testing.T) {
+	first := newRPCBackend(t, nil)
+	woken := make(chan string, 10)
+	second := newRPCBackend(t, woken)
+	sessions := make(chan *proxySession, 1)
+	proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(first.URL, "http"), func(p *proxySession) {
+		p.wake = func(ctx context.Context) (*MCPServer, BackendConn, error) {
+			backend, err := dialBackend(ctx, second)
+			return &MCPServer{ContainerID: "container-2"}, backend, err
+		}
+		p.resumed = make(chan BackendConn, 1)
+		sessions <- p
+	})
+	conn := dialTestProxy(t, proxy)
+	session := <-sessions
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	readRPC(t, conn)
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+	readRPC(t, conn)
+
+	if !session.suspend() {
+		t.Fatal("expected a session with wake to suspend")
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`))
+
+	if msg := readRPC(t, conn); rpcID(msg.ID) != "3" {
+		t.Errorf("expected the tools/list response and not the replayed initialize's, got %+v", msg)
+	}
+	var methods []string
+	for range 3 {
+		select {
+		case m := <-woken:
+			methods = append(methods, m)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected the new backend to receive the handshake and request, got %v", methods)
+		}
+	}
+	if strings.Join(methods, ",") != "initialize,notifications/initialized,tools/list" {
+		t.Errorf("expected the handshake to be replayed before the request, got %v", methods)
+	}
+}
+
+func TestSessionRegistrySuspendsServerSessions(t *testing.T) {
+	backend := newEchoBackend(t)
+	registry := NewSessionRegistry()
+	serverID := uuid.New()
+	registered := make(chan struct{}, 2)
+	wake := func(context.Context) (*MCPServer, BackendConn, error) { return nil, nil, errors.New("asleep") }
+	// The first session can wake its server, the second cannot.
+	n := 0
+	proxy := newProxyTestServer(t, "ws"+strings.TrimPrefix(backend.URL, "http"), func(p *proxySession) {
+		p.server = &MCPServer{ID: serverID}
+		if n++; n == 1 {
+			p.wake = wake
+			p.resumed = make(chan BackendConn, 1)
+		}
+		registry.add(serverID, p)
+		registered <- struct{}{}
+	})
+	suspendable := dialTestProxy(t, proxy)
+	<-registered
+	closable := dialTestProxy(t, proxy)
+	<-registered
+
+	suspended, closed := registry.suspendServer(serverID, websocket.CloseGoingAway, "server idle")
+	if suspended != 1 || closed != 1 {
+		t.Fatalf("expected 1 session suspended and 1 closed, got %d and %d", suspended, closed)
+	}
+
+	closable.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := closable.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected the session without wake to be closed as going away, got %v", err)
+	}
+	suspendable.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, _, err := suspendable.ReadMessage(); websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected the suspended session to stay open, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// toolSchemas maps tool names to their compiled inputSchema.
+type toolSchemas map[string]*jsonschema.Schema
+
+// SchemaCache compiles and caches the tool input schemas advertised by each
+// server. Entries are rebuilt whenever the server record changes, which is
+// when SyncCapabilities stores a fresh tools list.
+type SchemaCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]schemaEntry
+}
+
+type schemaEntry struct {
+	updatedAt time.Time
+	tools     toolSchemas
+}
+
+// NewSchemaCache creates an empty schema cache.
+func NewSchemaCache() *SchemaCache {
+	return &SchemaCache{entries: make(map[uuid.UUID]schemaEntry)}
+}
+
+// forServer returns the compiled tool schemas for server.
+func (c *SchemaCache) forServer(server *MCPServer) toolSchemas {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[server.ID]; ok && e.updatedAt.Equal(server.UpdatedAt) {
+		return e.tools
+	}
+	tools := compileToolSchemas(server)
+	c.entries[server.ID] = schemaEntry{updatedAt: server.UpdatedAt, tools: tools}
+	return tools
+}
+
+// compileToolSchemas compiles the inputSchema of every tool in server.Tools.
+// Tools whose schema is missing or does not compile are left unvalidated.
+func compileToolSchemas(server *MCPServer) toolSchemas {
+	schemas := make(toolSchemas)
+	for _, t := range server.Tools {
+		tool, ok := t.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := tool["name"].(string)
+		input, ok := tool["inputSchema"]
+		if name == "" || !ok {
+			continue
+		}
+
+		raw, err := json.Marshal(input)
+		if err != nil {
+			continue
+		}
+		url := fmt.Sprintf("nexusclaw:///servers/%s/tools/%s", server.ID, name)
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource(url, bytes.NewReader(raw)); err != nil {
+			slog.Warn("invalid tool input schema", "server_id", server.ID, "tool", name, "error", err)
+			continue
+		}
+		schema, err := compiler.Compile(url)
+		if err != nil {
+			slog.Warn("invalid tool input schema", "server_id", server.ID, "tool", name, "error", err)
+			continue
+		}
+		schemas[name] = schema
+	}
+	return schemas
+}
+
+// schemaViolation is a single argument validation failure. Path is a JSON
+// pointer into the tool arguments.
+type schemaViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// validateToolCall checks tools/call params against the named tool's schema.
+// It returns nil when the call is valid or the tool has no known schema.
+func (s toolSchemas) validateToolCall(params json.RawMessage) *RPCError {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &call); err != nil {
+		return &RPCError{Code: codeInvalidParams, Message: "Invalid params: " + err.Error()}
+	}
+
+	schema, ok := s[call.Name]
+	if !ok {
+		return nil
+	}
+
+	args := call.Arguments
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return &RPCError{Code: codeInvalidParams, Message: "Invalid params: " + err.Error()}
+	}
+
+	err := schema.Validate(v)
+	if err == nil {
+		return nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		slog.Error("validating tool arguments", "tool", call.Name, "error", err)
+		return nil
+	}
+
+	violations := leafViolations(ve, nil)
+	msg := fmt.Sprintf("Invalid params for tool %q", call.Name)
+	if len(violations) > 0 {
+		msg += fmt.Sprintf(": %s: %s", violationPath(violations[0].Path), violations[0].Message)
+	}
+	return &RPCError{
+		Code:    codeInvalidParams,
+		Message: msg,
+		Data:    map[string]any{"tool": call.Name, "errors": violations},
+	}
+}
+
+// leafViolations flattens a validation error tree into its most specific causes.
+func leafViolations(ve *jsonschema.ValidationError, out []schemaViolation) []schemaViolation {
+	if len(ve.Causes) == 0 {
+		return append(out, schemaViolation{Path: ve.InstanceLocation, Message: ve.Message})
+	}
+	for _, cause := range ve.Causes {
+		out = leafViolations(cause, out)
+	}
+	return out
+}
+
+func violationPath(path string) string {
+	if path == "" {
+		return "arguments"
+	}
+	return "arguments" + path
+}
@@ -0,0 +1,93 @@
+package nodes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testToolServer() *MCPServer {
+	var tools []any
+	json.Unmarshal([]byte(`[
+		{"name": "read_file", "inputSchema": {
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "minLength": 1},
+				"limit": {"type": "integer", "minimum": 1}
+			},
+			"required": ["path"],
+			"additionalProperties": false
+		}},
+		{"name": "no_schema"},
+		{"name": "broken", "inputSchema": {"type": 42}}
+	]`), &tools)
+	return &MCPServer{ID: uuid.New(), Tools: tools, UpdatedAt: time.Now()}
+}
+
+func TestValidateToolCall(t *testing.T) {
+	schemas := compileToolSchemas(testToolServer())
+
+	tests := []struct {
+		name     string
+		params   string
+		wantPath string
+	}{
+		{"valid", `{"name":"read_file","arguments":{"path":"/etc/hosts","limit":10}}`, ""},
+		{"missing required", `{"name":"read_file","arguments":{}}`, ""},
+		{"wrong type", `{"name":"read_file","arguments":{"path":"a","limit":"ten"}}`, "/limit"},
+		{"extra property", `{"name":"read_file","arguments":{"path":"a","mode":"rw"}}`, ""},
+		{"no arguments", `{"name":"read_file"}`, ""},
+		{"tool without schema", `{"name":"no_schema","arguments":{"anything":true}}`, ""},
+		{"unknown tool", `{"name":"other","arguments":1}`, ""},
+		{"broken schema", `{"name":"broken","arguments":{}}`, ""},
+	}
+	wantValid := map[string]bool{"valid": true, "tool without schema": true, "unknown tool": true, "broken schema": true}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpcErr := schemas.validateToolCall(json.RawMessage(tt.params))
+			if wantValid[tt.name] {
+				if rpcErr != nil {
+					t.Fatalf("expected valid call, got %+v", rpcErr)
+				}
+				return
+			}
+			if rpcErr == nil || rpcErr.Code != codeInvalidParams {
+				t.Fatalf("expected -32602 error, got %+v", rpcErr)
+			}
+			if !strings.Contains(rpcErr.Message, `"read_file"`) {
+				t.Errorf("expected message to name the tool, got %q", rpcErr.Message)
+			}
+			violations := rpcErr.Data.(map[string]any)["errors"].([]schemaViolation)
+			if len(violations) == 0 {
+				t.Fatal("expected violation details")
+			}
+			if tt.wantPath != "" && violations[0].Path != tt.wantPath {
+				t.Errorf("expected path %s, got %s", tt.wantPath, violations[0].Path)
+			}
+		})
+	}
+}
+
+func TestSchemaCacheRebuildsOnUpdate(t *testing.T) {
+	cache := NewSchemaCache()
+	server := testToolServer()
+
+	first := cache.forServer(server)
+	if _, ok := first["read_file"]; !ok {
+		t.Fatal("expected read_file schema")
+	}
+
+	server.Tools = nil
+	if got := cache.forServer(server); len(got) != len(first) {
+		t.Errorf("expected cached schemas for unchanged server, got %d", len(got))
+	}
+
+	server.UpdatedAt = server.UpdatedAt.Add(time.Second)
+	if got := cache.forServer(server); len(got) != 0 {
+		t.Errorf("expected schemas rebuilt after update, got %d", len(got))
+	}
+}
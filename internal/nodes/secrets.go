@@ -0,0 +1,139 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/google/uuid"
+
+	"github.com/kapella-hub/NexusClaw/internal/pass"
+)
+
+// Vault reads credentials from a user's pass vault.
+type Vault interface {
+	Relay(ctx context.Context, userID uuid.UUID, provider string) (*pass.Credential, error)
+}
+
+// resolvedSecrets are the plaintext values of a server's secrets. They only
+// live in memory on their way into the container.
+type resolvedSecrets struct {
+	env map[string]string
+	// files maps container paths to file contents.
+	files map[string]string
+}
+
+// resolveSecrets reads the secrets a server references from its owner's
+// vault and its OAuth grants. Only the owner writes a server's config:
+// RegisterServer makes the caller the owner and updates by anyone else are
+// refused, so a config can only reach its own author's secrets.
+func (s *service) resolveSecrets(ctx context.Context, server *MCPServer) (resolvedSecrets, error) {
+	var out resolvedSecrets
+	creds := make(map[string]*pass.Credential)
+	for _, name := range sortedKeys(server.Config.Secrets) {
+		ref := server.Config.Secrets[name]
+		var value string
+		var err error
+		if ref.OAuth != "" {
+			value, err = s.oauthSecret(ctx, server, ref)
+		} else {
+			value, err = s.vaultSecret(ctx, server, ref, creds)
+		}
+		if err != nil {
+			return out, fmt.Errorf("secret %s: %w", name, err)
+		}
+
+		if ref.File != "" {
+			if out.files == nil {
+				out.files = make(map[string]string)
+			}
+			out.files[ref.File] = value
+		} else {
+			if out.env == nil {
+				out.env = make(map[string]string)
+			}
+			out.env[name] = value
+		}
+	}
+	return out, nil
+}
+
+// vaultSecret reads a credential field from the server owner's vault. creds
+// caches the credentials already read.
+func (s *service) vaultSecret(ctx context.Context, server *MCPServer, ref SecretRef, creds map[string]*pass.Credential) (string, error) {
+	if s.vault == nil {
+		return "", fmt.Errorf("%w: no vault is configured", ErrSecretUnavailable)
+	}
+	cred, ok := creds[ref.Vault]
+	if !ok {
+		var err error
+		cred, err = s.vault.Relay(ctx, server.OwnerID, ref.Vault)
+		if errors.Is(err, pass.ErrNotFound) {
+			return "", fmt.Errorf("%w: no %s credential in the vault", ErrSecretUnavailable, ref.Vault)
+		}
+		if err != nil {
+			return "", err
+		}
+		creds[ref.Vault] = cred
+	}
+
+	value := cred.AccessToken
+	if ref.Field == "secret" {
+		value = cred.Secret
+	}
+	if value == "" {
+		return "", fmt.Errorf("%w: the %s credential has no %s", ErrSecretUnavailable, ref.Vault, ref.Field)
+	}
+	return value, nil
+}
+
+// oauthSecret reads the access token of the server's OAuth grant.
+func (s *service) oauthSecret(ctx context.Context, server *MCPServer, ref SecretRef) (string, error) {
+	if s.tokens == nil {
+		return "", fmt.Errorf("%w: no oauth providers are configured", ErrSecretUnavailable)
+	}
+	return s.tokens.AccessToken(ctx, server, ref.OAuth)
+}
+
+func (s *service) ReloadOAuthSecrets(ctx context.Context, id uuid.UUID, provider string) error {
+	if s.container == nil {
+		return ErrContainerNotAvailable
+	}
+	server, err := s.repo.GetServer(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting server: %w", err)
+	}
+	if server.Status != StatusRunning || server.ContainerID == "" {
+		// The token is read when the server next starts.
+		return nil
+	}
+
+	var inEnv, inFile bool
+	for _, ref := range server.Config.Secrets {
+		if ref.OAuth == provider {
+			inEnv = inEnv || ref.File == ""
+			inFile = inFile || ref.File != ""
+		}
+	}
+	switch {
+	case inEnv:
+		if err := s.StopServer(ctx, id); err != nil {
+			return err
+		}
+		return s.StartServer(ctx, id)
+	case inFile:
+		secrets, err := s.resolveSecrets(ctx, server)
+		if err != nil {
+			return err
+		}
+		return s.container.UpdateSecrets(ctx, server.ContainerID, secrets.files)
+	}
+	return nil
+}
+
+// apply adds the secrets to a container's configuration.
+func (r resolvedSecrets) apply(cfg *ContainerConfig) {
+	maps.Copy(cfg.Env, r.env)
+	cfg.SecretFiles = r.files
+}
@@ -0,0 +1,118 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SecurityProfile hardens a server's container. Servers run with
+// DefaultSecurityProfile unless their spec overrides some of its fields.
+type SecurityProfile struct {
+	// ReadOnlyRootfs mounts the image's filesystem read-only. Tmpfs lists
+	// the absolute paths of writable in-memory mounts.
+	ReadOnlyRootfs bool     `json:"read_only_rootfs"`
+	Tmpfs          []string `json:"tmpfs"`
+	// CapDrop and CapAdd adjust the container's Linux capabilities. Only
+	// the capabilities in addableCaps may be added.
+	CapDrop         []string `json:"cap_drop"`
+	CapAdd          []string `json:"cap_add,omitempty"`
+	NoNewPrivileges bool     `json:"no_new_privileges"`
+	// User is the user[:group] the server runs as; empty keeps the image's.
+	// Running as root must be allowed by the operator.
+	User string `json:"user"`
+	// PidsLimit caps the number of processes; zero means unlimited.
+	PidsLimit int64 `json:"pids_limit"`
+	// Seccomp names a profile in the gateway's seccomp directory, or is
+	// "unconfined" where the operator allows it. Empty uses Docker's default
+	// profile.
+	Seccomp string `json:"seccomp,omitempty"`
+	// NoNetwork gives the container no network at all. Only stdio servers,
+	// which the gateway reaches by attaching, can use it.
+	NoNetwork bool `json:"no_network"`
+}
+
+// DefaultSecurityProfile returns the hardened profile servers run with by
+// default: a read-only root filesystem with a writable /tmp, no capabilities,
+// no privilege escalation, the unprivileged nobody user and at most 256
+// processes.
+func DefaultSecurityProfile() SecurityProfile {
+	return SecurityProfile{
+		ReadOnlyRootfs:  true,
+		Tmpfs:           []string{"/tmp"},
+		CapDrop:         []string{"ALL"},
+		NoNewPrivileges: true,
+		User:            "65534:65534",
+		PidsLimit:       256,
+	}
+}
+
+// seccompProfileName matches the profile names Seccomp may refer to.
+var seccompProfileName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// addableCaps are the capabilities a spec may add back: those Docker grants
+// by default, less NET_RAW and MKNOD. None of them lets a container reach
+// beyond itself.
+var addableCaps = map[string]bool{
+	"AUDIT_WRITE":      true,
+	"CHOWN":            true,
+	"DAC_OVERRIDE":     true,
+	"FOWNER":           true,
+	"FSETID":           true,
+	"KILL":             true,
+	"NET_BIND_SERVICE": true,
+	"SETFCAP":          true,
+	"SETGID":           true,
+	"SETPCAP":          true,
+	"SETUID":           true,
+	"SYS_CHROOT":       true,
+}
+
+// capName normalizes a capability name as Docker does, e.g. "cap_chown" to
+// "CHOWN".
+func capName(c string) string {
+	return strings.TrimPrefix(strings.ToUpper(c), "CAP_")
+}
+
+// runsAsRoot reports whether a user[:group] names the root user.
+func runsAsRoot(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "root" || name == "0"
+}
+
+// serverSecurity returns the server's security profile: the default, with the
+// fields set in its spec overridden.
+func serverSecurity(server *MCPServer) SecurityProfile {
+	if server.Config.Security != nil {
+		return *server.Config.Security
+	}
+	return DefaultSecurityProfile()
+}
+
+// seccompOption returns the Docker security option applying the named seccomp
+// profile, read from dir/<name>.json.
+func seccompOption(dir, name string) (string, error) {
+	switch name {
+	case "":
+		return "", nil
+	case "unconfined":
+		return "seccomp=unconfined", nil
+	}
+	if dir == "" {
+		return "", fmt.Errorf("seccomp profile %q requested but no seccomp directory is configured", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return "", fmt.Errorf("reading seccomp profile %q: %w", name, err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return "", fmt.Errorf("seccomp profile %q is not valid JSON", name)
+	}
+	return "seccomp=" + compact.String(), nil
+}
@@ -0,0 +1,73 @@
+package nodes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerSecurityDefaultsToHardened(t *testing.T) {
+	profile := serverSecurity(&MCPServer{})
+	if !profile.ReadOnlyRootfs || !profile.NoNewPrivileges || profile.User == "" || profile.PidsLimit == 0 {
+		t.Errorf("expected a hardened default, got %+v", profile)
+	}
+	if len(profile.CapDrop) != 1 || profile.CapDrop[0] != "ALL" {
+		t.Errorf("expected all capabilities dropped, got %v", profile.CapDrop)
+	}
+}
+
+func TestServerSecurityOverrides(t *testing.T) {
+	spec, err := ParseSpec([]byte(`{
+		"transport": "stdio",
+		"security": {"read_only_rootfs": false, "user": "", "cap_add": ["NET_BIND_SERVICE", "cap_chown"], "no_network": true}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseSpec failed: %v", err)
+	}
+	profile := serverSecurity(&MCPServer{Config: spec})
+	if profile.ReadOnlyRootfs || profile.User != "" || !profile.NoNetwork {
+		t.Errorf("expected overridden fields, got %+v", profile)
+	}
+	if !profile.NoNewPrivileges || profile.PidsLimit != 256 {
+		t.Errorf("expected unset fields to keep their defaults, got %+v", profile)
+	}
+}
+
+func TestServerSecurityInvalid(t *testing.T) {
+	tests := []string{
+		`{"security": {"privileged": true}}`,
+		`{"security": {"tmpfs": ["tmp"]}}`,
+		`{"security": {"pids_limit": -1}}`,
+		`{"security": {"seccomp": "../etc/passwd"}}`,
+		`{"security": {"cap_add": ["SYS_ADMIN"]}}`,
+		`{"security": {"cap_add": ["ALL"]}}`,
+		`{"security": {"cap_add": ["NET_RAW"]}}`,
+		`{"security": {"no_network": true}}`,
+		`{"security": "hardened"}`,
+	}
+	for _, config := range tests {
+		if _, err := ParseSpec([]byte(config)); err == nil {
+			t.Errorf("expected %s to be rejected", config)
+		}
+	}
+}
+
+func TestSeccompOption(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "strict.json"), []byte("{\n  \"defaultAction\": \"SCMP_ACT_ERRNO\"\n}\n"), 0o600)
+
+	opt, err := seccompOption(dir, "strict")
+	if err != nil {
+		t.Fatalf("seccompOption failed: %v", err)
+	}
+	if opt != `seccomp={"defaultAction":"SCMP_ACT_ERRNO"}` {
+		t.Errorf("unexpected option %q", opt)
+	}
+	if opt, _ := seccompOption("", "unconfined"); opt != "seccomp=unconfined" {
+		t.Errorf("unexpected unconfined option %q", opt)
+	}
+	if _, err := seccompOption("", "strict"); err == nil || !strings.Contains(err.Error(), "no seccomp directory") {
+		t.Errorf("expected an error without a seccomp directory, got %v", err)
+	}
+}
@@ -2,11 +2,20 @@ package nodes
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
@@ -19,17 +28,83 @@ type Service interface {
 	RemoveServer(ctx context.Context, id uuid.UUID) error
 	StartServer(ctx context.Context, id uuid.UUID) error
 	StopServer(ctx context.Context, id uuid.UUID) error
+	// QuarantineServer marks a server quarantined, optionally stopping its
+	// container. Quarantining an already quarantined server is a no-op.
+	QuarantineServer(ctx context.Context, id uuid.UUID, stop bool) error
+	// ReleaseServer returns a quarantined server to running or stopped,
+	// depending on whether its container is still up.
+	ReleaseServer(ctx context.Context, id uuid.UUID) error
+	ListAccessPolicies(ctx context.Context, serverID uuid.UUID) ([]AccessPolicy, error)
+	PutAccessPolicy(ctx context.Context, policy *AccessPolicy) error
+	DeleteAccessPolicy(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) error
+	// EffectivePolicy returns the policy governing userID on serverID: the
+	// user's own policy, else the server default, else nil (unrestricted).
+	EffectivePolicy(ctx context.Context, serverID, userID uuid.UUID) (*AccessPolicy, error)
 	ConnectWebSocket(ctx context.Context, serverID uuid.UUID, w http.ResponseWriter, r *http.Request) error
+	// OpenBackend connects to a running server's MCP endpoint over its
+	// configured transport. It returns ErrBackendBusy if a stdio server
+	// already has a client attached.
+	OpenBackend(ctx context.Context, server *MCPServer) (BackendConn, error)
+	// RemoveOrphans removes this gateway's containers that no server tracks
+	// and returns their IDs.
+	RemoveOrphans(ctx context.Context) ([]string, error)
+	// Logs returns the output of the server's container.
+	Logs(ctx context.Context, id uuid.UUID, opts LogOptions) (io.ReadCloser, error)
+	// Stats returns the current resource usage of the server's container.
+	Stats(ctx context.Context, id uuid.UUID) (*ContainerStats, error)
+	// ReloadOAuthSecrets passes the current token of the server's grant for
+	// provider to its running container: secret files are rewritten in
+	// place, while a token in an env var requires restarting the server.
+	ReloadOAuthSecrets(ctx context.Context, id uuid.UUID, provider string) error
+	// UpdateServer stores the server's new image or config as a new revision.
+	// A running server is switched over to a container of the new revision
+	// once it is healthy; if it never becomes healthy, the old container
+	// keeps serving and ErrRolloutFailed is returned.
+	UpdateServer(ctx context.Context, id uuid.UUID, update *ServerUpdate) (*MCPServer, error)
+	// RollbackServer updates the server to the image and config of an
+	// earlier revision, recorded as a new revision. Both return ErrNotFound
+	// to anyone but the server's owner.
+	RollbackServer(ctx context.Context, id uuid.UUID, revision int, userID uuid.UUID) (*MCPServer, error)
+	ListServerRevisions(ctx context.Context, id uuid.UUID) ([]ServerRevision, error)
+	GetServerRevision(ctx context.Context, id uuid.UUID, revision int) (*ServerRevision, error)
 }
 
+// orphanGracePeriod spares containers created so recently that the start
+// creating them may not have recorded their ID yet.
+const orphanGracePeriod = time.Minute
+
 type service struct {
 	repo      Repository
 	container ContainerManager
+	ports     *PortAllocator
+	egress    *EgressProxy
+	limits    *LimitTiers
+	vault     Vault
+	tokens    *OAuthTokens
+
+	mu sync.Mutex
+	// rolling holds the servers with an update being rolled out.
+	rolling map[uuid.UUID]bool
 }
 
-// NewService creates a new Managed MCP service.
-func NewService(repo Repository, container ContainerManager) Service {
-	return &service{repo: repo, container: container}
+// NewService creates a new Managed MCP service. If ports is non-nil, each
+// started container publishes its MCP port on a host port from the allocator;
+// otherwise the container manager decides how the port is reached. If egress
+// is non-nil, containers send their outbound HTTP(S) traffic through it. If
+// limits is non-nil, servers' resource limits are capped by their owner's
+// tier. vault and tokens resolve the vault credentials and OAuth grants
+// servers reference as secrets; without them, such servers cannot start.
+func NewService(repo Repository, container ContainerManager, ports *PortAllocator, egress *EgressProxy, limits *LimitTiers, vault Vault, tokens *OAuthTokens) Service {
+	return &service{
+		repo:      repo,
+		container: container,
+		ports:     ports,
+		egress:    egress,
+		limits:    limits,
+		vault:     vault,
+		tokens:    tokens,
+		rolling:   make(map[uuid.UUID]bool),
+	}
 }
 
 func (s *service) ListServers(ctx context.Context, ownerID uuid.UUID) ([]MCPServer, error) {
@@ -41,15 +116,45 @@ func (s *service) GetServer(ctx context.Context, id uuid.UUID) (*MCPServer, erro
 }
 
 func (s *service) RegisterServer(ctx context.Context, server *MCPServer) error {
+	if server.Config.Version == 0 {
+		server.Config.Version = SpecVersion
+	}
+	if fields := server.Config.Validate(); len(fields) > 0 {
+		return &SpecError{Fields: fields}
+	}
+	if _, err := s.limits.Apply(server.OwnerID, server.Config.Limits); err != nil {
+		return err
+	}
+
 	now := time.Now()
 	server.ID = uuid.New()
 	server.Status = StatusStopped
+	server.Revision = 1
 	server.CreatedAt = now
 	server.UpdatedAt = now
-	return s.repo.CreateServer(ctx, server)
+	if err := s.repo.CreateServer(ctx, server); err != nil {
+		return err
+	}
+	return s.repo.CreateServerRevision(ctx, &ServerRevision{
+		ServerID:  server.ID,
+		Image:     server.Image,
+		Config:    server.Config,
+		CreatedBy: &server.OwnerID,
+		CreatedAt: now,
+	})
 }
 
+// RemoveServer stops and removes the server's container and frees its port
+// before deleting the server.
 func (s *service) RemoveServer(ctx context.Context, id uuid.UUID) error {
+	server, err := s.repo.GetServer(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting server: %w", err)
+	}
+	if err := s.stopContainer(ctx, server); err != nil {
+		return err
+	}
+	s.releasePort(ctx, server.ID)
 	return s.repo.DeleteServer(ctx, id)
 }
 
@@ -62,76 +167,392 @@ func (s *service) StartServer(ctx context.Context, id uuid.UUID) error {
 	if err != nil {
 		return fmt.Errorf("getting server: %w", err)
 	}
+	switch server.Status {
+	case StatusQuarantined:
+		return ErrQuarantined
+	case StatusRunning, StatusStarting, StatusStopping:
+		// A second container would take over the name, secrets and port of
+		// the live one.
+		return ErrServerRunning
+	}
+
+	containerID, endpoint, err := s.launch(ctx, server, server.ID.String(), false)
+	if err != nil {
+		return err
+	}
+
+	server.Status = StatusRunning
+	server.ContainerID = containerID
+	server.Endpoint = endpoint
+	server.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateServer(ctx, server); err != nil {
+		return fmt.Errorf("updating server: %w", err)
+	}
+
+	s.syncCapabilitiesLater(id)
+	return nil
+}
+
+// launch creates and starts a container named name for the server and
+// returns its ID and MCP endpoint. A standby container, the new revision of
+// a running server, publishes its port on the server's standby host port.
+// On failure nothing is left behind.
+func (s *service) launch(ctx context.Context, server *MCPServer, name string, standby bool) (containerID, endpoint string, err error) {
+	secrets, err := s.resolveSecrets(ctx, server)
+	if err != nil {
+		return "", "", err
+	}
+	security := serverSecurity(server)
+	limits, err := s.containerLimits(server, &security)
+	if err != nil {
+		return "", "", err
+	}
 
+	stdio := serverTransport(server) == TransportStdio
+	port := backendPort(server)
 	cfg := &ContainerConfig{
-		Image: server.Image,
-		Env:   extractEnv(server.Config),
+		Name:        name,
+		Image:       server.Image,
+		Env:         maps.Clone(server.Config.Env),
+		Stdin:       stdio,
+		Labels:      containerLabels(server),
+		Security:    &security,
+		MemoryLimit: int64(limits.Memory),
+		CPULimit:    limits.CPUs,
+		DiskLimit:   int64(limits.Disk),
+	}
+	if cfg.Env == nil {
+		cfg.Env = make(map[string]string)
+	}
+	secrets.apply(cfg)
+	if s.egress != nil {
+		maps.Copy(cfg.Env, s.egress.Env(server.ID))
+	}
+	release := func() { s.releasePort(ctx, server.ID) }
+	if standby {
+		release = func() { s.releaseStandbyPort(ctx, server.ID) }
+	}
+	// Stdio servers are reached by attaching, so they need no port.
+	if !stdio {
+		portSpec := port
+		if s.ports != nil {
+			allocate := s.ports.Allocate
+			if standby {
+				allocate = s.ports.AllocateStandby
+			}
+			hostPort, err := allocate(ctx, server.ID)
+			if err != nil {
+				return "", "", fmt.Errorf("allocating host port: %w", err)
+			}
+			portSpec = fmt.Sprintf("%d:%s", hostPort, port)
+		}
+		cfg.Ports = []string{portSpec}
 	}
 
-	containerID, err := s.container.Create(ctx, cfg)
+	containerID, err = s.container.Create(ctx, cfg)
 	if err != nil {
-		return fmt.Errorf("creating container: %w", err)
+		release()
+		return "", "", fmt.Errorf("creating container: %w", err)
 	}
 
 	if err := s.container.Start(ctx, containerID); err != nil {
 		// Best-effort cleanup of the created container.
 		_ = s.container.Remove(ctx, containerID)
-		return fmt.Errorf("starting container: %w", err)
+		release()
+		return "", "", fmt.Errorf("starting container: %w", err)
 	}
 
-	server.Status = StatusRunning
-	server.ContainerID = containerID
-	server.UpdatedAt = time.Now()
-
-	if err := s.repo.UpdateServer(ctx, server); err != nil {
-		return fmt.Errorf("updating server: %w", err)
+	if !stdio {
+		endpoint, err = s.container.Endpoint(ctx, containerID, port)
+		if err != nil {
+			_ = s.container.Stop(ctx, containerID)
+			_ = s.container.Remove(ctx, containerID)
+			release()
+			return "", "", fmt.Errorf("resolving container endpoint: %w", err)
+		}
 	}
+	return containerID, endpoint, nil
+}
 
+// syncCapabilitiesLater refreshes the server's tools and resources once its
+// container has had a moment to come up.
+func (s *service) syncCapabilitiesLater(id uuid.UUID) {
 	go func() {
 		time.Sleep(2 * time.Second)
 		if err := s.SyncCapabilities(context.Background(), id); err != nil {
 			slog.Error("failed to sync capabilities", "server_id", id, "error", err)
 		}
 	}()
+}
+
+func (s *service) StopServer(ctx context.Context, id uuid.UUID) error {
+	if s.container == nil {
+		return ErrContainerNotAvailable
+	}
+
+	server, err := s.repo.GetServer(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting server: %w", err)
+	}
+
+	if err := s.stopContainer(ctx, server); err != nil {
+		return err
+	}
+	s.releasePort(ctx, server.ID)
+
+	server.Status = StatusStopped
+	server.ContainerID = ""
+	server.Endpoint = ""
+	server.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateServer(ctx, server); err != nil {
+		return fmt.Errorf("updating server: %w", err)
+	}
 
 	return nil
 }
 
-func (s *service) StopServer(ctx context.Context, id uuid.UUID) error {
+// stopContainer stops and removes the server's container, if it has one. A
+// container that is already gone counts as removed. On any other failure the
+// server is left in the error state, since its container may or may not
+// still be running.
+func (s *service) stopContainer(ctx context.Context, server *MCPServer) error {
+	if server.ContainerID == "" {
+		return nil
+	}
 	if s.container == nil {
 		return ErrContainerNotAvailable
 	}
+	// Mark the server stopping first so the supervisor does not treat the
+	// container's exit as a crash.
+	server.Status = StatusStopping
+	server.UpdatedAt = time.Now()
+	if err := s.repo.UpdateServer(ctx, server); err != nil {
+		return fmt.Errorf("updating server: %w", err)
+	}
+	fail := func(err error) error {
+		server.Status = StatusError
+		server.UpdatedAt = time.Now()
+		if uerr := s.repo.UpdateServer(context.WithoutCancel(ctx), server); uerr != nil {
+			slog.Error("updating server after failed stop", "server_id", server.ID, "error", uerr)
+		}
+		return err
+	}
+	if err := s.container.Stop(ctx, server.ContainerID); err != nil && !cerrdefs.IsNotFound(err) {
+		return fail(fmt.Errorf("stopping container: %w", err))
+	}
+	if err := s.container.Remove(ctx, server.ContainerID); err != nil && !cerrdefs.IsNotFound(err) {
+		return fail(fmt.Errorf("removing container: %w", err))
+	}
+	return nil
+}
+
+func (s *service) RemoveOrphans(ctx context.Context) ([]string, error) {
+	if s.container == nil {
+		return nil, ErrContainerNotAvailable
+	}
+
+	servers, err := s.repo.SearchServers(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("listing servers: %w", err)
+	}
+	containers, err := s.container.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		if server.ContainerID != "" {
+			tracked[server.ContainerID] = true
+		}
+	}
+	removed := []string{}
+	for _, c := range containers {
+		// The new container of an update in progress is not tracked yet.
+		if tracked[c.ID] || time.Since(c.Created) < orphanGracePeriod || s.rollingOut(c.ServerID) {
+			continue
+		}
+		if err := s.container.Remove(ctx, c.ID); err != nil {
+			return removed, err
+		}
+		slog.Info("removed orphaned container", "container_id", c.ID, "name", c.Name, "server_id", c.ServerID)
+		removed = append(removed, c.ID)
+	}
+	return removed, nil
+}
+
+func (s *service) Logs(ctx context.Context, id uuid.UUID, opts LogOptions) (io.ReadCloser, error) {
+	if s.container == nil {
+		return nil, ErrContainerNotAvailable
+	}
 
+	server, err := s.repo.GetServer(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting server: %w", err)
+	}
+	if server.ContainerID == "" {
+		return nil, ErrContainerNotAvailable
+	}
+	return s.container.Logs(ctx, server.ContainerID, opts)
+}
+
+func (s *service) Stats(ctx context.Context, id uuid.UUID) (*ContainerStats, error) {
+	if s.container == nil {
+		return nil, ErrContainerNotAvailable
+	}
+
+	server, err := s.repo.GetServer(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting server: %w", err)
+	}
+	if server.ContainerID == "" {
+		return nil, ErrContainerNotAvailable
+	}
+	return s.container.Stats(ctx, server.ContainerID)
+}
+
+func (s *service) QuarantineServer(ctx context.Context, id uuid.UUID, stop bool) error {
 	server, err := s.repo.GetServer(ctx, id)
 	if err != nil {
 		return fmt.Errorf("getting server: %w", err)
 	}
+	if server.Status == StatusQuarantined {
+		return nil
+	}
 
-	if server.ContainerID != "" {
+	if stop && s.container != nil && server.ContainerID != "" {
+		// Mark the server quarantined first so the supervisor does not
+		// restart it when the container exits.
+		server.Status = StatusQuarantined
+		server.UpdatedAt = time.Now()
+		if err := s.repo.UpdateServer(ctx, server); err != nil {
+			return fmt.Errorf("updating server: %w", err)
+		}
 		if err := s.container.Stop(ctx, server.ContainerID); err != nil {
 			return fmt.Errorf("stopping container: %w", err)
 		}
 		if err := s.container.Remove(ctx, server.ContainerID); err != nil {
 			return fmt.Errorf("removing container: %w", err)
 		}
+		s.releasePort(ctx, server.ID)
+		server.ContainerID = ""
+		server.Endpoint = ""
+	}
+
+	server.Status = StatusQuarantined
+	server.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateServer(ctx, server); err != nil {
+		return fmt.Errorf("updating server: %w", err)
+	}
+	return nil
+}
+
+func (s *service) ReleaseServer(ctx context.Context, id uuid.UUID) error {
+	server, err := s.repo.GetServer(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting server: %w", err)
+	}
+	if server.Status != StatusQuarantined {
+		return ErrNotQuarantined
 	}
 
 	server.Status = StatusStopped
-	server.ContainerID = ""
+	if server.ContainerID != "" {
+		server.Status = StatusRunning
+	}
 	server.UpdatedAt = time.Now()
 
 	if err := s.repo.UpdateServer(ctx, server); err != nil {
 		return fmt.Errorf("updating server: %w", err)
 	}
+	return nil
+}
+
+// containerLimits returns the resource limits of the server's container,
+// capped by its owner's tier, and sets the security profile's process limit to
+// match. Without an explicit pids limit the tighter of the profile's and the
+// tier's applies.
+func (s *service) containerLimits(server *MCPServer, security *SecurityProfile) (ResourceLimits, error) {
+	limits, err := s.limits.Apply(server.OwnerID, server.Config.Limits)
+	if err != nil {
+		return ResourceLimits{}, err
+	}
+	if server.Config.Limits.Pids == 0 && security.PidsLimit > 0 && (limits.Pids == 0 || security.PidsLimit < limits.Pids) {
+		limits.Pids = security.PidsLimit
+	}
+	security.PidsLimit = limits.Pids
+	return limits, nil
+}
 
+// releasePort frees the server's host port allocation, if ports are managed.
+// Failures are logged; a leaked allocation is reclaimed on the next restart.
+func (s *service) releasePort(ctx context.Context, serverID uuid.UUID) {
+	if s.ports == nil {
+		return
+	}
+	if err := s.ports.Release(ctx, serverID); err != nil {
+		slog.Error("releasing host port", "server_id", serverID, "error", err)
+	}
+}
+
+// releaseStandbyPort is releasePort for the standby port of a rollout.
+func (s *service) releaseStandbyPort(ctx context.Context, serverID uuid.UUID) {
+	if s.ports == nil {
+		return
+	}
+	if err := s.ports.ReleaseStandby(ctx, serverID); err != nil {
+		slog.Error("releasing standby host port", "server_id", serverID, "error", err)
+	}
+}
+
+func (s *service) ListAccessPolicies(ctx context.Context, serverID uuid.UUID) ([]AccessPolicy, error) {
+	return s.repo.ListAccessPolicies(ctx, serverID)
+}
+
+func (s *service) PutAccessPolicy(ctx context.Context, policy *AccessPolicy) error {
+	now := time.Now()
+	policy.ID = uuid.New()
+	policy.CreatedAt = now
+	policy.UpdatedAt = now
+	if err := s.repo.UpsertAccessPolicy(ctx, policy); err != nil {
+		return err
+	}
+
+	// Reload so an updated policy keeps its original ID and creation time.
+	stored, err := s.repo.GetAccessPolicy(ctx, policy.ServerID, policy.PrincipalID)
+	if err != nil {
+		return err
+	}
+	*policy = *stored
 	return nil
 }
 
+func (s *service) DeleteAccessPolicy(ctx context.Context, serverID uuid.UUID, principalID *uuid.UUID) error {
+	return s.repo.DeleteAccessPolicy(ctx, serverID, principalID)
+}
+
+func (s *service) EffectivePolicy(ctx context.Context, serverID, userID uuid.UUID) (*AccessPolicy, error) {
+	policy, err := s.repo.GetAccessPolicy(ctx, serverID, &userID)
+	if errors.Is(err, ErrNotFound) {
+		policy, err = s.repo.GetAccessPolicy(ctx, serverID, nil)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return policy, err
+}
+
 func (s *service) ConnectWebSocket(ctx context.Context, serverID uuid.UUID, w http.ResponseWriter, r *http.Request) error {
 	server, err := s.repo.GetServer(ctx, serverID)
 	if err != nil {
 		return err
 	}
+	if server.Status == StatusQuarantined {
+		return ErrQuarantined
+	}
 	if server.Status != StatusRunning {
 		return ErrContainerNotAvailable
 	}
@@ -139,21 +560,34 @@ func (s *service) ConnectWebSocket(ctx context.Context, serverID uuid.UUID, w ht
 	return nil
 }
 
+func (s *service) OpenBackend(ctx context.Context, server *MCPServer) (BackendConn, error) {
+	if serverTransport(server) == TransportStdio {
+		if s.container == nil || server.ContainerID == "" {
+			return nil, ErrContainerNotAvailable
+		}
+		rwc, err := s.container.Attach(ctx, server.ContainerID)
+		if err != nil {
+			return nil, err
+		}
+		return newStdioConn(rwc), nil
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, backendURL(server), nil)
+	if err != nil {
+		return nil, fmt.Errorf("dialing backend: %w", err)
+	}
+	return &wsConn{Conn: conn}, nil
+}
+
 func (s *service) SyncCapabilities(ctx context.Context, id uuid.UUID) error {
 	server, err := s.repo.GetServer(ctx, id)
 	if err != nil {
 		return err
 	}
-	backendPort := "8080"
-	if p, ok := server.Config["ws_port"].(string); ok {
-		backendPort = p
-	}
-	backendURL := "ws://localhost:" + backendPort
-
-	// Try dialing with retries since container might take a second to start
-	var backendConn *websocket.Conn
+	// Try connecting with retries since container might take a second to start
+	var conn BackendConn
 	for i := 0; i < 5; i++ {
-		backendConn, _, err = websocket.DefaultDialer.Dial(backendURL, nil)
+		conn, err = s.OpenBackend(ctx, server)
 		if err == nil {
 			break
 		}
@@ -162,63 +596,112 @@ func (s *service) SyncCapabilities(ctx context.Context, id uuid.UUID) error {
 	if err != nil {
 		return fmt.Errorf("failed to connect to mcp server for sync: %v", err)
 	}
-	defer backendConn.Close()
+	defer conn.Close()
 
-	// Send tools/list request
-	err = backendConn.WriteJSON(map[string]any{
-		"jsonrpc": "2.0",
-		"id":      "sync-tools",
-		"method":  "tools/list",
-	})
+	// Bound the whole exchange; closing the connection unblocks pending reads.
+	timer := time.AfterFunc(10*time.Second, func() { conn.Close() })
+	defer timer.Stop()
+
+	tools, err := requestList(conn, "sync-tools", "tools/list", "tools")
 	if err != nil {
 		return err
 	}
+	if tools == nil {
+		tools = server.Tools
+	}
 
-	// Read response (best effort, read next 5 messages max looking for ours)
-	var toolsResp struct {
-		ID     string `json:"id"`
-		Result struct {
-			Tools []any `json:"tools"`
-		} `json:"result"`
+	resources, err := requestList(conn, "sync-resources", "resources/list", "resources")
+	if err != nil {
+		return err
 	}
-	for i := 0; i < 5; i++ {
-		backendConn.SetReadDeadline(time.Now().Add(2 * time.Second))
-		err = backendConn.ReadJSON(&toolsResp)
-		if err == nil && toolsResp.ID == "sync-tools" {
-			if toolsResp.Result.Tools != nil {
-				server.Tools = toolsResp.Result.Tools
-			}
-			break
-		}
+	if resources == nil {
+		resources = server.Resources
 	}
 
-	// Send resources/list request
-	err = backendConn.WriteJSON(map[string]any{
+	// The server may have been stopped or updated meanwhile, so only its
+	// capabilities are written.
+	return s.repo.UpdateServerCapabilities(ctx, id, tools, resources)
+}
+
+// requestList sends a list request and returns the named result field from its
+// response, looking at most five messages ahead. Read failures are treated as
+// no response.
+func requestList(conn BackendConn, id, method, field string) ([]any, error) {
+	req, err := json.Marshal(map[string]any{
 		"jsonrpc": "2.0",
-		"id":      "sync-resources",
-		"method":  "resources/list",
+		"id":      id,
+		"method":  method,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	var resResp struct {
-		ID     string `json:"id"`
-		Result struct {
-			Resources []any `json:"resources"`
-		} `json:"result"`
+	if err := conn.WriteMessage(websocket.TextMessage, req); err != nil {
+		return nil, err
 	}
+
 	for i := 0; i < 5; i++ {
-		backendConn.SetReadDeadline(time.Now().Add(2 * time.Second))
-		err = backendConn.ReadJSON(&resResp)
-		if err == nil && resResp.ID == "sync-resources" {
-			if resResp.Result.Resources != nil {
-				server.Resources = resResp.Result.Resources
-			}
-			break
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return nil, nil
+		}
+		var resp struct {
+			ID     any                        `json:"id"`
+			Result map[string]json.RawMessage `json:"result"`
 		}
+		if err := json.Unmarshal(raw, &resp); err != nil || resp.ID != id {
+			continue
+		}
+		var items []any
+		if err := json.Unmarshal(resp.Result[field], &items); err != nil {
+			return nil, nil
+		}
+		return items, nil
+	}
+	return nil, nil
+}
+
+// serverTransport returns the transport the server's MCP endpoint speaks.
+func serverTransport(server *MCPServer) string {
+	if server.Config.Transport == TransportStdio {
+		return TransportStdio
 	}
+	return TransportWebSocket
+}
 
-	server.UpdatedAt = time.Now()
-	return s.repo.UpdateServer(ctx, server)
+// backendPort returns the container port the server's MCP WebSocket listens on.
+func backendPort(server *MCPServer) string {
+	if server.Config.Port > 0 {
+		return strconv.Itoa(server.Config.Port)
+	}
+	return "8080"
+}
+
+// containerLabels returns the labels identifying the container of server.
+func containerLabels(server *MCPServer) map[string]string {
+	return map[string]string{
+		labelServerID:   server.ID.String(),
+		labelOwnerID:    server.OwnerID.String(),
+		labelConfigHash: configHash(server),
+	}
+}
+
+// configHash fingerprints the image and configuration a server's container is
+// created from, so containers from an outdated configuration can be told
+// apart.
+func configHash(server *MCPServer) string {
+	data, _ := json.Marshal(struct {
+		Image  string     `json:"image"`
+		Config ServerSpec `json:"config"`
+	}{server.Image, server.Config})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// backendURL returns the WebSocket URL the gateway dials to reach server.
+// Servers started before endpoints were recorded fall back to localhost.
+func backendURL(server *MCPServer) string {
+	if server.Endpoint != "" {
+		return "ws://" + server.Endpoint
+	}
+	return "ws://localhost:" + backendPort(server)
 }
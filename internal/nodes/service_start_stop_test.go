@@ -2,10 +2,19 @@ package nodes
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/google/uuid"
+
+	"github.com/kapella-hub/NexusClaw/internal/pass"
+	"github.com/kapella-hub/NexusClaw/internal/platform/crypto"
 )
 
 // mockContainerManager implements ContainerManager for service tests.
@@ -15,6 +24,14 @@ type mockContainerManager struct {
 	StopFn   func(ctx context.Context, containerID string) error
 	RemoveFn func(ctx context.Context, containerID string) error
 	StatusFn func(ctx context.Context, containerID string) (ServerStatus, error)
+	EndpointFn func(ctx context.Context, containerID, port string) (string, error)
+	AttachFn   func(ctx context.Context, containerID string) (io.ReadWriteCloser, error)
+	EventsFn   func(ctx context.Context) (<-chan ContainerEvent, <-chan error)
+	ListFn     func(ctx context.Context) ([]ManagedContainer, error)
+	LogsFn     func(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error)
+	StatsFn    func(ctx context.Context, containerID string) (*ContainerStats, error)
+
+	UpdateSecretsFn func(ctx context.Context, containerID string, files map[string]string) error
 }
 
 func (m *mockContainerManager) Create(ctx context.Context, cfg *ContainerConfig) (string, error) {
@@ -32,6 +49,27 @@ func (m *mockContainerManager) Remove(ctx context.Context, containerID string) e
 func (m *mockContainerManager) Status(ctx context.Context, containerID string) (ServerStatus, error) {
 	return m.StatusFn(ctx, containerID)
 }
+func (m *mockContainerManager) Attach(ctx context.Context, containerID string) (io.ReadWriteCloser, error) {
+	return m.AttachFn(ctx, containerID)
+}
+func (m *mockContainerManager) Events(ctx context.Context) (<-chan ContainerEvent, <-chan error) {
+	return m.EventsFn(ctx)
+}
+func (m *mockContainerManager) List(ctx context.Context) ([]ManagedContainer, error) {
+	return m.ListFn(ctx)
+}
+func (m *mockContainerManager) Logs(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error) {
+	return m.LogsFn(ctx, containerID, opts)
+}
+func (m *mockContainerManager) Stats(ctx context.Context, containerID string) (*ContainerStats, error) {
+	return m.StatsFn(ctx, containerID)
+}
+func (m *mockContainerManager) UpdateSecrets(ctx context.Context, containerID string, files map[string]string) error {
+	return m.UpdateSecretsFn(ctx, containerID, files)
+}
+func (m *mockContainerManager) Endpoint(ctx context.Context, containerID, port string) (string, error) {
+	return m.EndpointFn(ctx, containerID, port)
+}
 
 func newMockContainerMgr() *mockContainerManager {
 	return &mockContainerManager{
@@ -44,6 +82,9 @@ func newMockContainerMgr() *mockContainerManager {
 		StatusFn: func(_ context.Context, _ string) (ServerStatus, error) {
 			return StatusRunning, nil
 		},
+		EndpointFn: func(_ context.Context, _, port string) (string, error) {
+			return "172.20.0.2:" + port, nil
+		},
 	}
 }
 
@@ -58,7 +99,6 @@ func TestStartServerWithContainer(t *testing.T) {
 				Name:   "test-server",
 				Image:  "mcp:latest",
 				Status: StatusStopped,
-				Config: map[string]any{},
 			}, nil
 		},
 		UpdateServerFn: func(_ context.Context, s *MCPServer) error {
@@ -68,12 +108,23 @@ func TestStartServerWithContainer(t *testing.T) {
 	}
 
 	cm := newMockContainerMgr()
-	svc := NewService(repo, cm)
+	var created *ContainerConfig
+	cm.CreateFn = func(_ context.Context, cfg *ContainerConfig) (string, error) {
+		created = cfg
+		return "container-abc", nil
+	}
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
 
 	err := svc.StartServer(context.Background(), serverID)
 	if err != nil {
 		t.Fatalf("StartServer failed: %v", err)
 	}
+	if created.Name != serverID.String() || created.Labels[labelServerID] != serverID.String() || created.Labels[labelConfigHash] == "" {
+		t.Errorf("expected the container to be named and labelled for the server, got %q %v", created.Name, created.Labels)
+	}
+	if created.Security == nil || !created.Security.ReadOnlyRootfs {
+		t.Errorf("expected the hardened security profile by default, got %+v", created.Security)
+	}
 
 	if updatedServer == nil {
 		t.Fatal("expected UpdateServer to be called")
@@ -84,13 +135,292 @@ func TestStartServerWithContainer(t *testing.T) {
 	if updatedServer.ContainerID != "container-abc" {
 		t.Errorf("expected container ID container-abc, got %s", updatedServer.ContainerID)
 	}
+	if updatedServer.Endpoint != "172.20.0.2:8080" {
+		t.Errorf("expected endpoint 172.20.0.2:8080, got %s", updatedServer.Endpoint)
+	}
+	if got := backendURL(updatedServer); got != "ws://172.20.0.2:8080" {
+		t.Errorf("expected backend URL ws://172.20.0.2:8080, got %s", got)
+	}
+}
+
+func TestStartServerRoutesThroughEgressProxy(t *testing.T) {
+	serverID := uuid.New()
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, Image: "mcp:latest", Config: ServerSpec{Env: map[string]string{"HTTPS_PROXY": "http://elsewhere:8080"}}}, nil
+		},
+		UpdateServerFn: func(_ context.Context, _ *MCPServer) error { return nil },
+	}
+	cm := newMockContainerMgr()
+	var created *ContainerConfig
+	cm.CreateFn = func(_ context.Context, cfg *ContainerConfig) (string, error) {
+		created = cfg
+		return "container-abc", nil
+	}
+	egress, err := NewEgressProxy(repo, &recordingAudit{}, []byte("test-secret"), "http://nexusclaw:3128")
+	if err != nil {
+		t.Fatalf("NewEgressProxy: %v", err)
+	}
+	svc := NewService(repo, cm, nil, egress, nil, nil, nil)
+
+	if err := svc.StartServer(context.Background(), serverID); err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+	want := egress.Env(serverID)["HTTPS_PROXY"]
+	if created.Env["HTTPS_PROXY"] != want || created.Env["http_proxy"] != want {
+		t.Errorf("expected the container to use the egress proxy, got %v", created.Env)
+	}
+	if !strings.Contains(want, serverID.String()+":") {
+		t.Errorf("expected the proxy URL to carry the server's credentials, got %s", want)
+	}
+}
+
+// mockVault implements Vault with fixed credentials per provider.
+type mockVault map[string]*pass.Credential
+
+func (v mockVault) Relay(_ context.Context, _ uuid.UUID, provider string) (*pass.Credential, error) {
+	if cred, ok := v[provider]; ok {
+		return cred, nil
+	}
+	return nil, pass.ErrNotFound
+}
+
+func TestStartServerInjectsSecrets(t *testing.T) {
+	spec := ServerSpec{Secrets: map[string]SecretRef{
+		"GITHUB_TOKEN": {Vault: "github", Field: "access_token"},
+		"SIGNING_KEY":  {Vault: "signer", Field: "secret", File: "/run/secrets/key"},
+	}}
+	var stored *MCPServer
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, Image: "mcp:latest", Config: spec}, nil
+		},
+		UpdateServerFn: func(_ context.Context, s *MCPServer) error {
+			stored = s
+			return nil
+		},
+	}
+	cm := newMockContainerMgr()
+	var created *ContainerConfig
+	cm.CreateFn = func(_ context.Context, cfg *ContainerConfig) (string, error) {
+		created = cfg
+		return "container-abc", nil
+	}
+	vault := mockVault{
+		"github": {AccessToken: "gho_token"},
+		"signer": {Secret: "key-material"},
+	}
+	svc := NewService(repo, cm, nil, nil, nil, vault, nil)
+
+	if err := svc.StartServer(context.Background(), uuid.New()); err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+	if created.Env["GITHUB_TOKEN"] != "gho_token" {
+		t.Errorf("expected the token in the environment, got %v", created.Env)
+	}
+	if _, ok := created.Env["SIGNING_KEY"]; ok || created.SecretFiles["/run/secrets/key"] != "key-material" {
+		t.Errorf("expected the key as a file only, got env %v files %v", created.Env, created.SecretFiles)
+	}
+	data, _ := json.Marshal(stored)
+	if strings.Contains(string(data), "gho_token") || strings.Contains(string(data), "key-material") {
+		t.Errorf("expected no plaintext secret in the stored server, got %s", data)
+	}
+
+	for name, vault := range map[string]Vault{
+		"missing entry": mockVault{"github": {AccessToken: "gho_token"}},
+		"missing field": mockVault{"github": {AccessToken: "gho_token"}, "signer": {AccessToken: "tok"}},
+		"no vault":      nil,
+	} {
+		err := NewService(repo, cm, nil, nil, nil, vault, nil).StartServer(context.Background(), uuid.New())
+		if !errors.Is(err, ErrSecretUnavailable) {
+			t.Errorf("%s: expected ErrSecretUnavailable, got %v", name, err)
+		}
+	}
+}
+
+func TestStartServerInjectsOAuthToken(t *testing.T) {
+	ownerID := uuid.New()
+	key := []byte("0123456789abcdef0123456789abcdef")
+	sealed, _ := crypto.Seal([]byte("gho_oauth"), key)
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, OwnerID: ownerID, Image: "mcp:latest", Config: ServerSpec{
+				Secrets: map[string]SecretRef{"GITHUB_TOKEN": {OAuth: "github"}},
+			}}, nil
+		},
+		UpdateServerFn: func(_ context.Context, _ *MCPServer) error { return nil },
+		GetServerOAuthGrantFn: func(_ context.Context, _ uuid.UUID, provider string) (*OAuthGrant, error) {
+			return &OAuthGrant{OwnerID: ownerID, Provider: provider, AccessTokenEnc: sealed}, nil
+		},
+	}
+	cm := newMockContainerMgr()
+	var created *ContainerConfig
+	cm.CreateFn = func(_ context.Context, cfg *ContainerConfig) (string, error) {
+		created = cfg
+		return "container-abc", nil
+	}
+
+	svc := NewService(repo, cm, nil, nil, nil, nil, NewOAuthTokens(repo, key))
+	if err := svc.StartServer(context.Background(), uuid.New()); err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+	if created.Env["GITHUB_TOKEN"] != "gho_oauth" {
+		t.Errorf("expected the grant's token in the environment, got %v", created.Env)
+	}
+
+	err := NewService(repo, cm, nil, nil, nil, nil, nil).StartServer(context.Background(), uuid.New())
+	if !errors.Is(err, ErrSecretUnavailable) {
+		t.Errorf("expected ErrSecretUnavailable without oauth providers, got %v", err)
+	}
+}
+
+func TestReloadOAuthSecrets(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	sealed, _ := crypto.Seal([]byte("gho_renewed"), key)
+	tests := []struct {
+		name        string
+		ref         SecretRef
+		status      ServerStatus
+		wantFiles   map[string]string
+		wantRestart bool
+	}{
+		{"file", SecretRef{OAuth: "github", File: "/run/secrets/token"}, StatusRunning, map[string]string{"/run/secrets/token": "gho_renewed"}, false},
+		{"env", SecretRef{OAuth: "github"}, StatusRunning, nil, true},
+		{"other provider", SecretRef{OAuth: "gitlab"}, StatusRunning, nil, false},
+		{"stopped", SecretRef{OAuth: "github"}, StatusStopped, nil, false},
+	}
+	for _, tt := range tests {
+		server := MCPServer{ID: uuid.New(), Image: "mcp:latest", Status: tt.status, ContainerID: "container-old", Config: ServerSpec{
+			Secrets: map[string]SecretRef{"TOKEN": tt.ref},
+		}}
+		repo := &mockRepo{
+			GetServerFn: func(_ context.Context, _ uuid.UUID) (*MCPServer, error) {
+				s := server
+				return &s, nil
+			},
+			UpdateServerFn: func(_ context.Context, s *MCPServer) error {
+				server = *s
+				return nil
+			},
+			GetServerOAuthGrantFn: func(_ context.Context, _ uuid.UUID, _ string) (*OAuthGrant, error) {
+				return &OAuthGrant{AccessTokenEnc: sealed}, nil
+			},
+		}
+		cm := newMockContainerMgr()
+		var updated map[string]string
+		cm.UpdateSecretsFn = func(_ context.Context, containerID string, files map[string]string) error {
+			if containerID != "container-old" {
+				t.Errorf("%s: expected the running container to be updated, got %s", tt.name, containerID)
+			}
+			updated = files
+			return nil
+		}
+		var stopped bool
+		cm.StopFn = func(_ context.Context, _ string) error {
+			stopped = true
+			return nil
+		}
+
+		svc := NewService(repo, cm, nil, nil, nil, nil, NewOAuthTokens(repo, key))
+		if err := svc.ReloadOAuthSecrets(context.Background(), server.ID, "github"); err != nil {
+			t.Fatalf("%s: ReloadOAuthSecrets failed: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(updated, tt.wantFiles) {
+			t.Errorf("%s: expected secret files %v, got %v", tt.name, tt.wantFiles, updated)
+		}
+		restarted := stopped && server.Status == StatusRunning && server.ContainerID == "container-abc"
+		if restarted != tt.wantRestart {
+			t.Errorf("%s: expected restart %v, got stopped %v and server %s in %s", tt.name, tt.wantRestart, stopped, server.Status, server.ContainerID)
+		}
+	}
+}
+
+func TestStartServerAppliesResourceLimits(t *testing.T) {
+	tiers := &LimitTiers{
+		Tiers:       map[string]ResourceLimits{"default": {Memory: 1 << 30, CPUs: 2, Pids: 100}},
+		DefaultTier: "default",
+	}
+	profile := DefaultSecurityProfile()
+	profile.PidsLimit = 64
+	requested := ResourceLimits{Memory: 256 << 20, CPUs: 0.5, Pids: 50, Disk: 1 << 30}
+	tests := []struct {
+		name   string
+		config ServerSpec
+		want   ResourceLimits
+	}{
+		{"tier maximums", ServerSpec{}, ResourceLimits{Memory: 1 << 30, CPUs: 2, Pids: 100}},
+		{"requested", ServerSpec{Limits: requested}, requested},
+		{"tighter profile", ServerSpec{Security: &profile}, ResourceLimits{Memory: 1 << 30, CPUs: 2, Pids: 64}},
+	}
+	for _, tt := range tests {
+		repo := &mockRepo{
+			GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+				return &MCPServer{ID: id, Image: "mcp:latest", Config: tt.config}, nil
+			},
+			UpdateServerFn: func(_ context.Context, _ *MCPServer) error { return nil },
+		}
+		cm := newMockContainerMgr()
+		var created *ContainerConfig
+		cm.CreateFn = func(_ context.Context, cfg *ContainerConfig) (string, error) {
+			created = cfg
+			return "container-abc", nil
+		}
+		svc := NewService(repo, cm, nil, nil, tiers, nil, nil)
+
+		if err := svc.StartServer(context.Background(), uuid.New()); err != nil {
+			t.Fatalf("%s: StartServer failed: %v", tt.name, err)
+		}
+		got := ResourceLimits{Memory: ByteSize(created.MemoryLimit), CPUs: created.CPULimit, Pids: created.Security.PidsLimit, Disk: ByteSize(created.DiskLimit)}
+		if got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestStartServerEndpointFails(t *testing.T) {
+	serverID := uuid.New()
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, Image: "mcp:latest", Config: ServerSpec{Port: 9000}}, nil
+		},
+		UpdateServerFn: func(_ context.Context, _ *MCPServer) error {
+			t.Error("UpdateServer should not be called when the endpoint is unknown")
+			return nil
+		},
+	}
+
+	cm := newMockContainerMgr()
+	var exposed []string
+	cm.CreateFn = func(_ context.Context, cfg *ContainerConfig) (string, error) {
+		exposed = cfg.Ports
+		return "container-abc", nil
+	}
+	cm.EndpointFn = func(_ context.Context, _, _ string) (string, error) {
+		return "", errors.New("no address")
+	}
+	removed := false
+	cm.RemoveFn = func(_ context.Context, _ string) error {
+		removed = true
+		return nil
+	}
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
+
+	if err := svc.StartServer(context.Background(), serverID); err == nil {
+		t.Fatal("expected error when endpoint cannot be resolved")
+	}
+	if len(exposed) != 1 || exposed[0] != "9000" {
+		t.Errorf("expected port 9000 to be exposed, got %v", exposed)
+	}
+	if !removed {
+		t.Error("expected container to be removed")
+	}
 }
 
 func TestStartServerContainerCreateFails(t *testing.T) {
 	serverID := uuid.New()
 	repo := &mockRepo{
 		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
-			return &MCPServer{ID: id, Image: "mcp:latest", Config: map[string]any{}}, nil
+			return &MCPServer{ID: id, Image: "mcp:latest"}, nil
 		},
 	}
 
@@ -98,7 +428,7 @@ func TestStartServerContainerCreateFails(t *testing.T) {
 	cm.CreateFn = func(_ context.Context, _ *ContainerConfig) (string, error) {
 		return "", errors.New("docker daemon down")
 	}
-	svc := NewService(repo, cm)
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
 
 	err := svc.StartServer(context.Background(), serverID)
 	if err == nil {
@@ -106,12 +436,39 @@ func TestStartServerContainerCreateFails(t *testing.T) {
 	}
 }
 
+func TestStartServerAlreadyRunning(t *testing.T) {
+	for _, status := range []ServerStatus{StatusRunning, StatusStarting, StatusStopping} {
+		t.Run(string(status), func(t *testing.T) {
+			repo := &mockRepo{
+				GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+					return &MCPServer{ID: id, Image: "mcp:latest", Status: status, ContainerID: "live"}, nil
+				},
+				UpdateServerFn: func(_ context.Context, _ *MCPServer) error {
+					t.Error("server record must not be written")
+					return nil
+				},
+			}
+			cm := newMockContainerMgr()
+			cm.CreateFn = func(_ context.Context, _ *ContainerConfig) (string, error) {
+				t.Error("no container may be created")
+				return "", nil
+			}
+			svc := NewService(repo, cm, nil, nil, nil, nil, nil)
+
+			err := svc.StartServer(context.Background(), uuid.New())
+			if !errors.Is(err, ErrServerRunning) {
+				t.Fatalf("expected ErrServerRunning, got %v", err)
+			}
+		})
+	}
+}
+
 func TestStartServerContainerStartFailsCleansUp(t *testing.T) {
 	serverID := uuid.New()
 	var removedID string
 	repo := &mockRepo{
 		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
-			return &MCPServer{ID: id, Image: "mcp:latest", Config: map[string]any{}}, nil
+			return &MCPServer{ID: id, Image: "mcp:latest"}, nil
 		},
 	}
 
@@ -123,7 +480,7 @@ func TestStartServerContainerStartFailsCleansUp(t *testing.T) {
 		removedID = id
 		return nil
 	}
-	svc := NewService(repo, cm)
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
 
 	err := svc.StartServer(context.Background(), serverID)
 	if err == nil {
@@ -153,7 +510,7 @@ func TestStopServerWithContainer(t *testing.T) {
 	}
 
 	cm := newMockContainerMgr()
-	svc := NewService(repo, cm)
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
 
 	err := svc.StopServer(context.Background(), serverID)
 	if err != nil {
@@ -171,6 +528,57 @@ func TestStopServerWithContainer(t *testing.T) {
 	}
 }
 
+func TestStopServerContainerAlreadyGone(t *testing.T) {
+	serverID := uuid.New()
+	server := &MCPServer{ID: serverID, Status: StatusRunning, ContainerID: "container-gone"}
+	repo := &mockRepo{
+		GetServerFn: func(context.Context, uuid.UUID) (*MCPServer, error) {
+			copied := *server
+			return &copied, nil
+		},
+		UpdateServerFn: func(_ context.Context, s *MCPServer) error {
+			*server = *s
+			return nil
+		},
+	}
+	cm := newMockContainerMgr()
+	cm.StopFn = func(context.Context, string) error { return cerrdefs.ErrNotFound }
+	cm.RemoveFn = func(context.Context, string) error { return cerrdefs.ErrNotFound }
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
+
+	if err := svc.StopServer(context.Background(), serverID); err != nil {
+		t.Fatalf("expected a missing container to count as stopped, got %v", err)
+	}
+	if server.Status != StatusStopped || server.ContainerID != "" {
+		t.Errorf("expected the server stopped without a container, got %s %q", server.Status, server.ContainerID)
+	}
+}
+
+func TestStopServerFailureLeavesErrorStatus(t *testing.T) {
+	serverID := uuid.New()
+	server := &MCPServer{ID: serverID, Status: StatusRunning, ContainerID: "container-xyz"}
+	repo := &mockRepo{
+		GetServerFn: func(context.Context, uuid.UUID) (*MCPServer, error) {
+			copied := *server
+			return &copied, nil
+		},
+		UpdateServerFn: func(_ context.Context, s *MCPServer) error {
+			*server = *s
+			return nil
+		},
+	}
+	cm := newMockContainerMgr()
+	cm.StopFn = func(context.Context, string) error { return errors.New("docker daemon down") }
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
+
+	if err := svc.StopServer(context.Background(), serverID); err == nil {
+		t.Fatal("expected an error when the container cannot be stopped")
+	}
+	if server.Status != StatusError || server.ContainerID != "container-xyz" {
+		t.Errorf("expected the server in the error state with its container, got %s %q", server.Status, server.ContainerID)
+	}
+}
+
 func TestConnectWebSocketRunningServer(t *testing.T) {
 	serverID := uuid.New()
 	repo := &mockRepo{
@@ -178,7 +586,7 @@ func TestConnectWebSocketRunningServer(t *testing.T) {
 			return &MCPServer{ID: id, Status: StatusRunning}, nil
 		},
 	}
-	svc := NewService(repo, nil)
+	svc := NewService(repo, nil, nil, nil, nil, nil, nil)
 
 	err := svc.ConnectWebSocket(context.Background(), serverID, nil, nil)
 	if err != nil {
@@ -192,10 +600,172 @@ func TestConnectWebSocketNotFound(t *testing.T) {
 			return nil, ErrNotFound
 		},
 	}
-	svc := NewService(repo, nil)
+	svc := NewService(repo, nil, nil, nil, nil, nil, nil)
 
 	err := svc.ConnectWebSocket(context.Background(), uuid.New(), nil, nil)
 	if !errors.Is(err, ErrNotFound) {
 		t.Errorf("expected ErrNotFound, got %v", err)
 	}
 }
+
+func TestQuarantineServerStopsContainer(t *testing.T) {
+	var updatedServer *MCPServer
+	var stopped string
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, Status: StatusRunning, ContainerID: "container-xyz"}, nil
+		},
+		UpdateServerFn: func(_ context.Context, s *MCPServer) error {
+			updatedServer = s
+			return nil
+		},
+	}
+	cm := newMockContainerMgr()
+	cm.StopFn = func(_ context.Context, id string) error {
+		stopped = id
+		return nil
+	}
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
+
+	if err := svc.QuarantineServer(context.Background(), uuid.New(), true); err != nil {
+		t.Fatalf("QuarantineServer failed: %v", err)
+	}
+	if stopped != "container-xyz" {
+		t.Errorf("expected container-xyz to be stopped, got %q", stopped)
+	}
+	if updatedServer == nil || updatedServer.Status != StatusQuarantined || updatedServer.ContainerID != "" {
+		t.Errorf("expected quarantined server without container, got %+v", updatedServer)
+	}
+}
+
+func TestQuarantinedServerRefusesConnectionsAndStart(t *testing.T) {
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, Status: StatusQuarantined}, nil
+		},
+	}
+	svc := NewService(repo, newMockContainerMgr(), nil, nil, nil, nil, nil)
+
+	if err := svc.ConnectWebSocket(context.Background(), uuid.New(), nil, nil); !errors.Is(err, ErrQuarantined) {
+		t.Errorf("expected ErrQuarantined from ConnectWebSocket, got %v", err)
+	}
+	if err := svc.StartServer(context.Background(), uuid.New()); !errors.Is(err, ErrQuarantined) {
+		t.Errorf("expected ErrQuarantined from StartServer, got %v", err)
+	}
+}
+
+func TestReleaseServer(t *testing.T) {
+	tests := []struct {
+		name      string
+		server    MCPServer
+		want      ServerStatus
+		wantError error
+	}{
+		{"container kept", MCPServer{Status: StatusQuarantined, ContainerID: "c1"}, StatusRunning, nil},
+		{"container stopped", MCPServer{Status: StatusQuarantined}, StatusStopped, nil},
+		{"not quarantined", MCPServer{Status: StatusRunning}, "", ErrNotQuarantined},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated *MCPServer
+			repo := &mockRepo{
+				GetServerFn: func(_ context.Context, _ uuid.UUID) (*MCPServer, error) {
+					s := tt.server
+					return &s, nil
+				},
+				UpdateServerFn: func(_ context.Context, s *MCPServer) error {
+					updated = s
+					return nil
+				},
+			}
+			err := NewService(repo, nil, nil, nil, nil, nil, nil).ReleaseServer(context.Background(), uuid.New())
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("expected %v, got %v", tt.wantError, err)
+			}
+			if tt.wantError == nil && updated.Status != tt.want {
+				t.Errorf("expected status %s, got %s", tt.want, updated.Status)
+			}
+		})
+	}
+}
+
+func TestStartServerStdio(t *testing.T) {
+	serverID := uuid.New()
+	var updated *MCPServer
+	repo := &mockRepo{
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id, Image: "mcp/filesystem", Config: ServerSpec{Transport: TransportStdio}}, nil
+		},
+		UpdateServerFn: func(_ context.Context, s *MCPServer) error {
+			updated = s
+			return nil
+		},
+	}
+
+	cm := newMockContainerMgr()
+	var cfg *ContainerConfig
+	cm.CreateFn = func(_ context.Context, c *ContainerConfig) (string, error) {
+		cfg = c
+		return "container-abc", nil
+	}
+	cm.EndpointFn = func(_ context.Context, _, _ string) (string, error) {
+		t.Error("Endpoint should not be resolved for stdio servers")
+		return "", nil
+	}
+	attached := ""
+	cm.AttachFn = func(_ context.Context, id string) (io.ReadWriteCloser, error) {
+		attached = id
+		return newStdioEchoServer(), nil
+	}
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
+
+	if err := svc.StartServer(context.Background(), serverID); err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+	if !cfg.Stdin || len(cfg.Ports) != 0 {
+		t.Errorf("expected stdin open and no ports, got %+v", cfg)
+	}
+
+	conn, err := svc.OpenBackend(context.Background(), updated)
+	if err != nil {
+		t.Fatalf("OpenBackend failed: %v", err)
+	}
+	defer conn.Close()
+	if attached != "container-abc" {
+		t.Errorf("expected attach to container-abc, got %q", attached)
+	}
+	if _, ok := conn.(*stdioConn); !ok {
+		t.Errorf("expected a stdio backend, got %T", conn)
+	}
+}
+
+func TestRemoveOrphans(t *testing.T) {
+	repo := &mockRepo{
+		SearchServersFn: func(_ context.Context, _ string) ([]MCPServer, error) {
+			return []MCPServer{{ID: uuid.New(), ContainerID: "tracked"}}, nil
+		},
+	}
+	cm := newMockContainerMgr()
+	old := time.Now().Add(-time.Hour)
+	cm.ListFn = func(_ context.Context) ([]ManagedContainer, error) {
+		return []ManagedContainer{
+			{ID: "tracked", Created: old},
+			{ID: "orphan", Created: old},
+			{ID: "starting", Created: time.Now()},
+		}, nil
+	}
+	var removed []string
+	cm.RemoveFn = func(_ context.Context, id string) error {
+		removed = append(removed, id)
+		return nil
+	}
+	svc := NewService(repo, cm, nil, nil, nil, nil, nil)
+
+	got, err := svc.RemoveOrphans(context.Background())
+	if err != nil {
+		t.Fatalf("RemoveOrphans failed: %v", err)
+	}
+	if len(got) != 1 || got[0] != "orphan" || len(removed) != 1 || removed[0] != "orphan" {
+		t.Errorf("expected only the old untracked container to be removed, got %v", got)
+	}
+}
@@ -3,6 +3,7 @@ package nodes
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -20,7 +21,7 @@ func TestListServersDelegatesToRepo(t *testing.T) {
 			return expected, nil
 		},
 	}
-	svc := NewService(repo, nil)
+	svc := NewService(repo, nil, nil, nil, nil, nil, nil)
 
 	servers, err := svc.ListServers(context.Background(), ownerID)
 	if err != nil {
@@ -46,7 +47,7 @@ func TestGetServerDelegatesToRepo(t *testing.T) {
 			return expected, nil
 		},
 	}
-	svc := NewService(repo, nil)
+	svc := NewService(repo, nil, nil, nil, nil, nil, nil)
 
 	server, err := svc.GetServer(context.Background(), serverID)
 	if err != nil {
@@ -59,13 +60,18 @@ func TestGetServerDelegatesToRepo(t *testing.T) {
 
 func TestRegisterServerSetsFieldsAndDelegates(t *testing.T) {
 	var created *MCPServer
+	var revision *ServerRevision
 	repo := &mockRepo{
 		CreateServerFn: func(_ context.Context, s *MCPServer) error {
 			created = s
 			return nil
 		},
+		CreateServerRevisionFn: func(_ context.Context, rev *ServerRevision) error {
+			revision = rev
+			return nil
+		},
 	}
-	svc := NewService(repo, nil)
+	svc := NewService(repo, nil, nil, nil, nil, nil, nil)
 
 	server := &MCPServer{
 		Name:  "new-server",
@@ -91,31 +97,81 @@ func TestRegisterServerSetsFieldsAndDelegates(t *testing.T) {
 	if created.UpdatedAt.IsZero() {
 		t.Error("expected UpdatedAt to be set")
 	}
+	if created.Revision != 1 || revision == nil || revision.ServerID != created.ID || revision.Image != "mcp-image:latest" {
+		t.Errorf("expected revision 1 to be recorded, got %d and %+v", created.Revision, revision)
+	}
 }
 
-func TestRemoveServerDelegatesToRepo(t *testing.T) {
-	serverID := uuid.New()
-	var calledWith uuid.UUID
-
+func TestRegisterServerRejectsLimitsAboveTier(t *testing.T) {
 	repo := &mockRepo{
-		DeleteServerFn: func(_ context.Context, id uuid.UUID) error {
-			calledWith = id
+		CreateServerFn: func(_ context.Context, _ *MCPServer) error {
+			t.Error("CreateServer should not be called")
 			return nil
 		},
 	}
-	svc := NewService(repo, nil)
+	tiers := &LimitTiers{Tiers: map[string]ResourceLimits{"default": {CPUs: 1}}, DefaultTier: "default"}
+	svc := NewService(repo, nil, nil, nil, tiers, nil, nil)
 
-	err := svc.RemoveServer(context.Background(), serverID)
-	if err != nil {
+	err := svc.RegisterServer(context.Background(), &MCPServer{
+		Name:   "greedy",
+		Image:  "mcp:latest",
+		Config: ServerSpec{Limits: ResourceLimits{CPUs: 8}},
+	})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+}
+
+func TestRemoveServerStopsContainerAndReleasesPort(t *testing.T) {
+	serverID := uuid.New()
+	server := &MCPServer{ID: serverID, Status: StatusRunning, ContainerID: "container-1"}
+	repo := portRepo(map[uuid.UUID]*MCPServer{serverID: server})
+	repo.GetServerFn = func(context.Context, uuid.UUID) (*MCPServer, error) {
+		copied := *server
+		return &copied, nil
+	}
+	repo.UpdateServerFn = func(_ context.Context, s *MCPServer) error {
+		*server = *s
+		return nil
+	}
+	ports, _ := NewPortAllocator(repo, 20000, 20010)
+	ports.Allocate(context.Background(), serverID)
+
+	var removed []string
+	cm := newMockContainerMgr()
+	cm.StopFn = func(context.Context, string) error {
+		if server.Status != StatusStopping {
+			t.Errorf("expected the server to be marked stopping before its container stops, got %s", server.Status)
+		}
+		return nil
+	}
+	cm.RemoveFn = func(_ context.Context, id string) error {
+		removed = append(removed, id)
+		return nil
+	}
+	var deleted uuid.UUID
+	repo.DeleteServerFn = func(_ context.Context, id uuid.UUID) error {
+		deleted = id
+		return nil
+	}
+	svc := NewService(repo, cm, ports, nil, nil, nil, nil)
+
+	if err := svc.RemoveServer(context.Background(), serverID); err != nil {
 		t.Fatalf("RemoveServer failed: %v", err)
 	}
-	if calledWith != serverID {
-		t.Errorf("expected DeleteServer called with %s, got %s", serverID, calledWith)
+	if len(removed) != 1 || removed[0] != "container-1" {
+		t.Errorf("expected the server's container to be removed, got %v", removed)
+	}
+	if allocs, _ := repo.ListPortAllocations(context.Background()); len(allocs) != 0 {
+		t.Errorf("expected the server's port to be released, got %+v", allocs)
+	}
+	if deleted != serverID {
+		t.Errorf("expected DeleteServer called with %s, got %s", serverID, deleted)
 	}
 }
 
 func TestStartServerReturnsContainerNotAvailable(t *testing.T) {
-	svc := NewService(&mockRepo{}, nil)
+	svc := NewService(&mockRepo{}, nil, nil, nil, nil, nil, nil)
 
 	err := svc.StartServer(context.Background(), uuid.New())
 	if !errors.Is(err, ErrContainerNotAvailable) {
@@ -124,7 +180,7 @@ func TestStartServerReturnsContainerNotAvailable(t *testing.T) {
 }
 
 func TestStopServerReturnsContainerNotAvailable(t *testing.T) {
-	svc := NewService(&mockRepo{}, nil)
+	svc := NewService(&mockRepo{}, nil, nil, nil, nil, nil, nil)
 
 	err := svc.StopServer(context.Background(), uuid.New())
 	if !errors.Is(err, ErrContainerNotAvailable) {
@@ -139,10 +195,69 @@ func TestConnectWebSocketReturnsContainerNotAvailableWhenStopped(t *testing.T) {
 			return &MCPServer{ID: id, Status: StatusStopped}, nil
 		},
 	}
-	svc := NewService(repo, nil)
+	svc := NewService(repo, nil, nil, nil, nil, nil, nil)
 
 	err := svc.ConnectWebSocket(context.Background(), serverID, nil, nil)
 	if !errors.Is(err, ErrContainerNotAvailable) {
 		t.Errorf("expected ErrContainerNotAvailable, got %v", err)
 	}
 }
+
+func TestEffectivePolicyFallsBackToDefault(t *testing.T) {
+	serverID, userID := uuid.New(), uuid.New()
+	defaultPolicy := &AccessPolicy{ServerID: serverID, Tools: []string{"search"}}
+
+	repo := &mockRepo{
+		GetAccessPolicyFn: func(_ context.Context, _ uuid.UUID, principalID *uuid.UUID) (*AccessPolicy, error) {
+			if principalID == nil {
+				return defaultPolicy, nil
+			}
+			return nil, ErrNotFound
+		},
+	}
+	svc := NewService(repo, nil, nil, nil, nil, nil, nil)
+
+	policy, err := svc.EffectivePolicy(context.Background(), serverID, userID)
+	if err != nil {
+		t.Fatalf("EffectivePolicy failed: %v", err)
+	}
+	if policy != defaultPolicy {
+		t.Errorf("expected default policy, got %+v", policy)
+	}
+
+	repo.GetAccessPolicyFn = func(_ context.Context, _ uuid.UUID, _ *uuid.UUID) (*AccessPolicy, error) {
+		return nil, ErrNotFound
+	}
+	policy, err = svc.EffectivePolicy(context.Background(), serverID, userID)
+	if err != nil || policy != nil {
+		t.Errorf("expected no policy, got %+v, %v", policy, err)
+	}
+}
+
+func TestSyncCapabilitiesOnlyWritesCapabilities(t *testing.T) {
+	backend := newRPCBackend(t, nil)
+	server := &MCPServer{ID: uuid.New(), Status: StatusRunning, Endpoint: strings.TrimPrefix(backend.URL, "http://"), Tools: []any{"search"}}
+	var tools []any
+	repo := &mockRepo{
+		GetServerFn: func(context.Context, uuid.UUID) (*MCPServer, error) {
+			copied := *server
+			return &copied, nil
+		},
+		UpdateServerFn: func(context.Context, *MCPServer) error {
+			t.Error("expected the server record not to be rewritten")
+			return nil
+		},
+		UpdateServerCapabilitiesFn: func(_ context.Context, _ uuid.UUID, listed, _ []any) error {
+			tools = listed
+			return nil
+		},
+	}
+	svc := NewService(repo, nil, nil, nil, nil, nil, nil).(*service)
+
+	if err := svc.SyncCapabilities(context.Background(), server.ID); err != nil {
+		t.Fatalf("SyncCapabilities failed: %v", err)
+	}
+	if len(tools) != 1 || tools[0] != "search" {
+		t.Errorf("expected the known tools to be kept when the server lists none, got %v", tools)
+	}
+}
@@ -0,0 +1,89 @@
+package nodes
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// liveSession is a client session that can be terminated by the gateway.
+type liveSession interface {
+	close(code int, reason string)
+}
+
+// suspendable is a session that can outlive its server's container, such as
+// a WebSocket session to a server that scales to zero.
+type suspendable interface {
+	suspend() bool
+}
+
+// SessionRegistry tracks live client sessions per server, over any client
+// transport, so they can be torn down when a server is quarantined or
+// stopped for being idle.
+type SessionRegistry struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]map[liveSession]struct{}
+}
+
+// NewSessionRegistry creates an empty session registry.
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{sessions: make(map[uuid.UUID]map[liveSession]struct{})}
+}
+
+// add registers s as a session to server id and returns a function that
+// unregisters it.
+func (r *SessionRegistry) add(id uuid.UUID, s liveSession) func() {
+	r.mu.Lock()
+	if r.sessions[id] == nil {
+		r.sessions[id] = make(map[liveSession]struct{})
+	}
+	r.sessions[id][s] = struct{}{}
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.sessions[id], s)
+		if len(r.sessions[id]) == 0 {
+			delete(r.sessions, id)
+		}
+	}
+}
+
+// CloseServer closes every live session to serverID with the given WebSocket
+// close code and reason, and returns how many were closed.
+func (r *SessionRegistry) CloseServer(serverID uuid.UUID, code int, reason string) int {
+	r.mu.Lock()
+	sessions := make([]liveSession, 0, len(r.sessions[serverID]))
+	for s := range r.sessions[serverID] {
+		sessions = append(sessions, s)
+	}
+	r.mu.Unlock()
+
+	for _, s := range sessions {
+		s.close(code, reason)
+	}
+	return len(sessions)
+}
+
+// suspendServer detaches the live sessions to serverID that can resume on the
+// server's next container and closes the others with the given WebSocket
+// close code and reason. It returns how many of each there were.
+func (r *SessionRegistry) suspendServer(serverID uuid.UUID, code int, reason string) (suspended, closed int) {
+	r.mu.Lock()
+	sessions := make([]liveSession, 0, len(r.sessions[serverID]))
+	for s := range r.sessions[serverID] {
+		sessions = append(sessions, s)
+	}
+	r.mu.Unlock()
+
+	for _, s := range sessions {
+		if sp, ok := s.(suspendable); ok && sp.suspend() {
+			suspended++
+			continue
+		}
+		s.close(code, reason)
+		closed++
+	}
+	return suspended, closed
+}
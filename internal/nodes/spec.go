@@ -0,0 +1,364 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// SpecVersion is the version of the server spec schema. Specs written before
+// versioning have no version and are read as version 1.
+const SpecVersion = 1
+
+// ServerSpec is the typed, versioned configuration of a managed MCP server.
+// It is stored as the server's config.
+type ServerSpec struct {
+	Version int `json:"version"`
+	// Transport is the protocol the image's MCP endpoint speaks:
+	// TransportWebSocket (the default) or TransportStdio.
+	Transport string `json:"transport,omitempty"`
+	// Port is the container port a WebSocket server listens on, 8080 if unset.
+	Port int `json:"port,omitempty"`
+	// Pooled shares one long-lived backend session among all clients.
+	Pooled bool `json:"pooled,omitempty"`
+	// Env holds the container's plain environment variables.
+	Env map[string]string `json:"env,omitempty"`
+	// Secrets maps environment variables to vault credentials resolved when
+	// the container starts. A secret with a file is named by its key.
+	Secrets map[string]SecretRef `json:"secrets,omitempty"`
+	Limits  ResourceLimits       `json:"limits,omitzero"`
+	// Healthcheck tunes the supervisor's checks of this server.
+	Healthcheck Healthcheck `json:"healthcheck,omitzero"`
+	// Restart is the supervisor's restart policy, RestartOnFailure if unset.
+	Restart RestartPolicy `json:"restart,omitempty"`
+	// IdleTimeout, if set, scales the server to zero: it is stopped once no
+	// traffic has been proxied to it for this long and started again when a
+	// client connects.
+	IdleTimeout Duration `json:"idle_timeout,omitempty"`
+	// Security overrides fields of DefaultSecurityProfile.
+	Security *SecurityProfile `json:"security,omitempty"`
+	// Egress is the server's network policy behind the egress proxy.
+	Egress EgressPolicy `json:"egress,omitzero"`
+}
+
+// SecretRef names a credential in the server owner's vault, or the OAuth
+// grant bound to the server.
+type SecretRef struct {
+	// Vault is the provider the credential is stored under.
+	Vault string `json:"vault,omitempty"`
+	// Field is the part of the credential to use: access_token or secret.
+	Field string `json:"field,omitempty"`
+	// OAuth is the provider of an OAuth grant bound to the server, whose
+	// access token is the secret. It excludes Vault and Field.
+	OAuth string `json:"oauth,omitempty"`
+	// File, if set, is the absolute path in the container of a read-only file
+	// holding the secret, which is then not set as an environment variable.
+	File string `json:"file,omitempty"`
+}
+
+// Healthcheck tunes the supervisor's MCP ping of a server. Zero values take
+// the supervisor's defaults.
+type Healthcheck struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// Interval is rounded up to a multiple of the supervisor's interval.
+	Interval Duration `json:"interval,omitempty"`
+	Timeout  Duration `json:"timeout,omitempty"`
+	// Retries is how many consecutive failed checks make the server unhealthy.
+	Retries int `json:"retries,omitempty"`
+}
+
+// EgressPolicy lists the domains a server may reach through the egress proxy.
+// An entry "*.example.com" matches subdomains of example.com but not
+// example.com itself. An entry allows port 443 for HTTPS and port 80 for
+// plain HTTP unless it names a port, as in "api.example.com:8443". Names that
+// resolve to loopback, link-local or private addresses are refused.
+type EgressPolicy struct {
+	Allow []string `json:"allow,omitempty"`
+}
+
+// ByteSize is a size in bytes. In JSON it is a number of bytes or a string
+// such as "512m".
+type ByteSize int64
+
+// invalidValue is what a ByteSize or Duration that is not valid JSON for its
+// type decodes to, so that Validate can report it with the field's name;
+// errors returned by UnmarshalJSON methods carry no field.
+const invalidValue = -1
+
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	*b = invalidValue
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch s := v.(type) {
+	case float64:
+		if s >= 0 && s == math.Trunc(s) {
+			*b = ByteSize(s)
+		}
+	case string:
+		if n, err := units.RAMInBytes(s); err == nil && n >= 0 {
+			*b = ByteSize(n)
+		}
+	}
+	return nil
+}
+
+func (b ByteSize) String() string { return units.BytesSize(float64(b)) }
+
+// Duration is a time.Duration written in JSON as a string such as "30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	*d = invalidValue
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if v, err := time.ParseDuration(s); err == nil && v >= 0 {
+			*d = Duration(v)
+		}
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON overlays the fields present in data on
+// DefaultSecurityProfile, so a spec only lists what it changes.
+func (p *SecurityProfile) UnmarshalJSON(data []byte) error {
+	type plain SecurityProfile
+	profile := plain(DefaultSecurityProfile())
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&profile); err != nil {
+		return err
+	}
+	*p = SecurityProfile(profile)
+	return nil
+}
+
+// FieldError describes an invalid field of a server registration.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// SpecError lists the invalid fields of a server registration.
+type SpecError struct {
+	Fields []FieldError
+}
+
+func (e *SpecError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "invalid server spec: " + strings.Join(msgs, "; ")
+}
+
+// ParseSpec decodes and validates a server spec. Unknown fields are errors.
+// An empty or null spec is the default spec. The error is a *SpecError.
+func ParseSpec(data []byte) (ServerSpec, error) {
+	var spec ServerSpec
+	if len(bytes.TrimSpace(data)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&spec); err != nil {
+			return spec, &SpecError{Fields: []FieldError{decodeFieldError(err)}}
+		}
+	}
+	if spec.Version == 0 {
+		spec.Version = SpecVersion
+	}
+	if fields := spec.Validate(); len(fields) > 0 {
+		return spec, &SpecError{Fields: fields}
+	}
+	return spec, nil
+}
+
+// decodeFieldError turns a JSON decoding error into a field error.
+func decodeFieldError(err error) FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := "config"
+		if typeErr.Field != "" {
+			field += "." + typeErr.Field
+		}
+		return FieldError{Field: field, Message: "must be " + describeType(typeErr.Type)}
+	}
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return FieldError{Field: "config", Message: "unknown field " + name}
+	}
+	return FieldError{Field: "config", Message: "must be a JSON object"}
+}
+
+// describeType names what a value of t looks like in JSON.
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int64:
+		return "an integer"
+	case reflect.Float64:
+		return "a number"
+	case reflect.Slice:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+var (
+	// envName matches valid environment variable names.
+	envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// egressDomain matches allowlist entries: a domain name of at least two
+	// labels, optionally with a leading "*." wildcard and a trailing port.
+	egressDomain = regexp.MustCompile(`^(\*\.)?([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?\.)+[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(:[0-9]{1,5})?$`)
+)
+
+// Validate checks the spec's values and returns the invalid fields.
+func (s *ServerSpec) Validate() []FieldError {
+	var errs []FieldError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, FieldError{Field: "config." + field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Version < 0 || s.Version > SpecVersion {
+		add("version", "unsupported version %d; this gateway supports version %d", s.Version, SpecVersion)
+	}
+	switch s.Transport {
+	case "", TransportWebSocket, TransportStdio:
+	default:
+		add("transport", "must be websocket or stdio")
+	}
+	if s.Port < 0 || s.Port > math.MaxUint16 {
+		add("port", "must be between 1 and 65535")
+	}
+
+	for _, name := range sortedKeys(s.Env) {
+		if !envName.MatchString(name) {
+			add("env."+name, "is not a valid environment variable name")
+		}
+	}
+	files := make(map[string]string)
+	for _, name := range sortedKeys(s.Secrets) {
+		ref := s.Secrets[name]
+		switch {
+		case !envName.MatchString(name):
+			add("secrets."+name, "is not a valid environment variable name")
+		case ref.File == "" && s.Env[name] != "":
+			add("secrets."+name, "is also set in env")
+		case ref.OAuth != "" && (ref.Vault != "" || ref.Field != ""):
+			add("secrets."+name, "must reference either a vault credential or an oauth grant")
+		case ref.OAuth == "" && ref.Vault == "":
+			add("secrets."+name+".vault", "is required")
+		case ref.OAuth == "" && ref.Field != "access_token" && ref.Field != "secret":
+			add("secrets."+name+".field", "must be access_token or secret")
+		case ref.File == "":
+		case !path.IsAbs(ref.File) || path.Clean(ref.File) != ref.File || ref.File == "/":
+			add("secrets."+name+".file", "must be an absolute path to a file")
+		case files[ref.File] != "":
+			add("secrets."+name+".file", "is also the file of secret %s", files[ref.File])
+		default:
+			files[ref.File] = name
+		}
+	}
+
+	if s.Limits.Memory < 0 {
+		add("limits.memory", "must be a size in bytes or such as 512m")
+	}
+	if s.Limits.Disk < 0 {
+		add("limits.disk", "must be a size in bytes or such as 512m")
+	}
+	if s.Limits.CPUs < 0 {
+		add("limits.cpus", "must not be negative")
+	}
+	if s.Limits.Pids < 0 {
+		add("limits.pids", "must not be negative")
+	}
+	if s.Healthcheck.Interval < 0 {
+		add("healthcheck.interval", "must be a duration such as 30s")
+	}
+	if s.Healthcheck.Timeout < 0 {
+		add("healthcheck.timeout", "must be a duration such as 30s")
+	}
+	if s.Healthcheck.Retries < 0 {
+		add("healthcheck.retries", "must not be negative")
+	}
+
+	switch s.Restart {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		add("restart", "must be never, on-failure or always")
+	}
+	if s.IdleTimeout < 0 {
+		add("idle_timeout", "must be a duration such as 30s")
+	}
+
+	if sec := s.Security; sec != nil {
+		for _, p := range sec.Tmpfs {
+			if !path.IsAbs(p) {
+				add("security.tmpfs", "path %q is not absolute", p)
+			}
+		}
+		if sec.PidsLimit < 0 {
+			add("security.pids_limit", "must not be negative")
+		}
+		for i, c := range sec.CapAdd {
+			if !addableCaps[capName(c)] {
+				add("security.cap_add."+strconv.Itoa(i), "capability %q may not be added", c)
+			}
+		}
+		if sec.Seccomp != "" && sec.Seccomp != "unconfined" && !seccompProfileName.MatchString(sec.Seccomp) {
+			add("security.seccomp", "profile %q is not a plain name", sec.Seccomp)
+		}
+		if sec.NoNetwork && s.Transport != TransportStdio {
+			add("security.no_network", "requires the stdio transport")
+		}
+	}
+
+	for i, d := range s.Egress.Allow {
+		field := "egress.allow." + strconv.Itoa(i)
+		if !egressDomain.MatchString(d) {
+			add(field, "%q is not a domain name", d)
+			continue
+		}
+		host, port := d, ""
+		if h, p, err := net.SplitHostPort(d); err == nil {
+			host, port = h, p
+		}
+		switch {
+		case net.ParseIP(host) != nil:
+			add(field, "%q is an IP address, not a domain name", d)
+		case port != "":
+			if n, _ := strconv.Atoi(port); n < 1 || n > 65535 {
+				add(field, "port %s is out of range", port)
+			}
+		}
+	}
+	return errs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,110 @@
+package nodes
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec([]byte(`{
+		"transport": "websocket",
+		"port": 9000,
+		"pooled": true,
+		"env": {"LOG_LEVEL": "debug"},
+		"secrets": {"GITHUB_TOKEN": {"vault": "github", "field": "access_token"}},
+		"limits": {"memory": "512m", "cpus": 1.5, "pids": 128, "disk": 1073741824},
+		"healthcheck": {"interval": "1m", "timeout": "2s", "retries": 5},
+		"restart": "always",
+		"idle_timeout": "15m",
+		"egress": {"allow": ["api.github.com", "*.example.com", "git.example.com:8443"]}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseSpec failed: %v", err)
+	}
+	want := ServerSpec{
+		Version:     SpecVersion,
+		Transport:   TransportWebSocket,
+		Port:        9000,
+		Pooled:      true,
+		Env:         map[string]string{"LOG_LEVEL": "debug"},
+		Secrets:     map[string]SecretRef{"GITHUB_TOKEN": {Vault: "github", Field: "access_token"}},
+		Limits:      ResourceLimits{Memory: 512 << 20, CPUs: 1.5, Pids: 128, Disk: 1 << 30},
+		Healthcheck: Healthcheck{Interval: Duration(time.Minute), Timeout: Duration(2 * time.Second), Retries: 5},
+		Restart:     RestartAlways,
+		IdleTimeout: Duration(15 * time.Minute),
+		Egress:      EgressPolicy{Allow: []string{"api.github.com", "*.example.com", "git.example.com:8443"}},
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("expected %+v, got %+v", want, spec)
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("marshaling spec: %v", err)
+	}
+	if again, err := ParseSpec(data); err != nil || !reflect.DeepEqual(again, want) {
+		t.Errorf("expected the spec to round-trip, got %+v %v from %s", again, err, data)
+	}
+}
+
+func TestParseSpecDefaults(t *testing.T) {
+	for _, data := range []string{"", "null", "{}"} {
+		spec, err := ParseSpec([]byte(data))
+		if err != nil {
+			t.Fatalf("ParseSpec(%q) failed: %v", data, err)
+		}
+		if !reflect.DeepEqual(spec, ServerSpec{Version: SpecVersion}) {
+			t.Errorf("ParseSpec(%q): expected the default spec, got %+v", data, spec)
+		}
+	}
+}
+
+func TestParseSpecFieldErrors(t *testing.T) {
+	tests := []struct {
+		config string
+		want   FieldError
+	}{
+		{`{"version": 2}`, FieldError{"config.version", "unsupported version 2; this gateway supports version 1"}},
+		{`{"transport": "grpc"}`, FieldError{"config.transport", "must be websocket or stdio"}},
+		{`{"port": "9000"}`, FieldError{"config.port", "must be an integer"}},
+		{`{"port": 70000}`, FieldError{"config.port", "must be between 1 and 65535"}},
+		{`{"pooled": "yes"}`, FieldError{"config.pooled", "must be a boolean"}},
+		{`{"env": {"API_KEY": 42}}`, FieldError{"config.env.API_KEY", "must be a string"}},
+		{`{"env": {"1X": "y"}}`, FieldError{"config.env.1X", "is not a valid environment variable name"}},
+		{`{"secrets": {"TOKEN": {"vault": "github", "field": "password"}}}`, FieldError{"config.secrets.TOKEN.field", "must be access_token or secret"}},
+		{`{"env": {"TOKEN": "x"}, "secrets": {"TOKEN": {"vault": "github", "field": "secret"}}}`, FieldError{"config.secrets.TOKEN", "is also set in env"}},
+		{`{"secrets": {"TOKEN": {"oauth": "github", "vault": "github"}}}`, FieldError{"config.secrets.TOKEN", "must reference either a vault credential or an oauth grant"}},
+		{`{"secrets": {"KEY": {"vault": "signer", "field": "secret", "file": "run/key"}}}`, FieldError{"config.secrets.KEY.file", "must be an absolute path to a file"}},
+		{`{"secrets": {"A": {"vault": "x", "field": "secret", "file": "/key"}, "B": {"vault": "y", "field": "secret", "file": "/key"}}}`, FieldError{"config.secrets.B.file", "is also the file of secret A"}},
+		{`{"limits": {"memory": "lots"}}`, FieldError{"config.limits.memory", "must be a size in bytes or such as 512m"}},
+		{`{"limits": {"disk": 1.5}}`, FieldError{"config.limits.disk", "must be a size in bytes or such as 512m"}},
+		{`{"limits": {"cpus": -1}}`, FieldError{"config.limits.cpus", "must not be negative"}},
+		{`{"limits": {"gpus": 1}}`, FieldError{"config", `unknown field "gpus"`}},
+		{`{"healthcheck": {"interval": 30}}`, FieldError{"config.healthcheck.interval", "must be a duration such as 30s"}},
+		{`{"restart": "sometimes"}`, FieldError{"config.restart", "must be never, on-failure or always"}},
+		{`{"idle_timeout": "soon"}`, FieldError{"config.idle_timeout", "must be a duration such as 30s"}},
+		{`{"security": {"no_network": true}}`, FieldError{"config.security.no_network", "requires the stdio transport"}},
+		{`{"egress": {"allow": "api.github.com"}}`, FieldError{"config.egress.allow", "must be an array"}},
+		{`{"egress": {"allow": ["https://api.github.com"]}}`, FieldError{"config.egress.allow.0", `"https://api.github.com" is not a domain name`}},
+		{`{"egress": {"allow": ["169.254.169.254"]}}`, FieldError{"config.egress.allow.0", `"169.254.169.254" is an IP address, not a domain name`}},
+		{`{"egress": {"allow": ["localhost"]}}`, FieldError{"config.egress.allow.0", `"localhost" is not a domain name`}},
+		{`{"egress": {"allow": ["*.com"]}}`, FieldError{"config.egress.allow.0", `"*.com" is not a domain name`}},
+		{`{"egress": {"allow": ["api.github.com:99999"]}}`, FieldError{"config.egress.allow.0", "port 99999 is out of range"}},
+		{`{"ws_port": 9000}`, FieldError{"config", `unknown field "ws_port"`}},
+		{`[]`, FieldError{"config", "must be an object"}},
+	}
+	for _, tt := range tests {
+		_, err := ParseSpec([]byte(tt.config))
+		var specErr *SpecError
+		if !errors.As(err, &specErr) {
+			t.Errorf("%s: expected a *SpecError, got %v", tt.config, err)
+			continue
+		}
+		if len(specErr.Fields) != 1 || specErr.Fields[0] != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.config, tt.want, specErr.Fields)
+		}
+	}
+}
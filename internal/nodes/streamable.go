@@ -0,0 +1,443 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/kapella-hub/NexusClaw/internal/platform/respond"
+)
+
+// Streamable HTTP transport headers.
+const (
+	mcpSessionHeader  = "Mcp-Session-Id"
+	lastEventIDHeader = "Last-Event-ID"
+)
+
+const (
+	// maxSessionEvents bounds each session's replay buffer for resumption.
+	maxSessionEvents = 512
+	// httpSessionIdle is how long a session may go unused before it is closed.
+	httpSessionIdle = 30 * time.Minute
+	// maxMCPBody bounds a single POSTed JSON-RPC message or batch.
+	maxMCPBody = 4 << 20
+)
+
+// HTTPSessions tracks the sessions of the Streamable HTTP MCP endpoint.
+type HTTPSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// NewHTTPSessions creates an empty session table.
+func NewHTTPSessions() *HTTPSessions {
+	return &HTTPSessions{sessions: make(map[string]*httpSession)}
+}
+
+// add stores s and closes any sessions that have been idle too long.
+func (m *HTTPSessions) add(s *httpSession) {
+	now := time.Now()
+
+	m.mu.Lock()
+	var expired []*httpSession
+	for _, other := range m.sessions {
+		if other.idle(now) > httpSessionIdle {
+			expired = append(expired, other)
+		}
+	}
+	m.sessions[s.id] = s
+	m.mu.Unlock()
+
+	for _, other := range expired {
+		other.close(websocket.CloseGoingAway, "session expired")
+	}
+}
+
+// get returns the session with id if it belongs to userID on serverID.
+func (m *HTTPSessions) get(id string, serverID, userID uuid.UUID) *httpSession {
+	m.mu.Lock()
+	s := m.sessions[id]
+	m.mu.Unlock()
+
+	if s == nil || s.proxy.server.ID != serverID || s.proxy.userID != userID {
+		return nil
+	}
+	s.touch()
+	return s
+}
+
+func (m *HTTPSessions) remove(id string) {
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+}
+
+// sseEvent is a backend message queued for one of a session's SSE streams.
+type sseEvent struct {
+	seq    uint64
+	stream uint64
+	data   []byte
+}
+
+// id is the SSE event ID. It names the stream as well as the position so a
+// client resuming with Last-Event-ID picks up the stream it lost.
+func (e sseEvent) id() string {
+	return fmt.Sprintf("%d-%d", e.stream, e.seq)
+}
+
+func parseEventID(id string) (stream, seq uint64, ok bool) {
+	s, q, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	stream, err1 := strconv.ParseUint(s, 10, 64)
+	seq, err2 := strconv.ParseUint(q, 10, 64)
+	return stream, seq, err1 == nil && err2 == nil
+}
+
+// sseStream is one logical SSE stream of a session. Stream 0 is the standalone
+// stream opened by GET; every other stream answers one POST and is complete
+// once each request in it has a response.
+type sseStream struct {
+	pending map[string]bool
+	// sent is the seq of the last event handed to a client connection.
+	sent uint64
+	// owner identifies the connection writing the stream; a reconnect takes
+	// the stream over from a stale connection.
+	owner int
+}
+
+// httpSession is a Streamable HTTP client session bound to one backend
+// connection. Client messages go through the same proxy core as the WebSocket
+// endpoint. Backend responses are routed to the stream of the POST carrying
+// their request; notifications and server-initiated requests go to the
+// standalone stream.
+type httpSession struct {
+	id      string
+	proxy   *proxySession
+	backend BackendConn
+	onClose func()
+
+	mu         sync.Mutex
+	events     []sseEvent
+	seq        uint64
+	streams    map[uint64]*sseStream
+	nextStream uint64
+	changed    chan struct{}
+	closed     bool
+	attached   int
+	lastUsed   time.Time
+}
+
+func newHTTPSession(proxy *proxySession, backend BackendConn) *httpSession {
+	return &httpSession{
+		id:       uuid.NewString(),
+		proxy:    proxy,
+		backend:  backend,
+		streams:  map[uint64]*sseStream{0: {}},
+		changed:  make(chan struct{}),
+		lastUsed: time.Now(),
+	}
+}
+
+// readBackend routes backend messages until the backend disconnects, then
+// closes the session.
+func (s *httpSession) readBackend() {
+	for {
+		_, msg, err := s.backend.ReadMessage()
+		if err != nil {
+			break
+		}
+		s.proxy.touch()
+		if s.proxy.policy != nil {
+			msg = s.proxy.filterResponse(msg)
+		}
+		s.route(msg)
+	}
+	s.close(websocket.CloseGoingAway, "backend disconnected")
+}
+
+// route queues a message on the stream awaiting its response, or on the
+// standalone stream.
+func (s *httpSession) route(raw []byte) {
+	var key string
+	var msg rpcMessage
+	if err := json.Unmarshal(raw, &msg); err == nil && !msg.isRequest() && len(msg.ID) > 0 {
+		key = rpcID(msg.ID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream := uint64(0)
+	if key != "" {
+		for id, st := range s.streams {
+			if st.pending[key] {
+				delete(st.pending, key)
+				stream = id
+				break
+			}
+		}
+	}
+
+	s.seq++
+	s.events = append(s.events, sseEvent{seq: s.seq, stream: stream, data: raw})
+	if len(s.events) > maxSessionEvents {
+		s.events = s.events[len(s.events)-maxSessionEvents:]
+	}
+	s.notifyLocked()
+}
+
+func (s *httpSession) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// send inspects and forwards the messages of one POST. It returns the stream
+// that will carry the responses, and false if the POST held no requests.
+func (s *httpSession) send(ctx context.Context, msgs []json.RawMessage) (uint64, bool) {
+	var ids []string
+	for _, raw := range msgs {
+		var msg rpcMessage
+		if err := json.Unmarshal(raw, &msg); err == nil && msg.isRequest() && !msg.isNotification() {
+			ids = append(ids, rpcID(msg.ID))
+		}
+	}
+
+	var stream uint64
+	if len(ids) > 0 {
+		st := &sseStream{pending: make(map[string]bool, len(ids))}
+		for _, id := range ids {
+			st.pending[id] = true
+		}
+		s.mu.Lock()
+		s.nextStream++
+		stream = s.nextStream
+		s.streams[stream] = st
+		s.mu.Unlock()
+	}
+
+	for _, raw := range msgs {
+		forward, reply := s.proxy.inspect(ctx, raw)
+		if reply != nil {
+			s.route(reply)
+		}
+		if !forward {
+			continue
+		}
+		if err := s.backend.WriteMessage(websocket.TextMessage, raw); err != nil {
+			var msg rpcMessage
+			if json.Unmarshal(raw, &msg) == nil && msg.isRequest() && !msg.isNotification() {
+				s.route(rpcErrorResponse(msg.ID, &RPCError{Code: codeInternalError, Message: "backend unavailable"}))
+			}
+		}
+	}
+	return stream, len(ids) > 0
+}
+
+// follow writes the events of stream after seq to w as SSE. It returns when a
+// POST stream is complete, the session closes, another connection takes the
+// stream over, or ctx is done.
+func (s *httpSession) follow(ctx context.Context, w http.ResponseWriter, stream, after uint64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respond.Error(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	s.mu.Lock()
+	st := s.streams[stream]
+	if st == nil {
+		// A completed stream: replay whatever is still buffered.
+		st = &sseStream{}
+	}
+	st.owner++
+	owner := st.owner
+	s.attached++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.attached--
+		s.lastUsed = time.Now()
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		s.mu.Lock()
+		if st.owner != owner {
+			s.mu.Unlock()
+			return
+		}
+		var batch []sseEvent
+		for _, e := range s.events {
+			if e.stream == stream && e.seq > after {
+				batch = append(batch, e)
+			}
+		}
+		if len(batch) > 0 {
+			st.sent = batch[len(batch)-1].seq
+		}
+		finished := s.closed || (stream != 0 && len(st.pending) == 0)
+		if finished && stream != 0 {
+			delete(s.streams, stream)
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		for _, e := range batch {
+			writeSSE(w, e)
+			after = e.seq
+		}
+		flusher.Flush()
+		if finished {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+// collect waits for every response on stream and writes them as a plain JSON
+// body: a single object, or an array when the POST was a batch.
+func (s *httpSession) collect(ctx context.Context, w http.ResponseWriter, stream uint64, batch bool) {
+	for {
+		s.mu.Lock()
+		st := s.streams[stream]
+		finished := s.closed || st == nil || len(st.pending) == 0
+		var out []json.RawMessage
+		if finished {
+			for _, e := range s.events {
+				if e.stream == stream {
+					out = append(out, e.data)
+				}
+			}
+			delete(s.streams, stream)
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if finished {
+			switch {
+			case len(out) == 0:
+				respond.Error(w, http.StatusBadGateway, "backend disconnected")
+			case !batch && len(out) == 1:
+				respond.JSON(w, http.StatusOK, out[0])
+			default:
+				respond.JSON(w, http.StatusOK, out)
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+// sentOn returns the seq of the last event handed out on stream.
+func (s *httpSession) sentOn(stream uint64) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st := s.streams[stream]; st != nil {
+		return st.sent
+	}
+	return 0
+}
+
+func (s *httpSession) touch() {
+	s.mu.Lock()
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+}
+
+// idle returns how long the session has gone unused. Sessions with an open
+// stream are never idle.
+func (s *httpSession) idle(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attached > 0 {
+		return 0
+	}
+	return now.Sub(s.lastUsed)
+}
+
+// close ends the session, its backend connection and every open stream.
+func (s *httpSession) close(_ int, _ string) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	s.backend.Close()
+	if s.onClose != nil {
+		s.onClose()
+	}
+}
+
+func writeSSE(w http.ResponseWriter, e sseEvent) {
+	fmt.Fprintf(w, "id: %s\nevent: message\n", e.id())
+	for _, line := range bytes.Split(e.data, []byte("\n")) {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// splitBatch splits a POST body into its JSON-RPC messages and reports whether
+// it was a batch.
+func splitBatch(body []byte) ([]json.RawMessage, bool, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var msgs []json.RawMessage
+		if err := json.Unmarshal(body, &msgs); err != nil {
+			return nil, true, err
+		}
+		if len(msgs) == 0 {
+			return nil, true, errors.New("empty batch")
+		}
+		return msgs, true, nil
+	}
+	if !json.Valid(body) {
+		return nil, false, errors.New("invalid JSON")
+	}
+	return []json.RawMessage{body}, false, nil
+}
+
+func hasInitialize(msgs []json.RawMessage) bool {
+	for _, raw := range msgs {
+		var msg rpcMessage
+		if err := json.Unmarshal(raw, &msg); err == nil && msg.Method == "initialize" {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
@@ -0,0 +1,266 @@
+package nodes
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/kapella-hub/NexusClaw/internal/platform/crypto"
+)
+
+// newStdioResponder starts a stdio MCP server that answers each request with
+// its method name. A "notify" request also emits a notification first.
+func newStdioResponder() *stdioServer {
+	return newStdioServer(func(line []byte) [][]byte {
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil || !msg.isRequest() || msg.isNotification() {
+			return nil
+		}
+		var out [][]byte
+		if msg.Method == "notify" {
+			out = append(out, []byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{"data":"hello"}}`))
+		}
+		result, _ := json.Marshal(map[string]string{"method": msg.Method})
+		resp, _ := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		return append(out, resp)
+	})
+}
+
+type mcpTestClient struct {
+	t        *testing.T
+	url      string
+	token    string
+	session  string
+	sessions *SessionRegistry
+}
+
+func newMCPTestClient(t *testing.T, configure func(*mockService)) *mcpTestClient {
+	t.Helper()
+	svc := &mockService{
+		ConnectWebSocketFn: func(_ context.Context, _ uuid.UUID, _ http.ResponseWriter, _ *http.Request) error {
+			return nil
+		},
+		GetServerFn: func(_ context.Context, id uuid.UUID) (*MCPServer, error) {
+			return &MCPServer{ID: id}, nil
+		},
+		EffectivePolicyFn: func(_ context.Context, _, _ uuid.UUID) (*AccessPolicy, error) {
+			return nil, nil
+		},
+		OpenBackendFn: func(_ context.Context, _ *MCPServer) (BackendConn, error) {
+			return newStdioConn(newStdioResponder()), nil
+		},
+	}
+	if configure != nil {
+		configure(svc)
+	}
+	h := newTestHandler(svc)
+	h.Sessions = NewSessionRegistry()
+	h.HTTPSessions = NewHTTPSessions()
+	ts := httptest.NewServer(h.Routes())
+	t.Cleanup(ts.Close)
+
+	token, _ := crypto.IssueToken(uuid.New().String(), time.Hour, handlerTestSecret)
+	return &mcpTestClient{
+		t:        t,
+		url:      ts.URL + "/" + uuid.New().String() + "/mcp",
+		token:    token,
+		sessions: h.Sessions,
+	}
+}
+
+func (c *mcpTestClient) do(method, accept, body string, header map[string]string) *http.Response {
+	c.t.Helper()
+	req, _ := http.NewRequest(method, c.url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if c.session != "" {
+		req.Header.Set(mcpSessionHeader, c.session)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, c.url, err)
+	}
+	c.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func (c *mcpTestClient) initialize() {
+	c.t.Helper()
+	resp := c.do(http.MethodPost, "application/json", `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`, nil)
+	if resp.StatusCode != http.StatusOK {
+		c.t.Fatalf("initialize: expected 200, got %d", resp.StatusCode)
+	}
+	if c.session = resp.Header.Get(mcpSessionHeader); c.session == "" {
+		c.t.Fatal("initialize: expected an Mcp-Session-Id header")
+	}
+}
+
+// readSSE reads one SSE event, returning its ID and decoded data.
+func readSSE(t *testing.T, r *bufio.Reader) (string, rpcMessage) {
+	t.Helper()
+	var id, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" && data != "" {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "id: "); ok {
+			id = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data += v
+		}
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		t.Fatalf("decoding event data %q: %v", data, err)
+	}
+	return id, msg
+}
+
+func TestMCPHTTPSessionLifecycle(t *testing.T) {
+	c := newMCPTestClient(t, nil)
+
+	resp := c.do(http.MethodPost, "application/json", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without a session, got %d", resp.StatusCode)
+	}
+
+	c.initialize()
+
+	resp = c.do(http.MethodPost, "application/json", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, nil)
+	var msg rpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if string(msg.ID) != "1" || !strings.Contains(string(msg.Result), "tools/list") {
+		t.Errorf("expected tools/list result, got %+v", msg)
+	}
+
+	resp = c.do(http.MethodPost, "application/json, text/event-stream", `[{"jsonrpc":"2.0","id":2,"method":"ping"},{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"x"}}]`, nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+	events := bufio.NewReader(resp.Body)
+	seen := map[string]bool{}
+	for range 2 {
+		_, msg := readSSE(t, events)
+		seen[string(msg.ID)] = true
+	}
+	if !seen["2"] || !seen["3"] {
+		t.Errorf("expected responses to both batched requests, got %v", seen)
+	}
+	if _, err := io.ReadAll(events); err != nil {
+		t.Errorf("expected the stream to end once answered: %v", err)
+	}
+
+	resp = c.do(http.MethodPost, "application/json", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expected 202 for a notification, got %d", resp.StatusCode)
+	}
+
+	resp = c.do(http.MethodDelete, "", "", nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 on delete, got %d", resp.StatusCode)
+	}
+	resp = c.do(http.MethodPost, "application/json", `{"jsonrpc":"2.0","id":4,"method":"ping"}`, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+}
+
+func TestMCPHTTPResumesStandaloneStream(t *testing.T) {
+	c := newMCPTestClient(t, nil)
+	c.initialize()
+
+	resp := c.do(http.MethodPost, "application/json", `{"jsonrpc":"2.0","id":1,"method":"notify"}`, nil)
+	resp.Body.Close()
+
+	resp = c.do(http.MethodGet, "text/event-stream", "", nil)
+	id, msg := readSSE(t, bufio.NewReader(resp.Body))
+	if msg.Method != "notifications/message" {
+		t.Fatalf("expected notification on the standalone stream, got %+v", msg)
+	}
+	resp.Body.Close()
+
+	resp = c.do(http.MethodPost, "application/json", `{"jsonrpc":"2.0","id":2,"method":"notify"}`, nil)
+	resp.Body.Close()
+
+	resp = c.do(http.MethodGet, "text/event-stream", "", map[string]string{lastEventIDHeader: id})
+	next, msg := readSSE(t, bufio.NewReader(resp.Body))
+	if next == id || msg.Method != "notifications/message" {
+		t.Errorf("expected the event after %s, got %s %+v", id, next, msg)
+	}
+
+	resp = c.do(http.MethodGet, "application/json", "", nil)
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("expected 406 without text/event-stream, got %d", resp.StatusCode)
+	}
+}
+
+func TestMCPHTTPAppliesAccessPolicy(t *testing.T) {
+	c := newMCPTestClient(t, func(svc *mockService) {
+		svc.EffectivePolicyFn = func(_ context.Context, _, _ uuid.UUID) (*AccessPolicy, error) {
+			return &AccessPolicy{Tools: []string{"read_*"}}, nil
+		}
+	})
+	c.initialize()
+
+	resp := c.do(http.MethodPost, "application/json", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"delete_file"}}`, nil)
+	var msg rpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if msg.Error == nil || msg.Error.Code != codeInvalidParams {
+		t.Errorf("expected hidden tool error, got %+v", msg)
+	}
+}
+
+func TestMCPHTTPSessionClosedOnQuarantine(t *testing.T) {
+	c := newMCPTestClient(t, nil)
+	c.initialize()
+
+	resp := c.do(http.MethodGet, "text/event-stream", "", nil)
+	serverID, _ := uuid.Parse(strings.Split(c.url, "/")[3])
+	if n := c.sessions.CloseServer(serverID, websocket.ClosePolicyViolation, "server quarantined"); n != 1 {
+		t.Fatalf("expected 1 session closed, got %d", n)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("expected the stream to end: %v", err)
+	}
+
+	resp = c.do(http.MethodPost, "application/json", `{"jsonrpc":"2.0","id":1,"method":"ping"}`, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a closed session, got %d", resp.StatusCode)
+	}
+}
+
+func TestMCPHTTPNotImplemented(t *testing.T) {
+	h := newTestHandler(&mockService{})
+	req := authenticatedRequest(http.MethodPost, "/"+uuid.New().String()+"/mcp", strings.NewReader(`{}`), uuid.New().String())
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
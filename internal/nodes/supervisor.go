@@ -0,0 +1,429 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// RestartPolicy controls whether the supervisor restarts a server whose
+// container exits or fails its health checks. It is set in the server's spec
+// and defaults to RestartOnFailure.
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+)
+
+// restartPolicy returns the server's configured restart policy.
+func restartPolicy(server *MCPServer) RestartPolicy {
+	if server.Config.Restart == "" {
+		return RestartOnFailure
+	}
+	return server.Config.Restart
+}
+
+// SupervisorOptions tunes the supervisor. Zero values take the defaults noted.
+type SupervisorOptions struct {
+	// HealthInterval is how often running servers are checked (30s).
+	HealthInterval time.Duration
+	// HealthTimeout bounds each MCP ping (5s).
+	HealthTimeout time.Duration
+	// Concurrency is how many servers are checked at once (8).
+	Concurrency int
+	// MaxFailures is how many consecutive failed pings mark a server
+	// unhealthy (3).
+	MaxFailures int
+	// BackoffMin and BackoffMax bound the delay before a restart, which
+	// doubles with each restart and resets once a server has stayed up for
+	// BackoffMax (1s and 5m).
+	BackoffMin time.Duration
+	BackoffMax time.Duration
+}
+
+func (o *SupervisorOptions) setDefaults() {
+	if o.HealthInterval <= 0 {
+		o.HealthInterval = 30 * time.Second
+	}
+	if o.HealthTimeout <= 0 {
+		o.HealthTimeout = 5 * time.Second
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 8
+	}
+	if o.MaxFailures <= 0 {
+		o.MaxFailures = 3
+	}
+	if o.BackoffMin <= 0 {
+		o.BackoffMin = time.Second
+	}
+	if o.BackoffMax < o.BackoffMin {
+		o.BackoffMax = 5 * time.Minute
+	}
+}
+
+// superviseState is the supervisor's bookkeeping for one server.
+type superviseState struct {
+	failures    int
+	backoff     time.Duration
+	lastRestart time.Time
+	lastCheck   time.Time
+	pending     bool
+}
+
+// Supervisor watches running servers through Docker exit events and periodic
+// MCP pings. It keeps each server's status in line with its container and
+// restarts failed servers with exponential backoff according to their restart
+// policy.
+type Supervisor struct {
+	repo      Repository
+	container ContainerManager
+	svc       Service
+	opts      SupervisorOptions
+
+	mu    sync.Mutex
+	state map[uuid.UUID]*superviseState
+}
+
+// NewSupervisor creates a supervisor. svc is used to restart servers and to
+// open backend connections for health pings.
+func NewSupervisor(repo Repository, container ContainerManager, svc Service, opts SupervisorOptions) *Supervisor {
+	opts.setDefaults()
+	return &Supervisor{
+		repo:      repo,
+		container: container,
+		svc:       svc,
+		opts:      opts,
+		state:     make(map[uuid.UUID]*superviseState),
+	}
+}
+
+// Run supervises until ctx is done.
+func (s *Supervisor) Run(ctx context.Context) {
+	go s.watchEvents(ctx)
+
+	ticker := time.NewTicker(s.opts.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkAll(ctx)
+		}
+	}
+}
+
+// watchEvents handles container exits, resubscribing if the stream breaks.
+func (s *Supervisor) watchEvents(ctx context.Context) {
+	for {
+		events, errs := s.container.Events(ctx)
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-events:
+				if !ok {
+					break stream
+				}
+				s.handleExit(ctx, ev)
+			case err := <-errs:
+				if ctx.Err() == nil {
+					slog.Warn("container event stream failed", "error", err)
+				}
+				break stream
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.opts.BackoffMin):
+		}
+	}
+}
+
+// handleExit records the exit of a running server's container.
+func (s *Supervisor) handleExit(ctx context.Context, ev ContainerEvent) {
+	servers, err := s.repo.SearchServers(ctx, "")
+	if err != nil {
+		slog.Error("supervisor listing servers", "error", err)
+		return
+	}
+	for i := range servers {
+		server := &servers[i]
+		if server.ContainerID == ev.ContainerID && server.Status == StatusRunning {
+			code := ev.ExitCode
+			s.fail(ctx, server, &code, "container exited")
+			return
+		}
+	}
+}
+
+// checkAll checks every running server, several at a time so that servers
+// that do not answer cannot delay the checks of the others.
+func (s *Supervisor) checkAll(ctx context.Context) {
+	servers, err := s.repo.SearchServers(ctx, "")
+	if err != nil {
+		slog.Error("supervisor listing servers", "error", err)
+		return
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.opts.Concurrency)
+	for i := range servers {
+		server := &servers[i]
+		if server.Status != StatusRunning || server.ContainerID == "" {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.check(ctx, server)
+		}()
+	}
+	wg.Wait()
+}
+
+// check verifies the server's container is up and answers an MCP ping, as
+// tuned by the server's healthcheck spec.
+func (s *Supervisor) check(ctx context.Context, server *MCPServer) {
+	status, err := s.container.Status(ctx, server.ContainerID)
+	switch {
+	case cerrdefs.IsNotFound(err):
+		s.fail(ctx, server, nil, "container missing")
+		return
+	case err != nil:
+		slog.Warn("supervisor inspecting container", "server_id", server.ID, "error", err)
+		return
+	case status != StatusRunning && status != StatusStarting:
+		// The exit event was missed, e.g. while the event stream reconnected.
+		s.fail(ctx, server, nil, "container not running")
+		return
+	}
+
+	hc := server.Config.Healthcheck
+	if hc.Disabled {
+		return
+	}
+	s.mu.Lock()
+	st := s.stateFor(server.ID)
+	due := time.Since(st.lastCheck) >= time.Duration(hc.Interval)
+	if due {
+		st.lastCheck = time.Now()
+	}
+	s.mu.Unlock()
+	if !due {
+		return
+	}
+
+	timeout := s.opts.HealthTimeout
+	if hc.Timeout > 0 {
+		timeout = time.Duration(hc.Timeout)
+	}
+	maxFailures := s.opts.MaxFailures
+	if hc.Retries > 0 {
+		maxFailures = hc.Retries
+	}
+	// The timeout also bounds opening the backend connection.
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	err = pingServer(pingCtx, s.svc, server, timeout)
+	cancel()
+
+	s.mu.Lock()
+	st = s.stateFor(server.ID)
+	if err == nil {
+		st.failures = 0
+		s.mu.Unlock()
+		return
+	}
+	st.failures++
+	unhealthy := st.failures >= maxFailures
+	if unhealthy {
+		st.failures = 0
+	}
+	s.mu.Unlock()
+
+	slog.Warn("server health check failed", "server_id", server.ID, "error", err)
+	if unhealthy {
+		s.fail(ctx, server, nil, "health check failed")
+	}
+}
+
+// pingServer sends an MCP ping to the server and waits up to timeout for the
+// response.
+func pingServer(ctx context.Context, svc Service, server *MCPServer, timeout time.Duration) error {
+	conn, err := svc.OpenBackend(ctx, server)
+	if errors.Is(err, ErrBackendBusy) {
+		// A client holds the stdio attachment, so the server is in use.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks a pending read.
+	timer := time.AfterFunc(timeout, func() { conn.Close() })
+	defer timer.Stop()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":"nexusclaw-health","method":"ping"}`)); err != nil {
+		return err
+	}
+	for i := 0; i < 5; i++ {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(raw, &msg); err == nil && !msg.isRequest() && rpcID(msg.ID) == `"nexusclaw-health"` {
+			return nil
+		}
+	}
+	return errors.New("no ping response")
+}
+
+// fail marks the server failed and schedules a restart if its policy allows.
+// A nil exitCode means the container did not exit on its own. seen is the
+// server as listed when the failure was noticed; nothing is written if it has
+// since been stopped, restarted or quarantined.
+func (s *Supervisor) fail(ctx context.Context, seen *MCPServer, exitCode *int, reason string) {
+	failed := exitCode == nil || *exitCode != 0
+
+	server, err := s.repo.GetServer(ctx, seen.ID)
+	if err != nil {
+		slog.Error("supervisor reading server", "server_id", seen.ID, "error", err)
+		return
+	}
+	if server.Status != StatusRunning || server.ContainerID != seen.ContainerID {
+		return
+	}
+
+	server.Status = StatusError
+	if !failed {
+		server.Status = StatusStopped
+	}
+	if exitCode != nil {
+		server.LastExitCode = exitCode
+	}
+	server.UpdatedAt = time.Now()
+	if err := s.repo.UpdateServer(ctx, server); err != nil {
+		slog.Error("supervisor updating server", "server_id", server.ID, "error", err)
+		return
+	}
+
+	policy := restartPolicy(server)
+	slog.Warn("server failed", "server_id", server.ID, "reason", reason, "exit_code", exitCode, "restart", policy)
+	if policy == RestartAlways || (policy == RestartOnFailure && failed) {
+		s.scheduleRestart(ctx, server.ID, server.ContainerID)
+	}
+}
+
+// scheduleRestart restarts the server after its current backoff.
+func (s *Supervisor) scheduleRestart(ctx context.Context, serverID uuid.UUID, containerID string) {
+	s.mu.Lock()
+	st := s.stateFor(serverID)
+	if st.pending {
+		s.mu.Unlock()
+		return
+	}
+	if time.Since(st.lastRestart) > s.opts.BackoffMax {
+		st.backoff = 0
+	}
+	delay := st.backoff
+	if delay == 0 {
+		delay = s.opts.BackoffMin
+	}
+	st.backoff = min(delay*2, s.opts.BackoffMax)
+	st.pending = true
+	s.mu.Unlock()
+
+	time.AfterFunc(delay, func() { s.restart(ctx, serverID, containerID) })
+}
+
+// restart replaces the failed container, unless the server was stopped,
+// restarted or quarantined in the meantime.
+func (s *Supervisor) restart(ctx context.Context, serverID uuid.UUID, containerID string) {
+	s.mu.Lock()
+	st := s.stateFor(serverID)
+	st.pending = false
+	st.lastRestart = time.Now()
+	s.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return
+	}
+	server, err := s.repo.GetServer(ctx, serverID)
+	if err != nil || !awaitsRestart(server, containerID) {
+		return
+	}
+
+	if err := s.container.Remove(ctx, containerID); err != nil && !cerrdefs.IsNotFound(err) {
+		slog.Warn("removing failed container", "server_id", serverID, "error", err)
+	}
+	// The server may have been started or deleted while the container was
+	// removed.
+	server, err = s.repo.GetServer(ctx, serverID)
+	if err != nil || !awaitsRestart(server, containerID) {
+		return
+	}
+	server.RestartCount++
+	server.UpdatedAt = time.Now()
+	if err := s.repo.UpdateServer(ctx, server); err != nil {
+		slog.Error("supervisor updating server", "server_id", serverID, "error", err)
+		return
+	}
+
+	if err := s.svc.StartServer(ctx, serverID); err != nil {
+		slog.Error("restarting server", "server_id", serverID, "attempt", server.RestartCount, "error", err)
+		s.scheduleRestart(ctx, serverID, containerID)
+		return
+	}
+	slog.Info("server restarted", "server_id", serverID, "restarts", server.RestartCount)
+}
+
+// awaitsRestart reports whether the server is still down with the failed
+// container.
+func awaitsRestart(server *MCPServer, containerID string) bool {
+	return server.ContainerID == containerID && (server.Status == StatusError || server.Status == StatusStopped)
+}
+
+func (s *Supervisor) stateFor(id uuid.UUID) *superviseState {
+	st := s.state[id]
+	if st == nil {
+		st = &superviseState{}
+		s.state[id] = st
+	}
+	return st
+}
+
+// waitReady pings the server until it answers or timeout passes.
+func waitReady(ctx context.Context, svc Service, server *MCPServer, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := pingServer(ctx, svc, server, time.Until(deadline))
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("waiting for server to become ready: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// supervisedServer is an in-memory server row shared by a mock repository.
+type supervisedServer struct {
+	mu     sync.Mutex
+	server MCPServer
+}
+
+func (s *supervisedServer) repo() *mockRepo {
+	return &mockRepo{
+		SearchServersFn: func(_ context.Context, _ string) ([]MCPServer, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return []MCPServer{s.server}, nil
+		},
+		GetServerFn: func(_ context.Context, _ uuid.UUID) (*MCPServer, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			server := s.server
+			return &server, nil
+		},
+		UpdateServerFn: func(_ context.Context, server *MCPServer) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.server = *server
+			return nil
+		},
+	}
+}
+
+func (s *supervisedServer) get() MCPServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.server
+}
+
+func newSupervisedServer(restart RestartPolicy) *supervisedServer {
+	return &supervisedServer{server: MCPServer{
+		ID:          uuid.New(),
+		Status:      StatusRunning,
+		ContainerID: "container-1",
+		Config:      ServerSpec{Restart: restart},
+	}}
+}
+
+var fastSupervisor = SupervisorOptions{
+	HealthInterval: time.Hour,
+	BackoffMin:     time.Millisecond,
+	BackoffMax:     10 * time.Millisecond,
+	MaxFailures:    2,
+}
+
+func TestSupervisorRestartsCrashedServer(t *testing.T) {
+	row := newSupervisedServer("")
+	events := make(chan ContainerEvent, 1)
+	cm := newMockContainerMgr()
+	var removed string
+	cm.RemoveFn = func(_ context.Context, id string) error {
+		removed = id
+		return nil
+	}
+	cm.EventsFn = func(_ context.Context) (<-chan ContainerEvent, <-chan error) {
+		return events, nil
+	}
+	started := make(chan uuid.UUID, 1)
+	svc := &mockService{StartServerFn: func(_ context.Context, id uuid.UUID) error {
+		started <- id
+		return nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewSupervisor(row.repo(), cm, svc, fastSupervisor).Run(ctx)
+
+	events <- ContainerEvent{ContainerID: "container-1", ExitCode: 137}
+	select {
+	case id := <-started:
+		if id != row.get().ID {
+			t.Errorf("restarted wrong server %s", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the crashed server to be restarted")
+	}
+
+	got := row.get()
+	if got.RestartCount != 1 {
+		t.Errorf("expected restart count 1, got %d", got.RestartCount)
+	}
+	if got.LastExitCode == nil || *got.LastExitCode != 137 {
+		t.Errorf("expected last exit code 137, got %v", got.LastExitCode)
+	}
+	if removed != "container-1" {
+		t.Errorf("expected the failed container to be removed, got %q", removed)
+	}
+}
+
+func TestSupervisorIgnoresStoppingServer(t *testing.T) {
+	row := newSupervisedServer("always")
+	row.server.Status = StatusStopping
+	svc := &mockService{StartServerFn: func(_ context.Context, _ uuid.UUID) error {
+		t.Error("expected no restart of a server being stopped")
+		return nil
+	}}
+	sup := NewSupervisor(row.repo(), newMockContainerMgr(), svc, fastSupervisor)
+
+	sup.handleExit(context.Background(), ContainerEvent{ContainerID: "container-1", ExitCode: 143})
+	time.Sleep(20 * time.Millisecond)
+
+	if got := row.get(); got.Status != StatusStopping || got.LastExitCode != nil {
+		t.Errorf("expected the server to be left alone, got %+v", got)
+	}
+}
+
+func TestSupervisorRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy   RestartPolicy
+		exitCode int
+		status   ServerStatus
+		restart  bool
+	}{
+		{"never", 1, StatusError, false},
+		{"on-failure", 0, StatusStopped, false},
+		{"on-failure", 1, StatusError, true},
+		{"always", 0, StatusStopped, true},
+	}
+	for _, tt := range tests {
+		row := newSupervisedServer(tt.policy)
+		started := make(chan struct{}, 1)
+		svc := &mockService{StartServerFn: func(_ context.Context, _ uuid.UUID) error {
+			started <- struct{}{}
+			return nil
+		}}
+		sup := NewSupervisor(row.repo(), newMockContainerMgr(), svc, fastSupervisor)
+
+		sup.handleExit(context.Background(), ContainerEvent{ContainerID: "container-1", ExitCode: tt.exitCode})
+		if got := row.get().Status; got != tt.status && !tt.restart {
+			t.Errorf("%s/%d: expected status %s, got %s", tt.policy, tt.exitCode, tt.status, got)
+		}
+
+		var restarted bool
+		select {
+		case <-started:
+			restarted = true
+		case <-time.After(50 * time.Millisecond):
+		}
+		if restarted != tt.restart {
+			t.Errorf("%s/%d: expected restart=%v, got %v", tt.policy, tt.exitCode, tt.restart, restarted)
+		}
+	}
+}
+
+func TestSupervisorHealthCheckFailures(t *testing.T) {
+	row := newSupervisedServer("")
+	started := make(chan struct{}, 1)
+	svc := &mockService{
+		OpenBackendFn: func(_ context.Context, _ *MCPServer) (BackendConn, error) {
+			return nil, errors.New("connection refused")
+		},
+		StartServerFn: func(_ context.Context, _ uuid.UUID) error {
+			started <- struct{}{}
+			return nil
+		},
+	}
+	sup := NewSupervisor(row.repo(), newMockContainerMgr(), svc, fastSupervisor)
+
+	sup.checkAll(context.Background())
+	if got := row.get().Status; got != StatusRunning {
+		t.Fatalf("expected one failed ping to be tolerated, got %s", got)
+	}
+	sup.checkAll(context.Background())
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected an unhealthy server to be restarted")
+	}
+}
+
+func TestSupervisorPingsHealthyServer(t *testing.T) {
+	row := newSupervisedServer("")
+	svc := &mockService{
+		OpenBackendFn: func(_ context.Context, _ *MCPServer) (BackendConn, error) {
+			return newStdioConn(newStdioResponder()), nil
+		},
+	}
+	sup := NewSupervisor(row.repo(), newMockContainerMgr(), svc, fastSupervisor)
+
+	for range 3 {
+		sup.checkAll(context.Background())
+	}
+	if got := row.get().Status; got != StatusRunning {
+		t.Errorf("expected a healthy server to stay running, got %s", got)
+	}
+}
+
+func TestSupervisorHealthcheckSpec(t *testing.T) {
+	row := newSupervisedServer("")
+	pings := 0
+	svc := &mockService{
+		OpenBackendFn: func(_ context.Context, _ *MCPServer) (BackendConn, error) {
+			pings++
+			return nil, errors.New("connection refused")
+		},
+		StartServerFn: func(_ context.Context, _ uuid.UUID) error { return nil },
+	}
+	sup := NewSupervisor(row.repo(), newMockContainerMgr(), svc, fastSupervisor)
+
+	row.server.Config.Healthcheck = Healthcheck{Disabled: true}
+	sup.checkAll(context.Background())
+	if pings != 0 {
+		t.Fatalf("expected a disabled healthcheck not to ping, got %d pings", pings)
+	}
+
+	row.server.Config.Healthcheck = Healthcheck{Interval: Duration(time.Hour), Retries: 5}
+	sup.checkAll(context.Background())
+	sup.checkAll(context.Background())
+	if pings != 1 {
+		t.Fatalf("expected one ping within the interval, got %d", pings)
+	}
+
+	row.server.Config.Healthcheck = Healthcheck{Retries: 1}
+	sup.checkAll(context.Background())
+	if got := row.get().Status; got != StatusError {
+		t.Errorf("expected one failed ping to mark the server failed, got %s", got)
+	}
+}
+
+func TestSupervisorFailLeavesRestartedServerAlone(t *testing.T) {
+	row := newSupervisedServer("always")
+	seen := row.get()
+	// The server moved to a new container after it was listed.
+	row.server.ContainerID = "container-2"
+	svc := &mockService{StartServerFn: func(_ context.Context, _ uuid.UUID) error {
+		t.Error("expected no restart of a server that moved on")
+		return nil
+	}}
+	sup := NewSupervisor(row.repo(), newMockContainerMgr(), svc, fastSupervisor)
+
+	sup.fail(context.Background(), &seen, nil, "health check failed")
+	time.Sleep(20 * time.Millisecond)
+
+	if got := row.get(); got.Status != StatusRunning || got.ContainerID != "container-2" {
+		t.Errorf("expected the restarted server to be left alone, got %s %q", got.Status, got.ContainerID)
+	}
+}
+
+func TestSupervisorChecksServersConcurrently(t *testing.T) {
+	hung, healthy := uuid.New(), uuid.New()
+	servers := []MCPServer{
+		{ID: hung, Status: StatusRunning, ContainerID: "container-1", Config: ServerSpec{Healthcheck: Healthcheck{Retries: 5}}},
+		{ID: healthy, Status: StatusRunning, ContainerID: "container-2"},
+	}
+	repo := &mockRepo{SearchServersFn: func(context.Context, string) ([]MCPServer, error) {
+		return servers, nil
+	}}
+	pinged := make(chan struct{})
+	svc := &mockService{
+		OpenBackendFn: func(ctx context.Context, server *MCPServer) (BackendConn, error) {
+			if server.ID == hung {
+				// Hangs until the ping times out.
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			close(pinged)
+			return newStdioConn(newStdioResponder()), nil
+		},
+	}
+	opts := fastSupervisor
+	opts.HealthTimeout = time.Second
+	sup := NewSupervisor(repo, newMockContainerMgr(), svc, opts)
+
+	done := make(chan struct{})
+	go func() {
+		sup.checkAll(context.Background())
+		close(done)
+	}()
+	select {
+	case <-pinged:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("expected the healthy server to be pinged while the other hangs")
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the hung ping to time out")
+	}
+}
@@ -32,6 +32,9 @@ type RedisConfig struct {
 type AuthConfig struct {
 	TokenSecret string        `mapstructure:"token_secret"`
 	TokenExpiry time.Duration `mapstructure:"token_expiry"`
+	// Admins are the user IDs allowed gateway-wide operations, such as
+	// removing orphaned containers.
+	Admins []string `mapstructure:"admins"`
 }
 
 // LogConfig holds logging settings.
@@ -42,22 +45,154 @@ type LogConfig struct {
 
 // OAuthProviderConfig holds settings for a single OAuth provider.
 type OAuthProviderConfig struct {
-	ClientID     string   `mapstructure:"client_id"`
-	ClientSecret string   `mapstructure:"client_secret"`
-	AuthURL      string   `mapstructure:"auth_url"`
-	TokenURL     string   `mapstructure:"token_url"`
-	RedirectURL  string   `mapstructure:"redirect_url"`
-	Scopes       []string `mapstructure:"scopes"`
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	AuthURL      string `mapstructure:"auth_url"`
+	TokenURL     string `mapstructure:"token_url"`
+	// RevocationURL is the provider's RFC 7009 token revocation endpoint,
+	// called when a grant is deleted. Optional.
+	RevocationURL string   `mapstructure:"revocation_url"`
+	RedirectURL   string   `mapstructure:"redirect_url"`
+	Scopes        []string `mapstructure:"scopes"`
+}
+
+// OAuthRefreshConfig holds settings for the background renewal of OAuth
+// grants before they expire.
+type OAuthRefreshConfig struct {
+	Interval time.Duration `mapstructure:"interval"`
+	Window   time.Duration `mapstructure:"window"`
+}
+
+// SentryConfig holds firewall settings.
+type SentryConfig struct {
+	WebhookURL      string           `mapstructure:"webhook_url"`
+	ApprovalTimeout time.Duration    `mapstructure:"approval_timeout"`
+	Approvers       []string         `mapstructure:"approvers"`
+	Anomaly         AnomalyConfig    `mapstructure:"anomaly"`
+	Quarantine      QuarantineConfig `mapstructure:"quarantine"`
+}
+
+// AnomalyConfig holds behavioural anomaly detection settings.
+type AnomalyConfig struct {
+	Enabled    bool    `mapstructure:"enabled"`
+	ZThreshold float64 `mapstructure:"z_threshold"`
+	MinSamples int     `mapstructure:"min_samples"`
+	Alpha      float64 `mapstructure:"alpha"`
+}
+
+// QuarantineConfig holds the automatic server quarantine policy.
+type QuarantineConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Threshold     int           `mapstructure:"threshold"`
+	Window        time.Duration `mapstructure:"window"`
+	MinSeverity   string        `mapstructure:"min_severity"`
+	StopContainer bool          `mapstructure:"stop_container"`
+}
+
+// DockerConfig holds settings for MCP server containers.
+type DockerConfig struct {
+	// Network, if set, is a Docker network the gateway attaches MCP server
+	// containers to and reaches them on by IP. Use it when the gateway itself
+	// runs in a container on the same network.
+	Network string `mapstructure:"network"`
+	// Host is the address used to reach host-published container ports when
+	// no network is set.
+	Host string `mapstructure:"host"`
+	// PortMin and PortMax bound the host ports allocated to containers when
+	// no network is set.
+	PortMin int `mapstructure:"port_min"`
+	PortMax int `mapstructure:"port_max"`
+	// InstanceID names this gateway's containers and labels them, so that
+	// gateways sharing a Docker host leave each other's containers alone.
+	InstanceID string `mapstructure:"instance_id"`
+	// SeccompDir holds seccomp profiles servers may select by name in their
+	// security profile, as <name>.json.
+	SeccompDir string `mapstructure:"seccomp_dir"`
+	// SecretsDir is where the files of file secrets are written and
+	// bind-mounted from. It should be on a tmpfs, and when the gateway runs
+	// in a container, be mounted at the same path as on the Docker host.
+	SecretsDir string `mapstructure:"secrets_dir"`
+	// AllowUnconfinedSeccomp and AllowRoot let server specs disable seccomp
+	// filtering and run as root. Both weaken the container's isolation from
+	// the host, so they are the operator's decision rather than the server
+	// owner's.
+	AllowUnconfinedSeccomp bool `mapstructure:"allow_unconfined_seccomp"`
+	AllowRoot              bool `mapstructure:"allow_root"`
+}
+
+// EgressConfig holds settings for the egress proxy, the only way out for MCP
+// server containers on an internal Docker network.
+type EgressConfig struct {
+	// Enabled creates docker.network as an internal network and routes
+	// container HTTP(S) traffic through the proxy. It requires docker.network.
+	Enabled bool `mapstructure:"enabled"`
+	// Listen is the address the proxy listens on.
+	Listen string `mapstructure:"listen"`
+	// ProxyURL is the proxy's address as seen from containers on the network.
+	ProxyURL string `mapstructure:"proxy_url"`
+}
+
+// LimitsConfig holds the resource maximums of MCP server containers per user
+// tier. Tiers are set in the config file, e.g.
+//
+//	limits:
+//	  tiers:
+//	    default: {memory: 1g, cpus: 1, pids: 256}
+//	    pro: {memory: 4g, cpus: 4, pids: 1024, disk: 10g}
+//	  users:
+//	    3f1c...: pro
+type LimitsConfig struct {
+	// DefaultTier applies to users not assigned a tier in Users.
+	DefaultTier string                `mapstructure:"default_tier"`
+	Tiers       map[string]TierConfig `mapstructure:"tiers"`
+	// Users maps user IDs to tier names.
+	Users map[string]string `mapstructure:"users"`
+}
+
+// TierConfig holds the resource maximums of a user tier. Sizes are given like
+// "512m"; zero or empty leaves a resource unlimited.
+type TierConfig struct {
+	Memory string  `mapstructure:"memory"`
+	CPUs   float64 `mapstructure:"cpus"`
+	Pids   int64   `mapstructure:"pids"`
+	Disk   string  `mapstructure:"disk"`
+}
+
+// SupervisorConfig holds settings for the MCP server supervisor, which
+// restarts crashed or unhealthy server containers.
+type SupervisorConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	HealthInterval time.Duration `mapstructure:"health_interval"`
+	HealthTimeout  time.Duration `mapstructure:"health_timeout"`
+	MaxFailures    int           `mapstructure:"max_failures"`
+	BackoffMin     time.Duration `mapstructure:"backoff_min"`
+	BackoffMax     time.Duration `mapstructure:"backoff_max"`
+}
+
+// ScaleConfig holds settings for scaling servers with an idle timeout to
+// zero.
+type ScaleConfig struct {
+	// Interval is how often running servers are checked for idleness.
+	Interval time.Duration `mapstructure:"interval"`
+	// ReadyTimeout bounds how long a woken server has to answer a ping.
+	ReadyTimeout time.Duration `mapstructure:"ready_timeout"`
 }
 
 // Config is the root configuration for the application.
 type Config struct {
-	Server   ServerConfig                   `mapstructure:"server"`
-	Database DatabaseConfig                 `mapstructure:"database"`
-	Redis    RedisConfig                    `mapstructure:"redis"`
-	Auth     AuthConfig                     `mapstructure:"auth"`
-	Log      LogConfig                      `mapstructure:"log"`
-	OAuth    map[string]OAuthProviderConfig `mapstructure:"oauth"`
+	Server       ServerConfig                   `mapstructure:"server"`
+	Database     DatabaseConfig                 `mapstructure:"database"`
+	Redis        RedisConfig                    `mapstructure:"redis"`
+	Auth         AuthConfig                     `mapstructure:"auth"`
+	Log          LogConfig                      `mapstructure:"log"`
+	OAuth        map[string]OAuthProviderConfig `mapstructure:"oauth"`
+	OAuthRefresh OAuthRefreshConfig             `mapstructure:"oauth_refresh"`
+	Sentry       SentryConfig                   `mapstructure:"sentry"`
+	Docker       DockerConfig                   `mapstructure:"docker"`
+	Egress       EgressConfig                   `mapstructure:"egress"`
+	Limits       LimitsConfig                   `mapstructure:"limits"`
+	Supervisor   SupervisorConfig               `mapstructure:"supervisor"`
+	Scale        ScaleConfig                    `mapstructure:"scale"`
 }
 
 // Load reads configuration from the file at path and environment variables.
@@ -74,8 +209,43 @@ func Load(path string) (*Config, error) {
 	v.SetDefault("redis.db", 0)
 	v.SetDefault("auth.token_secret", "nexusclaw-fallback-secret-development-only")
 	v.SetDefault("auth.token_expiry", 24*time.Hour)
+	v.SetDefault("auth.admins", []string{})
 	v.SetDefault("log.level", "info")
 	v.SetDefault("log.format", "json")
+	v.SetDefault("oauth_refresh.interval", time.Minute)
+	v.SetDefault("oauth_refresh.window", 5*time.Minute)
+	v.SetDefault("sentry.webhook_url", "")
+	v.SetDefault("sentry.approval_timeout", 5*time.Minute)
+	v.SetDefault("sentry.anomaly.enabled", true)
+	v.SetDefault("sentry.anomaly.z_threshold", 3.0)
+	v.SetDefault("sentry.anomaly.min_samples", 20)
+	v.SetDefault("sentry.anomaly.alpha", 0.1)
+	v.SetDefault("sentry.quarantine.enabled", true)
+	v.SetDefault("sentry.quarantine.threshold", 3)
+	v.SetDefault("sentry.quarantine.window", 10*time.Minute)
+	v.SetDefault("sentry.quarantine.min_severity", "high")
+	v.SetDefault("sentry.quarantine.stop_container", false)
+	v.SetDefault("docker.network", "")
+	v.SetDefault("docker.host", "localhost")
+	v.SetDefault("docker.port_min", 20000)
+	v.SetDefault("docker.port_max", 20999)
+	v.SetDefault("docker.instance_id", "default")
+	v.SetDefault("docker.seccomp_dir", "")
+	v.SetDefault("docker.secrets_dir", "")
+	v.SetDefault("docker.allow_unconfined_seccomp", false)
+	v.SetDefault("docker.allow_root", false)
+	v.SetDefault("egress.enabled", false)
+	v.SetDefault("egress.listen", ":3128")
+	v.SetDefault("egress.proxy_url", "http://nexusclaw:3128")
+	v.SetDefault("limits.default_tier", "default")
+	v.SetDefault("supervisor.enabled", true)
+	v.SetDefault("supervisor.health_interval", 30*time.Second)
+	v.SetDefault("supervisor.health_timeout", 5*time.Second)
+	v.SetDefault("supervisor.max_failures", 3)
+	v.SetDefault("supervisor.backoff_min", time.Second)
+	v.SetDefault("supervisor.backoff_max", 5*time.Minute)
+	v.SetDefault("scale.interval", 30*time.Second)
+	v.SetDefault("scale.ready_timeout", time.Minute)
 
 	if path != "" {
 		v.SetConfigFile(path)
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS sentry_approvals;
@@ -0,0 +1,13 @@
+CREATE TABLE sentry_alerts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    rule_id UUID REFERENCES sentry_rules(id) ON DELETE SET NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    server_id UUID,
+    kind VARCHAR(50) NOT NULL,
+    message TEXT NOT NULL,
+    severity VARCHAR(50) NOT NULL,
+    metadata JSONB DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_alerts_user ON sentry_alerts(user_id);
+CREATE INDEX idx_alerts_server ON sentry_alerts(server_id);
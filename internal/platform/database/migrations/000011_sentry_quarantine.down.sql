@@ -0,0 +1 @@
+ALTER TABLE sentry_rules DROP COLUMN severity;
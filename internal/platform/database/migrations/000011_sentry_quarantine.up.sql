@@ -0,0 +1 @@
+ALTER TABLE sentry_rules ADD COLUMN severity VARCHAR(20) NOT NULL DEFAULT 'medium';
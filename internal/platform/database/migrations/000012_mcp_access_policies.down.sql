@@ -0,0 +1 @@
+DROP TABLE IF EXISTS mcp_access_policies;
@@ -0,0 +1,12 @@
+CREATE TABLE mcp_access_policies (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    server_id UUID NOT NULL REFERENCES mcp_servers(id) ON DELETE CASCADE,
+    principal_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    tools TEXT[],
+    resources TEXT[],
+    prompts TEXT[],
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_access_policy_principal ON mcp_access_policies(server_id, principal_id) WHERE principal_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_access_policy_default ON mcp_access_policies(server_id) WHERE principal_id IS NULL;
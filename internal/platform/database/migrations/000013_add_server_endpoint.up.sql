@@ -0,0 +1 @@
+ALTER TABLE mcp_servers ADD COLUMN endpoint VARCHAR(255) DEFAULT '';
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS mcp_port_allocations;
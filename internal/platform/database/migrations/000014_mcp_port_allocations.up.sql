@@ -0,0 +1,5 @@
+CREATE TABLE mcp_port_allocations (
+    port INTEGER PRIMARY KEY,
+    server_id UUID NOT NULL UNIQUE REFERENCES mcp_servers(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
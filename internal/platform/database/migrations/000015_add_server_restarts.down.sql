@@ -0,0 +1,2 @@
+ALTER TABLE mcp_servers DROP COLUMN IF EXISTS last_exit_code;
+ALTER TABLE mcp_servers DROP COLUMN IF EXISTS restart_count;
@@ -0,0 +1,2 @@
+ALTER TABLE mcp_servers ADD COLUMN restart_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE mcp_servers ADD COLUMN last_exit_code INTEGER;
@@ -0,0 +1,7 @@
+-- Servers registered since the upgrade have no legacy config, nor has any
+-- server once 000021 has dropped the legacy configs; their specs are kept,
+-- with port written back as ws_port.
+UPDATE mcp_servers SET config = legacy_config WHERE legacy_config IS NOT NULL;
+UPDATE mcp_servers SET config = (config - 'port') || jsonb_build_object('ws_port', config->'port')
+WHERE legacy_config IS NULL AND config ? 'port';
+ALTER TABLE mcp_servers DROP COLUMN IF EXISTS legacy_config;
@@ -0,0 +1,34 @@
+-- Server configs become typed, versioned specs. The original free-form config
+-- is kept in legacy_config so the migration can be reversed. Configs that
+-- already have a version are specs and are left alone.
+ALTER TABLE mcp_servers ADD COLUMN IF NOT EXISTS legacy_config JSONB;
+
+-- Env vars were read from the "env" object and from top-level upper-case keys
+-- containing an underscore; they are now all under "env" as strings. ws_port
+-- is now port.
+UPDATE mcp_servers SET legacy_config = config, config = jsonb_strip_nulls(jsonb_build_object(
+    'version', 1,
+    'transport', CASE WHEN config->>'transport' IN ('websocket', 'stdio') THEN config->'transport' END,
+    'port', CASE WHEN config->>'ws_port' ~ '^[0-9]{1,5}$' THEN
+        CASE WHEN (config->>'ws_port')::int BETWEEN 1 AND 65535 THEN to_jsonb((config->>'ws_port')::int) END
+    END,
+    'pooled', CASE WHEN jsonb_typeof(config->'pooled') = 'boolean' THEN config->'pooled' END,
+    'env', (
+        SELECT jsonb_object_agg(key, value)
+        FROM (
+            SELECT key, CASE WHEN jsonb_typeof(value) = 'string' THEN value ELSE to_jsonb(value::text) END AS value
+            FROM jsonb_each(CASE WHEN jsonb_typeof(config->'env') = 'object' THEN config->'env' ELSE '{}' END)
+            WHERE key ~ '^[A-Za-z_][A-Za-z0-9_]*$'
+              AND NOT (config ? key AND jsonb_typeof(config->key) = 'string' AND key ~ '^[A-Z_][A-Z0-9_]*$' AND strpos(key, '_') > 0)
+            UNION ALL
+            SELECT key, value
+            FROM jsonb_each(config)
+            WHERE jsonb_typeof(value) = 'string' AND key ~ '^[A-Z_][A-Z0-9_]*$' AND strpos(key, '_') > 0
+        ) vars
+    ),
+    'restart', CASE WHEN config->>'restart' IN ('never', 'on-failure', 'always') THEN config->'restart' END,
+    'limits', CASE WHEN jsonb_typeof(config->'limits') = 'object' THEN config->'limits' END,
+    'security', CASE WHEN jsonb_typeof(config->'security') = 'object' THEN config->'security' END,
+    'egress', CASE WHEN jsonb_typeof(config->'egress') = 'object' THEN config->'egress' END
+))
+WHERE NOT config ? 'version';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_oauth_grants_server_provider;
+ALTER TABLE oauth_grants DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,9 @@
+-- Grants are bound to a server and the owner who authorized them, one per
+-- server and provider.
+ALTER TABLE oauth_grants ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES users(id) ON DELETE CASCADE;
+UPDATE oauth_grants g SET owner_id = s.owner_id FROM mcp_servers s WHERE g.server_id = s.id AND g.owner_id IS NULL;
+ALTER TABLE oauth_grants ALTER COLUMN owner_id SET NOT NULL;
+DELETE FROM oauth_grants g USING oauth_grants newer
+WHERE g.server_id = newer.server_id AND g.provider = newer.provider
+  AND (g.created_at, g.id) < (newer.created_at, newer.id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_grants_server_provider ON oauth_grants(server_id, provider);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_oauth_grants_refresh;
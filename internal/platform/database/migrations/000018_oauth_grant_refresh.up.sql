@@ -0,0 +1,2 @@
+-- The refresher scans for grants it can renew before they expire.
+CREATE INDEX IF NOT EXISTS idx_oauth_grants_refresh ON oauth_grants(expires_at) WHERE refresh_token_enc IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_oauth_grants_owner;
+DROP TABLE IF EXISTS oauth_states;
@@ -0,0 +1,13 @@
+-- Pending OAuth flows, each tied to the user who started it and holding the
+-- PKCE verifier for the code exchange.
+CREATE TABLE IF NOT EXISTS oauth_states (
+    state VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    server_id UUID NOT NULL REFERENCES mcp_servers(id) ON DELETE CASCADE,
+    provider VARCHAR(100) NOT NULL,
+    verifier TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_oauth_states_expires ON oauth_states(expires_at);
+CREATE INDEX IF NOT EXISTS idx_oauth_grants_owner ON oauth_grants(owner_id);
@@ -0,0 +1,6 @@
+DELETE FROM mcp_port_allocations WHERE standby;
+DROP INDEX IF EXISTS idx_mcp_port_allocations_server_standby;
+ALTER TABLE mcp_port_allocations DROP COLUMN IF EXISTS standby;
+ALTER TABLE mcp_port_allocations ADD CONSTRAINT mcp_port_allocations_server_id_key UNIQUE (server_id);
+DROP TABLE IF EXISTS mcp_server_revisions;
+ALTER TABLE mcp_servers DROP COLUMN IF EXISTS revision;
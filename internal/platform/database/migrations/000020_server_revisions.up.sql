@@ -0,0 +1,26 @@
+-- Every change of a server's image or config is kept as a numbered revision
+-- the server can be rolled back to; the server records the one it runs.
+ALTER TABLE mcp_servers ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS mcp_server_revisions (
+    server_id UUID NOT NULL REFERENCES mcp_servers(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    image VARCHAR(512) NOT NULL,
+    config JSONB NOT NULL,
+    rollback_of INTEGER,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (server_id, revision)
+);
+
+-- Servers registered before revisions existed start with their current
+-- image and config as revision 1.
+INSERT INTO mcp_server_revisions (server_id, revision, image, config, created_by, created_at)
+SELECT id, revision, image, config, owner_id, created_at FROM mcp_servers
+ON CONFLICT DO NOTHING;
+
+-- During a blue/green update a server holds a second, standby host port for
+-- its new container.
+ALTER TABLE mcp_port_allocations ADD COLUMN IF NOT EXISTS standby BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE mcp_port_allocations DROP CONSTRAINT IF EXISTS mcp_port_allocations_server_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mcp_port_allocations_server_standby ON mcp_port_allocations(server_id, standby);
@@ -0,0 +1,4 @@
+-- The dropped legacy configs cannot be restored. The column comes back empty,
+-- so reverting 000016 afterwards converts every server's spec back to a
+-- free-form config rather than restoring its original one.
+ALTER TABLE mcp_servers ADD COLUMN IF NOT EXISTS legacy_config JSONB;
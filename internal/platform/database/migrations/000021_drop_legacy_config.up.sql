@@ -0,0 +1,4 @@
+-- The free-form configs kept by 000016 hold env values, often credentials, in
+-- plaintext long after the server's spec has moved them to secrets. They are
+-- dropped.
+ALTER TABLE mcp_servers DROP COLUMN IF EXISTS legacy_config;
@@ -0,0 +1,38 @@
+package sentry
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventAlertRaised is published whenever an alert is raised.
+const EventAlertRaised = "alert.raised"
+
+// Alerter records alerts and notifies subscribers about them.
+type Alerter interface {
+	Raise(ctx context.Context, alert *Alert) error
+}
+
+type alerter struct {
+	repo     Repository
+	notifier Notifier
+}
+
+// NewAlerter creates a DB-backed alerter that also publishes each alert to notifier.
+func NewAlerter(repo Repository, notifier Notifier) Alerter {
+	return &alerter{repo: repo, notifier: notifier}
+}
+
+func (a *alerter) Raise(ctx context.Context, alert *Alert) error {
+	alert.ID = uuid.New()
+	alert.CreatedAt = time.Now()
+	if err := a.repo.CreateAlert(ctx, alert); err != nil {
+		return err
+	}
+	if a.notifier != nil {
+		a.notifier.Notify(ctx, NewEvent(EventAlertRaised, alert))
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Observation is a single MCP call fed to the anomaly detector.
+type Observation struct {
+	UserID      uuid.UUID
+	ServerID    uuid.UUID
+	Method      string
+	Target      string
+	PayloadSize int
+	At          time.Time
+}
+
+// AnomalyConfig tunes the anomaly detector.
+type AnomalyConfig struct {
+	// ZThreshold is the z-score above which a deviation raises an alert.
+	// Deviations beyond twice the threshold are reported as high severity.
+	ZThreshold float64
+	// MinSamples is the number of observations (or active minutes, for call
+	// rates) a baseline needs before it is trusted.
+	MinSamples int
+	// Alpha is the EWMA smoothing factor in (0, 1]; larger values adapt faster.
+	Alpha float64
+}
+
+// DefaultAnomalyConfig returns conservative detector settings.
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{ZThreshold: 3, MinSamples: 20, Alpha: 0.1}
+}
+
+// AnomalyDetector keeps rolling per-user and per-server baselines of MCP
+// traffic and raises alerts when behaviour deviates from them.
+type AnomalyDetector interface {
+	// Observe folds obs into the baselines and returns any alerts it raised.
+	Observe(ctx context.Context, obs *Observation) []*Alert
+}
+
+// ewma tracks an exponentially weighted mean and variance.
+type ewma struct {
+	mean, variance float64
+	n              int
+}
+
+func (e *ewma) add(x, alpha float64) {
+	if e.n == 0 {
+		e.mean = x
+	} else {
+		diff := x - e.mean
+		incr := alpha * diff
+		e.mean += incr
+		e.variance = (1 - alpha) * (e.variance + diff*incr)
+	}
+	e.n++
+}
+
+// z returns how many standard deviations x lies above the mean. The standard
+// deviation is floored so a perfectly steady baseline does not make every
+// small change look infinitely anomalous.
+func (e *ewma) z(x, minStd float64) float64 {
+	std := math.Max(math.Sqrt(e.variance), minStd)
+	return (x - e.mean) / std
+}
+
+const (
+	// maxBaselineTools caps the tools a baseline counts, so a client calling
+	// made-up tool names cannot grow it without bound. Further tools are
+	// neither counted nor reported as new.
+	maxBaselineTools = 256
+	// baselineTTL is how long a baseline survives without traffic.
+	baselineTTL = 7 * 24 * time.Hour
+	// sweepInterval is how often idle baselines are looked for.
+	sweepInterval = time.Hour
+)
+
+// serverBaseline is the traffic profile of one user against one server.
+type serverBaseline struct {
+	rate     ewma // calls per active minute
+	size     ewma // payload bytes per call
+	tools    map[string]int
+	calls    int
+	lastSeen time.Time
+
+	minute      time.Time
+	minuteCount int
+	// rateAlerted is the severity already reported for the current minute,
+	// so a spike alerts once per severity level rather than on every call.
+	rateAlerted string
+}
+
+// userBaseline tracks which servers a user normally talks to.
+type userBaseline struct {
+	servers  map[uuid.UUID]bool
+	calls    int
+	lastSeen time.Time
+}
+
+type baselineKey struct {
+	user, server uuid.UUID
+}
+
+// cooldownKey identifies a server's alerts from one check.
+type cooldownKey struct {
+	server uuid.UUID
+	check  string
+}
+
+// cooldown is the severity already reported for a check in a minute.
+type cooldown struct {
+	minute   time.Time
+	severity string
+}
+
+type anomalyDetector struct {
+	cfg     AnomalyConfig
+	alerter Alerter
+
+	mu        sync.Mutex
+	servers   map[baselineKey]*serverBaseline
+	users     map[uuid.UUID]*userBaseline
+	cooldowns map[cooldownKey]cooldown
+	lastSweep time.Time
+}
+
+// NewAnomalyDetector creates an in-memory anomaly detector. Alerts are raised
+// through alerter when it is non-nil.
+func NewAnomalyDetector(cfg AnomalyConfig, alerter Alerter) AnomalyDetector {
+	return &anomalyDetector{
+		cfg:     cfg,
+		alerter: alerter,
+		servers:   make(map[baselineKey]*serverBaseline),
+		users:     make(map[uuid.UUID]*userBaseline),
+		cooldowns: make(map[cooldownKey]cooldown),
+	}
+}
+
+func (d *anomalyDetector) Observe(ctx context.Context, obs *Observation) []*Alert {
+	at := obs.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	d.mu.Lock()
+	alerts := d.observe(obs, at)
+	d.mu.Unlock()
+
+	if d.alerter != nil {
+		for _, a := range alerts {
+			if err := d.alerter.Raise(context.WithoutCancel(ctx), a); err != nil {
+				slog.Error("raising anomaly alert", "error", err)
+			}
+		}
+	}
+	return alerts
+}
+
+// observe updates baselines and evaluates the observation. Callers hold d.mu.
+func (d *anomalyDetector) observe(obs *Observation, at time.Time) []*Alert {
+	var alerts []*Alert
+	newAlert := func(severity, msg string, meta map[string]any) {
+		serverID := obs.ServerID
+		meta["method"] = obs.Method
+		meta["target"] = obs.Target
+		alerts = append(alerts, &Alert{
+			UserID:   obs.UserID,
+			ServerID: &serverID,
+			Kind:     AlertKindAnomaly,
+			Message:  msg,
+			Severity: severity,
+			Metadata: meta,
+		})
+	}
+
+	if at.Sub(d.lastSweep) >= sweepInterval {
+		d.sweep(at)
+	}
+
+	ub, ok := d.users[obs.UserID]
+	if !ok {
+		ub = &userBaseline{servers: make(map[uuid.UUID]bool)}
+		d.users[obs.UserID] = ub
+	}
+	ub.lastSeen = at
+	if !ub.servers[obs.ServerID] && ub.calls >= d.cfg.MinSamples {
+		newAlert(SeverityMedium, "call to a server this user has never used", map[string]any{"check": "new_server"})
+	}
+	ub.servers[obs.ServerID] = true
+	ub.calls++
+
+	key := baselineKey{user: obs.UserID, server: obs.ServerID}
+	sb, ok := d.servers[key]
+	if !ok {
+		sb = &serverBaseline{tools: make(map[string]int)}
+		d.servers[key] = sb
+	}
+	sb.lastSeen = at
+
+	// Call rate: roll the per-minute bucket, then compare the running count
+	// against the baseline of previous active minutes.
+	minute := at.Truncate(time.Minute)
+	if !minute.Equal(sb.minute) {
+		if sb.minuteCount > 0 {
+			sb.rate.add(float64(sb.minuteCount), d.cfg.Alpha)
+		}
+		sb.minute = minute
+		sb.minuteCount = 0
+		sb.rateAlerted = ""
+	}
+	sb.minuteCount++
+	if sb.rate.n >= d.cfg.MinSamples {
+		z := sb.rate.z(float64(sb.minuteCount), math.Max(1, sb.rate.mean*0.1))
+		if severity := d.severity(z); z >= d.cfg.ZThreshold && severity != sb.rateAlerted && sb.rateAlerted != SeverityHigh {
+			sb.rateAlerted = severity
+			newAlert(severity, fmt.Sprintf("call rate %d/min deviates from baseline %.1f/min", sb.minuteCount, sb.rate.mean),
+				map[string]any{"check": "call_rate", "z": z, "rate": sb.minuteCount, "baseline": sb.rate.mean})
+		}
+	}
+
+	// Payload size, reported once per severity per server and minute like
+	// the call rate.
+	size := float64(obs.PayloadSize)
+	if sb.size.n >= d.cfg.MinSamples {
+		if z := sb.size.z(size, math.Max(64, sb.size.mean*0.1)); z >= d.cfg.ZThreshold && d.cool(obs.ServerID, "payload_size", minute, d.severity(z)) {
+			newAlert(d.severity(z), fmt.Sprintf("payload of %d bytes deviates from baseline %.0f bytes", obs.PayloadSize, sb.size.mean),
+				map[string]any{"check": "payload_size", "z": z, "size": obs.PayloadSize, "baseline": sb.size.mean})
+		}
+	}
+	sb.size.add(size, d.cfg.Alpha)
+
+	// Tool mix: a tool never seen on an established baseline is notable.
+	if _, known := sb.tools[obs.Target]; obs.Target != "" && (known || len(sb.tools) < maxBaselineTools) {
+		if !known && sb.calls >= d.cfg.MinSamples {
+			newAlert(SeverityLow, "call to a tool not previously used on this server",
+				map[string]any{"check": "new_tool"})
+		}
+		sb.tools[obs.Target]++
+	}
+	sb.calls++
+
+	return alerts
+}
+
+// cool reports whether an alert of severity from check on server may be
+// raised in minute, and if so records it. Within a minute only an escalation
+// is reported again.
+func (d *anomalyDetector) cool(server uuid.UUID, check string, minute time.Time, severity string) bool {
+	key := cooldownKey{server: server, check: check}
+	if prev, ok := d.cooldowns[key]; ok && prev.minute.Equal(minute) &&
+		(prev.severity == severity || prev.severity == SeverityHigh) {
+		return false
+	}
+	d.cooldowns[key] = cooldown{minute: minute, severity: severity}
+	return true
+}
+
+// sweep drops baselines without traffic for baselineTTL and expired
+// cooldowns. Callers hold d.mu.
+func (d *anomalyDetector) sweep(at time.Time) {
+	d.lastSweep = at
+	cutoff := at.Add(-baselineTTL)
+	for key, sb := range d.servers {
+		if sb.lastSeen.Before(cutoff) {
+			delete(d.servers, key)
+		}
+	}
+	for id, ub := range d.users {
+		if ub.lastSeen.Before(cutoff) {
+			delete(d.users, id)
+		}
+	}
+	minute := at.Truncate(time.Minute)
+	for key, c := range d.cooldowns {
+		if c.minute.Before(minute) {
+			delete(d.cooldowns, key)
+		}
+	}
+}
+
+func (d *anomalyDetector) severity(z float64) string {
+	if z >= 2*d.cfg.ZThreshold {
+		return SeverityHigh
+	}
+	return SeverityMedium
+}
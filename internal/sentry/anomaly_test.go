@@ -0,0 +1,189 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type recordingAlerter struct {
+	alerts []*Alert
+}
+
+func (r *recordingAlerter) Raise(_ context.Context, a *Alert) error {
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+// steadyTraffic feeds perMinute calls to tool for the given number of minutes
+// starting at start, and returns the time just after the last minute.
+func steadyTraffic(d AnomalyDetector, user, server uuid.UUID, tool string, perMinute, minutes int, start time.Time) time.Time {
+	for m := 0; m < minutes; m++ {
+		minute := start.Add(time.Duration(m) * time.Minute)
+		for i := 0; i < perMinute; i++ {
+			d.Observe(context.Background(), &Observation{
+				UserID: user, ServerID: server, Method: "tools/call", Target: tool,
+				PayloadSize: 200 + i%5,
+				At:          minute.Add(time.Duration(i) * time.Second),
+			})
+		}
+	}
+	return start.Add(time.Duration(minutes) * time.Minute)
+}
+
+func checksOf(alerts []*Alert) map[string]*Alert {
+	out := map[string]*Alert{}
+	for _, a := range alerts {
+		out[a.Metadata["check"].(string)] = a
+	}
+	return out
+}
+
+func TestAnomalyDetectorCallRateSpike(t *testing.T) {
+	alerter := &recordingAlerter{}
+	d := NewAnomalyDetector(DefaultAnomalyConfig(), alerter)
+	user, server := uuid.New(), uuid.New()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	next := steadyTraffic(d, user, server, "search", 3, 30, start)
+	if len(alerter.alerts) != 0 {
+		t.Fatalf("expected no alerts for steady traffic, got %d", len(alerter.alerts))
+	}
+
+	// 200 calls in one minute.
+	var spike []*Alert
+	for i := 0; i < 200; i++ {
+		spike = append(spike, d.Observe(context.Background(), &Observation{
+			UserID: user, ServerID: server, Method: "tools/call", Target: "search",
+			PayloadSize: 200, At: next.Add(time.Duration(i) * 250 * time.Millisecond),
+		})...)
+	}
+
+	rate, ok := checksOf(spike)["call_rate"]
+	if !ok {
+		t.Fatal("expected a call_rate alert")
+	}
+	if rate.Severity != SeverityHigh {
+		t.Errorf("expected spike to escalate to high severity, got %s", rate.Severity)
+	}
+	if rate.Kind != AlertKindAnomaly || rate.UserID != user || *rate.ServerID != server {
+		t.Errorf("unexpected alert attribution: %+v", rate)
+	}
+
+	count := 0
+	for _, a := range spike {
+		if a.Metadata["check"] == "call_rate" {
+			count++
+		}
+	}
+	if count != 2 {
+		t.Errorf("expected one call_rate alert per severity level, got %d", count)
+	}
+	if len(alerter.alerts) != len(spike) {
+		t.Errorf("expected every alert to be raised, got %d of %d", len(alerter.alerts), len(spike))
+	}
+}
+
+func TestAnomalyDetectorNewServerAndTool(t *testing.T) {
+	d := NewAnomalyDetector(DefaultAnomalyConfig(), nil)
+	user, server := uuid.New(), uuid.New()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	next := steadyTraffic(d, user, server, "search", 2, 15, start)
+
+	alerts := checksOf(d.Observe(context.Background(), &Observation{
+		UserID: user, ServerID: server, Method: "tools/call", Target: "delete_repo", PayloadSize: 200, At: next,
+	}))
+	if _, ok := alerts["new_tool"]; !ok {
+		t.Error("expected new_tool alert")
+	}
+
+	alerts = checksOf(d.Observe(context.Background(), &Observation{
+		UserID: user, ServerID: uuid.New(), Method: "tools/call", Target: "search", PayloadSize: 200, At: next,
+	}))
+	if a, ok := alerts["new_server"]; !ok || a.Severity != SeverityMedium {
+		t.Errorf("expected medium new_server alert, got %+v", alerts)
+	}
+}
+
+func TestAnomalyDetectorPayloadSize(t *testing.T) {
+	d := NewAnomalyDetector(DefaultAnomalyConfig(), nil)
+	user, server := uuid.New(), uuid.New()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	next := steadyTraffic(d, user, server, "search", 2, 15, start)
+
+	alerts := checksOf(d.Observe(context.Background(), &Observation{
+		UserID: user, ServerID: server, Method: "tools/call", Target: "search", PayloadSize: 50_000, At: next,
+	}))
+	if _, ok := alerts["payload_size"]; !ok {
+		t.Error("expected payload_size alert")
+	}
+}
+
+func TestAnomalyDetectorNeedsWarmup(t *testing.T) {
+	d := NewAnomalyDetector(DefaultAnomalyConfig(), nil)
+	user := uuid.New()
+
+	for i := 0; i < 5; i++ {
+		alerts := d.Observe(context.Background(), &Observation{
+			UserID: user, ServerID: uuid.New(), Method: "tools/call", Target: "x", PayloadSize: 10 * (i + 1) * 1000,
+		})
+		if len(alerts) != 0 {
+			t.Fatalf("expected no alerts before baseline warm-up, got %+v", alerts)
+		}
+	}
+}
+
+func TestAnomalyDetectorPayloadSizeCooldown(t *testing.T) {
+	d := NewAnomalyDetector(DefaultAnomalyConfig(), nil)
+	user, server := uuid.New(), uuid.New()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	next := steadyTraffic(d, user, server, "search", 2, 15, start)
+
+	count := 0
+	for i := 0; i < 10; i++ {
+		alerts := checksOf(d.Observe(context.Background(), &Observation{
+			UserID: user, ServerID: server, Method: "tools/call", Target: "search", PayloadSize: 50_000,
+			At: next.Add(time.Duration(i) * time.Second),
+		}))
+		if _, ok := alerts["payload_size"]; ok {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected one payload_size alert within a minute, got %d", count)
+	}
+}
+
+func TestAnomalyDetectorBoundsBaselines(t *testing.T) {
+	d := NewAnomalyDetector(DefaultAnomalyConfig(), nil).(*anomalyDetector)
+	user, server := uuid.New(), uuid.New()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2*maxBaselineTools; i++ {
+		d.Observe(context.Background(), &Observation{
+			UserID: user, ServerID: server, Method: "tools/call", Target: fmt.Sprintf("tool-%d", i),
+			PayloadSize: 200, At: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+	if n := len(d.servers[baselineKey{user: user, server: server}].tools); n != maxBaselineTools {
+		t.Errorf("expected the tools to be capped at %d, got %d", maxBaselineTools, n)
+	}
+
+	// Traffic from another user after the baseline TTL sweeps the idle one.
+	d.Observe(context.Background(), &Observation{
+		UserID: uuid.New(), ServerID: server, Method: "tools/call", Target: "search",
+		PayloadSize: 200, At: start.Add(baselineTTL + 2*sweepInterval),
+	})
+	if _, ok := d.servers[baselineKey{user: user, server: server}]; ok {
+		t.Error("expected the idle baseline to be evicted")
+	}
+	if _, ok := d.users[user]; ok {
+		t.Error("expected the idle user baseline to be evicted")
+	}
+}
@@ -0,0 +1,260 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrApprovalClosed is returned when deciding an approval that is no longer pending.
+var ErrApprovalClosed = errors.New("approval is no longer pending")
+
+// ErrNotApprover is returned when a user without approver rights decides an approval.
+var ErrNotApprover = errors.New("user is not an approver")
+
+// ServerOwners resolves the owner of an MCP server. It is implemented by the
+// nodes module so Sentry does not depend on it.
+type ServerOwners interface {
+	// ServerOwner returns the ID of the user owning the server, or ErrNotFound.
+	ServerOwner(ctx context.Context, serverID uuid.UUID) (uuid.UUID, error)
+}
+
+// ApprovalQueue holds sensitive requests until a human approves or denies them.
+type ApprovalQueue interface {
+	// Await records a pending approval, notifies approvers and blocks until it
+	// is decided, it times out, or ctx is cancelled. It returns the final status.
+	Await(ctx context.Context, approval *Approval) (ApprovalStatus, error)
+	// Decide approves or denies a pending approval on behalf of approverID.
+	Decide(ctx context.Context, id, approverID uuid.UUID, approve bool) (*Approval, error)
+	// List returns the approvals with status, or all if it is empty, that
+	// userID requested or may decide.
+	List(ctx context.Context, status ApprovalStatus, userID uuid.UUID) ([]Approval, error)
+	// Visible reports whether userID requested or may decide the approval.
+	Visible(ctx context.Context, approval *Approval, userID uuid.UUID) bool
+	// IsApprover reports whether userID is a configured approver, who may see
+	// and decide every approval but their own.
+	IsApprover(userID uuid.UUID) bool
+}
+
+type approvalQueue struct {
+	repo      Repository
+	notifier  Notifier
+	timeout   time.Duration
+	approvers map[uuid.UUID]bool
+	owners    ServerOwners
+
+	mu      sync.Mutex
+	waiters map[uuid.UUID]chan ApprovalStatus
+}
+
+// NewApprovalQueue creates a DB-backed approval queue. Pending approvals expire
+// after timeout. If approvers is empty the owner of the server a request was
+// sent to decides it, as looked up in owners, which may be nil to leave every
+// approval to expire. Nobody may decide their own request.
+func NewApprovalQueue(repo Repository, notifier Notifier, timeout time.Duration, approvers []uuid.UUID, owners ServerOwners) ApprovalQueue {
+	q := &approvalQueue{
+		repo:      repo,
+		notifier:  notifier,
+		timeout:   timeout,
+		approvers: make(map[uuid.UUID]bool, len(approvers)),
+		owners:    owners,
+		waiters:   make(map[uuid.UUID]chan ApprovalStatus),
+	}
+	for _, id := range approvers {
+		q.approvers[id] = true
+	}
+	return q
+}
+
+func (q *approvalQueue) Await(ctx context.Context, approval *Approval) (ApprovalStatus, error) {
+	now := time.Now()
+	approval.ID = uuid.New()
+	approval.Status = ApprovalPending
+	approval.CreatedAt = now
+	approval.ExpiresAt = now.Add(q.timeout)
+
+	// Register the waiter before persisting so a fast decision is not lost.
+	ch := make(chan ApprovalStatus, 1)
+	q.mu.Lock()
+	q.waiters[approval.ID] = ch
+	q.mu.Unlock()
+
+	if err := q.repo.CreateApproval(ctx, approval); err != nil {
+		q.release(approval.ID)
+		return "", fmt.Errorf("creating approval: %w", err)
+	}
+
+	q.publish(ctx, EventApprovalRequested, approval)
+
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+
+	select {
+	case status := <-ch:
+		approval.Status = status
+		return status, nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	// Expire the approval unless a decision raced with the timeout.
+	if !q.release(approval.ID) {
+		status := <-ch
+		approval.Status = status
+		return status, nil
+	}
+
+	approval.Status = ApprovalExpired
+	if err := q.repo.UpdateApproval(context.WithoutCancel(ctx), approval); err != nil {
+		return ApprovalExpired, fmt.Errorf("expiring approval: %w", err)
+	}
+	return ApprovalExpired, nil
+}
+
+func (q *approvalQueue) Decide(ctx context.Context, id, approverID uuid.UUID, approve bool) (*Approval, error) {
+	if len(q.approvers) > 0 && !q.approvers[approverID] {
+		return nil, ErrNotApprover
+	}
+
+	approval, err := q.repo.GetApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.authorize(ctx, approval, approverID); err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	ch, waiting := q.waiters[id]
+	delete(q.waiters, id)
+	q.mu.Unlock()
+
+	if !waiting {
+		// Nobody waits for a decision on a request left pending by a restart,
+		// so it can never be delivered.
+		if approval.Status == ApprovalPending {
+			if err := q.expire(ctx, approval); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrApprovalClosed
+	}
+
+	now := time.Now()
+	approval.Status = ApprovalDenied
+	if approve {
+		approval.Status = ApprovalApproved
+	}
+	approval.DecidedBy = &approverID
+	approval.DecidedAt = &now
+
+	if err := q.repo.UpdateApproval(ctx, approval); err != nil {
+		ch <- ApprovalDenied
+		return nil, fmt.Errorf("updating approval: %w", err)
+	}
+	ch <- approval.Status
+
+	q.publish(ctx, EventApprovalDecided, approval)
+	return approval, nil
+}
+
+func (q *approvalQueue) List(ctx context.Context, status ApprovalStatus, userID uuid.UUID) ([]Approval, error) {
+	approvals, err := q.repo.ListApprovals(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	visible := approvals[:0]
+	for i := range approvals {
+		approval := &approvals[i]
+		if approval.Status == ApprovalPending && time.Now().After(approval.ExpiresAt) && !q.waiting(approval.ID) {
+			// Left pending past its expiry by a restart.
+			if err := q.expire(ctx, approval); err != nil {
+				return nil, err
+			}
+			if status == ApprovalPending {
+				continue
+			}
+		}
+		if q.approvers[userID] || q.Visible(ctx, approval, userID) {
+			visible = append(visible, *approval)
+		}
+	}
+	return visible, nil
+}
+
+func (q *approvalQueue) Visible(ctx context.Context, approval *Approval, userID uuid.UUID) bool {
+	return approval.UserID == userID || q.authorize(ctx, approval, userID) == nil
+}
+
+func (q *approvalQueue) IsApprover(userID uuid.UUID) bool {
+	return q.approvers[userID]
+}
+
+// authorize checks that approverID may decide the approval. Nobody may decide
+// their own request; otherwise the configured approvers may or, without any,
+// the owner of the server the request was sent to.
+func (q *approvalQueue) authorize(ctx context.Context, approval *Approval, approverID uuid.UUID) error {
+	if approverID == approval.UserID {
+		return ErrNotApprover
+	}
+	if len(q.approvers) > 0 {
+		if !q.approvers[approverID] {
+			return ErrNotApprover
+		}
+		return nil
+	}
+	if q.owners == nil {
+		return ErrNotApprover
+	}
+	ownerID, err := q.owners.ServerOwner(ctx, approval.ServerID)
+	if errors.Is(err, ErrNotFound) {
+		return ErrNotApprover
+	}
+	if err != nil {
+		return fmt.Errorf("looking up server owner: %w", err)
+	}
+	if ownerID != approverID {
+		return ErrNotApprover
+	}
+	return nil
+}
+
+// expire marks a pending approval nobody waits for as expired.
+func (q *approvalQueue) expire(ctx context.Context, approval *Approval) error {
+	approval.Status = ApprovalExpired
+	if err := q.repo.UpdateApproval(ctx, approval); err != nil {
+		return fmt.Errorf("expiring approval: %w", err)
+	}
+	return nil
+}
+
+// publish sends an approval event. The event carries a copy: subscribers read
+// it on other goroutines while the caller goes on updating the approval.
+func (q *approvalQueue) publish(ctx context.Context, eventType string, approval *Approval) {
+	if q.notifier == nil {
+		return
+	}
+	a := *approval
+	q.notifier.Notify(ctx, NewEvent(eventType, &a))
+}
+
+// waiting reports whether an Await in this process waits for the decision on id.
+func (q *approvalQueue) waiting(id uuid.UUID) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.waiters[id]
+	return ok
+}
+
+// release removes the waiter for id, reporting whether it was still registered.
+func (q *approvalQueue) release(id uuid.UUID) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.waiters[id]
+	delete(q.waiters, id)
+	return ok
+}
@@ -0,0 +1,247 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// approvalStore is an in-memory approval repository for queue tests.
+func approvalStore() *mockRepo {
+	var mu sync.Mutex
+	store := map[uuid.UUID]Approval{}
+	return &mockRepo{
+		CreateApprovalFn: func(_ context.Context, a *Approval) error {
+			mu.Lock()
+			defer mu.Unlock()
+			store[a.ID] = *a
+			return nil
+		},
+		GetApprovalFn: func(_ context.Context, id uuid.UUID) (*Approval, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			a, ok := store[id]
+			if !ok {
+				return nil, ErrNotFound
+			}
+			return &a, nil
+		},
+		UpdateApprovalFn: func(_ context.Context, a *Approval) error {
+			mu.Lock()
+			defer mu.Unlock()
+			store[a.ID] = *a
+			return nil
+		},
+		ListApprovalsFn: func(_ context.Context, status ApprovalStatus) ([]Approval, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			var approvals []Approval
+			for _, a := range store {
+				if status == "" || a.Status == status {
+					approvals = append(approvals, a)
+				}
+			}
+			return approvals, nil
+		},
+	}
+}
+
+// ownerOf resolves every server to the same owner.
+type ownerOf uuid.UUID
+
+func (o ownerOf) ServerOwner(context.Context, uuid.UUID) (uuid.UUID, error) {
+	return uuid.UUID(o), nil
+}
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, e *Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, e)
+}
+
+func (n *recordingNotifier) waitFor(t *testing.T, eventType string) *Event {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		n.mu.Lock()
+		for _, e := range n.events {
+			if e.Type == eventType {
+				n.mu.Unlock()
+				return e
+			}
+		}
+		n.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s event", eventType)
+	return nil
+}
+
+func TestApprovalQueueApprove(t *testing.T) {
+	notifier := &recordingNotifier{}
+	approverID := uuid.New()
+	q := NewApprovalQueue(approvalStore(), notifier, time.Minute, nil, ownerOf(approverID))
+
+	result := make(chan ApprovalStatus, 1)
+	go func() {
+		status, err := q.Await(context.Background(), &Approval{Method: "tools/call", Target: "deploy"})
+		if err != nil {
+			t.Errorf("Await failed: %v", err)
+		}
+		result <- status
+	}()
+
+	requested := notifier.waitFor(t, EventApprovalRequested)
+	pending := requested.Data.(*Approval)
+
+	decided, err := q.Decide(context.Background(), pending.ID, approverID, true)
+	if err != nil {
+		t.Fatalf("Decide failed: %v", err)
+	}
+	if decided.DecidedBy == nil || *decided.DecidedBy != approverID {
+		t.Error("expected DecidedBy to be recorded")
+	}
+
+	if status := <-result; status != ApprovalApproved {
+		t.Errorf("expected approved, got %s", status)
+	}
+	if e := notifier.waitFor(t, EventApprovalDecided); e.Data.(*Approval) == decided {
+		t.Error("expected the decided event to carry a copy of the approval")
+	}
+	// Events carry copies, so Await settling the approval does not race
+	// with subscribers reading it.
+	if pending.Status != ApprovalPending {
+		t.Errorf("expected the requested event to keep its pending approval, got %s", pending.Status)
+	}
+
+	if _, err := q.Decide(context.Background(), pending.ID, approverID, false); !errors.Is(err, ErrApprovalClosed) {
+		t.Errorf("expected ErrApprovalClosed on second decision, got %v", err)
+	}
+}
+
+func TestApprovalQueueTimeout(t *testing.T) {
+	repo := approvalStore()
+	q := NewApprovalQueue(repo, nil, 20*time.Millisecond, nil, nil)
+
+	approval := &Approval{Method: "tools/call", Target: "delete_file"}
+	status, err := q.Await(context.Background(), approval)
+	if err != nil {
+		t.Fatalf("Await failed: %v", err)
+	}
+	if status != ApprovalExpired {
+		t.Fatalf("expected expired, got %s", status)
+	}
+
+	stored, _ := repo.GetApproval(context.Background(), approval.ID)
+	if stored.Status != ApprovalExpired {
+		t.Errorf("expected stored status expired, got %s", stored.Status)
+	}
+}
+
+func TestApprovalQueueRejectsNonApprover(t *testing.T) {
+	q := NewApprovalQueue(approvalStore(), nil, time.Minute, []uuid.UUID{uuid.New()}, nil)
+
+	_, err := q.Decide(context.Background(), uuid.New(), uuid.New(), true)
+	if !errors.Is(err, ErrNotApprover) {
+		t.Errorf("expected ErrNotApprover, got %v", err)
+	}
+}
+
+func TestApprovalQueueDecideUnknown(t *testing.T) {
+	q := NewApprovalQueue(approvalStore(), nil, time.Minute, nil, ownerOf(uuid.New()))
+
+	_, err := q.Decide(context.Background(), uuid.New(), uuid.New(), true)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestApprovalQueueRejectsSelfApproval(t *testing.T) {
+	userID, ownerID := uuid.New(), uuid.New()
+	tests := []struct {
+		name      string
+		approvers []uuid.UUID
+		owners    ServerOwners
+		deciderID uuid.UUID
+	}{
+		{"requester is an approver", []uuid.UUID{userID}, nil, userID},
+		{"requester owns the server", nil, ownerOf(userID), userID},
+		{"no approvers and no owner lookup", nil, nil, ownerID},
+		{"no approvers and not the owner", nil, ownerOf(ownerID), uuid.New()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := approvalStore()
+			approval := &Approval{ID: uuid.New(), UserID: userID, ServerID: uuid.New(), Status: ApprovalPending}
+			repo.CreateApproval(context.Background(), approval)
+			q := NewApprovalQueue(repo, nil, time.Minute, tt.approvers, tt.owners)
+
+			if _, err := q.Decide(context.Background(), approval.ID, tt.deciderID, true); !errors.Is(err, ErrNotApprover) {
+				t.Errorf("expected ErrNotApprover, got %v", err)
+			}
+		})
+	}
+}
+
+func TestApprovalQueueListOnlyShowsCallersApprovals(t *testing.T) {
+	userID, ownerID, approverID := uuid.New(), uuid.New(), uuid.New()
+	own := Approval{ID: uuid.New(), UserID: userID}
+	other := Approval{ID: uuid.New(), UserID: uuid.New()}
+	repo := &mockRepo{ListApprovalsFn: func(context.Context, ApprovalStatus) ([]Approval, error) {
+		return []Approval{own, other}, nil
+	}}
+
+	tests := []struct {
+		name      string
+		approvers []uuid.UUID
+		caller    uuid.UUID
+		want      int
+	}{
+		{"requester", nil, userID, 1},
+		{"server owner", nil, ownerID, 2},
+		{"configured approver", []uuid.UUID{approverID}, approverID, 2},
+		{"owner with configured approvers", []uuid.UUID{approverID}, ownerID, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewApprovalQueue(repo, nil, time.Minute, tt.approvers, ownerOf(ownerID))
+			approvals, err := q.List(context.Background(), ApprovalPending, tt.caller)
+			if err != nil || len(approvals) != tt.want {
+				t.Errorf("expected %d approvals, got %v and %+v", tt.want, err, approvals)
+			}
+		})
+	}
+}
+
+func TestApprovalQueueExpiresOrphanedApprovals(t *testing.T) {
+	ownerID := uuid.New()
+	repo := approvalStore()
+	// Left pending by a restart: no Await in this process waits for them.
+	undecided := &Approval{ID: uuid.New(), UserID: uuid.New(), Status: ApprovalPending, ExpiresAt: time.Now().Add(time.Hour)}
+	overdue := &Approval{ID: uuid.New(), UserID: uuid.New(), Status: ApprovalPending, ExpiresAt: time.Now().Add(-time.Minute)}
+	repo.CreateApproval(context.Background(), undecided)
+	repo.CreateApproval(context.Background(), overdue)
+	q := NewApprovalQueue(repo, nil, time.Minute, nil, ownerOf(ownerID))
+
+	if _, err := q.Decide(context.Background(), undecided.ID, ownerID, true); !errors.Is(err, ErrApprovalClosed) {
+		t.Errorf("expected ErrApprovalClosed, got %v", err)
+	}
+	pending, err := q.List(context.Background(), ApprovalPending, ownerID)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("expected no pending approvals, got %v and %+v", err, pending)
+	}
+	for _, a := range []*Approval{undecided, overdue} {
+		if stored, _ := repo.GetApproval(context.Background(), a.ID); stored.Status != ApprovalExpired {
+			t.Errorf("expected approval %s to be stored as expired, got %s", a.ID, stored.Status)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrBlocked is returned when a blocking rule matches a request.
+var ErrBlocked = errors.New("request blocked by sentry rule")
+
+// ErrApprovalDenied is returned when an approver denies a held request.
+var ErrApprovalDenied = errors.New("request denied by approver")
+
+// ErrApprovalExpired is returned when a held request is not decided in time.
+var ErrApprovalExpired = errors.New("approval timed out")
+
+// Request describes an MCP call passing through the gateway proxy.
+type Request struct {
+	UserID   uuid.UUID
+	ServerID uuid.UUID
+	Method   string
+	// Target is the tool name, resource URI or prompt name the call acts on.
+	Target string
+	Params json.RawMessage
+}
+
+// Resource returns the rule-matching resource for the request in the form
+// "<server_id>/<target>".
+func (r *Request) Resource() string {
+	return r.ServerID.String() + "/" + r.Target
+}
+
+// Enforcer applies firewall rules to MCP requests before they are forwarded.
+type Enforcer interface {
+	// Enforce returns nil if the request may proceed. It blocks while a
+	// require_approval rule waits for a human decision.
+	Enforce(ctx context.Context, req *Request) error
+}
+
+type enforcer struct {
+	rules     RuleEngine
+	approvals ApprovalQueue
+	audit     AuditLogger
+	alerter   Alerter
+	anomalies AnomalyDetector
+}
+
+// NewEnforcer creates an Enforcer. approvals may be nil, in which case
+// require_approval rules block outright. alerter receives an alert, at the
+// rule's severity, for every matching alert or block rule; anomalies may be nil
+// to disable behavioural anomaly detection.
+func NewEnforcer(rules RuleEngine, approvals ApprovalQueue, audit AuditLogger, alerter Alerter, anomalies AnomalyDetector) Enforcer {
+	return &enforcer{rules: rules, approvals: approvals, audit: audit, alerter: alerter, anomalies: anomalies}
+}
+
+func (e *enforcer) Enforce(ctx context.Context, req *Request) error {
+	if e.anomalies != nil {
+		e.anomalies.Observe(ctx, &Observation{
+			UserID:      req.UserID,
+			ServerID:    req.ServerID,
+			Method:      req.Method,
+			Target:      req.Target,
+			PayloadSize: len(req.Params),
+			At:          time.Now(),
+		})
+	}
+
+	decision, err := e.rules.Decide(ctx, req.Method, req.Resource())
+	if err != nil {
+		return fmt.Errorf("evaluating rules: %w", err)
+	}
+
+	switch decision.Action {
+	case ActionBlock:
+		e.record(ctx, "mcp.blocked", req, decision.Rule, nil)
+		e.raise(ctx, req, decision.Rule)
+		return fmt.Errorf("%w: %s", ErrBlocked, decision.Rule.Name)
+
+	case ActionRequireApproval:
+		if e.approvals == nil {
+			e.record(ctx, "mcp.blocked", req, decision.Rule, nil)
+			return fmt.Errorf("%w: %s", ErrBlocked, decision.Rule.Name)
+		}
+
+		approval := &Approval{
+			RuleID:   &decision.Rule.ID,
+			UserID:   req.UserID,
+			ServerID: req.ServerID,
+			Method:   req.Method,
+			Target:   req.Target,
+			Params:   req.Params,
+		}
+		status, err := e.approvals.Await(ctx, approval)
+		if err != nil {
+			return fmt.Errorf("awaiting approval: %w", err)
+		}
+		e.record(ctx, "mcp.approval", req, decision.Rule, map[string]any{
+			"approval_id": approval.ID.String(),
+			"status":      string(status),
+		})
+
+		switch status {
+		case ApprovalApproved:
+			return nil
+		case ApprovalDenied:
+			return ErrApprovalDenied
+		default:
+			return ErrApprovalExpired
+		}
+
+	case ActionAlert:
+		e.record(ctx, "mcp.alert", req, decision.Rule, nil)
+		e.raise(ctx, req, decision.Rule)
+	}
+
+	return nil
+}
+
+func (e *enforcer) record(ctx context.Context, action string, req *Request, rule *Rule, extra map[string]any) {
+	if e.audit == nil {
+		return
+	}
+
+	meta := map[string]any{
+		"server_id": req.ServerID.String(),
+		"method":    req.Method,
+		"target":    req.Target,
+	}
+	if rule != nil {
+		meta["rule_id"] = rule.ID.String()
+		meta["rule"] = rule.Name
+	}
+	for k, v := range extra {
+		meta[k] = v
+	}
+
+	userID := req.UserID
+	entry := &AuditEntry{
+		UserID:   &userID,
+		Action:   action,
+		Resource: req.Resource(),
+		Metadata: meta,
+	}
+	if err := e.audit.Log(context.WithoutCancel(ctx), entry); err != nil {
+		slog.Error("writing sentry audit entry", "action", action, "error", err)
+	}
+}
+
+// raise reports a rule match as an alert so it counts towards quarantine.
+func (e *enforcer) raise(ctx context.Context, req *Request, rule *Rule) {
+	if e.alerter == nil {
+		return
+	}
+
+	severity := rule.Severity
+	if severity == "" {
+		severity = SeverityMedium
+	}
+	ruleID, serverID := rule.ID, req.ServerID
+	alert := &Alert{
+		RuleID:   &ruleID,
+		UserID:   req.UserID,
+		ServerID: &serverID,
+		Kind:     AlertKindRule,
+		Message:  fmt.Sprintf("rule %q matched %s on %s", rule.Name, req.Method, req.Target),
+		Severity: severity,
+		Metadata: map[string]any{
+			"rule":   rule.Name,
+			"action": rule.Action,
+			"method": req.Method,
+			"target": req.Target,
+		},
+	}
+	if err := e.alerter.Raise(context.WithoutCancel(ctx), alert); err != nil {
+		slog.Error("raising sentry rule alert", "rule", rule.Name, "error", err)
+	}
+}
@@ -0,0 +1,141 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func rulesRepo(rules ...Rule) *mockRepo {
+	return &mockRepo{
+		ListRulesFn: func(_ context.Context) ([]Rule, error) {
+			return rules, nil
+		},
+		CreateAuditEntryFn: func(_ context.Context, _ *AuditEntry) error {
+			return nil
+		},
+	}
+}
+
+// stubApprovals resolves every approval with a fixed status.
+type stubApprovals struct {
+	status ApprovalStatus
+	seen   *Approval
+}
+
+func (s *stubApprovals) Await(_ context.Context, a *Approval) (ApprovalStatus, error) {
+	s.seen = a
+	return s.status, nil
+}
+func (s *stubApprovals) Decide(_ context.Context, _, _ uuid.UUID, _ bool) (*Approval, error) {
+	return nil, ErrNotImplemented
+}
+func (s *stubApprovals) List(_ context.Context, _ ApprovalStatus, _ uuid.UUID) ([]Approval, error) {
+	return nil, ErrNotImplemented
+}
+func (s *stubApprovals) Visible(context.Context, *Approval, uuid.UUID) bool { return false }
+func (s *stubApprovals) IsApprover(uuid.UUID) bool                          { return false }
+
+func TestDecidePrecedence(t *testing.T) {
+	repo := rulesRepo(
+		Rule{Name: "alert-all", Pattern: ".*", Action: ActionAlert, Enabled: true},
+		Rule{Name: "hold-deploy", Pattern: "tools/call:.*/deploy", Action: ActionRequireApproval, Enabled: true},
+		Rule{Name: "disabled-block", Pattern: ".*", Action: ActionBlock, Enabled: false},
+	)
+	engine := NewRuleEngine(repo)
+
+	d, err := engine.Decide(context.Background(), "tools/call", "srv/deploy")
+	if err != nil {
+		t.Fatalf("Decide failed: %v", err)
+	}
+	if d.Action != ActionRequireApproval || d.Rule.Name != "hold-deploy" {
+		t.Errorf("expected hold-deploy require_approval, got %s from %v", d.Action, d.Rule)
+	}
+
+	d, _ = engine.Decide(context.Background(), "tools/list", "srv/")
+	if d.Action != ActionAlert {
+		t.Errorf("expected alert, got %s", d.Action)
+	}
+}
+
+func TestEnforceBlock(t *testing.T) {
+	var audited *AuditEntry
+	repo := rulesRepo(Rule{Name: "no-delete", Pattern: "delete_file", Action: ActionBlock, Enabled: true})
+	repo.CreateAuditEntryFn = func(_ context.Context, e *AuditEntry) error {
+		audited = e
+		return nil
+	}
+	e := NewEnforcer(NewRuleEngine(repo), nil, NewAuditLogger(repo), nil, nil)
+
+	err := e.Enforce(context.Background(), &Request{UserID: uuid.New(), ServerID: uuid.New(), Method: "tools/call", Target: "delete_file"})
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected ErrBlocked, got %v", err)
+	}
+	if audited == nil || audited.Action != "mcp.blocked" {
+		t.Errorf("expected mcp.blocked audit entry, got %+v", audited)
+	}
+}
+
+func TestEnforceRequireApproval(t *testing.T) {
+	repo := rulesRepo(Rule{ID: uuid.New(), Name: "hold", Pattern: "tools/call", Action: ActionRequireApproval, Enabled: true})
+
+	tests := []struct {
+		status ApprovalStatus
+		want   error
+	}{
+		{ApprovalApproved, nil},
+		{ApprovalDenied, ErrApprovalDenied},
+		{ApprovalExpired, ErrApprovalExpired},
+	}
+	for _, tt := range tests {
+		approvals := &stubApprovals{status: tt.status}
+		e := NewEnforcer(NewRuleEngine(repo), approvals, NewAuditLogger(repo), nil, nil)
+
+		err := e.Enforce(context.Background(), &Request{ServerID: uuid.New(), Method: "tools/call", Target: "pay"})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("status %s: expected %v, got %v", tt.status, tt.want, err)
+		}
+		if approvals.seen == nil || approvals.seen.Target != "pay" {
+			t.Errorf("status %s: expected approval for target pay", tt.status)
+		}
+	}
+}
+
+func TestEnforceRequireApprovalWithoutQueueBlocks(t *testing.T) {
+	repo := rulesRepo(Rule{Name: "hold", Pattern: ".*", Action: ActionRequireApproval, Enabled: true})
+	e := NewEnforcer(NewRuleEngine(repo), nil, nil, nil, nil)
+
+	err := e.Enforce(context.Background(), &Request{Method: "tools/call"})
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected ErrBlocked, got %v", err)
+	}
+}
+
+func TestEnforceAllowsUnmatched(t *testing.T) {
+	repo := rulesRepo(Rule{Name: "no-delete", Pattern: "delete_file", Action: ActionBlock, Enabled: true})
+	e := NewEnforcer(NewRuleEngine(repo), nil, nil, nil, nil)
+
+	if err := e.Enforce(context.Background(), &Request{Method: "tools/call", Target: "read_file"}); err != nil {
+		t.Errorf("expected request to be allowed, got %v", err)
+	}
+}
+
+func TestEnforceRaisesRuleAlerts(t *testing.T) {
+	repo := rulesRepo(Rule{ID: uuid.New(), Name: "exfil", Pattern: "upload", Action: ActionAlert, Severity: SeverityCritical, Enabled: true})
+	alerter := &recordingAlerter{}
+	e := NewEnforcer(NewRuleEngine(repo), nil, nil, alerter, nil)
+
+	serverID := uuid.New()
+	if err := e.Enforce(context.Background(), &Request{ServerID: serverID, Method: "tools/call", Target: "upload"}); err != nil {
+		t.Fatalf("expected alert rule to allow the request, got %v", err)
+	}
+	if len(alerter.alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerter.alerts))
+	}
+	a := alerter.alerts[0]
+	if a.Kind != AlertKindRule || a.Severity != SeverityCritical || *a.ServerID != serverID {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+}
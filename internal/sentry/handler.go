@@ -1,7 +1,10 @@
 package sentry
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -13,8 +16,11 @@ import (
 
 // Handler exposes HTTP endpoints for the Firewall module.
 type Handler struct {
-	Service Service
-	AuthMW  func(http.Handler) http.Handler
+	Service    Service
+	Approvals  ApprovalQueue
+	Quarantine *QuarantinePolicy
+	Events     *Broker
+	AuthMW     func(http.Handler) http.Handler
 }
 
 // Routes returns a chi.Router with all Firewall routes mounted.
@@ -32,6 +38,11 @@ func (h *Handler) Routes() chi.Router {
 	r.Delete("/rules/{id}", h.DeleteRule)
 	r.Get("/budget", h.GetBudget)
 	r.Put("/budget", h.UpdateBudget)
+	r.Get("/alerts", h.ListAlerts)
+	r.Get("/approvals", h.ListApprovals)
+	r.Post("/approvals/{id}", h.DecideApproval)
+	r.Post("/quarantine/{serverID}/release", h.ReleaseServer)
+	r.Get("/events", h.StreamEvents)
 
 	return r
 }
@@ -164,3 +175,186 @@ func (h *Handler) UpdateBudget(w http.ResponseWriter, r *http.Request) {
 
 	respond.JSON(w, http.StatusOK, budget)
 }
+
+func (h *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mw.GetUserID(r.Context()))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	alerts, err := h.Service.ListAlerts(r.Context(), userID)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to list alerts")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, alerts)
+}
+
+func (h *Handler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	if h.Approvals == nil {
+		respond.Error(w, http.StatusNotImplemented, "approvals not configured")
+		return
+	}
+
+	userID, err := uuid.Parse(mw.GetUserID(r.Context()))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	status := ApprovalStatus(r.URL.Query().Get("status"))
+	approvals, err := h.Approvals.List(r.Context(), status, userID)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, "failed to list approvals")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, approvals)
+}
+
+func (h *Handler) DecideApproval(w http.ResponseWriter, r *http.Request) {
+	if h.Approvals == nil {
+		respond.Error(w, http.StatusNotImplemented, "approvals not configured")
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid approval id")
+		return
+	}
+
+	approverID, err := uuid.Parse(mw.GetUserID(r.Context()))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req struct {
+		Decision string `json:"decision"` // "approve" or "deny"
+	}
+	if !respond.Decode(w, r, &req) {
+		return
+	}
+	if req.Decision != "approve" && req.Decision != "deny" {
+		respond.Error(w, http.StatusBadRequest, "decision must be approve or deny")
+		return
+	}
+
+	approval, err := h.Approvals.Decide(r.Context(), id, approverID, req.Decision == "approve")
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			respond.Error(w, http.StatusNotFound, "not found")
+		case errors.Is(err, ErrApprovalClosed):
+			respond.Error(w, http.StatusConflict, "approval is no longer pending")
+		case errors.Is(err, ErrNotApprover):
+			respond.Error(w, http.StatusForbidden, "not an approver")
+		default:
+			respond.Error(w, http.StatusInternalServerError, "failed to decide approval")
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, approval)
+}
+
+func (h *Handler) ReleaseServer(w http.ResponseWriter, r *http.Request) {
+	if h.Quarantine == nil {
+		respond.Error(w, http.StatusNotImplemented, "quarantine not configured")
+		return
+	}
+
+	serverID, err := uuid.Parse(chi.URLParam(r, "serverID"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid server id")
+		return
+	}
+
+	adminID, err := uuid.Parse(mw.GetUserID(r.Context()))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := h.Quarantine.Release(r.Context(), serverID, adminID); err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			respond.Error(w, http.StatusNotFound, "not found")
+		case errors.Is(err, ErrNotQuarantined):
+			respond.Error(w, http.StatusConflict, "server is not quarantined")
+		case errors.Is(err, ErrNotAdmin):
+			respond.Error(w, http.StatusForbidden, "not a sentry admin")
+		default:
+			respond.Error(w, http.StatusInternalServerError, "failed to release server")
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]string{"status": "released"})
+}
+
+// StreamEvents streams Sentry events to the client as Server-Sent Events.
+// Approvers receive every event; other users only those about their own
+// requests and the approvals they may decide.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	if h.Events == nil {
+		respond.Error(w, http.StatusNotImplemented, "event stream not configured")
+		return
+	}
+
+	userID, err := uuid.Parse(mw.GetUserID(r.Context()))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respond.Error(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	events, cancel := h.Events.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			if !h.streamable(r.Context(), event, userID) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// streamable reports whether the event may be streamed to userID.
+func (h *Handler) streamable(ctx context.Context, event *Event, userID uuid.UUID) bool {
+	if h.Approvals != nil && h.Approvals.IsApprover(userID) {
+		return true
+	}
+	switch data := event.Data.(type) {
+	case *Approval:
+		return h.Approvals != nil && h.Approvals.Visible(ctx, data, userID)
+	case *Alert:
+		return data.UserID == userID
+	default:
+		return false
+	}
+}
@@ -1,12 +1,14 @@
 package sentry
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -38,6 +40,7 @@ type mockService struct {
 	DeleteRuleFn       func(ctx context.Context, id uuid.UUID) error
 	GetBudgetFn        func(ctx context.Context, userID uuid.UUID) (*BudgetCap, error)
 	UpdateBudgetFn     func(ctx context.Context, budget *BudgetCap) error
+	ListAlertsFn       func(ctx context.Context, userID uuid.UUID) ([]Alert, error)
 }
 
 func (m *mockService) ListAuditEntries(ctx context.Context, userID uuid.UUID) ([]AuditEntry, error) {
@@ -67,6 +70,9 @@ func (m *mockService) GetBudget(ctx context.Context, userID uuid.UUID) (*BudgetC
 func (m *mockService) UpdateBudget(ctx context.Context, budget *BudgetCap) error {
 	return m.UpdateBudgetFn(ctx, budget)
 }
+func (m *mockService) ListAlerts(ctx context.Context, userID uuid.UUID) ([]Alert, error) {
+	return m.ListAlertsFn(ctx, userID)
+}
 
 func newTestHandler(svc *mockService) *Handler {
 	return &Handler{
@@ -312,3 +318,195 @@ func TestListAuditHandlerUnauthorized(t *testing.T) {
 		t.Fatalf("expected 401, got %d: %s", rec.Code, rec.Body.String())
 	}
 }
+
+// mockApprovals implements ApprovalQueue with function fields for handler tests.
+type mockApprovals struct {
+	AwaitFn  func(ctx context.Context, approval *Approval) (ApprovalStatus, error)
+	DecideFn func(ctx context.Context, id, approverID uuid.UUID, approve bool) (*Approval, error)
+	ListFn   func(ctx context.Context, status ApprovalStatus, userID uuid.UUID) ([]Approval, error)
+}
+
+func (m *mockApprovals) Await(ctx context.Context, approval *Approval) (ApprovalStatus, error) {
+	return m.AwaitFn(ctx, approval)
+}
+func (m *mockApprovals) Decide(ctx context.Context, id, approverID uuid.UUID, approve bool) (*Approval, error) {
+	return m.DecideFn(ctx, id, approverID, approve)
+}
+func (m *mockApprovals) List(ctx context.Context, status ApprovalStatus, userID uuid.UUID) ([]Approval, error) {
+	return m.ListFn(ctx, status, userID)
+}
+func (m *mockApprovals) Visible(_ context.Context, approval *Approval, userID uuid.UUID) bool {
+	return approval.UserID == userID
+}
+func (m *mockApprovals) IsApprover(uuid.UUID) bool { return false }
+
+func TestListApprovalsHandler(t *testing.T) {
+	userID := uuid.New()
+	approvals := &mockApprovals{
+		ListFn: func(_ context.Context, status ApprovalStatus, caller uuid.UUID) ([]Approval, error) {
+			if status != ApprovalPending || caller != userID {
+				t.Errorf("expected the caller's pending approvals, got %q for %s", status, caller)
+			}
+			return []Approval{{Method: "tools/call", Status: ApprovalPending}}, nil
+		},
+	}
+	h := &Handler{Service: &mockService{}, Approvals: approvals, AuthMW: middleware.Auth(handlerTestSecret)}
+	router := h.Routes()
+
+	req := authenticatedRequest(http.MethodGet, "/approvals?status=pending", nil, userID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestStreamEventsHandlerOnlySendsCallersEvents(t *testing.T) {
+	userID := uuid.New()
+	events := NewBroker()
+	h := &Handler{Service: &mockService{}, Approvals: &mockApprovals{}, Events: events, AuthMW: middleware.Auth(handlerTestSecret)}
+	srv := httptest.NewServer(h.Routes())
+	defer srv.Close()
+
+	req := authenticatedRequest(http.MethodGet, srv.URL+"/events", nil, userID.String())
+	req.RequestURI = ""
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	other := uuid.New()
+	events.Notify(context.Background(), NewEvent(EventApprovalRequested, &Approval{UserID: other, Params: json.RawMessage(`{"secret":true}`)}))
+	events.Notify(context.Background(), NewEvent(EventAlertRaised, &Alert{UserID: other}))
+	events.Notify(context.Background(), NewEvent(EventServerQuarantined, map[string]string{"server_id": uuid.New().String()}))
+	events.Notify(context.Background(), NewEvent(EventAlertRaised, &Alert{UserID: userID, Message: "mine"}))
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "event: ")
+		if !ok {
+			continue
+		}
+		if line != EventAlertRaised || !scanner.Scan() || !strings.Contains(scanner.Text(), `"mine"`) {
+			t.Fatalf("expected the caller's alert as the first event, got %s %s", line, scanner.Text())
+		}
+		return
+	}
+	t.Fatalf("stream ended without an event: %v", scanner.Err())
+}
+
+func TestDecideApprovalHandler(t *testing.T) {
+	userID := uuid.New()
+	approvalID := uuid.New()
+	approvals := &mockApprovals{
+		DecideFn: func(_ context.Context, id, approverID uuid.UUID, approve bool) (*Approval, error) {
+			if id != approvalID || approverID != userID || !approve {
+				t.Errorf("unexpected decision: id=%s approver=%s approve=%v", id, approverID, approve)
+			}
+			return &Approval{ID: id, Status: ApprovalApproved}, nil
+		},
+	}
+	h := &Handler{Service: &mockService{}, Approvals: approvals, AuthMW: middleware.Auth(handlerTestSecret)}
+	router := h.Routes()
+
+	body := `{"decision":"approve"}`
+	req := authenticatedRequest(http.MethodPost, "/approvals/"+approvalID.String(), bytes.NewBufferString(body), userID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDecideApprovalHandlerClosed(t *testing.T) {
+	userID := uuid.New()
+	approvals := &mockApprovals{
+		DecideFn: func(_ context.Context, _, _ uuid.UUID, _ bool) (*Approval, error) {
+			return nil, ErrApprovalClosed
+		},
+	}
+	h := &Handler{Service: &mockService{}, Approvals: approvals, AuthMW: middleware.Auth(handlerTestSecret)}
+	router := h.Routes()
+
+	body := `{"decision":"deny"}`
+	req := authenticatedRequest(http.MethodPost, "/approvals/"+uuid.New().String(), bytes.NewBufferString(body), userID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDecideApprovalHandlerBadDecision(t *testing.T) {
+	userID := uuid.New()
+	h := &Handler{Service: &mockService{}, Approvals: &mockApprovals{}, AuthMW: middleware.Auth(handlerTestSecret)}
+	router := h.Routes()
+
+	body := `{"decision":"maybe"}`
+	req := authenticatedRequest(http.MethodPost, "/approvals/"+uuid.New().String(), bytes.NewBufferString(body), userID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestListAlertsHandler(t *testing.T) {
+	userID := uuid.New()
+	svc := &mockService{
+		ListAlertsFn: func(_ context.Context, id uuid.UUID) ([]Alert, error) {
+			if id != userID {
+				t.Errorf("expected userID %s, got %s", userID, id)
+			}
+			return []Alert{{Kind: AlertKindAnomaly, Severity: SeverityHigh}}, nil
+		},
+	}
+	h := newTestHandler(svc)
+	router := h.Routes()
+
+	req := authenticatedRequest(http.MethodGet, "/alerts", nil, userID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var alerts []Alert
+	if err := json.NewDecoder(rec.Body).Decode(&alerts); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Errorf("expected 1 alert, got %d", len(alerts))
+	}
+}
+
+func TestReleaseServerHandler(t *testing.T) {
+	admin := uuid.New()
+	ctrl := &mockController{ReleaseFn: func(_ context.Context, _ uuid.UUID) error { return ErrNotQuarantined }}
+	policy := NewQuarantinePolicy(QuarantineConfig{Threshold: 1, Window: time.Minute, MinSeverity: SeverityHigh}, ctrl, nil, nil, []uuid.UUID{admin})
+	h := &Handler{Service: &mockService{}, Quarantine: policy, AuthMW: middleware.Auth(handlerTestSecret)}
+	router := h.Routes()
+
+	tests := []struct {
+		user uuid.UUID
+		want int
+	}{
+		{uuid.New(), http.StatusForbidden},
+		{admin, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		req := authenticatedRequest(http.MethodPost, "/quarantine/"+uuid.New().String()+"/release", nil, tt.user.String())
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+		}
+	}
+}
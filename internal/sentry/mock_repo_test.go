@@ -16,6 +16,12 @@ type mockRepo struct {
 	DeleteRuleFn       func(ctx context.Context, id uuid.UUID) error
 	GetBudgetFn        func(ctx context.Context, userID uuid.UUID) (*BudgetCap, error)
 	UpdateBudgetFn     func(ctx context.Context, budget *BudgetCap) error
+	ListApprovalsFn    func(ctx context.Context, status ApprovalStatus) ([]Approval, error)
+	GetApprovalFn      func(ctx context.Context, id uuid.UUID) (*Approval, error)
+	CreateApprovalFn   func(ctx context.Context, approval *Approval) error
+	UpdateApprovalFn   func(ctx context.Context, approval *Approval) error
+	ListAlertsFn       func(ctx context.Context, userID uuid.UUID) ([]Alert, error)
+	CreateAlertFn      func(ctx context.Context, alert *Alert) error
 }
 
 func (m *mockRepo) ListAuditEntries(ctx context.Context, userID uuid.UUID) ([]AuditEntry, error) {
@@ -53,3 +59,27 @@ func (m *mockRepo) GetBudget(ctx context.Context, userID uuid.UUID) (*BudgetCap,
 func (m *mockRepo) UpdateBudget(ctx context.Context, budget *BudgetCap) error {
 	return m.UpdateBudgetFn(ctx, budget)
 }
+
+func (m *mockRepo) ListApprovals(ctx context.Context, status ApprovalStatus) ([]Approval, error) {
+	return m.ListApprovalsFn(ctx, status)
+}
+
+func (m *mockRepo) GetApproval(ctx context.Context, id uuid.UUID) (*Approval, error) {
+	return m.GetApprovalFn(ctx, id)
+}
+
+func (m *mockRepo) CreateApproval(ctx context.Context, approval *Approval) error {
+	return m.CreateApprovalFn(ctx, approval)
+}
+
+func (m *mockRepo) UpdateApproval(ctx context.Context, approval *Approval) error {
+	return m.UpdateApprovalFn(ctx, approval)
+}
+
+func (m *mockRepo) ListAlerts(ctx context.Context, userID uuid.UUID) ([]Alert, error) {
+	return m.ListAlertsFn(ctx, userID)
+}
+
+func (m *mockRepo) CreateAlert(ctx context.Context, alert *Alert) error {
+	return m.CreateAlertFn(ctx, alert)
+}
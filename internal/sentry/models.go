@@ -1,6 +1,7 @@
 package sentry
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,12 +23,21 @@ type Rule struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description,omitempty"`
 	Pattern     string    `json:"pattern"`
-	Action      string    `json:"action"` // "block", "allow", "alert"
+	Action      string    `json:"action"`   // "block", "allow", "alert", "require_approval"
+	Severity    string    `json:"severity"` // "low", "medium", "high", "critical"
 	Enabled     bool      `json:"enabled"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// Rule actions.
+const (
+	ActionAllow           = "allow"
+	ActionAlert           = "alert"
+	ActionBlock           = "block"
+	ActionRequireApproval = "require_approval"
+)
+
 // BudgetCap tracks token usage budgets per user.
 type BudgetCap struct {
 	ID         uuid.UUID `json:"id"`
@@ -39,12 +49,55 @@ type BudgetCap struct {
 	CreatedAt  time.Time `json:"created_at"`
 }
 
-// Alert represents an alert triggered by a firewall rule.
+// Alert represents an alert raised by a firewall rule, the anomaly detector, a
+// failed OAuth token refresh or a refused egress request.
 type Alert struct {
-	ID        uuid.UUID `json:"id"`
-	RuleID    uuid.UUID `json:"rule_id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Message   string    `json:"message"`
-	Severity  string    `json:"severity"` // "low", "medium", "high", "critical"
-	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID      `json:"id"`
+	RuleID    *uuid.UUID     `json:"rule_id,omitempty"`
+	UserID    uuid.UUID      `json:"user_id"`
+	ServerID  *uuid.UUID     `json:"server_id,omitempty"`
+	Kind      string         `json:"kind"` // "rule", "anomaly", "oauth", "egress"
+	Message   string         `json:"message"`
+	Severity  string         `json:"severity"` // "low", "medium", "high", "critical"
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Alert kinds and severities.
+const (
+	AlertKindRule    = "rule"
+	AlertKindAnomaly = "anomaly"
+	AlertKindOAuth   = "oauth"
+	AlertKindEgress  = "egress"
+
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// ApprovalStatus represents the lifecycle state of an approval request.
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalDenied   ApprovalStatus = "denied"
+	ApprovalExpired  ApprovalStatus = "expired"
+)
+
+// Approval is a held MCP request awaiting a human decision.
+type Approval struct {
+	ID        uuid.UUID       `json:"id"`
+	RuleID    *uuid.UUID      `json:"rule_id,omitempty"`
+	UserID    uuid.UUID       `json:"user_id"`
+	ServerID  uuid.UUID       `json:"server_id"`
+	Method    string          `json:"method"`
+	Target    string          `json:"target,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Status    ApprovalStatus  `json:"status"`
+	DecidedBy *uuid.UUID      `json:"decided_by,omitempty"`
+	DecidedAt *time.Time      `json:"decided_at,omitempty"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	CreatedAt time.Time       `json:"created_at"`
 }
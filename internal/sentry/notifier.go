@@ -0,0 +1,144 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types published by Sentry.
+const (
+	EventApprovalRequested = "approval.requested"
+	EventApprovalDecided   = "approval.decided"
+)
+
+// Event is a Sentry notification delivered to webhooks and SSE subscribers.
+type Event struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	Data      any       `json:"data"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notifier delivers Sentry events to interested parties. Implementations must
+// not block the caller for long; delivery is best-effort.
+type Notifier interface {
+	Notify(ctx context.Context, event *Event)
+}
+
+// NewEvent creates an event with a fresh ID and timestamp.
+func NewEvent(eventType string, data any) *Event {
+	return &Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+}
+
+// Notifiers fans an event out to several notifiers.
+type Notifiers []Notifier
+
+func (ns Notifiers) Notify(ctx context.Context, event *Event) {
+	for _, n := range ns {
+		if n != nil {
+			n.Notify(ctx, event)
+		}
+	}
+}
+
+// Broker is an in-process pub/sub hub used to stream events over SSE.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[chan *Event]struct{}
+}
+
+// NewBroker creates an empty event broker.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[chan *Event]struct{})}
+}
+
+// Subscribe registers a new subscriber. The returned cancel func must be
+// called to release it.
+func (b *Broker) Subscribe() (<-chan *Event, func()) {
+	ch := make(chan *Event, 16)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+}
+
+// Notify delivers the event to every subscriber. Slow subscribers miss events
+// rather than stalling the publisher.
+func (b *Broker) Notify(_ context.Context, event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// WebhookNotifier POSTs events as JSON to a configured URL.
+type WebhookNotifier struct {
+	url  string
+	http *http.Client
+}
+
+// NewWebhookNotifier creates a notifier that delivers events to url.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:  url,
+		http: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify sends the event asynchronously; failures are logged.
+func (n *WebhookNotifier) Notify(_ context.Context, event *Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("marshalling sentry event", "type", event.Type, "error", err)
+		return
+	}
+
+	go func() {
+		if err := n.post(body); err != nil {
+			slog.Error("delivering sentry webhook", "type", event.Type, "error", err)
+		}
+	}()
+}
+
+func (n *WebhookNotifier) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
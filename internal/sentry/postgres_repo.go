@@ -73,7 +73,7 @@ func (r *PgRepository) CreateAuditEntry(ctx context.Context, entry *AuditEntry)
 
 func (r *PgRepository) ListRules(ctx context.Context) ([]Rule, error) {
 	rows, err := r.pool.Query(ctx,
-		`SELECT id, name, description, pattern, action, enabled, created_at, updated_at
+		`SELECT id, name, description, pattern, action, severity, enabled, created_at, updated_at
 		 FROM sentry_rules
 		 ORDER BY created_at DESC`,
 	)
@@ -85,7 +85,7 @@ func (r *PgRepository) ListRules(ctx context.Context) ([]Rule, error) {
 	var rules []Rule
 	for rows.Next() {
 		var rule Rule
-		if err := rows.Scan(&rule.ID, &rule.Name, &rule.Description, &rule.Pattern, &rule.Action, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.Description, &rule.Pattern, &rule.Action, &rule.Severity, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
 			return nil, err
 		}
 		rules = append(rules, rule)
@@ -103,10 +103,10 @@ func (r *PgRepository) ListRules(ctx context.Context) ([]Rule, error) {
 func (r *PgRepository) GetRule(ctx context.Context, id uuid.UUID) (*Rule, error) {
 	var rule Rule
 	err := r.pool.QueryRow(ctx,
-		`SELECT id, name, description, pattern, action, enabled, created_at, updated_at
+		`SELECT id, name, description, pattern, action, severity, enabled, created_at, updated_at
 		 FROM sentry_rules WHERE id = $1`,
 		id,
-	).Scan(&rule.ID, &rule.Name, &rule.Description, &rule.Pattern, &rule.Action, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt)
+	).Scan(&rule.ID, &rule.Name, &rule.Description, &rule.Pattern, &rule.Action, &rule.Severity, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNotFound
 	}
@@ -118,18 +118,18 @@ func (r *PgRepository) GetRule(ctx context.Context, id uuid.UUID) (*Rule, error)
 
 func (r *PgRepository) CreateRule(ctx context.Context, rule *Rule) error {
 	_, err := r.pool.Exec(ctx,
-		`INSERT INTO sentry_rules (id, name, description, pattern, action, enabled, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		rule.ID, rule.Name, rule.Description, rule.Pattern, rule.Action, rule.Enabled, rule.CreatedAt, rule.UpdatedAt,
+		`INSERT INTO sentry_rules (id, name, description, pattern, action, severity, enabled, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		rule.ID, rule.Name, rule.Description, rule.Pattern, rule.Action, rule.Severity, rule.Enabled, rule.CreatedAt, rule.UpdatedAt,
 	)
 	return err
 }
 
 func (r *PgRepository) UpdateRule(ctx context.Context, rule *Rule) error {
 	tag, err := r.pool.Exec(ctx,
-		`UPDATE sentry_rules SET name = $2, description = $3, pattern = $4, action = $5, enabled = $6, updated_at = $7
+		`UPDATE sentry_rules SET name = $2, description = $3, pattern = $4, action = $5, severity = $6, enabled = $7, updated_at = $8
 		 WHERE id = $1`,
-		rule.ID, rule.Name, rule.Description, rule.Pattern, rule.Action, rule.Enabled, rule.UpdatedAt,
+		rule.ID, rule.Name, rule.Description, rule.Pattern, rule.Action, rule.Severity, rule.Enabled, rule.UpdatedAt,
 	)
 	if err != nil {
 		return err
@@ -180,3 +180,135 @@ func (r *PgRepository) UpdateBudget(ctx context.Context, budget *BudgetCap) erro
 	)
 	return err
 }
+
+func (r *PgRepository) ListApprovals(ctx context.Context, status ApprovalStatus) ([]Approval, error) {
+	var rows pgx.Rows
+	var err error
+
+	if status == "" {
+		rows, err = r.pool.Query(ctx,
+			`SELECT id, rule_id, user_id, server_id, method, target, params, status, decided_by, decided_at, expires_at, created_at
+			 FROM sentry_approvals ORDER BY created_at DESC`)
+	} else {
+		rows, err = r.pool.Query(ctx,
+			`SELECT id, rule_id, user_id, server_id, method, target, params, status, decided_by, decided_at, expires_at, created_at
+			 FROM sentry_approvals WHERE status = $1
+			 ORDER BY created_at DESC`, status)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []Approval
+	for rows.Next() {
+		var a Approval
+		if err := rows.Scan(&a.ID, &a.RuleID, &a.UserID, &a.ServerID, &a.Method, &a.Target, &a.Params, &a.Status, &a.DecidedBy, &a.DecidedAt, &a.ExpiresAt, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if approvals == nil {
+		approvals = []Approval{}
+	}
+	return approvals, nil
+}
+
+func (r *PgRepository) GetApproval(ctx context.Context, id uuid.UUID) (*Approval, error) {
+	var a Approval
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, rule_id, user_id, server_id, method, target, params, status, decided_by, decided_at, expires_at, created_at
+		 FROM sentry_approvals WHERE id = $1`,
+		id,
+	).Scan(&a.ID, &a.RuleID, &a.UserID, &a.ServerID, &a.Method, &a.Target, &a.Params, &a.Status, &a.DecidedBy, &a.DecidedAt, &a.ExpiresAt, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *PgRepository) CreateApproval(ctx context.Context, approval *Approval) error {
+	params := approval.Params
+	if len(params) == 0 {
+		params = json.RawMessage(`{}`)
+	}
+
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO sentry_approvals (id, rule_id, user_id, server_id, method, target, params, status, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		approval.ID, approval.RuleID, approval.UserID, approval.ServerID, approval.Method, approval.Target, []byte(params), approval.Status, approval.ExpiresAt, approval.CreatedAt,
+	)
+	return err
+}
+
+func (r *PgRepository) UpdateApproval(ctx context.Context, approval *Approval) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE sentry_approvals SET status = $2, decided_by = $3, decided_at = $4
+		 WHERE id = $1`,
+		approval.ID, approval.Status, approval.DecidedBy, approval.DecidedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PgRepository) ListAlerts(ctx context.Context, userID uuid.UUID) ([]Alert, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, rule_id, user_id, server_id, kind, message, severity, metadata, created_at
+		 FROM sentry_alerts WHERE user_id = $1
+		 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+	for rows.Next() {
+		var a Alert
+		var metaBytes []byte
+		if err := rows.Scan(&a.ID, &a.RuleID, &a.UserID, &a.ServerID, &a.Kind, &a.Message, &a.Severity, &metaBytes, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if metaBytes != nil {
+			if err := json.Unmarshal(metaBytes, &a.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		alerts = append(alerts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if alerts == nil {
+		alerts = []Alert{}
+	}
+	return alerts, nil
+}
+
+func (r *PgRepository) CreateAlert(ctx context.Context, alert *Alert) error {
+	metaBytes, err := json.Marshal(alert.Metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.pool.Exec(ctx,
+		`INSERT INTO sentry_alerts (id, rule_id, user_id, server_id, kind, message, severity, metadata, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		alert.ID, alert.RuleID, alert.UserID, alert.ServerID, alert.Kind, alert.Message, alert.Severity, metaBytes, alert.CreatedAt,
+	)
+	return err
+}
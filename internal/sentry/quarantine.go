@@ -0,0 +1,229 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Quarantine events.
+const (
+	EventServerQuarantined = "server.quarantined"
+	EventServerReleased    = "server.released"
+)
+
+// ErrNotQuarantined is returned when releasing a server that is not quarantined.
+var ErrNotQuarantined = errors.New("server is not quarantined")
+
+// ErrNotAdmin is returned when a user who is not a Sentry admin releases a
+// quarantined server.
+var ErrNotAdmin = errors.New("user is not a sentry admin")
+
+// QuarantineController isolates and releases MCP servers. It is implemented by
+// the nodes module so Sentry does not depend on it.
+type QuarantineController interface {
+	// Quarantine marks the server quarantined, refusing new connections and
+	// closing existing ones.
+	Quarantine(ctx context.Context, serverID uuid.UUID, reason string) error
+	// Release returns a quarantined server to service. It returns
+	// ErrNotQuarantined if the server is not quarantined.
+	Release(ctx context.Context, serverID uuid.UUID) error
+	// Quarantined returns the IDs of the servers currently quarantined.
+	Quarantined(ctx context.Context) ([]uuid.UUID, error)
+}
+
+// QuarantineConfig is the policy deciding when a server is quarantined.
+type QuarantineConfig struct {
+	// Threshold is the number of qualifying alerts within Window that
+	// quarantines a server.
+	Threshold int
+	Window    time.Duration
+	// MinSeverity is the lowest alert severity that counts towards Threshold.
+	MinSeverity string
+}
+
+// severityRank orders alert severities from least to most severe.
+var severityRank = map[string]int{
+	SeverityLow:      0,
+	SeverityMedium:   1,
+	SeverityHigh:     2,
+	SeverityCritical: 3,
+}
+
+// QuarantinePolicy watches raised alerts and quarantines servers that
+// repeatedly trip high-severity checks on their own output, such as egress
+// requests to destinations they may not reach. It is a Notifier so it
+// can be attached to an Alerter alongside other subscribers.
+type QuarantinePolicy struct {
+	cfg        QuarantineConfig
+	controller QuarantineController
+	audit      AuditLogger
+	notifier   Notifier
+	admins     map[uuid.UUID]bool
+
+	mu          sync.Mutex
+	hits        map[uuid.UUID][]time.Time
+	quarantined map[uuid.UUID]bool
+}
+
+// NewQuarantinePolicy creates a quarantine policy. Quarantines and releases are
+// written to audit and published to notifier, either of which may be nil.
+// Only admins may release a server.
+func NewQuarantinePolicy(cfg QuarantineConfig, controller QuarantineController, audit AuditLogger, notifier Notifier, admins []uuid.UUID) *QuarantinePolicy {
+	p := &QuarantinePolicy{
+		cfg:         cfg,
+		controller:  controller,
+		audit:       audit,
+		notifier:    notifier,
+		admins:      make(map[uuid.UUID]bool, len(admins)),
+		hits:        make(map[uuid.UUID][]time.Time),
+		quarantined: make(map[uuid.UUID]bool),
+	}
+	for _, id := range admins {
+		p.admins[id] = true
+	}
+	return p
+}
+
+// Load marks the servers the controller reports as quarantined, so that
+// quarantines from before a restart are not counted again.
+func (p *QuarantinePolicy) Load(ctx context.Context) error {
+	ids, err := p.controller.Quarantined(ctx)
+	if err != nil {
+		return fmt.Errorf("listing quarantined servers: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range ids {
+		p.quarantined[id] = true
+	}
+	return nil
+}
+
+// quarantineKinds are the alert kinds that count towards quarantine: those
+// about the server's own output. Rule and anomaly alerts are raised on client
+// requests, so any client could trip them on purpose to take a server out of
+// service, and OAuth alerts say nothing about the server's behavior.
+var quarantineKinds = map[string]bool{
+	AlertKindEgress: true,
+}
+
+// Notify counts alert.raised events about a server's own output towards its
+// quarantine threshold. Other events and alerts are ignored.
+func (p *QuarantinePolicy) Notify(ctx context.Context, event *Event) {
+	if event.Type != EventAlertRaised {
+		return
+	}
+	alert, ok := event.Data.(*Alert)
+	if !ok || alert.ServerID == nil || !quarantineKinds[alert.Kind] || severityRank[alert.Severity] < severityRank[p.cfg.MinSeverity] {
+		return
+	}
+
+	serverID := *alert.ServerID
+	if !p.record(serverID, event.Timestamp) {
+		return
+	}
+
+	reason := fmt.Sprintf("%d %s+ alerts within %s", p.cfg.Threshold, p.cfg.MinSeverity, p.cfg.Window)
+	if err := p.controller.Quarantine(context.WithoutCancel(ctx), serverID, reason); err != nil {
+		slog.Error("quarantining server", "server_id", serverID, "error", err)
+		p.mu.Lock()
+		delete(p.quarantined, serverID)
+		p.mu.Unlock()
+		return
+	}
+
+	userID := alert.UserID
+	p.log(ctx, "server.quarantined", &userID, serverID, map[string]any{
+		"reason":   reason,
+		"alert_id": alert.ID.String(),
+	})
+	p.publish(ctx, EventServerQuarantined, serverID, reason)
+}
+
+// record adds an alert hit for serverID and reports whether it crossed the
+// threshold, in which case the server is marked as quarantined.
+func (p *QuarantinePolicy) record(serverID uuid.UUID, at time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.quarantined[serverID] {
+		return false
+	}
+
+	cutoff := at.Add(-p.cfg.Window)
+	hits := p.hits[serverID][:0]
+	for _, t := range p.hits[serverID] {
+		if t.After(cutoff) {
+			hits = append(hits, t)
+		}
+	}
+	hits = append(hits, at)
+
+	if len(hits) < p.cfg.Threshold {
+		p.hits[serverID] = hits
+		return false
+	}
+	delete(p.hits, serverID)
+	p.quarantined[serverID] = true
+	return true
+}
+
+// Release returns a quarantined server to service on behalf of adminID, who
+// must be an admin. The server's owner may not release it: a quarantine is
+// the reaction to that server misbehaving, and the owner is the one who
+// deployed it.
+func (p *QuarantinePolicy) Release(ctx context.Context, serverID, adminID uuid.UUID) error {
+	if !p.admins[adminID] {
+		return ErrNotAdmin
+	}
+
+	if err := p.controller.Release(ctx, serverID); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	delete(p.quarantined, serverID)
+	delete(p.hits, serverID)
+	p.mu.Unlock()
+
+	p.log(ctx, "server.released", &adminID, serverID, nil)
+	p.publish(ctx, EventServerReleased, serverID, "")
+	return nil
+}
+
+func (p *QuarantinePolicy) log(ctx context.Context, action string, userID *uuid.UUID, serverID uuid.UUID, meta map[string]any) {
+	if p.audit == nil {
+		return
+	}
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	meta["server_id"] = serverID.String()
+
+	entry := &AuditEntry{
+		UserID:   userID,
+		Action:   action,
+		Resource: serverID.String(),
+		Metadata: meta,
+	}
+	if err := p.audit.Log(context.WithoutCancel(ctx), entry); err != nil {
+		slog.Error("writing sentry audit entry", "action", action, "error", err)
+	}
+}
+
+func (p *QuarantinePolicy) publish(ctx context.Context, eventType string, serverID uuid.UUID, reason string) {
+	if p.notifier == nil {
+		return
+	}
+	data := map[string]string{"server_id": serverID.String()}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	p.notifier.Notify(ctx, NewEvent(eventType, data))
+}
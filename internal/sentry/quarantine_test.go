@@ -0,0 +1,156 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mockController records quarantine and release calls.
+type mockController struct {
+	quarantined []uuid.UUID
+	ReleaseFn   func(ctx context.Context, serverID uuid.UUID) error
+}
+
+func (m *mockController) Quarantined(context.Context) ([]uuid.UUID, error) {
+	return m.quarantined, nil
+}
+
+func (m *mockController) Quarantine(_ context.Context, serverID uuid.UUID, _ string) error {
+	m.quarantined = append(m.quarantined, serverID)
+	return nil
+}
+
+func (m *mockController) Release(ctx context.Context, serverID uuid.UUID) error {
+	if m.ReleaseFn != nil {
+		return m.ReleaseFn(ctx, serverID)
+	}
+	return nil
+}
+
+func alertEvent(serverID uuid.UUID, severity string, at time.Time) *Event {
+	e := NewEvent(EventAlertRaised, &Alert{ID: uuid.New(), UserID: uuid.New(), ServerID: &serverID, Kind: AlertKindEgress, Severity: severity})
+	e.Timestamp = at
+	return e
+}
+
+func TestQuarantinePolicyThreshold(t *testing.T) {
+	var audited []string
+	repo := &mockRepo{CreateAuditEntryFn: func(_ context.Context, e *AuditEntry) error {
+		audited = append(audited, e.Action)
+		return nil
+	}}
+	ctrl := &mockController{}
+	p := NewQuarantinePolicy(QuarantineConfig{Threshold: 3, Window: time.Minute, MinSeverity: SeverityHigh}, ctrl, NewAuditLogger(repo), nil, nil)
+
+	server := uuid.New()
+	start := time.Now()
+	p.Notify(context.Background(), alertEvent(server, SeverityHigh, start))
+	p.Notify(context.Background(), alertEvent(server, SeverityMedium, start.Add(time.Second)))
+	p.Notify(context.Background(), alertEvent(server, SeverityCritical, start.Add(2*time.Second)))
+	if len(ctrl.quarantined) != 0 {
+		t.Fatalf("expected no quarantine below threshold, got %v", ctrl.quarantined)
+	}
+
+	p.Notify(context.Background(), alertEvent(server, SeverityHigh, start.Add(3*time.Second)))
+	if len(ctrl.quarantined) != 1 || ctrl.quarantined[0] != server {
+		t.Fatalf("expected server to be quarantined, got %v", ctrl.quarantined)
+	}
+	if len(audited) != 1 || audited[0] != "server.quarantined" {
+		t.Errorf("expected server.quarantined audit entry, got %v", audited)
+	}
+
+	// Further alerts on a quarantined server are not acted on again.
+	p.Notify(context.Background(), alertEvent(server, SeverityHigh, start.Add(4*time.Second)))
+	if len(ctrl.quarantined) != 1 {
+		t.Errorf("expected a single quarantine, got %d", len(ctrl.quarantined))
+	}
+}
+
+func TestQuarantinePolicyWindow(t *testing.T) {
+	ctrl := &mockController{}
+	p := NewQuarantinePolicy(QuarantineConfig{Threshold: 2, Window: time.Minute, MinSeverity: SeverityHigh}, ctrl, nil, nil, nil)
+
+	server := uuid.New()
+	start := time.Now()
+	p.Notify(context.Background(), alertEvent(server, SeverityHigh, start))
+	p.Notify(context.Background(), alertEvent(server, SeverityHigh, start.Add(2*time.Minute)))
+	if len(ctrl.quarantined) != 0 {
+		t.Errorf("expected alerts outside the window not to accumulate, got %v", ctrl.quarantined)
+	}
+}
+
+func TestQuarantinePolicyIgnoresAlertsNotAboutServerOutput(t *testing.T) {
+	ctrl := &mockController{}
+	p := NewQuarantinePolicy(QuarantineConfig{Threshold: 1, Window: time.Minute, MinSeverity: SeverityLow}, ctrl, nil, nil, nil)
+
+	server := uuid.New()
+	// Rule and anomaly alerts are triggered by client requests.
+	for _, kind := range []string{AlertKindRule, AlertKindAnomaly, AlertKindOAuth} {
+		event := alertEvent(server, SeverityCritical, time.Now())
+		event.Data.(*Alert).Kind = kind
+		p.Notify(context.Background(), event)
+	}
+	if len(ctrl.quarantined) != 0 {
+		t.Errorf("expected only alerts about server output to count towards quarantine, got %v", ctrl.quarantined)
+	}
+}
+
+func TestQuarantinePolicyRelease(t *testing.T) {
+	admin := uuid.New()
+	ctrl := &mockController{}
+	p := NewQuarantinePolicy(QuarantineConfig{Threshold: 1, Window: time.Minute, MinSeverity: SeverityHigh}, ctrl, nil, nil, []uuid.UUID{admin})
+
+	server := uuid.New()
+	p.Notify(context.Background(), alertEvent(server, SeverityHigh, time.Now()))
+
+	if err := p.Release(context.Background(), server, uuid.New()); !errors.Is(err, ErrNotAdmin) {
+		t.Errorf("expected ErrNotAdmin for non-admin, got %v", err)
+	}
+	if err := p.Release(context.Background(), server, admin); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+
+	// A released server can be quarantined again.
+	p.Notify(context.Background(), alertEvent(server, SeverityHigh, time.Now()))
+	if len(ctrl.quarantined) != 2 {
+		t.Errorf("expected server to be re-quarantined, got %d quarantines", len(ctrl.quarantined))
+	}
+
+	ctrl.ReleaseFn = func(_ context.Context, _ uuid.UUID) error { return ErrNotQuarantined }
+	if err := p.Release(context.Background(), uuid.New(), admin); !errors.Is(err, ErrNotQuarantined) {
+		t.Errorf("expected ErrNotQuarantined, got %v", err)
+	}
+}
+
+func TestQuarantinePolicyReleaseRefusesNonAdmins(t *testing.T) {
+	server := uuid.New()
+	ctrl := &mockController{ReleaseFn: func(context.Context, uuid.UUID) error {
+		t.Error("expected the controller not to release the server")
+		return nil
+	}}
+	p := NewQuarantinePolicy(QuarantineConfig{Threshold: 1, Window: time.Minute, MinSeverity: SeverityHigh}, ctrl, nil, nil, nil)
+	p.Notify(context.Background(), alertEvent(server, SeverityHigh, time.Now()))
+
+	// Any other user, the server's owner included, is refused.
+	if err := p.Release(context.Background(), server, uuid.New()); !errors.Is(err, ErrNotAdmin) {
+		t.Errorf("expected ErrNotAdmin for a non-admin, got %v", err)
+	}
+}
+
+func TestQuarantinePolicyLoadsQuarantinedServers(t *testing.T) {
+	server := uuid.New()
+	ctrl := &mockController{quarantined: []uuid.UUID{server}}
+	p := NewQuarantinePolicy(QuarantineConfig{Threshold: 1, Window: time.Minute, MinSeverity: SeverityHigh}, ctrl, nil, nil, nil)
+	if err := p.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	p.Notify(context.Background(), alertEvent(server, SeverityHigh, time.Now()))
+	if len(ctrl.quarantined) != 1 {
+		t.Errorf("expected an already quarantined server not to be quarantined again, got %v", ctrl.quarantined)
+	}
+}
@@ -24,4 +24,10 @@ type Repository interface {
 	DeleteRule(ctx context.Context, id uuid.UUID) error
 	GetBudget(ctx context.Context, userID uuid.UUID) (*BudgetCap, error)
 	UpdateBudget(ctx context.Context, budget *BudgetCap) error
+	ListApprovals(ctx context.Context, status ApprovalStatus) ([]Approval, error)
+	GetApproval(ctx context.Context, id uuid.UUID) (*Approval, error)
+	CreateApproval(ctx context.Context, approval *Approval) error
+	UpdateApproval(ctx context.Context, approval *Approval) error
+	ListAlerts(ctx context.Context, userID uuid.UUID) ([]Alert, error)
+	CreateAlert(ctx context.Context, alert *Alert) error
 }
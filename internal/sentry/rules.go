@@ -8,6 +8,22 @@ import (
 // RuleEngine evaluates firewall rules against requests.
 type RuleEngine interface {
 	Evaluate(ctx context.Context, action, resource string) (bool, error)
+	Decide(ctx context.Context, action, resource string) (*Decision, error)
+}
+
+// Decision is the outcome of evaluating all enabled rules against a request.
+// Rule is nil when no rule matched.
+type Decision struct {
+	Action string
+	Rule   *Rule
+}
+
+// actionPrecedence orders rule actions from least to most restrictive.
+var actionPrecedence = map[string]int{
+	ActionAllow:           0,
+	ActionAlert:           1,
+	ActionRequireApproval: 2,
+	ActionBlock:           3,
 }
 
 type ruleEngine struct {
@@ -22,27 +38,46 @@ func NewRuleEngine(repo Repository) RuleEngine {
 // Evaluate loads all enabled rules and checks them against the given action and
 // resource. Returns false if any blocking rule matches, true otherwise.
 func (re *ruleEngine) Evaluate(ctx context.Context, action, resource string) (bool, error) {
-	rules, err := re.repo.ListRules(ctx)
+	d, err := re.Decide(ctx, action, resource)
 	if err != nil {
 		return false, err
 	}
+	return d.Action != ActionBlock, nil
+}
+
+// Decide loads all enabled rules and returns the most restrictive action among
+// those matching "action:resource". Precedence is block, require_approval,
+// alert, allow; with no match the decision is allow.
+func (re *ruleEngine) Decide(ctx context.Context, action, resource string) (*Decision, error) {
+	rules, err := re.repo.ListRules(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	subject := action + ":" + resource
+	decision := &Decision{Action: ActionAllow}
 
-	for _, rule := range rules {
+	for i := range rules {
+		rule := &rules[i]
 		if !rule.Enabled {
 			continue
 		}
 
+		rank, known := actionPrecedence[rule.Action]
+		if !known {
+			continue
+		}
+
 		matched, err := regexp.MatchString(rule.Pattern, subject)
 		if err != nil {
 			// Invalid pattern; skip this rule rather than blocking everything.
 			continue
 		}
-		if matched && rule.Action == "block" {
-			return false, nil
+		if matched && (decision.Rule == nil || rank > actionPrecedence[decision.Action]) {
+			decision.Action = rule.Action
+			decision.Rule = rule
 		}
 	}
 
-	return true, nil
+	return decision, nil
 }
@@ -0,0 +1,52 @@
+package sentry
+
+import "regexp"
+
+// SecretDetector finds one kind of credential in text. Its pattern may
+// capture a prefix in group 1 and a suffix in group 2, which are kept when
+// the match is redacted; everything else in the match is the secret.
+type SecretDetector struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultSecretDetectors cover common cloud, SaaS and generic credentials.
+var DefaultSecretDetectors = []SecretDetector{
+	{"private_key", regexp.MustCompile(`()-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+	{"aws_access_key", regexp.MustCompile(`()\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws_secret_key", regexp.MustCompile(`(?i)(aws_?secret_?access_?key["']?\s*[:=]\s*["']?)[A-Za-z0-9/+]{40}`)},
+	{"github_token", regexp.MustCompile(`()\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"slack_token", regexp.MustCompile(`()\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"stripe_key", regexp.MustCompile(`()\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}`)},
+	{"api_key", regexp.MustCompile(`()\bsk-[A-Za-z0-9_-]{20,}`)},
+	{"jwt", regexp.MustCompile(`()\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{"bearer_token", regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/-]{16,}=*`)},
+	{"url_password", regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+(@)`)},
+	{"credential", regexp.MustCompile(`(?i)(\b(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|client[_-]?secret)["']?\s*[:=]\s*["']?)[^\s"',;\[][^\s"',;]{3,}`)},
+}
+
+// Redactor replaces secrets in text with "[REDACTED:<detector>]".
+type Redactor struct {
+	detectors []SecretDetector
+}
+
+// NewRedactor creates a redactor using detectors, or DefaultSecretDetectors
+// if none are given.
+func NewRedactor(detectors ...SecretDetector) *Redactor {
+	if len(detectors) == 0 {
+		detectors = DefaultSecretDetectors
+	}
+	return &Redactor{detectors: detectors}
+}
+
+// Redact returns s with every detected secret replaced. Detectors run in
+// order, so secrets already redacted by one are not matched by later ones.
+func (r *Redactor) Redact(s string) string {
+	for _, d := range r.detectors {
+		if !d.Pattern.MatchString(s) {
+			continue
+		}
+		s = d.Pattern.ReplaceAllString(s, "${1}[REDACTED:"+d.Name+"]${2}")
+	}
+	return s
+}
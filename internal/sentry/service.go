@@ -18,6 +18,7 @@ type Service interface {
 	DeleteRule(ctx context.Context, id uuid.UUID) error
 	GetBudget(ctx context.Context, userID uuid.UUID) (*BudgetCap, error)
 	UpdateBudget(ctx context.Context, budget *BudgetCap) error
+	ListAlerts(ctx context.Context, userID uuid.UUID) ([]Alert, error)
 }
 
 type service struct {
@@ -52,11 +53,17 @@ func (s *service) CreateRule(ctx context.Context, rule *Rule) error {
 	rule.ID = uuid.New()
 	rule.CreatedAt = now
 	rule.UpdatedAt = now
+	if rule.Severity == "" {
+		rule.Severity = SeverityMedium
+	}
 	return s.repo.CreateRule(ctx, rule)
 }
 
 func (s *service) UpdateRule(ctx context.Context, rule *Rule) error {
 	rule.UpdatedAt = time.Now()
+	if rule.Severity == "" {
+		rule.Severity = SeverityMedium
+	}
 	return s.repo.UpdateRule(ctx, rule)
 }
 
@@ -71,3 +78,7 @@ func (s *service) GetBudget(ctx context.Context, userID uuid.UUID) (*BudgetCap,
 func (s *service) UpdateBudget(ctx context.Context, budget *BudgetCap) error {
 	return s.repo.UpdateBudget(ctx, budget)
 }
+
+func (s *service) ListAlerts(ctx context.Context, userID uuid.UUID) ([]Alert, error) {
+	return s.repo.ListAlerts(ctx, userID)
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -114,6 +115,44 @@ func (c *Client) RegisterServer(ctx context.Context, name, image string, config
 	return &server, nil
 }
 
+// UpdateServer changes a server's image or config, as a new revision. An
+// empty image keeps the current one; a non-nil config replaces the whole
+// config. A running server is switched over to the new revision before the
+// call returns.
+func (c *Client) UpdateServer(ctx context.Context, serverID, image string, config map[string]any) (*MCPServer, error) {
+	body := map[string]any{}
+	if image != "" {
+		body["image"] = image
+	}
+	if config != nil {
+		body["config"] = config
+	}
+	var server MCPServer
+	if err := c.doRequest(ctx, http.MethodPatch, "/api/v1/nodes/"+serverID, body, &server); err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// ListServerRevisions returns a server's revisions, newest first.
+func (c *Client) ListServerRevisions(ctx context.Context, serverID string) ([]ServerRevision, error) {
+	var revisions []ServerRevision
+	if err := c.doRequest(ctx, http.MethodGet, "/api/v1/nodes/"+serverID+"/revisions", nil, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// RollbackServer restores the image and config of an earlier revision as a
+// new revision.
+func (c *Client) RollbackServer(ctx context.Context, serverID string, revision int) (*MCPServer, error) {
+	var server MCPServer
+	if err := c.doRequest(ctx, http.MethodPost, "/api/v1/nodes/"+serverID+"/rollback", map[string]int{"revision": revision}, &server); err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
 // RemoveServer deletes an MCP server by ID.
 func (c *Client) RemoveServer(ctx context.Context, serverID string) error {
 	return c.doRequest(ctx, http.MethodDelete, "/api/v1/nodes/"+serverID, nil, nil)
@@ -129,6 +168,57 @@ func (c *Client) StopServer(ctx context.Context, serverID string) error {
 	return c.doRequest(ctx, http.MethodPost, "/api/v1/nodes/"+serverID+"/stop", nil, nil)
 }
 
+// ListAccessPolicies returns the access policies configured on a server.
+func (c *Client) ListAccessPolicies(ctx context.Context, serverID string) ([]AccessPolicy, error) {
+	var policies []AccessPolicy
+	if err := c.doRequest(ctx, http.MethodGet, "/api/v1/nodes/"+serverID+"/policies", nil, &policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// PutAccessPolicy sets the access policy for principal, a user ID or "default".
+func (c *Client) PutAccessPolicy(ctx context.Context, serverID, principal string, policy *AccessPolicy) (*AccessPolicy, error) {
+	var stored AccessPolicy
+	if err := c.doRequest(ctx, http.MethodPut, "/api/v1/nodes/"+serverID+"/policies/"+principal, policy, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// DeleteAccessPolicy removes the access policy for principal, a user ID or "default".
+func (c *Client) DeleteAccessPolicy(ctx context.Context, serverID, principal string) error {
+	return c.doRequest(ctx, http.MethodDelete, "/api/v1/nodes/"+serverID+"/policies/"+principal, nil, nil)
+}
+
+// ListOAuthGrants returns the OAuth grants the user authorized, only those of
+// serverID unless it is empty.
+func (c *Client) ListOAuthGrants(ctx context.Context, serverID string) ([]OAuthGrant, error) {
+	path := "/api/v1/nodes/oauth/grants"
+	if serverID != "" {
+		path += "?server_id=" + url.QueryEscape(serverID)
+	}
+	var grants []OAuthGrant
+	if err := c.doRequest(ctx, http.MethodGet, path, nil, &grants); err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// GetOAuthGrant returns a single OAuth grant by ID.
+func (c *Client) GetOAuthGrant(ctx context.Context, grantID string) (*OAuthGrant, error) {
+	var grant OAuthGrant
+	if err := c.doRequest(ctx, http.MethodGet, "/api/v1/nodes/oauth/grants/"+grantID, nil, &grant); err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// RevokeOAuthGrant revokes an OAuth grant at its provider and deletes it.
+func (c *Client) RevokeOAuthGrant(ctx context.Context, grantID string) error {
+	return c.doRequest(ctx, http.MethodDelete, "/api/v1/nodes/oauth/grants/"+grantID, nil, nil)
+}
+
 // Connect establishes a WebSocket connection to an MCP server.
 func (c *Client) Connect(ctx context.Context, serverID string) error {
 	wsURL := strings.Replace(c.baseURL, "http", "ws", 1) + "/api/v1/nodes/" + serverID + "/ws"
@@ -80,6 +80,56 @@ func TestRegisterServer(t *testing.T) {
 	}
 }
 
+func TestUpdateServer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v1/nodes/abc-123" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["config"]; ok || body["image"] != "img:v2" {
+			t.Errorf("expected only the image to be sent, got %v", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPServer{ID: "abc-123", Image: "img:v2", Revision: 2})
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "test-token")
+	server, err := c.UpdateServer(context.Background(), "abc-123", "img:v2", nil)
+	if err != nil {
+		t.Fatalf("UpdateServer failed: %v", err)
+	}
+	if server.Revision != 2 {
+		t.Errorf("expected revision 2, got %d", server.Revision)
+	}
+}
+
+func TestRollbackServer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/nodes/abc-123/rollback" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]int
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["revision"] != 1 {
+			t.Errorf("expected revision 1, got %v", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPServer{ID: "abc-123", Revision: 3})
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "test-token")
+	server, err := c.RollbackServer(context.Background(), "abc-123", 1)
+	if err != nil {
+		t.Fatalf("RollbackServer failed: %v", err)
+	}
+	if server.Revision != 3 {
+		t.Errorf("expected revision 3, got %d", server.Revision)
+	}
+}
+
 func TestStartServer(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/api/v1/nodes/abc-123/start" {
@@ -128,6 +178,66 @@ func TestRemoveServer(t *testing.T) {
 	}
 }
 
+func TestPutAccessPolicy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/api/v1/nodes/abc-123/policies/default" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var p AccessPolicy
+		json.NewDecoder(r.Body).Decode(&p)
+		if len(p.Tools) != 1 || p.Resources != nil {
+			t.Errorf("unexpected policy body: %+v", p)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "test-token")
+	policy, err := c.PutAccessPolicy(context.Background(), "abc-123", "default", &AccessPolicy{Tools: []string{"search"}})
+	if err != nil {
+		t.Fatalf("PutAccessPolicy failed: %v", err)
+	}
+	if len(policy.Tools) != 1 || policy.Tools[0] != "search" {
+		t.Errorf("unexpected policy: %+v", policy)
+	}
+}
+
+func TestListOAuthGrants(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes/oauth/grants" || r.URL.Query().Get("server_id") != "abc-123" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]OAuthGrant{{ID: "g1", ServerID: "abc-123", Provider: "github"}})
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "test-token")
+	grants, err := c.ListOAuthGrants(context.Background(), "abc-123")
+	if err != nil {
+		t.Fatalf("ListOAuthGrants failed: %v", err)
+	}
+	if len(grants) != 1 || grants[0].Provider != "github" {
+		t.Errorf("unexpected grants: %+v", grants)
+	}
+}
+
+func TestRevokeOAuthGrant(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/api/v1/nodes/oauth/grants/g1" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "test-token")
+	if err := c.RevokeOAuthGrant(context.Background(), "g1"); err != nil {
+		t.Fatalf("RevokeOAuthGrant failed: %v", err)
+	}
+}
+
 func TestErrorResponse(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
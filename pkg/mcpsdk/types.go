@@ -13,10 +13,46 @@ type MCPServer struct {
 	Image     string         `json:"image"`
 	Status    string         `json:"status"`
 	Config    map[string]any `json:"config,omitempty"`
+	Revision  int            `json:"revision"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 }
 
+// ServerRevision is a stored version of a server's image and config.
+type ServerRevision struct {
+	ServerID   string         `json:"server_id"`
+	Revision   int            `json:"revision"`
+	Image      string         `json:"image"`
+	Config     map[string]any `json:"config,omitempty"`
+	RollbackOf *int           `json:"rollback_of,omitempty"`
+	CreatedBy  string         `json:"created_by,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// AccessPolicy restricts the tools, resources and prompts a principal may see
+// on a server. A nil list leaves that kind unrestricted.
+type AccessPolicy struct {
+	ID          string    `json:"id,omitempty"`
+	ServerID    string    `json:"server_id,omitempty"`
+	PrincipalID string    `json:"principal_id,omitempty"`
+	Tools       []string  `json:"tools"`
+	Resources   []string  `json:"resources"`
+	Prompts     []string  `json:"prompts"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// OAuthGrant is an OAuth authorization the user gave a server. Its tokens
+// never leave the gateway.
+type OAuthGrant struct {
+	ID        string     `json:"id"`
+	ServerID  string     `json:"server_id"`
+	OwnerID   string     `json:"owner_id"`
+	Provider  string     `json:"provider"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
 // RPCRequest is a JSON-RPC 2.0 request.
 type RPCRequest struct {
 	JSONRPC string `json:"jsonrpc"`
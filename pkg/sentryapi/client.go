@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -131,3 +132,44 @@ func (c *Client) UpdateBudget(ctx context.Context, budget *BudgetCap) (*BudgetCa
 	}
 	return &updated, nil
 }
+
+// ListApprovals returns approvals, optionally filtered by status.
+func (c *Client) ListApprovals(ctx context.Context, status string) ([]Approval, error) {
+	path := "/api/v1/sentry/approvals"
+	if status != "" {
+		path += "?status=" + url.QueryEscape(status)
+	}
+	var approvals []Approval
+	if err := c.doRequest(ctx, http.MethodGet, path, nil, &approvals); err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// DecideApproval approves or denies a pending approval by ID.
+func (c *Client) DecideApproval(ctx context.Context, id string, approve bool) (*Approval, error) {
+	decision := "deny"
+	if approve {
+		decision = "approve"
+	}
+	var approval Approval
+	body := map[string]string{"decision": decision}
+	if err := c.doRequest(ctx, http.MethodPost, "/api/v1/sentry/approvals/"+id, body, &approval); err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// ListAlerts returns the current user's alerts.
+func (c *Client) ListAlerts(ctx context.Context) ([]Alert, error) {
+	var alerts []Alert
+	if err := c.doRequest(ctx, http.MethodGet, "/api/v1/sentry/alerts", nil, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// ReleaseServer releases a quarantined MCP server. The caller must be a Sentry admin.
+func (c *Client) ReleaseServer(ctx context.Context, serverID string) error {
+	return c.doRequest(ctx, http.MethodPost, "/api/v1/sentry/quarantine/"+serverID+"/release", nil, nil)
+}
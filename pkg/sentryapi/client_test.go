@@ -258,3 +258,71 @@ func TestNewClientTrimsMultipleTrailingSlashes(t *testing.T) {
 		t.Errorf("expected trailing slashes trimmed, got %s", c.baseURL)
 	}
 }
+
+func TestDecideApproval(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/sentry/approvals/ap-1" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["decision"] != "approve" {
+			t.Errorf("expected decision approve, got %q", body["decision"])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Approval{ID: "ap-1", Status: "approved"})
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "test-token")
+	approval, err := c.DecideApproval(context.Background(), "ap-1", true)
+	if err != nil {
+		t.Fatalf("DecideApproval failed: %v", err)
+	}
+	if approval.Status != "approved" {
+		t.Errorf("expected approved, got %s", approval.Status)
+	}
+}
+
+func TestListApprovalsFiltersStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("status") != "pending" {
+			t.Errorf("expected status=pending, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]Approval{{ID: "ap-1", Status: "pending"}})
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "test-token")
+	approvals, err := c.ListApprovals(context.Background(), "pending")
+	if err != nil {
+		t.Fatalf("ListApprovals failed: %v", err)
+	}
+	if len(approvals) != 1 {
+		t.Fatalf("expected 1 approval, got %d", len(approvals))
+	}
+}
+
+func TestListAlerts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/sentry/alerts" {
+			t.Errorf("expected /api/v1/sentry/alerts, got %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]Alert{{ID: "al-1", Kind: "anomaly", Severity: "high"}})
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "test-token")
+	alerts, err := c.ListAlerts(context.Background())
+	if err != nil {
+		t.Fatalf("ListAlerts failed: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Severity != "high" {
+		t.Fatalf("unexpected alerts: %+v", alerts)
+	}
+}
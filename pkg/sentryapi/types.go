@@ -2,6 +2,7 @@
 package sentryapi
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -56,6 +57,7 @@ type Rule struct {
 	Description string    `json:"description,omitempty"`
 	Pattern     string    `json:"pattern"`
 	Action      string    `json:"action"`
+	Severity    string    `json:"severity,omitempty"`
 	Enabled     bool      `json:"enabled"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -71,3 +73,32 @@ type BudgetCap struct {
 	ResetAt    time.Time `json:"reset_at"`
 	CreatedAt  time.Time `json:"created_at"`
 }
+
+// Approval represents a held MCP request returned by the Sentry API.
+type Approval struct {
+	ID        string          `json:"id"`
+	RuleID    string          `json:"rule_id,omitempty"`
+	UserID    string          `json:"user_id"`
+	ServerID  string          `json:"server_id"`
+	Method    string          `json:"method"`
+	Target    string          `json:"target,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Status    string          `json:"status"` // "pending", "approved", "denied", "expired"
+	DecidedBy string          `json:"decided_by,omitempty"`
+	DecidedAt *time.Time      `json:"decided_at,omitempty"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Alert represents a rule or anomaly alert returned by the Sentry API.
+type Alert struct {
+	ID        string         `json:"id"`
+	RuleID    string         `json:"rule_id,omitempty"`
+	UserID    string         `json:"user_id"`
+	ServerID  string         `json:"server_id,omitempty"`
+	Kind      string         `json:"kind"` // "rule", "anomaly"
+	Message   string         `json:"message"`
+	Severity  string         `json:"severity"` // "low", "medium", "high", "critical"
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
@@ -3,7 +3,7 @@
 import { useCallback, useEffect, useState } from "react";
 import { useRouter } from "next/navigation";
 import { Plus, Server } from "lucide-react";
-import type { MCPServer } from "@/lib/types";
+import type { MCPServer, ServerSpec } from "@/lib/types";
 import { useAuth } from "@/lib/auth-context";
 import { toast } from "sonner";
 import {
@@ -40,21 +40,21 @@ const SERVER_TEMPLATES = [
     id: "sqlite",
     name: "SQLite Integration",
     image: "mcp/sqlite:latest",
-    config: '{\n  "db_path": "/data/mcp.db"\n}',
+    config: '{\n  "env": {\n    "DB_PATH": "/data/mcp.db"\n  }\n}',
     description: "Read and write to a local SQLite database",
   },
   {
     id: "github",
     name: "GitHub Plugin",
     image: "mcp/github:latest",
-    config: '{\n  "github_token": "YOUR_TOKEN_HERE"\n}',
+    config: '{\n  "secrets": {\n    "GITHUB_TOKEN": { "vault": "github", "field": "access_token" }\n  },\n  "egress": {\n    "allow": ["api.github.com"]\n  }\n}',
     description: "Interact with GitHub APIs",
   },
   {
     id: "filesystem",
     name: "Local Filesystem",
     image: "mcp/filesystem:latest",
-    config: '{\n  "allowed_dirs": ["/data"]\n}',
+    config: '{\n  "env": {\n    "ALLOWED_DIRS": "/data"\n  }\n}',
     description: "Read and write files in allowed directories",
   },
 ];
@@ -116,7 +116,7 @@ export default function ServersPage() {
     e.preventDefault();
     setFormLoading(true);
 
-    let config: Record<string, unknown> = {};
+    let config: ServerSpec = {};
     if (formConfig.trim()) {
       try {
         config = JSON.parse(formConfig);
@@ -137,7 +137,13 @@ export default function ServersPage() {
       toast.success("Server registered successfully!");
       await fetchServers();
     } catch (err) {
-      if (err instanceof ApiRequestError) {
+      if (err instanceof ApiRequestError && err.body.fields?.length) {
+        toast.error(err.message, {
+          description: err.body.fields
+            .map((f) => `${f.field} ${f.message}`)
+            .join("\n"),
+        });
+      } else if (err instanceof ApiRequestError) {
         toast.error(err.message);
       } else {
         toast.error("Failed to register server.");
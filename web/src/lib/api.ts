@@ -5,6 +5,7 @@ import type {
   Credential,
   MCPServer,
   Rule,
+  ServerSpec,
   Session,
   VaultEntry,
 } from "./types";
@@ -143,7 +144,7 @@ export async function listServers(): Promise<MCPServer[]> {
 export async function createServer(
   name: string,
   image: string,
-  config?: Record<string, unknown>,
+  config?: ServerSpec,
 ): Promise<MCPServer> {
   return apiFetch("/api/v1/nodes/", {
     method: "POST",
@@ -29,13 +29,43 @@ export interface Credential {
 
 export type ServerStatus = "stopped" | "starting" | "running" | "stopping" | "error";
 
+export type SecretRef =
+  | { vault: string; field: "access_token" | "secret"; file?: string }
+  | { oauth: string; file?: string };
+
+export interface ServerSpec {
+  version?: number;
+  transport?: "websocket" | "stdio";
+  port?: number;
+  pooled?: boolean;
+  env?: Record<string, string>;
+  secrets?: Record<string, SecretRef>;
+  limits?: {
+    memory?: number | string;
+    cpus?: number;
+    pids?: number;
+    disk?: number | string;
+  };
+  healthcheck?: {
+    disabled?: boolean;
+    interval?: string;
+    timeout?: string;
+    retries?: number;
+  };
+  restart?: "never" | "on-failure" | "always";
+  idle_timeout?: string;
+  security?: Record<string, unknown>;
+  egress?: { allow?: string[] };
+}
+
 export interface MCPServer {
   id: string;
   owner_id: string;
   name: string;
   image: string;
   status: ServerStatus;
-  config: Record<string, unknown>;
+  config: ServerSpec;
+  revision: number;
   container_id?: string;
   tools?: any[];
   resources?: any[];
@@ -77,6 +107,12 @@ export interface BudgetCap {
   created_at: string;
 }
 
+export interface FieldError {
+  field: string;
+  message: string;
+}
+
 export interface ApiError {
   error: string;
+  fields?: FieldError[];
 }